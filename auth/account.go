@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-dawn/dawn/fiberx"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ExportContributor adds data which is kept by other modules
+// to the data export of a user
+type ExportContributor interface {
+	// Export gets data of the user, it must be able to
+	// be marshaled to json
+	Export(ctx context.Context, u User) (interface{}, error)
+}
+
+// ExportFunc is an adapter to allow the use of ordinary
+// functions as export contributors
+type ExportFunc func(ctx context.Context, u User) (interface{}, error)
+
+// Export calls f(ctx, u)
+func (f ExportFunc) Export(ctx context.Context, u User) (interface{}, error) {
+	return f(ctx, u)
+}
+
+// exportReserved are sections of the archive filled by auth module
+var exportReserved = map[string]bool{
+	"user":         true,
+	"identities":   true,
+	"credentials":  true,
+	"audit_events": true,
+}
+
+var (
+	contributorsMu sync.RWMutex
+	contributors   = map[string]ExportContributor{}
+)
+
+// RegisterExportContributor adds data of the contributor to data
+// exports under the name, the contributor replaces any previous
+// one with the same name. Tokens are stateless, so apps which keep
+// sessions should register a "sessions" contributor. It panics if
+// name is empty or reserved, or contributor is nil
+func RegisterExportContributor(name string, contributor ExportContributor) {
+	if name == "" {
+		panic("auth: export contributor name is empty")
+	}
+
+	if exportReserved[name] {
+		panic("auth: export contributor name " + name + " is reserved")
+	}
+
+	if contributor == nil {
+		panic("auth: export contributor " + name + " is nil")
+	}
+
+	contributorsMu.Lock()
+	defer contributorsMu.Unlock()
+
+	contributors[name] = contributor
+}
+
+// exportContributors gets a copy of registered contributors
+func exportContributors() map[string]ExportContributor {
+	contributorsMu.RLock()
+	defer contributorsMu.RUnlock()
+
+	cs := make(map[string]ExportContributor, len(contributors))
+	for name, c := range contributors {
+		cs[name] = c
+	}
+
+	return cs
+}
+
+// exportMe responses all data of current user as a json file
+func (m module) exportMe(c *fiber.Ctx) (err error) {
+	var (
+		id      = UserID(c)
+		u       User
+		archive fiber.Map
+	)
+
+	// Data of users can't be read by impersonators
+	if RealUserID(c) != id {
+		return errResp(c, ErrPermissionDenied)
+	}
+
+	s := m.service(c)
+
+	if u, err = s.UserCtx(UserContext(c), id); err != nil {
+		return errResp(c, err)
+	}
+
+	if archive, err = exportArchive(UserContext(c), s, u); err != nil {
+		return
+	}
+
+	c.Attachment("user-" + strconv.Itoa(id) + ".json")
+
+	return c.JSON(archive)
+}
+
+// exportArchive collects data of the user, the export fails
+// if any contributor fails
+func exportArchive(ctx context.Context, s ContextAccountService, u User) (archive fiber.Map, err error) {
+	archive = fiber.Map{"user": u}
+
+	if archive["identities"], err = s.IdentitiesCtx(ctx, u.ID); err != nil {
+		return
+	}
+
+	if archive["credentials"], err = s.CredentialsCtx(ctx, u.ID); err != nil {
+		return
+	}
+
+	if archive["audit_events"], err = s.AuditEventsCtx(ctx, u.ID); err != nil {
+		return
+	}
+
+	for name, c := range exportContributors() {
+		if archive[name], err = c.Export(ctx, u); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// deleteMe soft deletes current user and revokes all tokens, it
+// must be used after RequireRecentAuth. Personal data is scrubbed
+// by the purge job after DeletionGracePeriod
+func (m module) deleteMe(c *fiber.Ctx) (err error) {
+	id := UserID(c)
+
+	if RealUserID(c) != id {
+		return errResp(c, ErrPermissionDenied)
+	}
+
+	// Revoke first so that a failed deletion only logs the user out
+	if err = m.revokeTokens(UserContext(c), Tenant(c), id); err != nil {
+		return
+	}
+
+	if err = m.service(c).DeleteUserCtx(UserContext(c), id); err != nil {
+		return errResp(c, err)
+	}
+
+	return fiberx.Message(c, "Account deleted")
+}
+
+// purge scrubs personal data of deleted users periodically
+// until done is closed
+func (m module) purge(done <-chan struct{}) {
+	ticker := time.NewTicker(m.PurgeInterval)
+	defer ticker.Stop()
+
+	s := contextService(m.Service)
+
+	for {
+		select {
+		case <-done:
+			return
+		case t := <-ticker.C:
+			_, _ = s.PurgeDeletedUsersCtx(context.Background(), t.Add(-m.DeletionGracePeriod))
+		}
+	}
+}
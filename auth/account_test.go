@@ -0,0 +1,337 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-dawn/module/cache"
+	"github.com/go-dawn/pkg/deck"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func Test_Auth_RegisterExportContributor(t *testing.T) {
+	at := assert.New(t)
+
+	t.Run("panics", func(t *testing.T) {
+		at.Panics(func() {
+			RegisterExportContributor("", ExportFunc(nil))
+		})
+		at.Panics(func() {
+			RegisterExportContributor("user", ExportFunc(func(ctx context.Context, u User) (interface{}, error) {
+				return nil, nil
+			}))
+		})
+		at.Panics(func() {
+			RegisterExportContributor("nil", nil)
+		})
+	})
+
+	t.Run("success", func(t *testing.T) {
+		RegisterExportContributor("test_orders", ExportFunc(func(ctx context.Context, u User) (interface{}, error) {
+			return []int{u.ID}, nil
+		}))
+		defer unregisterExportContributor("test_orders")
+
+		c, ok := exportContributors()["test_orders"]
+		at.True(ok)
+
+		data, err := c.Export(context.Background(), User{ID: 1})
+		at.Nil(err)
+		at.Equal([]int{1}, data)
+	})
+}
+
+func Test_Auth_ExportMe(t *testing.T) {
+	at := assert.New(t)
+
+	m, mockService := routeModule()
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		app.Use(m.jwt())
+		app.Get("/me/export", m.exportMe)
+	})
+
+	token, err := generateToken("", m.SigningKey, 1, time.Hour)
+	at.Nil(err)
+
+	t.Run("success", func(t *testing.T) {
+		RegisterExportContributor("test_sessions", ExportFunc(func(ctx context.Context, u User) (interface{}, error) {
+			return []string{"session"}, nil
+		}))
+		defer unregisterExportContributor("test_sessions")
+
+		mockService.On("User", 1).
+			Once().Return(User{ID: 1, Username: "kiyon"}, nil).
+			On("Identities", 1).
+			Once().Return([]Identity{{Provider: "ldap", Subject: "uid=kiyon"}}, nil).
+			On("Credentials", 1).
+			Once().Return([]Credential{{ID: "credential", UserID: 1}}, nil).
+			On("AuditEvents", 1).
+			Once().Return([]AuditEvent{{ID: 1, Action: "impersonate", ActorID: 2, UserID: 1}}, nil)
+
+		resp := e.GET("/me/export").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+token).
+			Expect().
+			Status(fiber.StatusOK)
+
+		resp.Header(fiber.HeaderContentDisposition).Contains("user-1.json")
+
+		obj := resp.JSON().Object()
+		obj.Value("user").Object().Value("username").Equal("kiyon")
+		obj.Value("identities").Array().Length().Equal(1)
+		obj.Value("identities").Path("$[0].subject").Equal("uid=kiyon")
+		obj.Value("credentials").Array().Length().Equal(1)
+		obj.Value("credentials").Path("$[0].id").Equal("credential")
+		obj.Value("audit_events").Array().Length().Equal(1)
+		obj.Value("test_sessions").Array().Elements("session")
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		mockService.On("User", 1).
+			Once().Return(User{}, ErrUserNotFound)
+
+		e.GET("/me/export").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+token).
+			Expect().
+			Status(fiber.StatusNotFound)
+	})
+
+	t.Run("contributor error", func(t *testing.T) {
+		RegisterExportContributor("test_error", ExportFunc(func(ctx context.Context, u User) (interface{}, error) {
+			return nil, errors.New("fake error")
+		}))
+		defer unregisterExportContributor("test_error")
+
+		mockService.On("User", 1).
+			Once().Return(User{ID: 1}, nil).
+			On("Identities", 1).
+			Once().Return([]Identity(nil), nil).
+			On("Credentials", 1).
+			Once().Return([]Credential(nil), nil).
+			On("AuditEvents", 1).
+			Once().Return([]AuditEvent(nil), nil)
+
+		e.GET("/me/export").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+token).
+			Expect().
+			Status(fiber.StatusInternalServerError)
+	})
+
+	t.Run("impersonator", func(t *testing.T) {
+		token, err := generateToken("", m.SigningKey, 1, time.Hour, actorClaims(2))
+		at.Nil(err)
+
+		resp := e.GET("/me/export").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+token).
+			Expect().
+			Status(fiber.StatusForbidden)
+
+		deck.AssertRespCode(resp, CodePermissionDenied)
+	})
+
+	at.True(mockService.AssertExpectations(t))
+}
+
+func Test_Auth_DeleteMe(t *testing.T) {
+	at := assert.New(t)
+
+	m, mockService := routeModule()
+
+	cache.New().Init()
+	m.Storage = cache.Storage()
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		app.Use(m.jwt())
+		app.Delete("/me", RequireRecentAuth(time.Minute), m.deleteMe)
+	})
+
+	id := 38
+
+	token, err := generateToken("", m.SigningKey, id, time.Hour)
+	at.Nil(err)
+	bearer := "Bearer " + token
+
+	t.Run("login is not recent", func(t *testing.T) {
+		token := staleToken(t, m.SigningKey, id)
+
+		resp := e.DELETE("/me").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+token).
+			Expect().
+			Status(fiber.StatusUnauthorized)
+
+		deck.AssertRespCode(resp, CodeReauthRequired)
+	})
+
+	t.Run("impersonator", func(t *testing.T) {
+		token, err := generateToken("", m.SigningKey, id, time.Hour, actorClaims(1))
+		at.Nil(err)
+
+		e.DELETE("/me").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+token).
+			Expect().
+			Status(fiber.StatusForbidden)
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		token, err := generateToken("", m.SigningKey, id+1, time.Hour)
+		at.Nil(err)
+
+		mockService.On("DeleteUser", id+1).
+			Once().Return(ErrUserNotFound)
+
+		e.DELETE("/me").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+token).
+			Expect().
+			Status(fiber.StatusNotFound)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		mockService.On("DeleteUser", id).
+			Once().Return(nil)
+
+		resp := e.DELETE("/me").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusOK)
+
+		deck.AssertRespMsg(resp, "Account deleted")
+	})
+
+	t.Run("tokens revoked", func(t *testing.T) {
+		resp := e.DELETE("/me").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusUnauthorized)
+
+		deck.AssertRespCode(resp, CodeTokenRevoked)
+	})
+
+	at.True(mockService.AssertExpectations(t))
+}
+
+func Test_Auth_CheckRevoked(t *testing.T) {
+	at := assert.New(t)
+
+	m, _ := routeModule()
+	m.Expiration = time.Hour
+
+	cache.New().Init()
+	m.Storage = cache.Storage()
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		app.Use(m.jwt())
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendString("ok")
+		})
+	})
+
+	id := 39
+
+	t.Run("revoke", func(t *testing.T) {
+		at.Nil(m.revokeTokens(context.Background(), "", id))
+
+		b, err := m.Storage.Get(revokedKey("", id))
+		at.Nil(err)
+		at.NotEmpty(b)
+	})
+
+	t.Run("issued before revocation", func(t *testing.T) {
+		later := strconv.FormatInt(time.Now().Add(time.Minute).UnixNano()/int64(time.Millisecond), 10)
+		at.Nil(m.Storage.Set(revokedKey("", id), []byte(later), time.Minute))
+
+		token, err := generateToken("", m.SigningKey, id, time.Hour)
+		at.Nil(err)
+
+		e.GET("/").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+token).
+			Expect().
+			Status(fiber.StatusUnauthorized)
+	})
+
+	t.Run("issued after revocation", func(t *testing.T) {
+		at.Nil(m.Storage.Set(revokedKey("", id), []byte("1"), time.Minute))
+
+		token, err := generateToken("", m.SigningKey, id, time.Hour)
+		at.Nil(err)
+
+		e.GET("/").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+token).
+			Expect().
+			Status(fiber.StatusOK)
+	})
+
+	t.Run("issued in the next millisecond", func(t *testing.T) {
+		at.Nil(m.revokeTokens(context.Background(), "", id))
+		time.Sleep(time.Millisecond * 2)
+
+		token, err := generateToken("", m.SigningKey, id, time.Hour)
+		at.Nil(err)
+
+		e.GET("/").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+token).
+			Expect().
+			Status(fiber.StatusOK)
+	})
+
+	t.Run("other users", func(t *testing.T) {
+		token, err := generateToken("", m.SigningKey, id+1, time.Hour)
+		at.Nil(err)
+
+		e.GET("/").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+token).
+			Expect().
+			Status(fiber.StatusOK)
+	})
+
+	t.Run("invalid mark", func(t *testing.T) {
+		at.Nil(m.Storage.Set(revokedKey("", id), []byte("x"), time.Minute))
+
+		token, err := generateToken("", m.SigningKey, id, time.Hour)
+		at.Nil(err)
+
+		e.GET("/").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+token).
+			Expect().
+			Status(fiber.StatusInternalServerError)
+	})
+}
+
+func Test_Auth_Purge(t *testing.T) {
+	at := assert.New(t)
+
+	m, mockService := routeModule()
+	m.PurgeInterval = time.Millisecond * 10
+	m.DeletionGracePeriod = time.Hour
+
+	purged := make(chan time.Time, 1)
+	mockService.On("PurgeDeletedUsers", mock.AnythingOfType("time.Time")).
+		Run(func(args mock.Arguments) {
+			select {
+			case purged <- args.Get(0).(time.Time):
+			default:
+			}
+		}).Return(0, nil)
+
+	done := make(chan struct{})
+	go m.purge(done)
+	defer close(done)
+
+	select {
+	case before := <-purged:
+		at.WithinDuration(time.Now().Add(-time.Hour), before, time.Second)
+	case <-time.After(time.Second):
+		at.Fail("purge is not called")
+	}
+}
+
+func unregisterExportContributor(name string) {
+	contributorsMu.Lock()
+	defer contributorsMu.Unlock()
+
+	delete(contributors, name)
+}
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/go-dawn/dawn/config"
+	"github.com/go-dawn/dawn/fiberx"
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	// ErrPermissionDenied occurs when user has no permission
+	// to access the resource
+	ErrPermissionDenied = errors.New("auth: permission denied")
+
+	// ErrInvalidUserID occurs when user id in path is invalid
+	ErrInvalidUserID = errors.New("auth: invalid user id")
+)
+
+var maxPageSize = 100
+
+// admin only allows users with admin role, it must be used
+// after jwt middleware
+func (m module) admin() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if role, _ := claims(c)["role"].(string); role == "" || role != m.AdminRole {
+			return errResp(c, ErrPermissionDenied)
+		}
+
+		return c.Next()
+	}
+}
+
+func (m module) adminUsers(c *fiber.Ctx) error {
+	q := UserQuery{
+		Search:   c.Query("search"),
+		Page:     c.Context().QueryArgs().GetUintOrZero("page"),
+		PageSize: c.Context().QueryArgs().GetUintOrZero("pageSize"),
+	}
+
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+
+	if q.PageSize <= 0 {
+		q.PageSize = config.GetInt("http.pageSize", 15)
+	}
+
+	if q.PageSize > maxPageSize {
+		q.PageSize = maxPageSize
+	}
+
+	users, total, err := m.service(c).UsersCtx(UserContext(c), q)
+	if err != nil {
+		return err
+	}
+
+	// Keep the same shape with gormx.Pagination
+	return fiberx.Data(c, fiber.Map{
+		"page":      q.Page,
+		"page_size": q.PageSize,
+		"total":     total,
+		"data":      users,
+	})
+}
+
+func (m module) adminUser(c *fiber.Ctx) (err error) {
+	var (
+		id int
+		u  User
+	)
+
+	if id, err = userIDParam(c); err != nil {
+		return
+	}
+
+	if u, err = m.service(c).UserCtx(UserContext(c), id); err != nil {
+		return errResp(c, err)
+	}
+
+	return fiberx.Data(c, u)
+}
+
+func (m module) adminDisableUser(c *fiber.Ctx) error {
+	return m.adminAction(c, m.revoking(c, ContextAccountService.DisableUserCtx), "User disabled")
+}
+
+func (m module) adminEnableUser(c *fiber.Ctx) error {
+	return m.adminAction(c, ContextAccountService.EnableUserCtx, "User enabled")
+}
+
+func (m module) adminResetPassword(c *fiber.Ctx) error {
+	return m.adminAction(c, m.revoking(c, ContextAccountService.ResetPasswordCtx), "Password reset")
+}
+
+func (m module) adminDeleteUser(c *fiber.Ctx) error {
+	return m.adminAction(c, m.revoking(c, ContextAccountService.DeleteUserCtx), "User deleted")
+}
+
+func (m module) adminRevokeTokens(c *fiber.Ctx) error {
+	return m.adminAction(c, func(s ContextAccountService, ctx context.Context, id int) error {
+		// Make sure the user exists in the tenant
+		if _, err := s.UserCtx(ctx, id); err != nil {
+			return err
+		}
+		return m.revokeTokens(ctx, Tenant(c), id)
+	}, "Tokens revoked")
+}
+
+// revoking revokes all tokens of the user after the action succeeds
+func (m module) revoking(c *fiber.Ctx, action func(ContextAccountService, context.Context, int) error) func(ContextAccountService, context.Context, int) error {
+	return func(s ContextAccountService, ctx context.Context, id int) error {
+		if err := action(s, ctx, id); err != nil {
+			return err
+		}
+		return m.revokeTokens(ctx, Tenant(c), id)
+	}
+}
+
+// adminAction applies the action on the user in path
+func (m module) adminAction(c *fiber.Ctx, action func(ContextAccountService, context.Context, int) error, msg string) (err error) {
+	var id int
+
+	if id, err = userIDParam(c); err != nil {
+		return
+	}
+
+	if err = action(m.service(c), UserContext(c), id); err != nil {
+		return errResp(c, err)
+	}
+
+	return fiberx.Message(c, msg)
+}
+
+func userIDParam(c *fiber.Ctx) (int, error) {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		return 0, fiberx.CodeErr(fiber.StatusBadRequest, ErrInvalidUserID, "Invalid user id")
+	}
+	return id, nil
+}
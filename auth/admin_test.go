@@ -0,0 +1,237 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/gavv/httpexpect/v2"
+	"github.com/go-dawn/module/cache"
+	"github.com/go-dawn/pkg/deck"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Auth_Admin(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	m, mockService := routeModule()
+	m.AdminRole = "admin"
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		admin := app.Group("/admin", m.jwt(), m.admin())
+		admin.Get("/users", m.adminUsers)
+		admin.Get("/users/:id", m.adminUser)
+		admin.Put("/users/:id/disable", m.adminDisableUser)
+		admin.Put("/users/:id/enable", m.adminEnableUser)
+		admin.Post("/users/:id/password-reset", m.adminResetPassword)
+		admin.Delete("/users/:id", m.adminDeleteUser)
+	})
+
+	token, err := generateToken("", m.SigningKey, 1, time.Hour, jwt.MapClaims{"role": "admin"})
+	at.Nil(err)
+	bearer := "Bearer " + token
+
+	t.Run("permission denied", func(t *testing.T) {
+		token, err := generateToken("", m.SigningKey, 1, time.Hour)
+		at.Nil(err)
+
+		resp := e.GET("/admin/users").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+token).
+			Expect().
+			Status(fiber.StatusForbidden)
+
+		deck.AssertRespMsg(resp, "Permission denied")
+	})
+
+	t.Run("users", func(t *testing.T) {
+		mockService.On("Users", UserQuery{Search: "kiyon", Page: 2, PageSize: maxPageSize}).
+			Once().Return([]User{{ID: 1, Username: "kiyon"}}, 101, nil)
+
+		resp := e.GET("/admin/users").
+			WithQuery("search", "kiyon").
+			WithQuery("page", 2).
+			WithQuery("pageSize", 1000).
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusOK)
+
+		deck.AssertRespDataCheck(resp, func(v *httpexpect.Value) {
+			obj := v.Object()
+			obj.Value("page").Equal(2)
+			obj.Value("page_size").Equal(maxPageSize)
+			obj.Value("total").Equal(101)
+			obj.Value("data").Array().Length().Equal(1)
+		})
+	})
+
+	t.Run("users with default pagination", func(t *testing.T) {
+		mockService.On("Users", UserQuery{Page: 1, PageSize: 15}).
+			Once().Return([]User{}, 0, nil)
+
+		e.GET("/admin/users").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusOK)
+	})
+
+	t.Run("failed to get users", func(t *testing.T) {
+		mockService.On("Users", UserQuery{Page: 1, PageSize: 15}).
+			Once().Return(nil, 0, errors.New("fake error"))
+
+		e.GET("/admin/users").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusInternalServerError)
+	})
+
+	t.Run("user", func(t *testing.T) {
+		mockService.On("User", 1).
+			Once().Return(User{ID: 1, Username: "kiyon"}, nil)
+
+		resp := e.GET("/admin/users/1").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusOK)
+
+		deck.AssertRespDataCheck(resp, func(v *httpexpect.Value) {
+			v.Object().Value("username").Equal("kiyon")
+		})
+	})
+
+	t.Run("invalid user id", func(t *testing.T) {
+		resp := e.GET("/admin/users/x").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusBadRequest)
+
+		deck.AssertRespMsg(resp, "Invalid user id")
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		mockService.On("User", 2).
+			Once().Return(User{}, ErrUserNotFound)
+
+		resp := e.GET("/admin/users/2").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusNotFound)
+
+		deck.AssertRespMsg(resp, "User not found")
+	})
+
+	tests := []struct {
+		method  string
+		path    string
+		action  string
+		message string
+	}{
+		{fiber.MethodPut, "/admin/users/1/disable", "DisableUser", "User disabled"},
+		{fiber.MethodPut, "/admin/users/1/enable", "EnableUser", "User enabled"},
+		{fiber.MethodPost, "/admin/users/1/password-reset", "ResetPassword", "Password reset"},
+		{fiber.MethodDelete, "/admin/users/1", "DeleteUser", "User deleted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			mockService.On(tt.action, 1).
+				Once().Return(nil)
+
+			resp := e.Request(tt.method, tt.path).
+				WithHeader(fiber.HeaderAuthorization, bearer).
+				Expect().
+				Status(fiber.StatusOK)
+
+			deck.AssertRespMsg(resp, tt.message)
+
+			mockService.On(tt.action, 1).
+				Once().Return(ErrUserNotFound)
+
+			e.Request(tt.method, tt.path).
+				WithHeader(fiber.HeaderAuthorization, bearer).
+				Expect().
+				Status(fiber.StatusNotFound)
+		})
+	}
+}
+
+func Test_Auth_Admin_RevokeTokens(t *testing.T) {
+	at := assert.New(t)
+
+	m, mockService := routeModule()
+	m.AdminRole = "admin"
+
+	cache.New().Init()
+	m.Storage = cache.Storage()
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		app.Use(m.jwt())
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendString("ok")
+		})
+
+		admin := app.Group("/admin", m.admin())
+		admin.Put("/users/:id/disable", m.adminDisableUser)
+		admin.Post("/users/:id/password-reset", m.adminResetPassword)
+		admin.Delete("/users/:id", m.adminDeleteUser)
+		admin.Delete("/users/:id/tokens", m.adminRevokeTokens)
+	})
+
+	token, err := generateToken("", m.SigningKey, 1, time.Hour, jwt.MapClaims{"role": "admin"})
+	at.Nil(err)
+	bearer := "Bearer " + token
+
+	tests := []struct {
+		method string
+		path   string
+		action string
+		id     int
+	}{
+		{fiber.MethodPut, "/admin/users/40/disable", "DisableUser", 40},
+		{fiber.MethodDelete, "/admin/users/41", "DeleteUser", 41},
+		{fiber.MethodDelete, "/admin/users/42/tokens", "User", 42},
+		{fiber.MethodPost, "/admin/users/43/password-reset", "ResetPassword", 43},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			userToken, err := generateToken("", m.SigningKey, tt.id, time.Hour)
+			at.Nil(err)
+
+			if tt.action == "User" {
+				mockService.On(tt.action, tt.id).
+					Once().Return(User{ID: tt.id}, nil)
+			} else {
+				mockService.On(tt.action, tt.id).
+					Once().Return(nil)
+			}
+
+			e.Request(tt.method, tt.path).
+				WithHeader(fiber.HeaderAuthorization, bearer).
+				Expect().
+				Status(fiber.StatusOK)
+
+			resp := e.GET("/").
+				WithHeader(fiber.HeaderAuthorization, "Bearer "+userToken).
+				Expect().
+				Status(fiber.StatusUnauthorized)
+
+			deck.AssertRespCode(resp, CodeTokenRevoked)
+		})
+	}
+
+	t.Run("user not found", func(t *testing.T) {
+		mockService.On("User", 43).
+			Once().Return(User{}, ErrUserNotFound)
+
+		e.DELETE("/admin/users/43/tokens").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusNotFound)
+	})
+
+	at.True(mockService.AssertExpectations(t))
+}
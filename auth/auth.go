@@ -1,8 +1,12 @@
 package auth
 
 import (
+	"errors"
+
 	"github.com/go-dawn/dawn"
 	"github.com/go-dawn/dawn/db/sql"
+	"github.com/go-dawn/module/cache"
+	"github.com/go-dawn/module/confie"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -33,17 +37,107 @@ func (m module) Init() dawn.Cleanup {
 
 	// Use custom Service
 	if m.Service == nil {
-		// TODO need code validator implementation
-		m.Service = service{repository{sql.Conn()}, nil}
+		m.Service = service{
+			repo:   repository{db: sql.Conn(), hooks: m.Hooks, openRegistration: m.OpenRegistration},
+			v:      callEnvoy(""),
+			email:  callEnvoy(m.EmailEnvoy),
+			mobile: callEnvoy(m.MobileEnvoy),
+		}
 	}
 
-	return nil
+	if _, ok := m.Service.(TenantService); m.TenantLookup != "" && !ok {
+		panic("auth: service does not support multi-tenancy")
+	}
+
+	if m.Storage == nil {
+		m.Storage = cache.Storage()
+	}
+
+	m.enableStrategies()
+
+	if m.Sender == nil {
+		if e := confie.Call(m.EmailEnvoy); e != nil {
+			m.Sender = e
+		}
+	}
+
+	done := make(chan struct{})
+	go m.purge(done)
+
+	return func() {
+		close(done)
+	}
 }
 
 func (m module) RegisterRoutes(router fiber.Router) {
-	g := router.Group("/auth")
+	g := router.Group("/auth", m.tenant())
 
 	g.Post("/login", m.login)
+	g.Post("/register", m.register)
+	g.Get("/register/invitation", m.registerInvitation)
+	g.Post("/magic-link", m.sendMagicLink)
+	g.Get("/magic-link/callback", m.magicLinkCallback)
+	g.Post("/webauthn/login", m.webAuthnLoginOptions)
+	g.Post("/introspect", m.introspect)
 
 	g.Use(m.jwt())
+
+	// Sensitive operations need a recent login
+	recent := RequireRecentAuth(m.ReauthMaxAge)
+
+	g.Post("/verify/email", m.sendEmailVerification)
+	g.Post("/verify/email/confirm", m.verifyEmail)
+	g.Post("/verify/mobile", m.sendMobileVerification)
+	g.Post("/verify/mobile/confirm", m.verifyMobile)
+	g.Post("/webauthn/register", recent, m.webAuthnRegisterOptions)
+	g.Post("/webauthn/register/finish", recent, m.webAuthnRegister)
+	g.Put("/password", recent, m.changePassword)
+	g.Post("/logout", m.logout)
+	g.Get("/me/export", m.exportMe)
+	g.Post("/reauth", m.reauth)
+	g.Delete("/me", recent, m.deleteMe)
+
+	g.Post("/impersonate/:id", m.admin(), recent, m.impersonate)
+
+	admin := g.Group("/admin", m.admin())
+
+	admin.Get("/users", m.adminUsers)
+	admin.Get("/users/:id", m.adminUser)
+	admin.Put("/users/:id/disable", recent, m.adminDisableUser)
+	admin.Put("/users/:id/enable", recent, m.adminEnableUser)
+	admin.Post("/users/:id/password-reset", recent, m.adminResetPassword)
+	admin.Delete("/users/:id", recent, m.adminDeleteUser)
+	admin.Delete("/users/:id/tokens", recent, m.adminRevokeTokens)
+	admin.Post("/invitations", m.adminInvite)
+	admin.Delete("/invitations/:id", m.adminRevokeInvitation)
+	admin.Post("/invitations/:id/resend", m.adminResendInvitation)
+}
+
+type codeEnvoy interface {
+	CodeValidator
+	CodeSender
+}
+
+// callEnvoy gets a named confie envoy, nil will be
+// returned if the envoy is not available
+func callEnvoy(name string) codeEnvoy {
+	if e := confie.Call(name); e != nil {
+		return envoy{e}
+	}
+	return nil
+}
+
+// envoy adapts confie envoy to CodeValidator and CodeSender
+type envoy struct {
+	*confie.Envoy
+}
+
+func (e envoy) Validate(key, code string) error {
+	if err := e.Verify(key, code); err != nil {
+		if errors.Is(err, confie.ErrNotMatched) {
+			return ErrCodeMismatch
+		}
+		return err
+	}
+	return nil
 }
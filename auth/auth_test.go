@@ -4,6 +4,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/go-dawn/pkg/deck"
 	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/assert"
 )
@@ -37,9 +39,21 @@ func Test_Module_Init(t *testing.T) {
 			SigningKey: "xx",
 		}}
 
-		at.Nil(m.Init())
+		cleanup := m.Init()
+		at.NotNil(cleanup)
+		defer cleanup()
+
 		at.Equal("xx", m.SigningKey)
 		at.Equal(time.Hour, m.Expiration)
+		at.Equal(time.Minute*15, m.MagicLinkTTL)
+		at.Equal(time.Minute*5, m.WebAuthn.Timeout)
+		at.Equal("admin", m.AdminRole)
+		at.Equal(time.Minute*15, m.ImpersonationExpiration)
+		at.Equal(time.Hour*24*7, m.InvitationTTL)
+		at.Equal(time.Minute*5, m.ReauthMaxAge)
+		at.Equal(time.Hour*24*30, m.DeletionGracePeriod)
+		at.Equal(time.Hour, m.PurgeInterval)
+		at.Equal(DefaultHooks, m.Hooks)
 		at.NotNil(m.Service)
 	})
 }
@@ -55,6 +69,84 @@ func Test_Module_RegisterRoutes(t *testing.T) {
 
 	assertHasRouteGroup(t, app, "/auth")
 	assertHasRoute(t, app, fiber.MethodPost, "/auth/login")
+	assertHasRoute(t, app, fiber.MethodPost, "/auth/register")
+	assertHasRoute(t, app, fiber.MethodGet, "/auth/register/invitation")
+	assertHasRoute(t, app, fiber.MethodPost, "/auth/magic-link")
+	assertHasRoute(t, app, fiber.MethodGet, "/auth/magic-link/callback")
+	assertHasRoute(t, app, fiber.MethodPost, "/auth/webauthn/login")
+	assertHasRoute(t, app, fiber.MethodPost, "/auth/introspect")
+	assertHasRoute(t, app, fiber.MethodPost, "/auth/verify/email")
+	assertHasRoute(t, app, fiber.MethodPost, "/auth/verify/email/confirm")
+	assertHasRoute(t, app, fiber.MethodPost, "/auth/verify/mobile")
+	assertHasRoute(t, app, fiber.MethodPost, "/auth/verify/mobile/confirm")
+	assertHasRoute(t, app, fiber.MethodPost, "/auth/webauthn/register")
+	assertHasRoute(t, app, fiber.MethodPost, "/auth/webauthn/register/finish")
+	assertHasRoute(t, app, fiber.MethodPut, "/auth/password")
+	assertHasRoute(t, app, fiber.MethodPost, "/auth/logout")
+	assertHasRoute(t, app, fiber.MethodPost, "/auth/reauth")
+	assertHasRoute(t, app, fiber.MethodGet, "/auth/me/export")
+	assertHasRoute(t, app, fiber.MethodDelete, "/auth/me")
+	assertHasRoute(t, app, fiber.MethodPost, "/auth/impersonate/:id")
+	assertHasRoute(t, app, fiber.MethodGet, "/auth/admin/users")
+	assertHasRoute(t, app, fiber.MethodGet, "/auth/admin/users/:id")
+	assertHasRoute(t, app, fiber.MethodPut, "/auth/admin/users/:id/disable")
+	assertHasRoute(t, app, fiber.MethodPut, "/auth/admin/users/:id/enable")
+	assertHasRoute(t, app, fiber.MethodPost, "/auth/admin/users/:id/password-reset")
+	assertHasRoute(t, app, fiber.MethodDelete, "/auth/admin/users/:id")
+	assertHasRoute(t, app, fiber.MethodDelete, "/auth/admin/users/:id/tokens")
+	assertHasRoute(t, app, fiber.MethodPost, "/auth/admin/invitations")
+	assertHasRoute(t, app, fiber.MethodDelete, "/auth/admin/invitations/:id")
+	assertHasRoute(t, app, fiber.MethodPost, "/auth/admin/invitations/:id/resend")
+}
+
+func Test_Module_RegisterRoutes_RecentAuth(t *testing.T) {
+	t.Parallel()
+
+	m := module{Config: &Config{SigningKey: "xx", AdminRole: "admin", ReauthMaxAge: time.Minute}}
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		m.RegisterRoutes(app)
+	})
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"id":        1,
+		"role":      "admin",
+		"iat":       time.Now().Unix(),
+		"auth_time": time.Now().Add(-time.Hour).Unix(),
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(m.SigningKey))
+	assert.Nil(t, err)
+
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{fiber.MethodPut, "/auth/password"},
+		{fiber.MethodPost, "/auth/webauthn/register"},
+		{fiber.MethodPost, "/auth/webauthn/register/finish"},
+		{fiber.MethodDelete, "/auth/me"},
+		{fiber.MethodPost, "/auth/impersonate/2"},
+		{fiber.MethodPut, "/auth/admin/users/2/disable"},
+		{fiber.MethodPut, "/auth/admin/users/2/enable"},
+		{fiber.MethodPost, "/auth/admin/users/2/password-reset"},
+		{fiber.MethodDelete, "/auth/admin/users/2"},
+		{fiber.MethodDelete, "/auth/admin/users/2/tokens"},
+	}
+
+	for _, r := range routes {
+		t.Run(r.method+" "+r.path, func(t *testing.T) {
+			resp := e.Request(r.method, r.path).
+				WithHeader(fiber.HeaderAuthorization, "Bearer "+token).
+				Expect().
+				Status(fiber.StatusUnauthorized)
+
+			deck.AssertRespCode(resp, CodeReauthRequired)
+		})
+	}
+}
+
+func Test_Auth_CallEnvoy(t *testing.T) {
+	assert.Nil(t, callEnvoy("non-exist"))
 }
 
 func assertHasRoute(t *testing.T, app *fiber.App, method string, path string) {
@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/go-dawn/dawn/config"
+	"github.com/go-dawn/module/cache"
+	"github.com/go-dawn/module/confie"
 )
 
 // Config defines the config for auth module
@@ -21,6 +23,124 @@ type Config struct {
 
 	// Expiration is the effective duration of jwt token
 	Expiration time.Duration
+
+	// EmailEnvoy is the name of confie envoy which delivers
+	// email verification codes
+	// Optional. Default: confie default envoy
+	EmailEnvoy string
+
+	// MobileEnvoy is the name of confie envoy which delivers
+	// mobile verification codes
+	// Optional. Default: confie default envoy
+	MobileEnvoy string
+
+	// RequireVerifiedEmail blocks password login until the
+	// email address of the user is verified
+	// Optional. Default: false
+	RequireVerifiedEmail bool
+
+	// Storage keeps one-time tokens like magic links
+	// Optional. Default: cache.Storage()
+	Storage cache.Cacher
+
+	// Sender delivers magic links
+	// Optional. Default: confie envoy named by EmailEnvoy
+	Sender confie.Sender
+
+	// MagicLinkURL is the callback url of magic links, token
+	// and signature will be appended as query string
+	// Required for magic link login
+	MagicLinkURL string
+
+	// MagicLinkTTL is the effective duration of magic links
+	// Optional. Default: 15 minutes
+	MagicLinkTTL time.Duration
+
+	// WebAuthn configures passkey login
+	WebAuthn WebAuthnConfig
+
+	// LDAP configures directory login, it's read from
+	// the [auth.ldap] section
+	LDAP LDAPConfig
+
+	// TenantLookup is a string in the form of "<source>:<name>"
+	// that is used to resolve tenant of requests
+	// Optional. Default: "" which disables multi-tenancy
+	// Possible values:
+	// - "header:<name>"
+	// - "param:<name>"
+	// - "host"
+	// - "subdomain"
+	TenantLookup string
+
+	// AdminRole is the role of users who can access
+	// user administration routes
+	// Optional. Default: "admin"
+	AdminRole string
+
+	// ImpersonationExpiration is the effective duration of
+	// tokens issued by impersonation
+	// Optional. Default: 15 minutes
+	ImpersonationExpiration time.Duration
+
+	// OpenRegistration allows users to register without invitations,
+	// and users of external identities, e.g. ldap, to be created on
+	// their first login
+	// Optional. Default: false which means invitation only
+	OpenRegistration bool
+
+	// InvitationURL is the registration page of invitations,
+	// token will be appended as query string
+	// Required for sending invitations
+	InvitationURL string
+
+	// InvitationTTL is the effective duration of invitations
+	// Optional. Default: 7 days
+	InvitationTTL time.Duration
+
+	// IntrospectionClients are ids and secrets of clients, e.g.
+	// resource servers, which can call POST /auth/introspect
+	// Optional. Default: nil which rejects all clients
+	IntrospectionClients map[string]string
+
+	// ReauthMaxAge is the max age of the login for sensitive
+	// routes like account deletion, tokens with older auth_time
+	// must be refreshed by POST /auth/reauth
+	// Optional. Default: 5 minutes
+	ReauthMaxAge time.Duration
+
+	// DeletionGracePeriod is how long personal data of deleted
+	// users is kept before being scrubbed
+	// Optional. Default: 30 days
+	DeletionGracePeriod time.Duration
+
+	// PurgeInterval is the interval of the job which scrubs
+	// personal data of users deleted before the grace period
+	// Optional. Default: 1 hour
+	PurgeInterval time.Duration
+
+	// Hooks are run on lifecycle events like registered and login
+	// Optional. Default: DefaultHooks
+	Hooks *Hooks
+}
+
+// WebAuthnConfig defines the config for webauthn(passkey) login
+type WebAuthnConfig struct {
+	// RPID is the relying party id, usually the domain of the site
+	// Required for webauthn login
+	RPID string
+
+	// RPName is the display name of the relying party
+	// Optional. Default: RPID
+	RPName string
+
+	// Origins are the allowed origins of client data
+	// Required for webauthn login
+	Origins []string
+
+	// Timeout is the effective duration of challenges
+	// Optional. Default: 5 minutes
+	Timeout time.Duration
 }
 
 func (m module) setupConfig() {
@@ -43,4 +163,44 @@ func (m module) setupConfig() {
 	if m.Expiration == 0 {
 		m.Expiration = time.Hour
 	}
+
+	if m.MagicLinkTTL == 0 {
+		m.MagicLinkTTL = time.Minute * 15
+	}
+
+	if m.WebAuthn.RPName == "" {
+		m.WebAuthn.RPName = m.WebAuthn.RPID
+	}
+
+	if m.WebAuthn.Timeout == 0 {
+		m.WebAuthn.Timeout = time.Minute * 5
+	}
+
+	if m.AdminRole == "" {
+		m.AdminRole = "admin"
+	}
+
+	if m.ImpersonationExpiration == 0 {
+		m.ImpersonationExpiration = time.Minute * 15
+	}
+
+	if m.InvitationTTL == 0 {
+		m.InvitationTTL = time.Hour * 24 * 7
+	}
+
+	if m.ReauthMaxAge == 0 {
+		m.ReauthMaxAge = time.Minute * 5
+	}
+
+	if m.DeletionGracePeriod == 0 {
+		m.DeletionGracePeriod = time.Hour * 24 * 30
+	}
+
+	if m.PurgeInterval == 0 {
+		m.PurgeInterval = time.Hour
+	}
+
+	if m.Hooks == nil {
+		m.Hooks = DefaultHooks
+	}
 }
@@ -0,0 +1,841 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// UserContext gets the context of the request set by SetUserContext,
+// it defaults to c.Context() so that queries are canceled with the
+// server. Handlers pass it to services
+func UserContext(c *fiber.Ctx) context.Context {
+	if ctx, ok := c.Locals("user_context").(context.Context); ok {
+		return ctx
+	}
+
+	return c.Context()
+}
+
+// SetUserContext sets the context of the request, e.g. a middleware
+// sets a context with deadline or tracing values for services
+func SetUserContext(c *fiber.Ctx, ctx context.Context) {
+	c.Locals("user_context", ctx)
+}
+
+// contextService gets ContextAccountService from the service, Service
+// without context support is adapted and methods of AccountService
+// return ErrUnsupported if the service doesn't implement it
+func contextService(s Service) ContextAccountService {
+	if cs, ok := s.(ContextAccountService); ok {
+		return cs
+	}
+
+	cs, ok := s.(ContextService)
+	if !ok {
+		cs = serviceAdapter{s}
+	}
+
+	as, ok := s.(AccountService)
+	if !ok {
+		as = unsupportedService{s}
+	}
+
+	return accountServiceAdapter{cs, as}
+}
+
+// contextRepo gets ContextAccountRepo of the service, Repo without
+// context support is adapted and methods of AccountRepo return
+// ErrUnsupported if the repo doesn't implement it
+func (s service) contextRepo() ContextAccountRepo {
+	if cr, ok := s.repo.(ContextAccountRepo); ok {
+		return cr
+	}
+
+	cr, ok := s.repo.(ContextRepo)
+	if !ok {
+		cr = repoAdapter{s.repo}
+	}
+
+	ar, ok := s.repo.(AccountRepo)
+	if !ok {
+		ar = unsupportedRepo{s.repo}
+	}
+
+	return accountRepoAdapter{cr, ar}
+}
+
+// serviceAdapter adapts Service to ContextService,
+// the context is ignored
+type serviceAdapter struct {
+	Service
+}
+
+func (a serviceAdapter) RegisterByPasswordCtx(_ context.Context, username, pass string) (int, error) {
+	return a.Service.RegisterByPassword(username, pass)
+}
+
+func (a serviceAdapter) RegisterByMobileCodeCtx(_ context.Context, mobile, code string) (int, error) {
+	return a.Service.RegisterByMobileCode(mobile, code)
+}
+
+func (a serviceAdapter) RegisterByEmailCodeCtx(_ context.Context, email, code string) (int, error) {
+	return a.Service.RegisterByEmailCode(email, code)
+}
+
+func (a serviceAdapter) LoginByPasswordCtx(_ context.Context, username, pass string) (int, error) {
+	return a.Service.LoginByPassword(username, pass)
+}
+
+func (a serviceAdapter) LoginByMobileCodeCtx(_ context.Context, mobile, code string) (int, error) {
+	return a.Service.LoginByMobileCode(mobile, code)
+}
+
+func (a serviceAdapter) LoginByEmailCodeCtx(_ context.Context, email, code string) (int, error) {
+	return a.Service.LoginByEmailCode(email, code)
+}
+
+// accountServiceAdapter adapts AccountService to ContextAccountService,
+// the context is ignored
+type accountServiceAdapter struct {
+	ContextService
+	account AccountService
+}
+
+func (a accountServiceAdapter) UserCtx(_ context.Context, id int) (User, error) {
+	return a.account.User(id)
+}
+
+func (a accountServiceAdapter) UserByEmailCtx(_ context.Context, email string) (User, error) {
+	return a.account.UserByEmail(email)
+}
+
+func (a accountServiceAdapter) SendEmailVerificationCtx(_ context.Context, id int) error {
+	return a.account.SendEmailVerification(id)
+}
+
+func (a accountServiceAdapter) VerifyEmailCtx(_ context.Context, id int, code string) error {
+	return a.account.VerifyEmail(id, code)
+}
+
+func (a accountServiceAdapter) SendMobileVerificationCtx(_ context.Context, id int) error {
+	return a.account.SendMobileVerification(id)
+}
+
+func (a accountServiceAdapter) VerifyMobileCtx(_ context.Context, id int, code string) error {
+	return a.account.VerifyMobile(id, code)
+}
+
+func (a accountServiceAdapter) AddCredentialCtx(_ context.Context, c Credential) error {
+	return a.account.AddCredential(c)
+}
+
+func (a accountServiceAdapter) CredentialCtx(_ context.Context, id string) (Credential, error) {
+	return a.account.Credential(id)
+}
+
+func (a accountServiceAdapter) CredentialsCtx(_ context.Context, userID int) ([]Credential, error) {
+	return a.account.Credentials(userID)
+}
+
+func (a accountServiceAdapter) UpdateSignCountCtx(_ context.Context, id string, count uint32) error {
+	return a.account.UpdateSignCount(id, count)
+}
+
+func (a accountServiceAdapter) UsersCtx(_ context.Context, q UserQuery) ([]User, int, error) {
+	return a.account.Users(q)
+}
+
+func (a accountServiceAdapter) DisableUserCtx(_ context.Context, id int) error {
+	return a.account.DisableUser(id)
+}
+
+func (a accountServiceAdapter) EnableUserCtx(_ context.Context, id int) error {
+	return a.account.EnableUser(id)
+}
+
+func (a accountServiceAdapter) ResetPasswordCtx(_ context.Context, id int) error {
+	return a.account.ResetPassword(id)
+}
+
+func (a accountServiceAdapter) SetPasswordCtx(_ context.Context, id int, pass string) error {
+	return a.account.SetPassword(id, pass)
+}
+
+func (a accountServiceAdapter) ChangePasswordCtx(_ context.Context, id int, current, pass string) error {
+	return a.account.ChangePassword(id, current, pass)
+}
+
+func (a accountServiceAdapter) DeleteUserCtx(_ context.Context, id int) error {
+	return a.account.DeleteUser(id)
+}
+
+func (a accountServiceAdapter) AddAuditEventCtx(_ context.Context, e AuditEvent) error {
+	return a.account.AddAuditEvent(e)
+}
+
+func (a accountServiceAdapter) LinkUserCtx(_ context.Context, i Identity, u User) (int, error) {
+	return a.account.LinkUser(i, u)
+}
+
+func (a accountServiceAdapter) InviteCtx(_ context.Context, i Invitation) (Invitation, string, error) {
+	return a.account.Invite(i)
+}
+
+func (a accountServiceAdapter) ResendInvitationCtx(_ context.Context, id int, expiresAt time.Time) (Invitation, string, error) {
+	return a.account.ResendInvitation(id, expiresAt)
+}
+
+func (a accountServiceAdapter) RevokeInvitationCtx(_ context.Context, id int) error {
+	return a.account.RevokeInvitation(id)
+}
+
+func (a accountServiceAdapter) InvitationByTokenCtx(_ context.Context, token string) (Invitation, error) {
+	return a.account.InvitationByToken(token)
+}
+
+func (a accountServiceAdapter) RegisterByInvitationCtx(_ context.Context, token, username, pass string) (int, error) {
+	return a.account.RegisterByInvitation(token, username, pass)
+}
+
+func (a accountServiceAdapter) AuditEventsCtx(_ context.Context, userID int) ([]AuditEvent, error) {
+	return a.account.AuditEvents(userID)
+}
+
+func (a accountServiceAdapter) IdentitiesCtx(_ context.Context, userID int) ([]Identity, error) {
+	return a.account.Identities(userID)
+}
+
+func (a accountServiceAdapter) PurgeDeletedUsersCtx(_ context.Context, before time.Time) (int, error) {
+	return a.account.PurgeDeletedUsers(before)
+}
+
+// unsupportedService is the AccountService of services which don't
+// implement it, ErrUnsupported is returned by all of its methods
+type unsupportedService struct {
+	Service
+}
+
+func (unsupportedService) User(int) (User, error) {
+	return User{}, ErrUnsupported
+}
+
+func (unsupportedService) UserByEmail(string) (User, error) {
+	return User{}, ErrUnsupported
+}
+
+func (unsupportedService) SendEmailVerification(int) error {
+	return ErrUnsupported
+}
+
+func (unsupportedService) VerifyEmail(int, string) error {
+	return ErrUnsupported
+}
+
+func (unsupportedService) SendMobileVerification(int) error {
+	return ErrUnsupported
+}
+
+func (unsupportedService) VerifyMobile(int, string) error {
+	return ErrUnsupported
+}
+
+func (unsupportedService) AddCredential(Credential) error {
+	return ErrUnsupported
+}
+
+func (unsupportedService) Credential(string) (Credential, error) {
+	return Credential{}, ErrUnsupported
+}
+
+func (unsupportedService) Credentials(int) ([]Credential, error) {
+	return nil, ErrUnsupported
+}
+
+func (unsupportedService) UpdateSignCount(string, uint32) error {
+	return ErrUnsupported
+}
+
+func (unsupportedService) Users(UserQuery) ([]User, int, error) {
+	return nil, 0, ErrUnsupported
+}
+
+func (unsupportedService) DisableUser(int) error {
+	return ErrUnsupported
+}
+
+func (unsupportedService) EnableUser(int) error {
+	return ErrUnsupported
+}
+
+func (unsupportedService) ResetPassword(int) error {
+	return ErrUnsupported
+}
+
+func (unsupportedService) SetPassword(int, string) error {
+	return ErrUnsupported
+}
+
+func (unsupportedService) ChangePassword(int, string, string) error {
+	return ErrUnsupported
+}
+
+func (unsupportedService) DeleteUser(int) error {
+	return ErrUnsupported
+}
+
+func (unsupportedService) AddAuditEvent(AuditEvent) error {
+	return ErrUnsupported
+}
+
+func (unsupportedService) LinkUser(Identity, User) (int, error) {
+	return 0, ErrUnsupported
+}
+
+func (unsupportedService) Invite(Invitation) (Invitation, string, error) {
+	return Invitation{}, "", ErrUnsupported
+}
+
+func (unsupportedService) ResendInvitation(int, time.Time) (Invitation, string, error) {
+	return Invitation{}, "", ErrUnsupported
+}
+
+func (unsupportedService) RevokeInvitation(int) error {
+	return ErrUnsupported
+}
+
+func (unsupportedService) InvitationByToken(string) (Invitation, error) {
+	return Invitation{}, ErrUnsupported
+}
+
+func (unsupportedService) RegisterByInvitation(string, string, string) (int, error) {
+	return 0, ErrUnsupported
+}
+
+func (unsupportedService) AuditEvents(int) ([]AuditEvent, error) {
+	return nil, ErrUnsupported
+}
+
+func (unsupportedService) Identities(int) ([]Identity, error) {
+	return nil, ErrUnsupported
+}
+
+func (unsupportedService) PurgeDeletedUsers(time.Time) (int, error) {
+	return 0, ErrUnsupported
+}
+
+// repoAdapter adapts Repo to ContextRepo, the context
+// is ignored
+type repoAdapter struct {
+	Repo
+}
+
+func (a repoAdapter) RegisterByPasswordCtx(_ context.Context, username, pass string) (int, error) {
+	return a.Repo.RegisterByPassword(username, pass)
+}
+
+func (a repoAdapter) RegisterByMobileCtx(_ context.Context, mobile string) (int, error) {
+	return a.Repo.RegisterByMobile(mobile)
+}
+
+func (a repoAdapter) RegisterByEmailCtx(_ context.Context, email string) (int, error) {
+	return a.Repo.RegisterByEmail(email)
+}
+
+func (a repoAdapter) LoginByPasswordCtx(_ context.Context, username, pass string) (int, error) {
+	return a.Repo.LoginByPassword(username, pass)
+}
+
+func (a repoAdapter) LoginByMobileCtx(_ context.Context, mobile string) (int, error) {
+	return a.Repo.LoginByMobile(mobile)
+}
+
+func (a repoAdapter) LoginByEmailCtx(_ context.Context, email string) (int, error) {
+	return a.Repo.LoginByEmail(email)
+}
+
+// accountRepoAdapter adapts AccountRepo to ContextAccountRepo,
+// the context is ignored
+type accountRepoAdapter struct {
+	ContextRepo
+	account AccountRepo
+}
+
+func (a accountRepoAdapter) UserCtx(_ context.Context, id int) (User, error) {
+	return a.account.User(id)
+}
+
+func (a accountRepoAdapter) UserByEmailCtx(_ context.Context, email string) (User, error) {
+	return a.account.UserByEmail(email)
+}
+
+func (a accountRepoAdapter) VerifyEmailCtx(_ context.Context, id int) error {
+	return a.account.VerifyEmail(id)
+}
+
+func (a accountRepoAdapter) VerifyMobileCtx(_ context.Context, id int) error {
+	return a.account.VerifyMobile(id)
+}
+
+func (a accountRepoAdapter) AddCredentialCtx(_ context.Context, c Credential) error {
+	return a.account.AddCredential(c)
+}
+
+func (a accountRepoAdapter) CredentialCtx(_ context.Context, id string) (Credential, error) {
+	return a.account.Credential(id)
+}
+
+func (a accountRepoAdapter) CredentialsCtx(_ context.Context, userID int) ([]Credential, error) {
+	return a.account.Credentials(userID)
+}
+
+func (a accountRepoAdapter) UpdateSignCountCtx(_ context.Context, id string, count uint32) error {
+	return a.account.UpdateSignCount(id, count)
+}
+
+func (a accountRepoAdapter) UsersCtx(_ context.Context, q UserQuery) ([]User, int, error) {
+	return a.account.Users(q)
+}
+
+func (a accountRepoAdapter) DisableUserCtx(_ context.Context, id int) error {
+	return a.account.DisableUser(id)
+}
+
+func (a accountRepoAdapter) EnableUserCtx(_ context.Context, id int) error {
+	return a.account.EnableUser(id)
+}
+
+func (a accountRepoAdapter) ResetPasswordCtx(_ context.Context, id int) error {
+	return a.account.ResetPassword(id)
+}
+
+func (a accountRepoAdapter) SetPasswordCtx(_ context.Context, id int, pass string) error {
+	return a.account.SetPassword(id, pass)
+}
+
+func (a accountRepoAdapter) ChangePasswordCtx(_ context.Context, id int, current, pass string) error {
+	return a.account.ChangePassword(id, current, pass)
+}
+
+func (a accountRepoAdapter) DeleteUserCtx(_ context.Context, id int) error {
+	return a.account.DeleteUser(id)
+}
+
+func (a accountRepoAdapter) AddAuditEventCtx(_ context.Context, e AuditEvent) error {
+	return a.account.AddAuditEvent(e)
+}
+
+func (a accountRepoAdapter) LinkUserCtx(_ context.Context, i Identity, u User) (int, error) {
+	return a.account.LinkUser(i, u)
+}
+
+func (a accountRepoAdapter) AddInvitationCtx(_ context.Context, i Invitation) (int, error) {
+	return a.account.AddInvitation(i)
+}
+
+func (a accountRepoAdapter) InvitationCtx(_ context.Context, id int) (Invitation, error) {
+	return a.account.Invitation(id)
+}
+
+func (a accountRepoAdapter) InvitationByTokenCtx(_ context.Context, hash string) (Invitation, error) {
+	return a.account.InvitationByToken(hash)
+}
+
+func (a accountRepoAdapter) RenewInvitationCtx(_ context.Context, id int, hash string, expiresAt time.Time) error {
+	return a.account.RenewInvitation(id, hash, expiresAt)
+}
+
+func (a accountRepoAdapter) RevokeInvitationCtx(_ context.Context, id int) error {
+	return a.account.RevokeInvitation(id)
+}
+
+func (a accountRepoAdapter) RegisterByInvitationCtx(_ context.Context, id int, username, pass string) (int, error) {
+	return a.account.RegisterByInvitation(id, username, pass)
+}
+
+func (a accountRepoAdapter) AuditEventsCtx(_ context.Context, userID int) ([]AuditEvent, error) {
+	return a.account.AuditEvents(userID)
+}
+
+func (a accountRepoAdapter) IdentitiesCtx(_ context.Context, userID int) ([]Identity, error) {
+	return a.account.Identities(userID)
+}
+
+func (a accountRepoAdapter) PurgeDeletedUsersCtx(_ context.Context, before time.Time) (int, error) {
+	return a.account.PurgeDeletedUsers(before)
+}
+
+// unsupportedRepo is the AccountRepo of repositories which don't
+// implement it, ErrUnsupported is returned by all of its methods
+type unsupportedRepo struct {
+	Repo
+}
+
+func (unsupportedRepo) User(int) (User, error) {
+	return User{}, ErrUnsupported
+}
+
+func (unsupportedRepo) UserByEmail(string) (User, error) {
+	return User{}, ErrUnsupported
+}
+
+func (unsupportedRepo) VerifyEmail(int) error {
+	return ErrUnsupported
+}
+
+func (unsupportedRepo) VerifyMobile(int) error {
+	return ErrUnsupported
+}
+
+func (unsupportedRepo) AddCredential(Credential) error {
+	return ErrUnsupported
+}
+
+func (unsupportedRepo) Credential(string) (Credential, error) {
+	return Credential{}, ErrUnsupported
+}
+
+func (unsupportedRepo) Credentials(int) ([]Credential, error) {
+	return nil, ErrUnsupported
+}
+
+func (unsupportedRepo) UpdateSignCount(string, uint32) error {
+	return ErrUnsupported
+}
+
+func (unsupportedRepo) Users(UserQuery) ([]User, int, error) {
+	return nil, 0, ErrUnsupported
+}
+
+func (unsupportedRepo) DisableUser(int) error {
+	return ErrUnsupported
+}
+
+func (unsupportedRepo) EnableUser(int) error {
+	return ErrUnsupported
+}
+
+func (unsupportedRepo) ResetPassword(int) error {
+	return ErrUnsupported
+}
+
+func (unsupportedRepo) SetPassword(int, string) error {
+	return ErrUnsupported
+}
+
+func (unsupportedRepo) ChangePassword(int, string, string) error {
+	return ErrUnsupported
+}
+
+func (unsupportedRepo) DeleteUser(int) error {
+	return ErrUnsupported
+}
+
+func (unsupportedRepo) AddAuditEvent(AuditEvent) error {
+	return ErrUnsupported
+}
+
+func (unsupportedRepo) LinkUser(Identity, User) (int, error) {
+	return 0, ErrUnsupported
+}
+
+func (unsupportedRepo) AddInvitation(Invitation) (int, error) {
+	return 0, ErrUnsupported
+}
+
+func (unsupportedRepo) Invitation(int) (Invitation, error) {
+	return Invitation{}, ErrUnsupported
+}
+
+func (unsupportedRepo) InvitationByToken(string) (Invitation, error) {
+	return Invitation{}, ErrUnsupported
+}
+
+func (unsupportedRepo) RenewInvitation(int, string, time.Time) error {
+	return ErrUnsupported
+}
+
+func (unsupportedRepo) RevokeInvitation(int) error {
+	return ErrUnsupported
+}
+
+func (unsupportedRepo) RegisterByInvitation(int, string, string) (int, error) {
+	return 0, ErrUnsupported
+}
+
+func (unsupportedRepo) AuditEvents(int) ([]AuditEvent, error) {
+	return nil, ErrUnsupported
+}
+
+func (unsupportedRepo) Identities(int) ([]Identity, error) {
+	return nil, ErrUnsupported
+}
+
+func (unsupportedRepo) PurgeDeletedUsers(time.Time) (int, error) {
+	return 0, ErrUnsupported
+}
+
+// Methods of Service are implemented with background context
+
+func (s service) RegisterByPassword(username, pass string) (int, error) {
+	return s.RegisterByPasswordCtx(context.Background(), username, pass)
+}
+
+func (s service) RegisterByMobileCode(mobile, code string) (int, error) {
+	return s.RegisterByMobileCodeCtx(context.Background(), mobile, code)
+}
+
+func (s service) RegisterByEmailCode(email, code string) (int, error) {
+	return s.RegisterByEmailCodeCtx(context.Background(), email, code)
+}
+
+func (s service) LoginByPassword(username, pass string) (int, error) {
+	return s.LoginByPasswordCtx(context.Background(), username, pass)
+}
+
+func (s service) LoginByMobileCode(mobile, code string) (int, error) {
+	return s.LoginByMobileCodeCtx(context.Background(), mobile, code)
+}
+
+func (s service) LoginByEmailCode(email, code string) (int, error) {
+	return s.LoginByEmailCodeCtx(context.Background(), email, code)
+}
+
+func (s service) User(id int) (User, error) {
+	return s.UserCtx(context.Background(), id)
+}
+
+func (s service) UserByEmail(email string) (User, error) {
+	return s.UserByEmailCtx(context.Background(), email)
+}
+
+func (s service) SendEmailVerification(id int) error {
+	return s.SendEmailVerificationCtx(context.Background(), id)
+}
+
+func (s service) VerifyEmail(id int, code string) error {
+	return s.VerifyEmailCtx(context.Background(), id, code)
+}
+
+func (s service) SendMobileVerification(id int) error {
+	return s.SendMobileVerificationCtx(context.Background(), id)
+}
+
+func (s service) VerifyMobile(id int, code string) error {
+	return s.VerifyMobileCtx(context.Background(), id, code)
+}
+
+func (s service) AddCredential(c Credential) error {
+	return s.AddCredentialCtx(context.Background(), c)
+}
+
+func (s service) Credential(id string) (Credential, error) {
+	return s.CredentialCtx(context.Background(), id)
+}
+
+func (s service) Credentials(userID int) ([]Credential, error) {
+	return s.CredentialsCtx(context.Background(), userID)
+}
+
+func (s service) UpdateSignCount(id string, count uint32) error {
+	return s.UpdateSignCountCtx(context.Background(), id, count)
+}
+
+func (s service) Users(q UserQuery) ([]User, int, error) {
+	return s.UsersCtx(context.Background(), q)
+}
+
+func (s service) DisableUser(id int) error {
+	return s.DisableUserCtx(context.Background(), id)
+}
+
+func (s service) EnableUser(id int) error {
+	return s.EnableUserCtx(context.Background(), id)
+}
+
+func (s service) ResetPassword(id int) error {
+	return s.ResetPasswordCtx(context.Background(), id)
+}
+
+func (s service) SetPassword(id int, pass string) error {
+	return s.SetPasswordCtx(context.Background(), id, pass)
+}
+
+func (s service) ChangePassword(id int, current, pass string) error {
+	return s.ChangePasswordCtx(context.Background(), id, current, pass)
+}
+
+func (s service) DeleteUser(id int) error {
+	return s.DeleteUserCtx(context.Background(), id)
+}
+
+func (s service) AddAuditEvent(e AuditEvent) error {
+	return s.AddAuditEventCtx(context.Background(), e)
+}
+
+func (s service) LinkUser(i Identity, u User) (int, error) {
+	return s.LinkUserCtx(context.Background(), i, u)
+}
+
+func (s service) Invite(i Invitation) (Invitation, string, error) {
+	return s.InviteCtx(context.Background(), i)
+}
+
+func (s service) ResendInvitation(id int, expiresAt time.Time) (Invitation, string, error) {
+	return s.ResendInvitationCtx(context.Background(), id, expiresAt)
+}
+
+func (s service) RevokeInvitation(id int) error {
+	return s.RevokeInvitationCtx(context.Background(), id)
+}
+
+func (s service) InvitationByToken(token string) (Invitation, error) {
+	return s.InvitationByTokenCtx(context.Background(), token)
+}
+
+func (s service) RegisterByInvitation(token, username, pass string) (int, error) {
+	return s.RegisterByInvitationCtx(context.Background(), token, username, pass)
+}
+
+func (s service) AuditEvents(userID int) ([]AuditEvent, error) {
+	return s.AuditEventsCtx(context.Background(), userID)
+}
+
+func (s service) Identities(userID int) ([]Identity, error) {
+	return s.IdentitiesCtx(context.Background(), userID)
+}
+
+func (s service) PurgeDeletedUsers(before time.Time) (int, error) {
+	return s.PurgeDeletedUsersCtx(context.Background(), before)
+}
+
+// Methods of Repo are implemented with background context
+
+func (r repository) RegisterByPassword(username, pass string) (int, error) {
+	return r.RegisterByPasswordCtx(context.Background(), username, pass)
+}
+
+func (r repository) RegisterByMobile(mobile string) (int, error) {
+	return r.RegisterByMobileCtx(context.Background(), mobile)
+}
+
+func (r repository) RegisterByEmail(email string) (int, error) {
+	return r.RegisterByEmailCtx(context.Background(), email)
+}
+
+func (r repository) LoginByPassword(username, pass string) (int, error) {
+	return r.LoginByPasswordCtx(context.Background(), username, pass)
+}
+
+func (r repository) LoginByMobile(mobile string) (int, error) {
+	return r.LoginByMobileCtx(context.Background(), mobile)
+}
+
+func (r repository) LoginByEmail(email string) (int, error) {
+	return r.LoginByEmailCtx(context.Background(), email)
+}
+
+func (r repository) User(id int) (User, error) {
+	return r.UserCtx(context.Background(), id)
+}
+
+func (r repository) UserByEmail(email string) (User, error) {
+	return r.UserByEmailCtx(context.Background(), email)
+}
+
+func (r repository) VerifyEmail(id int) error {
+	return r.VerifyEmailCtx(context.Background(), id)
+}
+
+func (r repository) VerifyMobile(id int) error {
+	return r.VerifyMobileCtx(context.Background(), id)
+}
+
+func (r repository) AddCredential(c Credential) error {
+	return r.AddCredentialCtx(context.Background(), c)
+}
+
+func (r repository) Credential(id string) (Credential, error) {
+	return r.CredentialCtx(context.Background(), id)
+}
+
+func (r repository) Credentials(userID int) ([]Credential, error) {
+	return r.CredentialsCtx(context.Background(), userID)
+}
+
+func (r repository) UpdateSignCount(id string, count uint32) error {
+	return r.UpdateSignCountCtx(context.Background(), id, count)
+}
+
+func (r repository) Users(q UserQuery) ([]User, int, error) {
+	return r.UsersCtx(context.Background(), q)
+}
+
+func (r repository) DisableUser(id int) error {
+	return r.DisableUserCtx(context.Background(), id)
+}
+
+func (r repository) EnableUser(id int) error {
+	return r.EnableUserCtx(context.Background(), id)
+}
+
+func (r repository) ResetPassword(id int) error {
+	return r.ResetPasswordCtx(context.Background(), id)
+}
+
+func (r repository) SetPassword(id int, pass string) error {
+	return r.SetPasswordCtx(context.Background(), id, pass)
+}
+
+func (r repository) ChangePassword(id int, current, pass string) error {
+	return r.ChangePasswordCtx(context.Background(), id, current, pass)
+}
+
+func (r repository) DeleteUser(id int) error {
+	return r.DeleteUserCtx(context.Background(), id)
+}
+
+func (r repository) AddAuditEvent(e AuditEvent) error {
+	return r.AddAuditEventCtx(context.Background(), e)
+}
+
+func (r repository) LinkUser(i Identity, u User) (int, error) {
+	return r.LinkUserCtx(context.Background(), i, u)
+}
+
+func (r repository) AddInvitation(i Invitation) (int, error) {
+	return r.AddInvitationCtx(context.Background(), i)
+}
+
+func (r repository) Invitation(id int) (Invitation, error) {
+	return r.InvitationCtx(context.Background(), id)
+}
+
+func (r repository) InvitationByToken(hash string) (Invitation, error) {
+	return r.InvitationByTokenCtx(context.Background(), hash)
+}
+
+func (r repository) RenewInvitation(id int, hash string, expiresAt time.Time) error {
+	return r.RenewInvitationCtx(context.Background(), id, hash, expiresAt)
+}
+
+func (r repository) RevokeInvitation(id int) error {
+	return r.RevokeInvitationCtx(context.Background(), id)
+}
+
+func (r repository) RegisterByInvitation(id int, username, pass string) (int, error) {
+	return r.RegisterByInvitationCtx(context.Background(), id, username, pass)
+}
+
+func (r repository) AuditEvents(userID int) ([]AuditEvent, error) {
+	return r.AuditEventsCtx(context.Background(), userID)
+}
+
+func (r repository) Identities(userID int) ([]Identity, error) {
+	return r.IdentitiesCtx(context.Background(), userID)
+}
+
+func (r repository) PurgeDeletedUsers(before time.Time) (int, error) {
+	return r.PurgeDeletedUsersCtx(context.Background(), before)
+}
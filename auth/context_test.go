@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/go-dawn/module/auth/mocks"
+	"github.com/go-dawn/pkg/deck"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/valyala/fasthttp"
+)
+
+type ctxKey struct{}
+
+func Test_Auth_Context_Service(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	legacy := new(mocks.AccountService)
+	at.Equal(accountServiceAdapter{serviceAdapter{legacy}, legacy}, contextService(legacy))
+
+	s := service{}
+	at.Equal(s, contextService(s))
+
+	t.Run("adapter", func(t *testing.T) {
+		legacy.On("User", 1).
+			Once().Return(User{ID: 1}, nil)
+
+		u, err := contextService(legacy).UserCtx(context.Background(), 1)
+		at.Nil(err)
+		at.Equal(1, u.ID)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		basic := new(mocks.Service)
+		basic.On("LoginByPassword", "username", "pass").
+			Once().Return(1, nil)
+
+		cs := contextService(basic)
+
+		id, err := cs.LoginByPasswordCtx(context.Background(), "username", "pass")
+		at.Nil(err)
+		at.Equal(1, id)
+
+		_, err = cs.UserCtx(context.Background(), 1)
+		at.Equal(ErrUnsupported, err)
+	})
+}
+
+func Test_Auth_Context_Repo(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	legacy := new(mocks.AccountRepo)
+	at.Equal(accountRepoAdapter{repoAdapter{legacy}, legacy}, service{repo: legacy}.contextRepo())
+
+	r := repository{}
+	at.Equal(r, service{repo: r}.contextRepo())
+
+	t.Run("adapter", func(t *testing.T) {
+		legacy.On("User", 1).
+			Once().Return(User{ID: 1}, nil)
+
+		u, err := service{repo: legacy}.UserCtx(context.Background(), 1)
+		at.Nil(err)
+		at.Equal(1, u.ID)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		err := service{repo: new(mocks.Repo)}.DisableUserCtx(context.Background(), 1)
+		at.Equal(ErrUnsupported, err)
+	})
+
+	t.Run("pass context", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+		repo := new(mocks.ContextAccountRepo)
+		s := service{repo: ctxRepo{ContextAccountRepo: repo}}
+
+		repo.On("UserCtx", ctx, 1).
+			Once().Return(User{ID: 1}, nil)
+
+		u, err := s.UserCtx(ctx, 1)
+		at.Nil(err)
+		at.Equal(1, u.ID)
+
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		repo := getRepo(t)
+		u := repo.createUser(t, "username", "pass")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := repo.UserCtx(ctx, int(u.ID))
+		at.True(errors.Is(err, context.Canceled))
+
+		_, err = repo.User(int(u.ID))
+		at.Nil(err)
+	})
+}
+
+func Test_Auth_Context_Handler(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	mockService := new(mocks.ContextAccountService)
+	m := module{Config: &Config{
+		Service:    ctxService{ContextAccountService: mockService},
+		SigningKey: "test",
+	}}
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		app.Post("/login", func(c *fiber.Ctx) error {
+			SetUserContext(c, ctx)
+			return c.Next()
+		}, m.login)
+	})
+
+	// Strategies get the user context carrying its tenant
+	mockService.On("LoginByPasswordCtx", mock.MatchedBy(func(c context.Context) bool {
+		return c.Value(ctxKey{}) == "value" && TenantFromContext(c) == ""
+	}), "username", "pass").
+		Once().Return(1, nil).
+		On("UserCtx", ctx, 1).
+		Once().Return(User{ID: 1}, nil)
+
+	e.POST("/login").
+		WithJSON(loginForm{Username: "username", Code: "pass", Type: "password"}).
+		Expect().
+		Status(fiber.StatusOK)
+
+	at.True(mockService.AssertExpectations(t))
+}
+
+// ctxService is a Service which also supports context
+type ctxService struct {
+	*mocks.AccountService
+	*mocks.ContextAccountService
+}
+
+// ctxRepo is a Repo which also supports context
+type ctxRepo struct {
+	*mocks.AccountRepo
+	*mocks.ContextAccountRepo
+}
+
+func Test_Auth_UserContext(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	app := fiber.New()
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+
+	// Values of the request are seen by services
+	c.Context().SetUserValue("key", "value")
+	at.Equal(c.Context(), UserContext(c))
+	at.Equal("value", UserContext(c).Value("key"))
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+	SetUserContext(c, ctx)
+	at.Equal(ctx, UserContext(c))
+}
+
+func Test_Auth_Context_Unsupported(t *testing.T) {
+	t.Parallel()
+
+	m := module{Config: &Config{Service: new(mocks.Service)}}
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		app.Post("/email/verification", func(c *fiber.Ctx) error {
+			c.Locals("user", &jwt.Token{Claims: jwt.MapClaims{"id": float64(1)}})
+			return c.Next()
+		}, m.sendEmailVerification)
+	})
+
+	e.POST("/email/verification").
+		Expect().
+		Status(fiber.StatusNotImplemented).
+		JSON().Object().ValueEqual("code", CodeUnsupported)
+}
@@ -0,0 +1,104 @@
+// Package domain contains domain objects of auth module
+package domain
+
+import "time"
+
+// User is the domain object of an auth user
+type User struct {
+	ID               int        `json:"id"`
+	Tenant           string     `json:"tenant,omitempty"`
+	Username         string     `json:"username"`
+	Role             string     `json:"role,omitempty"`
+	Mobile           string     `json:"mobile"`
+	MobileVerifiedAt *time.Time `json:"mobile_verified_at"`
+	Email            string     `json:"email"`
+	EmailVerifiedAt  *time.Time `json:"email_verified_at"`
+	DisabledAt       *time.Time `json:"disabled_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// Disabled reports whether the user has been disabled
+func (u User) Disabled() bool {
+	return u.DisabledAt != nil
+}
+
+// EmailVerified reports whether the email address has been verified
+func (u User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// MobileVerified reports whether the mobile number has been verified
+func (u User) MobileVerified() bool {
+	return u.MobileVerifiedAt != nil
+}
+
+// UserQuery filters and paginates users
+type UserQuery struct {
+	// Search matches username, mobile or email
+	Search   string
+	Page     int
+	PageSize int
+}
+
+// Credential is the domain object of a webauthn credential
+type Credential struct {
+	// ID is the base64url encoded credential id
+	ID string `json:"id"`
+	// UserID is the owner of the credential
+	UserID int `json:"user_id"`
+	// PublicKey is the COSE encoded public key
+	PublicKey []byte `json:"-"`
+	// SignCount is the latest signature counter
+	SignCount uint32    `json:"sign_count"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Identity is an account of an external provider, it is
+// linked to a local user on the first login
+type Identity struct {
+	// Provider is the name of the provider, e.g. ldap
+	Provider string `json:"provider"`
+	// Subject is the stable id of the account in the
+	// provider, e.g. dn of a directory entry
+	Subject string `json:"subject"`
+	// SyncRole tells that the provider owns the role of
+	// the user, so the local role is overwritten by it
+	SyncRole bool `json:"-"`
+}
+
+// AuditEvent records a sensitive action on a user
+type AuditEvent struct {
+	ID int `json:"id"`
+	// Action is the name of the action, e.g. impersonate
+	Action string `json:"action"`
+	// ActorID is the user who performed the action
+	ActorID int `json:"actor_id"`
+	// UserID is the user affected by the action
+	UserID int `json:"user_id"`
+	// IP is the client ip of the request
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Invitation invites someone to register with the email
+type Invitation struct {
+	ID     int    `json:"id"`
+	Tenant string `json:"tenant,omitempty"`
+	// Email is locked when registering by the invitation
+	Email string `json:"email"`
+	// Role is granted to the registered user
+	Role string `json:"role,omitempty"`
+	// InviterID is the user who sent the invitation
+	InviterID int `json:"inviter_id"`
+	// TokenHash is the sha256 hash of the invitation token
+	TokenHash  string     `json:"-"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Pending reports whether the invitation can still be accepted
+func (i Invitation) Pending() bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil && time.Now().Before(i.ExpiresAt)
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/go-dawn/dawn/fiberx"
+	"github.com/go-sql-driver/mysql"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgconn"
+	"github.com/mattn/go-sqlite3"
+)
+
+var (
+	// ErrInvalidCredentials occurs when username, password or
+	// other credentials are not valid
+	ErrInvalidCredentials = errors.New("auth: invalid credentials")
+
+	// ErrUserNotFound occurs when the user doesn't exist
+	ErrUserNotFound = errors.New("auth: user not found")
+
+	// ErrUserDisabled occurs when a disabled user tries to login
+	ErrUserDisabled = errors.New("auth: user is disabled")
+
+	// ErrCodeMismatch occurs when verification code is not
+	// matched or has expired
+	ErrCodeMismatch = errors.New("auth: code is not matched")
+
+	// ErrDuplicate occurs when an identity has been taken,
+	// use errors.As with *DuplicateError to get the field
+	ErrDuplicate = errors.New("auth: duplicate identity")
+
+	// ErrCredentialNotFound occurs when webauthn credential
+	// doesn't exist
+	ErrCredentialNotFound = errors.New("auth: credential not found")
+
+	// ErrInvalidAuthType occurs when authenticate type
+	// is not supported
+	ErrInvalidAuthType = errors.New("auth: invalid authenticate type")
+
+	// ErrUnsupported occurs when the custom Service or Repo
+	// doesn't implement AccountService or AccountRepo
+	ErrUnsupported = errors.New("auth: operation is not supported")
+)
+
+// DuplicateError occurs when an identity has been taken,
+// it matches ErrDuplicate with errors.Is
+type DuplicateError struct {
+	// Field is the identity, e.g. username, mobile or email
+	Field string
+}
+
+func (e *DuplicateError) Error() string {
+	return "auth: " + e.Field + " already exists"
+}
+
+// Is reports whether the target is ErrDuplicate
+func (e *DuplicateError) Is(target error) bool {
+	return target == ErrDuplicate
+}
+
+// credentialsError marks a failure of authentication as
+// ErrInvalidCredentials and keeps the cause matchable
+type credentialsError struct {
+	err error
+}
+
+func (e credentialsError) Error() string {
+	return ErrInvalidCredentials.Error() + ": " + e.err.Error()
+}
+
+func (e credentialsError) Unwrap() error {
+	return e.err
+}
+
+func (e credentialsError) Is(target error) bool {
+	return target == ErrInvalidCredentials
+}
+
+// Error codes in response body, they are stable so that
+// clients can tell errors apart without parsing messages
+const (
+	CodeInvalidAuthType     = 40001
+	CodeCodeMismatch        = 40002
+	CodeNoEmail             = 40003
+	CodeNoMobile            = 40004
+	CodeInvalidInvitation   = 40005
+	CodeEmailLocked         = 40006
+	CodeInvalidCredentials  = 40101
+	CodeInvalidMagicLink    = 40102
+	CodeTenantMismatch      = 40103
+	CodeTokenRevoked        = 40104
+	CodeReauthRequired      = 40105
+	CodeUserDisabled        = 40301
+	CodeEmailNotVerified    = 40302
+	CodePermissionDenied    = 40303
+	CodeInvitationRequired  = 40304
+	CodeUserNotFound        = 40401
+	CodeInvitationNotFound  = 40402
+	CodeDuplicate           = 40901
+	CodeUnsupported         = 50101
+	CodeLDAPUnavailable     = 50301
+	CodeWebAuthnUnavailable = 50302
+	CodeSenderUnavailable   = 50303
+)
+
+// errMappings maps errors to status codes, error codes
+// and messages of responses
+var errMappings = []struct {
+	err     error
+	status  int
+	code    int
+	message string
+}{
+	// Failures of authentication wrap their causes, e.g.
+	// ErrCodeMismatch, so it goes before other errors
+	{ErrInvalidCredentials, fiber.StatusUnauthorized, CodeInvalidCredentials, "Invalid credentials"},
+	{ErrInvalidAuthType, fiber.StatusBadRequest, CodeInvalidAuthType, "Invalid authenticate type"},
+	{ErrCodeMismatch, fiber.StatusBadRequest, CodeCodeMismatch, "Invalid or expired code"},
+	{ErrNoEmail, fiber.StatusBadRequest, CodeNoEmail, "No email address"},
+	{ErrNoMobile, fiber.StatusBadRequest, CodeNoMobile, "No mobile number"},
+	{ErrInvalidInvitation, fiber.StatusBadRequest, CodeInvalidInvitation, "Invalid or expired invitation"},
+	{ErrEmailLocked, fiber.StatusBadRequest, CodeEmailLocked, "Email is locked by the invitation"},
+	{ErrInvalidMagicLink, fiber.StatusUnauthorized, CodeInvalidMagicLink, "Invalid magic link"},
+	{ErrTenantMismatch, fiber.StatusUnauthorized, CodeTenantMismatch, "Invalid tenant"},
+	{ErrTokenRevoked, fiber.StatusUnauthorized, CodeTokenRevoked, "Token revoked"},
+	{ErrReauthRequired, fiber.StatusUnauthorized, CodeReauthRequired, "Re-authentication required"},
+	{ErrUserDisabled, fiber.StatusForbidden, CodeUserDisabled, "User disabled"},
+	{ErrEmailNotVerified, fiber.StatusForbidden, CodeEmailNotVerified, "Email not verified"},
+	{ErrPermissionDenied, fiber.StatusForbidden, CodePermissionDenied, "Permission denied"},
+	{ErrInvitationRequired, fiber.StatusForbidden, CodeInvitationRequired, "Invitation required"},
+	{ErrUserNotFound, fiber.StatusNotFound, CodeUserNotFound, "User not found"},
+	{ErrInvitationNotFound, fiber.StatusNotFound, CodeInvitationNotFound, "Invitation not found"},
+	{ErrDuplicate, fiber.StatusConflict, CodeDuplicate, "Already exists"},
+	{ErrUnsupported, fiber.StatusNotImplemented, CodeUnsupported, "Not supported"},
+	{ErrLDAPUnavailable, fiber.StatusServiceUnavailable, CodeLDAPUnavailable, "LDAP unavailable"},
+	{ErrWebAuthnUnavailable, fiber.StatusServiceUnavailable, CodeWebAuthnUnavailable, "WebAuthn unavailable"},
+	{ErrNoSender, fiber.StatusServiceUnavailable, CodeSenderUnavailable, "Code sender unavailable"},
+}
+
+// errResp responses the error with its status code and error
+// code, unknown errors are returned to the error handler
+func errResp(c *fiber.Ctx, err error) error {
+	for _, m := range errMappings {
+		if !errors.Is(err, m.err) {
+			continue
+		}
+
+		res := fiberx.Response{Code: m.code, Message: m.message}
+
+		var de *DuplicateError
+		if errors.As(err, &de) {
+			res.Data = fiber.Map{"field": de.Field}
+		}
+
+		return fiberx.Resp(c, m.status, res)
+	}
+
+	return err
+}
+
+// isDuplicate reports whether the error is a violation
+// of unique constraints by codes of database drivers
+func isDuplicate(err error) bool {
+	var (
+		me *mysql.MySQLError
+		pe *pgconn.PgError
+		se sqlite3.Error
+	)
+
+	switch {
+	case errors.As(err, &me):
+		return me.Number == mysqlDupEntry
+	case errors.As(err, &pe):
+		return pe.Code == pgUniqueViolation
+	case errors.As(err, &se):
+		return se.ExtendedCode == sqlite3.ErrConstraintUnique ||
+			se.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	default:
+		return false
+	}
+}
+
+const (
+	// mysqlDupEntry is ER_DUP_ENTRY of mysql
+	mysqlDupEntry = 1062
+	// pgUniqueViolation is unique_violation of postgres
+	pgUniqueViolation = "23505"
+)
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/gavv/httpexpect/v2"
+	"github.com/go-dawn/pkg/deck"
+	"github.com/go-sql-driver/mysql"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgconn"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Auth_Errors_DuplicateError(t *testing.T) {
+	at := assert.New(t)
+
+	var err error = &DuplicateError{Field: "username"}
+
+	at.True(errors.Is(err, ErrDuplicate))
+	at.True(errors.Is(fmt.Errorf("wrapped: %w", err), ErrDuplicate))
+	at.Equal("auth: username already exists", err.Error())
+
+	var de *DuplicateError
+	at.True(errors.As(err, &de))
+	at.Equal("username", de.Field)
+}
+
+func Test_Auth_Errors_CredentialsError(t *testing.T) {
+	at := assert.New(t)
+
+	err := credentialsError{ErrSignCount}
+
+	at.True(errors.Is(err, ErrInvalidCredentials))
+	at.True(errors.Is(err, ErrSignCount))
+	at.Contains(err.Error(), ErrInvalidCredentials.Error())
+	at.Contains(err.Error(), ErrSignCount.Error())
+}
+
+func Test_Auth_Errors_IsDuplicate(t *testing.T) {
+	at := assert.New(t)
+
+	at.True(isDuplicate(sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintUnique}))
+	at.True(isDuplicate(fmt.Errorf("wrapped: %w", &mysql.MySQLError{Number: 1062})))
+	at.True(isDuplicate(&pgconn.PgError{Code: "23505"}))
+	at.False(isDuplicate(sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintNotNull}))
+	at.False(isDuplicate(&mysql.MySQLError{Number: 1048}))
+	at.False(isDuplicate(errors.New("duplicate key value violates unique constraint")))
+}
+
+func Test_Auth_Errors_Resp(t *testing.T) {
+	t.Parallel()
+
+	var err error
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		app.Get("/", func(c *fiber.Ctx) error {
+			return errResp(c, err)
+		})
+	})
+
+	for _, m := range errMappings {
+		t.Run(m.message, func(t *testing.T) {
+			err = fmt.Errorf("wrapped: %w", m.err)
+
+			resp := e.GET("/").Expect().Status(m.status)
+
+			deck.AssertRespCode(resp, m.code)
+			deck.AssertRespMsg(resp, m.message)
+		})
+	}
+
+	t.Run("duplicate field", func(t *testing.T) {
+		err = &DuplicateError{Field: "email"}
+
+		resp := e.GET("/").Expect().Status(fiber.StatusConflict)
+
+		deck.AssertRespCode(resp, CodeDuplicate)
+		deck.AssertRespDataCheck(resp, func(v *httpexpect.Value) {
+			v.Object().Value("field").Equal("email")
+		})
+	})
+
+	t.Run("unknown error", func(t *testing.T) {
+		err = errors.New("fake error")
+
+		e.GET("/").Expect().Status(fiber.StatusInternalServerError)
+	})
+}
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"context"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Names of lifecycle events
+const (
+	EventRegistered      = "registered"
+	EventLogin           = "login"
+	EventLoginFailed     = "login_failed"
+	EventLogout          = "logout"
+	EventPasswordChanged = "password_changed"
+)
+
+// Event is passed to hooks of lifecycle events
+type Event struct {
+	// Name is one of Event* constants
+	Name string
+
+	// UserID is the id of the user, it's 0 when the user
+	// of a failed login is unknown
+	UserID int
+
+	// Tenant is the tenant of the user
+	Tenant string
+
+	// Type is the login or register type, e.g. password, mobile,
+	// email, magic_link, invitation, link(created by LinkUser)
+	// or the name of a strategy. Password changes are typed as
+	// change, set or reset(cleared by admins)
+	Type string
+
+	// Identity is the username, mobile, email or other key
+	// which is used to register or login
+	Identity string
+
+	// IP is the client ip of the request, it's empty
+	// for events which are not fired by routes
+	IP string
+
+	// Err is the reason of a failed login
+	Err error
+
+	// Tx is the transaction of the user insert or the password
+	// update, it's only set for synchronous registered and password
+	// changed hooks of the built-in repository
+	Tx *gorm.DB
+}
+
+// Hook handles a lifecycle event, errors of synchronous
+// hooks fail the operation
+type Hook func(ctx context.Context, e Event) error
+
+// HookOption configures a hook
+type HookOption func(*hook)
+
+// Async runs the hook in a new goroutine after the operation
+// succeeds, it gets a background context and its error is
+// dropped, so handle errors inside the hook
+func Async() HookOption {
+	return func(h *hook) {
+		h.async = true
+	}
+}
+
+type hook struct {
+	fn    Hook
+	async bool
+}
+
+// Hooks holds hooks of lifecycle events, the zero value
+// is ready to use and a nil *Hooks runs nothing
+type Hooks struct {
+	mu    sync.RWMutex
+	hooks map[string][]hook
+}
+
+// DefaultHooks is used when Config.Hooks is not set,
+// e.g. the config is read from files
+var DefaultHooks = &Hooks{}
+
+// OnRegistered adds a hook which runs after a user is created,
+// synchronous hooks run inside the transaction of the user
+// insert and their errors roll it back
+func (h *Hooks) OnRegistered(fn Hook, opts ...HookOption) {
+	h.add(EventRegistered, fn, opts)
+}
+
+// OnLogin adds a hook which runs before the token is issued
+func (h *Hooks) OnLogin(fn Hook, opts ...HookOption) {
+	h.add(EventLogin, fn, opts)
+}
+
+// OnLoginFailed adds a hook which runs when a login is rejected
+func (h *Hooks) OnLoginFailed(fn Hook, opts ...HookOption) {
+	h.add(EventLoginFailed, fn, opts)
+}
+
+// OnLogout adds a hook which runs when a user logs out
+func (h *Hooks) OnLogout(fn Hook, opts ...HookOption) {
+	h.add(EventLogout, fn, opts)
+}
+
+// OnPasswordChanged adds a hook which runs after the password
+// of a user is changed, set or reset by admins. Synchronous hooks
+// run inside the transaction of the password update and their
+// errors roll it back
+func (h *Hooks) OnPasswordChanged(fn Hook, opts ...HookOption) {
+	h.add(EventPasswordChanged, fn, opts)
+}
+
+func (h *Hooks) add(name string, fn Hook, opts []HookOption) {
+	hk := hook{fn: fn}
+	for _, opt := range opts {
+		opt(&hk)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.hooks == nil {
+		h.hooks = make(map[string][]hook)
+	}
+
+	h.hooks[name] = append(h.hooks[name], hk)
+}
+
+func (h *Hooks) get(name string) []hook {
+	if h == nil {
+		return nil
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.hooks[name]
+}
+
+// run runs synchronous hooks of the event in order and stops at
+// the first error, asynchronous hooks run only if all succeed
+func (h *Hooks) run(ctx context.Context, e Event) error {
+	if err := h.runSync(ctx, e); err != nil {
+		return err
+	}
+
+	h.runAsync(e)
+
+	return nil
+}
+
+func (h *Hooks) runSync(ctx context.Context, e Event) error {
+	for _, hk := range h.get(e.Name) {
+		if hk.async {
+			continue
+		}
+
+		if err := hk.fn(ctx, e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *Hooks) runAsync(e Event) {
+	// The transaction is done when async hooks run
+	e.Tx = nil
+
+	for _, hk := range h.get(e.Name) {
+		if hk.async {
+			go func(fn Hook) {
+				_ = fn(context.Background(), e)
+			}(hk.fn)
+		}
+	}
+}
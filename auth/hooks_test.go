@@ -0,0 +1,377 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-dawn/pkg/deck"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Auth_Hooks_Run(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	t.Run("nil hooks", func(t *testing.T) {
+		var h *Hooks
+		at.Nil(h.run(context.Background(), Event{Name: EventLogin}))
+	})
+
+	t.Run("in order", func(t *testing.T) {
+		var (
+			h     Hooks
+			calls []int
+			async = make(chan Event, 1)
+		)
+
+		h.OnLogin(func(ctx context.Context, e Event) error {
+			async <- e
+			return nil
+		}, Async())
+		h.OnLogin(func(ctx context.Context, e Event) error {
+			calls = append(calls, 1)
+			return nil
+		})
+		h.OnLogin(func(ctx context.Context, e Event) error {
+			calls = append(calls, 2)
+			return nil
+		})
+
+		at.Nil(h.run(context.Background(), Event{Name: EventLogin, UserID: 1}))
+		at.Equal([]int{1, 2}, calls)
+
+		select {
+		case e := <-async:
+			at.Equal(1, e.UserID)
+		case <-time.After(time.Second):
+			at.Fail("async hook is not called")
+		}
+	})
+
+	t.Run("stop at error", func(t *testing.T) {
+		var (
+			h       Hooks
+			called  bool
+			fakeErr = errors.New("fake error")
+		)
+
+		h.OnLogout(func(ctx context.Context, e Event) error {
+			return fakeErr
+		})
+		h.OnLogout(func(ctx context.Context, e Event) error {
+			called = true
+			return nil
+		})
+		h.OnLogout(func(ctx context.Context, e Event) error {
+			called = true
+			return nil
+		}, Async())
+
+		at.Equal(fakeErr, h.run(context.Background(), Event{Name: EventLogout}))
+		at.False(called)
+	})
+}
+
+func Test_Auth_Hooks_Registered(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	t.Run("in transaction", func(t *testing.T) {
+		var (
+			h     Hooks
+			async = make(chan Event, 1)
+		)
+
+		repo := getRepo(t)
+		repo.hooks = &h
+
+		h.OnRegistered(func(ctx context.Context, e Event) error {
+			return e.Tx.Create(&auditEvent{Action: "profile", UserID: uint(e.UserID)}).Error
+		})
+		h.OnRegistered(func(ctx context.Context, e Event) error {
+			async <- e
+			return nil
+		}, Async())
+
+		id, err := repo.RegisterByMobile("13600008888")
+		at.Nil(err)
+
+		var ae auditEvent
+		at.Nil(repo.db.First(&ae).Error)
+		at.Equal(uint(id), ae.UserID)
+
+		select {
+		case e := <-async:
+			at.Equal(EventRegistered, e.Name)
+			at.Equal(id, e.UserID)
+			at.Equal("mobile", e.Type)
+			at.Equal("13600008888", e.Identity)
+			at.Nil(e.Tx)
+		case <-time.After(time.Second):
+			at.Fail("async hook is not called")
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		var (
+			h       Hooks
+			fakeErr = errors.New("fake error")
+		)
+
+		repo := getRepo(t)
+		repo.hooks = &h
+
+		h.OnRegistered(func(ctx context.Context, e Event) error {
+			return fakeErr
+		})
+
+		_, err := repo.RegisterByPassword("kiyon", "pass")
+		at.Equal(fakeErr, err)
+
+		var count int64
+		at.Nil(repo.db.Model(&user{}).Count(&count).Error)
+		at.Equal(int64(0), count)
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		var (
+			h      Hooks
+			called bool
+		)
+
+		repo := getRepo(t)
+		repo.hooks = &h
+		repo.createUser(t, "kiyon", "pass")
+
+		h.OnRegistered(func(ctx context.Context, e Event) error {
+			called = true
+			return nil
+		})
+
+		_, err := repo.RegisterByPassword("kiyon", "pass")
+		at.True(errors.Is(err, ErrDuplicate))
+		at.False(called)
+	})
+}
+
+func Test_Auth_Hooks_PasswordChanged(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	t.Run("in transaction", func(t *testing.T) {
+		var (
+			h     Hooks
+			async = make(chan Event, 3)
+		)
+
+		repo := getRepo(t)
+		repo.hooks = &h
+		u := repo.createUser(t, "kiyon", "pass")
+
+		h.OnPasswordChanged(func(ctx context.Context, e Event) error {
+			return e.Tx.Create(&auditEvent{Action: "password", UserID: uint(e.UserID)}).Error
+		})
+		h.OnPasswordChanged(func(ctx context.Context, e Event) error {
+			async <- e
+			return nil
+		}, Async())
+
+		at.Nil(repo.ChangePassword(int(u.ID), "pass", "new"))
+		at.Nil(repo.SetPassword(int(u.ID), "newer"))
+		at.Nil(repo.ResetPassword(int(u.ID)))
+
+		var count int64
+		at.Nil(repo.db.Model(&auditEvent{}).Where("action = ?", "password").Count(&count).Error)
+		at.Equal(int64(3), count)
+
+		types := map[string]bool{}
+		for i := 0; i < 3; i++ {
+			select {
+			case e := <-async:
+				at.Equal(EventPasswordChanged, e.Name)
+				at.Equal(int(u.ID), e.UserID)
+				at.Nil(e.Tx)
+				types[e.Type] = true
+			case <-time.After(time.Second):
+				at.Fail("async hook is not called")
+			}
+		}
+		at.Equal(map[string]bool{"change": true, "set": true, "reset": true}, types)
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		var (
+			h       Hooks
+			fakeErr = errors.New("fake error")
+		)
+
+		repo := getRepo(t)
+		repo.hooks = &h
+		u := repo.createUser(t, "kiyon", "pass")
+
+		h.OnPasswordChanged(func(ctx context.Context, e Event) error {
+			return fakeErr
+		})
+
+		at.Equal(fakeErr, repo.ChangePassword(int(u.ID), "pass", "new"))
+
+		_, err := repo.LoginByPassword("kiyon", "pass")
+		at.Nil(err)
+	})
+}
+
+func Test_Auth_Hooks_Routes(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	var (
+		h       Hooks
+		events  = make(chan Event, 10)
+		fakeErr = fiber.NewError(fiber.StatusTooManyRequests)
+	)
+
+	record := func(ctx context.Context, e Event) error {
+		events <- e
+		if e.Identity == "blocked" || e.UserID == 2 {
+			return fakeErr
+		}
+		return nil
+	}
+
+	h.OnLogin(record)
+	h.OnLoginFailed(record)
+	h.OnLogout(record)
+
+	m, mockService := routeModule()
+	m.Hooks = &h
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		app.Post("/login", m.login)
+		app.Use(m.jwt())
+		app.Post("/logout", m.logout)
+	})
+
+	next := func() Event {
+		select {
+		case e := <-events:
+			return e
+		default:
+			at.Fail("hook is not called")
+			return Event{}
+		}
+	}
+
+	t.Run("login", func(t *testing.T) {
+		mockService.On("LoginByPassword", "kiyon", "pass").
+			Once().Return(1, nil).
+			On("User", 1).
+			Once().Return(User{ID: 1}, nil)
+
+		e.POST("/login").
+			WithJSON(loginForm{Username: "kiyon", Code: "pass", Type: "password"}).
+			Expect().
+			Status(fiber.StatusOK)
+
+		ev := next()
+		at.Equal(EventLogin, ev.Name)
+		at.Equal(1, ev.UserID)
+		at.Equal("password", ev.Type)
+		at.Equal("kiyon", ev.Identity)
+		at.NotEmpty(ev.IP)
+	})
+
+	t.Run("login rejected by hook", func(t *testing.T) {
+		mockService.On("LoginByPassword", "kiyon", "pass").
+			Once().Return(2, nil).
+			On("User", 2).
+			Once().Return(User{ID: 2}, nil)
+
+		e.POST("/login").
+			WithJSON(loginForm{Username: "kiyon", Code: "pass", Type: "password"}).
+			Expect().
+			Status(fiber.StatusTooManyRequests)
+
+		at.Equal(EventLogin, next().Name)
+	})
+
+	t.Run("login failed", func(t *testing.T) {
+		mockService.On("LoginByPassword", "kiyon", "wrong").
+			Once().Return(0, ErrInvalidCredentials)
+
+		e.POST("/login").
+			WithJSON(loginForm{Username: "kiyon", Code: "wrong", Type: "password"}).
+			Expect().
+			Status(fiber.StatusUnauthorized)
+
+		ev := next()
+		at.Equal(EventLoginFailed, ev.Name)
+		at.Equal(0, ev.UserID)
+		at.Equal(ErrInvalidCredentials, ev.Err)
+	})
+
+	t.Run("login failed by disabled user", func(t *testing.T) {
+		now := time.Now()
+		mockService.On("LoginByPassword", "kiyon", "pass").
+			Once().Return(1, nil).
+			On("User", 1).
+			Once().Return(User{ID: 1, DisabledAt: &now}, nil)
+
+		e.POST("/login").
+			WithJSON(loginForm{Username: "kiyon", Code: "pass", Type: "password"}).
+			Expect().
+			Status(fiber.StatusForbidden)
+
+		ev := next()
+		at.Equal(EventLoginFailed, ev.Name)
+		at.Equal(1, ev.UserID)
+		at.Equal(ErrUserDisabled, ev.Err)
+	})
+
+	t.Run("login failed hook error", func(t *testing.T) {
+		mockService.On("LoginByPassword", "blocked", "pass").
+			Once().Return(0, ErrInvalidCredentials)
+
+		e.POST("/login").
+			WithJSON(loginForm{Username: "blocked", Code: "pass", Type: "password"}).
+			Expect().
+			Status(fiber.StatusTooManyRequests)
+
+		at.Equal(EventLoginFailed, next().Name)
+	})
+
+	token, err := generateToken("", m.SigningKey, 1, time.Hour)
+	at.Nil(err)
+
+	t.Run("logout", func(t *testing.T) {
+		resp := e.POST("/logout").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+token).
+			Expect().
+			Status(fiber.StatusOK)
+
+		deck.AssertRespMsg(resp, "Logged out")
+
+		ev := next()
+		at.Equal(EventLogout, ev.Name)
+		at.Equal(1, ev.UserID)
+	})
+
+	t.Run("hook errors", func(t *testing.T) {
+		token, err := generateToken("", m.SigningKey, 2, time.Hour)
+		at.Nil(err)
+
+		e.POST("/logout").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+token).
+			Expect().
+			Status(fiber.StatusTooManyRequests)
+		at.Equal(EventLogout, next().Name)
+	})
+
+	at.True(mockService.AssertExpectations(t))
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/go-dawn/dawn/fiberx"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrNestedImpersonation occurs when an impersonation token
+// is used to impersonate another user
+var ErrNestedImpersonation = errors.New("auth: nested impersonation is not allowed")
+
+// ErrImpersonateAdmin occurs when an admin tries to impersonate
+// another admin, whose privileges must not be borrowed
+var ErrImpersonateAdmin = errors.New("auth: admins can't be impersonated")
+
+const actionImpersonate = "impersonate"
+
+func (m module) impersonate(c *fiber.Ctx) (err error) {
+	var (
+		id      int
+		actorID = UserID(c)
+		u       User
+		t       string
+	)
+
+	if id, err = userIDParam(c); err != nil {
+		return
+	}
+
+	if _, ok := claims(c)["act"]; ok {
+		return fiberx.CodeErr(fiber.StatusForbidden, ErrNestedImpersonation, "Nested impersonation")
+	}
+
+	s := m.service(c)
+
+	if u, err = s.UserCtx(UserContext(c), id); err != nil {
+		return errResp(c, err)
+	}
+
+	if u.Disabled() {
+		return errResp(c, ErrUserDisabled)
+	}
+
+	if u.Role != "" && u.Role == m.AdminRole {
+		return fiberx.CodeErr(fiber.StatusForbidden, ErrImpersonateAdmin, "Admin impersonation")
+	}
+
+	// Token is issued only if the audit event is stored
+	if err = s.AddAuditEventCtx(UserContext(c), AuditEvent{
+		Action:  actionImpersonate,
+		ActorID: actorID,
+		UserID:  id,
+		IP:      c.IP(),
+	}); err != nil {
+		return
+	}
+
+	if t, err = generateToken(m.SigningMethod, m.SigningKey, id, m.ImpersonationExpiration,
+		userClaims(u), tenantClaims(c), actorClaims(actorID)); err != nil {
+		return
+	}
+
+	return fiberx.Data(c, t)
+}
+
+// RealUserID gets id of the user who really sends current
+// request, it's the actor when impersonating, otherwise
+// it's the same with UserID
+func RealUserID(c *fiber.Ctx) int {
+	if act, ok := claims(c)["act"].(map[string]interface{}); ok {
+		if id, ok := act["sub"].(float64); ok {
+			return int(id)
+		}
+	}
+	return UserID(c)
+}
+
+func actorClaims(actorID int) jwt.MapClaims {
+	return jwt.MapClaims{"act": map[string]interface{}{"sub": actorID}}
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/go-dawn/dawn/fiberx"
+	"github.com/go-dawn/pkg/deck"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func Test_Auth_Impersonate(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	m, mockService := routeModule()
+	m.AdminRole = "admin"
+	m.ImpersonationExpiration = time.Minute
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		app.Post("/impersonate/:id", m.jwt(), m.admin(), m.impersonate)
+		app.Get("/whoami", m.jwt(), func(c *fiber.Ctx) error {
+			return fiberx.Data(c, fiber.Map{"user": UserID(c), "real": RealUserID(c)})
+		})
+	})
+
+	token, err := generateToken("", m.SigningKey, 1, time.Hour, jwt.MapClaims{"role": "admin"})
+	at.Nil(err)
+	bearer := "Bearer " + token
+
+	t.Run("invalid user id", func(t *testing.T) {
+		e.POST("/impersonate/x").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusBadRequest)
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		mockService.On("User", 2).
+			Once().Return(User{}, ErrUserNotFound)
+
+		e.POST("/impersonate/2").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusNotFound)
+	})
+
+	t.Run("user disabled", func(t *testing.T) {
+		now := time.Now()
+		mockService.On("User", 2).
+			Once().Return(User{ID: 2, DisabledAt: &now}, nil)
+
+		resp := e.POST("/impersonate/2").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusForbidden)
+
+		deck.AssertRespMsg(resp, "User disabled")
+	})
+
+	t.Run("failed to audit", func(t *testing.T) {
+		mockService.On("User", 2).
+			Once().Return(User{ID: 2}, nil).
+			On("AddAuditEvent", mock.Anything).
+			Once().Return(errors.New("fake error"))
+
+		e.POST("/impersonate/2").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusInternalServerError)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		mockService.On("User", 2).
+			Once().Return(User{ID: 2}, nil).
+			On("AddAuditEvent", mock.MatchedBy(func(e AuditEvent) bool {
+				return e.Action == "impersonate" && e.ActorID == 1 && e.UserID == 2 && e.IP != ""
+			})).
+			Once().Return(nil)
+
+		raw := e.POST("/impersonate/2").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusOK).
+			JSON().Object().Value("data").String().Raw()
+
+		token, err := jwt.Parse(raw, func(*jwt.Token) (interface{}, error) {
+			return []byte(m.SigningKey), nil
+		})
+		at.Nil(err)
+
+		claims := token.Claims.(jwt.MapClaims)
+		at.Equal(float64(2), claims["id"])
+		at.Equal(map[string]interface{}{"sub": float64(1)}, claims["act"])
+		at.InDelta(time.Now().Add(time.Minute).Unix(), claims["exp"], 5)
+
+		obj := e.GET("/whoami").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+raw).
+			Expect().
+			Status(fiber.StatusOK).
+			JSON().Object().Value("data").Object()
+		obj.Value("user").Equal(2)
+		obj.Value("real").Equal(1)
+	})
+
+	t.Run("nested impersonation", func(t *testing.T) {
+		token, err := generateToken("", m.SigningKey, 2, time.Hour,
+			jwt.MapClaims{"role": "admin"}, actorClaims(1))
+		at.Nil(err)
+
+		resp := e.POST("/impersonate/3").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+token).
+			Expect().
+			Status(fiber.StatusForbidden)
+
+		deck.AssertRespMsg(resp, "Nested impersonation")
+	})
+
+	t.Run("admin", func(t *testing.T) {
+		mockService.On("User", 4).
+			Once().Return(User{ID: 4, Role: "admin"}, nil)
+
+		resp := e.POST("/impersonate/4").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusForbidden)
+
+		deck.AssertRespMsg(resp, "Admin impersonation")
+	})
+
+	t.Run("real user", func(t *testing.T) {
+		obj := e.GET("/whoami").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusOK).
+			JSON().Object().Value("data").Object()
+		obj.Value("user").Equal(1)
+		obj.Value("real").Equal(1)
+	})
+}
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrUnexpectedSigningMethod occurs when the token is not signed
+// by the configured signing method
+var ErrUnexpectedSigningMethod = errors.New("auth: unexpected signing method")
+
+// introspection is the response of token introspection(RFC 7662),
+// inactive tokens only have the active field. Scope is set when the
+// token carries it, client_id defaults to the introspection client
+type introspection struct {
+	Active   bool   `json:"active"`
+	Sub      string `json:"sub,omitempty"`
+	Exp      int64  `json:"exp,omitempty"`
+	Iat      int64  `json:"iat,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+}
+
+// introspect tells clients whether the token is active, tokens
+// which are expired, revoked or issued for other tenants are not.
+// Responses follow RFC 7662 instead of the response envelope
+func (m module) introspect(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	client, ok := m.introspectionClient(c)
+	if !ok {
+		c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="auth"`)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid_client"})
+	}
+
+	raw := c.FormValue("token")
+	if raw == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid_request"})
+	}
+
+	res, err := m.inspect(UserContext(c), Tenant(c), raw)
+	if err != nil {
+		return err
+	}
+
+	if res.Active && res.ClientID == "" {
+		res.ClientID = client
+	}
+
+	return c.JSON(res)
+}
+
+// inspect checks the token, errors are only returned when the
+// revocation state can't be read
+func (m module) inspect(ctx context.Context, tenant, raw string) (res introspection, err error) {
+	token, err := jwt.Parse(raw, m.keyFunc)
+	if err != nil || !token.Valid {
+		return res, nil
+	}
+
+	cl := token.Claims.(jwt.MapClaims)
+
+	if t, _ := cl["tenant"].(string); t != tenant {
+		return
+	}
+
+	id, ok := cl["id"].(float64)
+	if !ok {
+		return
+	}
+
+	if err = m.checkRevoked(ctx, tenant, cl); err != nil {
+		if errors.Is(err, ErrTokenRevoked) {
+			err = nil
+		}
+		return
+	}
+
+	exp, _ := cl["exp"].(float64)
+	iat, _ := cl["iat"].(float64)
+
+	res = introspection{
+		Active: true,
+		Sub:    strconv.Itoa(int(id)),
+		Exp:    int64(exp),
+		Iat:    int64(iat),
+	}
+	res.Scope, _ = cl["scope"].(string)
+	res.ClientID, _ = cl["client_id"].(string)
+
+	return
+}
+
+// keyFunc gets the key of tokens signed by SigningMethod
+func (m module) keyFunc(t *jwt.Token) (interface{}, error) {
+	if t.Method.Alg() != signingMethod(m.SigningMethod).Alg() {
+		return nil, ErrUnexpectedSigningMethod
+	}
+	return []byte(m.SigningKey), nil
+}
+
+// introspectionClient authenticates the client by basic auth, or
+// client_id and client_secret in the form, and gets its id
+func (m module) introspectionClient(c *fiber.Ctx) (string, bool) {
+	id, secret, ok := basicAuth(c.Get(fiber.HeaderAuthorization))
+	if !ok {
+		id, secret = c.FormValue("client_id"), c.FormValue("client_secret")
+	}
+
+	expected, found := m.IntrospectionClients[id]
+	if id == "" || !found || expected == "" {
+		return "", false
+	}
+
+	return id, subtle.ConstantTimeCompare([]byte(secret), []byte(expected)) == 1
+}
+
+// basicAuth parses credentials of basic auth, client id and secret
+// are form-urlencoded before encoding as RFC 6749 requires
+func basicAuth(header string) (id, secret string, ok bool) {
+	const prefix = "basic "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return
+	}
+
+	b, err := base64.StdEncoding.DecodeString(header[len(prefix):])
+	if err != nil {
+		return
+	}
+
+	parts := strings.SplitN(string(b), ":", 2)
+	if len(parts) != 2 {
+		return
+	}
+
+	if id, err = url.QueryUnescape(parts[0]); err != nil {
+		return
+	}
+
+	if secret, err = url.QueryUnescape(parts[1]); err != nil {
+		return
+	}
+
+	return id, secret, true
+}
@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"encoding/base64"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/go-dawn/module/cache"
+	"github.com/go-dawn/pkg/deck"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Auth_Introspect(t *testing.T) {
+	at := assert.New(t)
+
+	m, _ := routeModule()
+	m.IntrospectionClients = map[string]string{"api": "secret", "empty": ""}
+
+	cache.New().Init()
+	m.Storage = cache.Storage()
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		app.Post("/introspect", m.introspect)
+	})
+
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("api:secret"))
+
+	token, err := generateToken("", m.SigningKey, 50, time.Hour)
+	at.Nil(err)
+
+	t.Run("invalid client", func(t *testing.T) {
+		for _, auth := range []string{
+			"",
+			"Basic " + base64.StdEncoding.EncodeToString([]byte("api:wrong")),
+			"Basic " + base64.StdEncoding.EncodeToString([]byte("empty:")),
+			"Basic " + base64.StdEncoding.EncodeToString([]byte("api")),
+			"Basic !",
+			"Bearer " + token,
+		} {
+			resp := e.POST("/introspect").
+				WithHeader(fiber.HeaderAuthorization, auth).
+				WithFormField("token", token).
+				Expect().
+				Status(fiber.StatusUnauthorized)
+
+			resp.Header(fiber.HeaderWWWAuthenticate).NotEmpty()
+			resp.JSON().Object().ValueEqual("error", "invalid_client")
+		}
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		e.POST("/introspect").
+			WithHeader(fiber.HeaderAuthorization, basic).
+			Expect().
+			Status(fiber.StatusBadRequest).
+			JSON().Object().ValueEqual("error", "invalid_request")
+	})
+
+	t.Run("active", func(t *testing.T) {
+		resp := e.POST("/introspect").
+			WithHeader(fiber.HeaderAuthorization, basic).
+			WithFormField("token", token).
+			Expect().
+			Status(fiber.StatusOK)
+
+		resp.Header(fiber.HeaderCacheControl).Equal("no-store")
+
+		obj := resp.JSON().Object()
+		obj.ValueEqual("active", true)
+		obj.ValueEqual("sub", "50")
+		obj.NotContainsKey("scope")
+		obj.ValueEqual("client_id", "api")
+		obj.Value("exp").Number().Gt(time.Now().Unix())
+	})
+
+	t.Run("scope and client claims", func(t *testing.T) {
+		token, err := generateToken("", m.SigningKey, 50, time.Hour, jwt.MapClaims{"scope": "read write", "client_id": "web"})
+		at.Nil(err)
+
+		obj := e.POST("/introspect").
+			WithHeader(fiber.HeaderAuthorization, basic).
+			WithFormField("token", token).
+			Expect().
+			Status(fiber.StatusOK).
+			JSON().Object()
+
+		obj.ValueEqual("active", true)
+		obj.ValueEqual("scope", "read write")
+		obj.ValueEqual("client_id", "web")
+	})
+
+	t.Run("client credentials in form", func(t *testing.T) {
+		e.POST("/introspect").
+			WithFormField("client_id", "api").
+			WithFormField("client_secret", "secret").
+			WithFormField("token", token).
+			Expect().
+			Status(fiber.StatusOK).
+			JSON().Object().ValueEqual("active", true)
+	})
+
+	inactive := func(t *testing.T, token string) {
+		obj := e.POST("/introspect").
+			WithHeader(fiber.HeaderAuthorization, basic).
+			WithFormField("token", token).
+			Expect().
+			Status(fiber.StatusOK).
+			JSON().Object()
+
+		obj.ValueEqual("active", false)
+		obj.NotContainsKey("sub")
+		obj.NotContainsKey("client_id")
+	}
+
+	t.Run("malformed", func(t *testing.T) {
+		inactive(t, "malformed")
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		token, err := generateToken("", m.SigningKey, 50, -time.Minute)
+		at.Nil(err)
+		inactive(t, token)
+	})
+
+	t.Run("signed by other key", func(t *testing.T) {
+		token, err := generateToken("", "other", 50, time.Hour)
+		at.Nil(err)
+		inactive(t, token)
+	})
+
+	t.Run("other signing method", func(t *testing.T) {
+		token, err := generateToken("HS512", m.SigningKey, 50, time.Hour)
+		at.Nil(err)
+		inactive(t, token)
+	})
+
+	t.Run("other tenant", func(t *testing.T) {
+		token, err := generateToken("", m.SigningKey, 50, time.Hour, jwt.MapClaims{"tenant": "acme"})
+		at.Nil(err)
+		inactive(t, token)
+	})
+
+	t.Run("missing id", func(t *testing.T) {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}).SignedString([]byte(m.SigningKey))
+		at.Nil(err)
+		inactive(t, token)
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		later := strconv.FormatInt(time.Now().Add(time.Minute).UnixNano()/int64(time.Millisecond), 10)
+		at.Nil(m.Storage.Set(revokedKey("", 50), []byte(later), time.Minute))
+		inactive(t, token)
+	})
+
+	t.Run("revocation state error", func(t *testing.T) {
+		at.Nil(m.Storage.Set(revokedKey("", 50), []byte("x"), time.Minute))
+
+		e.POST("/introspect").
+			WithHeader(fiber.HeaderAuthorization, basic).
+			WithFormField("token", token).
+			Expect().
+			Status(fiber.StatusInternalServerError)
+	})
+}
+
+func Test_Auth_BasicAuth(t *testing.T) {
+	at := assert.New(t)
+
+	id, secret, ok := basicAuth("basic " + base64.StdEncoding.EncodeToString([]byte("my%20client:p%3Ass")))
+	at.True(ok)
+	at.Equal("my client", id)
+	at.Equal("p:ss", secret)
+
+	_, _, ok = basicAuth("Basic " + base64.StdEncoding.EncodeToString([]byte("%zz:secret")))
+	at.False(ok)
+
+	_, _, ok = basicAuth("Basic " + base64.StdEncoding.EncodeToString([]byte("id:%zz")))
+	at.False(ok)
+}
@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-dawn/dawn/fiberx"
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	// ErrInvitationUnavailable occurs when sender or url
+	// of invitations is not available
+	ErrInvitationUnavailable = errors.New("auth: invitation is not available")
+
+	// ErrInvalidInvitation occurs when invitation token is invalid,
+	// expired, revoked or has been accepted
+	ErrInvalidInvitation = errors.New("auth: invalid or expired invitation")
+
+	// ErrInvitationNotFound occurs when the invitation doesn't
+	// exist or is not pending any more
+	ErrInvitationNotFound = errors.New("auth: invitation not found")
+
+	// ErrInvitationRequired occurs when registering without
+	// an invitation in invite only mode
+	ErrInvitationRequired = errors.New("auth: invitation is required")
+
+	// ErrEmailLocked occurs when the email of registration
+	// doesn't match the invitation
+	ErrEmailLocked = errors.New("auth: email is locked by the invitation")
+
+	// ErrInvalidInvitationID occurs when invitation id in path is invalid
+	ErrInvalidInvitationID = errors.New("auth: invalid invitation id")
+)
+
+type registerForm struct {
+	// Username is account username
+	Username string `json:"username" validate:"required"`
+	// Password is account password
+	Password string `json:"password" validate:"required"`
+	// Email must be the same with the invitation if it's set
+	Email string `json:"email" validate:"omitempty,email"`
+	// Invite is the token of the invitation
+	Invite string `json:"invite"`
+}
+
+func (m module) register(c *fiber.Ctx) (err error) {
+	var (
+		data registerForm
+		id   int
+		u    User
+		t    string
+	)
+
+	if err = fiberx.ValidateBody(c, &data); err != nil {
+		return
+	}
+
+	s := m.service(c)
+
+	if id, err = m.registerFunc(UserContext(c), s, data); err != nil {
+		return errResp(c, err)
+	}
+
+	if u, err = s.UserCtx(UserContext(c), id); err != nil {
+		return errResp(c, err)
+	}
+
+	if t, err = generateToken(m.SigningMethod, m.SigningKey, id, m.Expiration, userClaims(u), tenantClaims(c)); err != nil {
+		return
+	}
+
+	return fiberx.Data(c, t)
+}
+
+// registerFunc registers by the invitation if the token is set,
+// otherwise by username and password when it's allowed
+func (m module) registerFunc(ctx context.Context, s ContextAccountService, data registerForm) (int, error) {
+	if data.Invite == "" {
+		if !m.OpenRegistration {
+			return 0, ErrInvitationRequired
+		}
+		return s.RegisterByPasswordCtx(ctx, data.Username, data.Password)
+	}
+
+	i, err := s.InvitationByTokenCtx(ctx, data.Invite)
+	if err != nil {
+		return 0, err
+	}
+
+	if data.Email != "" && !strings.EqualFold(data.Email, i.Email) {
+		return 0, ErrEmailLocked
+	}
+
+	return s.RegisterByInvitationCtx(ctx, data.Invite, data.Username, data.Password)
+}
+
+// registerInvitation gets the invitation to pre-fill the registration
+func (m module) registerInvitation(c *fiber.Ctx) error {
+	i, err := m.service(c).InvitationByTokenCtx(UserContext(c), c.Query("token"))
+	if err != nil {
+		return errResp(c, err)
+	}
+
+	return fiberx.Data(c, i)
+}
+
+type invitationForm struct {
+	// Email is the address which receives the invitation
+	Email string `json:"email" validate:"required,email"`
+	// Role is granted to the registered user
+	Role string `json:"role"`
+}
+
+func (m module) adminInvite(c *fiber.Ctx) (err error) {
+	var (
+		data  invitationForm
+		i     Invitation
+		token string
+	)
+
+	if err = fiberx.ValidateBody(c, &data); err != nil {
+		return
+	}
+
+	if m.Sender == nil || m.InvitationURL == "" {
+		return fiberx.Err(ErrInvitationUnavailable)
+	}
+
+	if i, token, err = m.service(c).InviteCtx(UserContext(c), Invitation{
+		Email:     data.Email,
+		Role:      data.Role,
+		InviterID: RealUserID(c),
+		ExpiresAt: time.Now().Add(m.InvitationTTL),
+	}); err != nil {
+		return errResp(c, err)
+	}
+
+	if err = m.Sender.Send(i.Email, m.invitationLink(token)); err != nil {
+		return
+	}
+
+	return fiberx.Data(c, i)
+}
+
+func (m module) adminResendInvitation(c *fiber.Ctx) (err error) {
+	var (
+		id    int
+		i     Invitation
+		token string
+	)
+
+	if id, err = invitationIDParam(c); err != nil {
+		return
+	}
+
+	if m.Sender == nil || m.InvitationURL == "" {
+		return fiberx.Err(ErrInvitationUnavailable)
+	}
+
+	if i, token, err = m.service(c).ResendInvitationCtx(UserContext(c), id, time.Now().Add(m.InvitationTTL)); err != nil {
+		return errResp(c, err)
+	}
+
+	if err = m.Sender.Send(i.Email, m.invitationLink(token)); err != nil {
+		return
+	}
+
+	return fiberx.Data(c, i)
+}
+
+func (m module) adminRevokeInvitation(c *fiber.Ctx) (err error) {
+	var id int
+
+	if id, err = invitationIDParam(c); err != nil {
+		return
+	}
+
+	if err = m.service(c).RevokeInvitationCtx(UserContext(c), id); err != nil {
+		return errResp(c, err)
+	}
+
+	return fiberx.Message(c, "Invitation revoked")
+}
+
+// invitationLink builds the registration link with token
+func (m module) invitationLink(token string) string {
+	q := url.Values{}
+	q.Set("token", token)
+
+	sep := "?"
+	if strings.Contains(m.InvitationURL, "?") {
+		sep = "&"
+	}
+
+	return m.InvitationURL + sep + q.Encode()
+}
+
+func invitationIDParam(c *fiber.Ctx) (int, error) {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		return 0, fiberx.CodeErr(fiber.StatusBadRequest, ErrInvalidInvitationID, "Invalid invitation id")
+	}
+	return id, nil
+}
@@ -0,0 +1,304 @@
+package auth
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/gavv/httpexpect/v2"
+	"github.com/go-dawn/pkg/deck"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func Test_Auth_Register(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	m, mockService := routeModule()
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		app.Post("/register", m.register)
+		app.Get("/register/invitation", m.registerInvitation)
+	})
+
+	invitation := Invitation{ID: 1, Email: "kiyon@example.com", Role: "editor"}
+
+	t.Run("bad request", func(t *testing.T) {
+		e.POST("/register").
+			Expect().
+			Status(fiber.StatusBadRequest)
+	})
+
+	t.Run("invite only", func(t *testing.T) {
+		resp := e.POST("/register").
+			WithJSON(registerForm{Username: "kiyon", Password: "pass"}).
+			Expect().
+			Status(fiber.StatusForbidden)
+
+		deck.AssertRespCode(resp, CodeInvitationRequired)
+	})
+
+	m.OpenRegistration = true
+
+	t.Run("open registration", func(t *testing.T) {
+		mockService.On("RegisterByPassword", "kiyon", "pass").
+			Once().Return(1, nil).
+			On("User", 1).
+			Once().Return(User{ID: 1}, nil)
+
+		e.POST("/register").
+			WithJSON(registerForm{Username: "kiyon", Password: "pass"}).
+			Expect().
+			Status(fiber.StatusOK)
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		mockService.On("RegisterByPassword", "kiyon", "pass").
+			Once().Return(0, &DuplicateError{Field: "username"})
+
+		resp := e.POST("/register").
+			WithJSON(registerForm{Username: "kiyon", Password: "pass"}).
+			Expect().
+			Status(fiber.StatusConflict)
+
+		deck.AssertRespCode(resp, CodeDuplicate)
+	})
+
+	t.Run("invitation", func(t *testing.T) {
+		mockService.On("InvitationByToken", "token").
+			Once().Return(invitation, nil).
+			On("RegisterByInvitation", "token", "kiyon", "pass").
+			Once().Return(2, nil).
+			On("User", 2).
+			Once().Return(User{ID: 2, Email: invitation.Email, Role: "editor"}, nil)
+
+		resp := e.POST("/register").
+			WithJSON(registerForm{Username: "kiyon", Password: "pass", Email: "Kiyon@example.com", Invite: "token"}).
+			Expect().
+			Status(fiber.StatusOK)
+
+		raw := resp.JSON().Object().Value("data").String().Raw()
+		token, err := jwt.Parse(raw, func(*jwt.Token) (interface{}, error) {
+			return []byte(m.SigningKey), nil
+		})
+		at.Nil(err)
+		at.Equal("editor", token.Claims.(jwt.MapClaims)["role"])
+	})
+
+	t.Run("email locked", func(t *testing.T) {
+		mockService.On("InvitationByToken", "token").
+			Once().Return(invitation, nil)
+
+		resp := e.POST("/register").
+			WithJSON(registerForm{Username: "kiyon", Password: "pass", Email: "other@example.com", Invite: "token"}).
+			Expect().
+			Status(fiber.StatusBadRequest)
+
+		deck.AssertRespCode(resp, CodeEmailLocked)
+	})
+
+	t.Run("invalid invitation", func(t *testing.T) {
+		mockService.On("InvitationByToken", "invalid").
+			Twice().Return(Invitation{}, ErrInvalidInvitation)
+
+		resp := e.POST("/register").
+			WithJSON(registerForm{Username: "kiyon", Password: "pass", Invite: "invalid"}).
+			Expect().
+			Status(fiber.StatusBadRequest)
+
+		deck.AssertRespCode(resp, CodeInvalidInvitation)
+
+		resp = e.GET("/register/invitation").
+			WithQuery("token", "invalid").
+			Expect().
+			Status(fiber.StatusBadRequest)
+
+		deck.AssertRespCode(resp, CodeInvalidInvitation)
+	})
+
+	t.Run("pre-fill", func(t *testing.T) {
+		mockService.On("InvitationByToken", "token").
+			Once().Return(invitation, nil)
+
+		resp := e.GET("/register/invitation").
+			WithQuery("token", "token").
+			Expect().
+			Status(fiber.StatusOK)
+
+		deck.AssertRespDataCheck(resp, func(v *httpexpect.Value) {
+			v.Object().Value("email").Equal(invitation.Email)
+			v.Object().Value("role").Equal("editor")
+		})
+	})
+
+	at.True(mockService.AssertExpectations(t))
+}
+
+func Test_Auth_Admin_Invitation(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	m, mockService := routeModule()
+	m.AdminRole = "admin"
+	m.InvitationTTL = time.Hour
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		admin := app.Group("/admin", m.jwt(), m.admin())
+		admin.Post("/invitations", m.adminInvite)
+		admin.Delete("/invitations/:id", m.adminRevokeInvitation)
+		admin.Post("/invitations/:id/resend", m.adminResendInvitation)
+	})
+
+	token, err := generateToken("", m.SigningKey, 1, time.Hour, jwt.MapClaims{"role": "admin"})
+	at.Nil(err)
+	bearer := "Bearer " + token
+
+	email := "kiyon@example.com"
+	form := invitationForm{Email: email, Role: "editor"}
+
+	t.Run("unavailable", func(t *testing.T) {
+		e.POST("/admin/invitations").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			WithJSON(form).
+			Expect().
+			Status(fiber.StatusInternalServerError)
+
+		e.POST("/admin/invitations/1/resend").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusInternalServerError)
+	})
+
+	sender := &fakeSender{}
+	m.Sender = sender
+	m.InvitationURL = "https://example.com/register?from=invitation"
+
+	t.Run("invite", func(t *testing.T) {
+		mockService.On("Invite", mock.MatchedBy(func(i Invitation) bool {
+			return i.Email == email && i.Role == "editor" && i.InviterID == 1 &&
+				time.Until(i.ExpiresAt) > time.Minute*59
+		})).
+			Once().Return(Invitation{ID: 1, Email: email, Role: "editor"}, "token", nil)
+
+		resp := e.POST("/admin/invitations").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			WithJSON(form).
+			Expect().
+			Status(fiber.StatusOK)
+
+		deck.AssertRespDataCheck(resp, func(v *httpexpect.Value) {
+			v.Object().Value("id").Equal(1)
+			v.Object().NotContainsKey("token_hash")
+		})
+
+		at.Equal(email, sender.address)
+
+		u, err := url.Parse(sender.msg)
+		at.Nil(err)
+		at.Equal("/register", u.Path)
+		at.Equal("invitation", u.Query().Get("from"))
+		at.Equal("token", u.Query().Get("token"))
+	})
+
+	t.Run("invite registered user", func(t *testing.T) {
+		mockService.On("Invite", mock.Anything).
+			Once().Return(Invitation{}, "", &DuplicateError{Field: "email"})
+
+		e.POST("/admin/invitations").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			WithJSON(form).
+			Expect().
+			Status(fiber.StatusConflict)
+	})
+
+	t.Run("invalid email", func(t *testing.T) {
+		e.POST("/admin/invitations").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			WithJSON(invitationForm{Email: "invalid"}).
+			Expect().
+			Status(fiber.StatusUnprocessableEntity)
+	})
+
+	t.Run("resend", func(t *testing.T) {
+		sender.address, sender.msg = "", ""
+
+		mockService.On("ResendInvitation", 1, mock.AnythingOfType("time.Time")).
+			Once().Return(Invitation{ID: 1, Email: email}, "new", nil)
+
+		e.POST("/admin/invitations/1/resend").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusOK)
+
+		at.Equal(email, sender.address)
+		at.Contains(sender.msg, "token=new")
+	})
+
+	t.Run("failed to send", func(t *testing.T) {
+		sender.err = errors.New("fake error")
+		defer func() {
+			sender.err = nil
+		}()
+
+		mockService.On("ResendInvitation", 1, mock.AnythingOfType("time.Time")).
+			Once().Return(Invitation{ID: 1, Email: email}, "new", nil)
+
+		e.POST("/admin/invitations/1/resend").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusInternalServerError)
+	})
+
+	t.Run("resend settled", func(t *testing.T) {
+		mockService.On("ResendInvitation", 2, mock.AnythingOfType("time.Time")).
+			Once().Return(Invitation{}, "", ErrInvitationNotFound)
+
+		resp := e.POST("/admin/invitations/2/resend").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusNotFound)
+
+		deck.AssertRespCode(resp, CodeInvitationNotFound)
+	})
+
+	t.Run("revoke", func(t *testing.T) {
+		mockService.On("RevokeInvitation", 1).
+			Once().Return(nil).
+			On("RevokeInvitation", 2).
+			Once().Return(ErrInvitationNotFound)
+
+		resp := e.DELETE("/admin/invitations/1").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusOK)
+
+		deck.AssertRespMsg(resp, "Invitation revoked")
+
+		e.DELETE("/admin/invitations/2").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusNotFound)
+	})
+
+	t.Run("invalid invitation id", func(t *testing.T) {
+		resp := e.DELETE("/admin/invitations/x").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusBadRequest)
+
+		deck.AssertRespMsg(resp, "Invalid invitation id")
+
+		e.POST("/admin/invitations/0/resend").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusBadRequest)
+	})
+
+	at.True(mockService.AssertExpectations(t))
+}
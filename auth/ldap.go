@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ErrLDAPUnavailable occurs when url of ldap server is not set
+var ErrLDAPUnavailable = errors.New("auth: ldap is not available")
+
+// strategyLDAP is bound to the module config, so it is used
+// when no strategy is registered with the same name
+const strategyLDAP = "ldap"
+
+// LDAPConfig defines the config for ldap(active directory) login,
+// users are searched by a service account and then bound as
+// themselves to check their passwords
+type LDAPConfig struct {
+	// URL is the address of ldap server, e.g. ldap://127.0.0.1:389
+	// or ldaps://ldap.example.com
+	// Required for ldap login
+	URL string
+
+	// StartTLS upgrades ldap:// connections to tls
+	// Optional. Default: false
+	StartTLS bool
+
+	// InsecureSkipVerify skips verification of server certificates
+	// Optional. Default: false
+	InsecureSkipVerify bool
+
+	// BindDN is the service account to search users
+	// Optional. Default: "" which means anonymous search
+	BindDN string
+
+	// BindPassword is the password of the service account
+	BindPassword string
+
+	// BaseDN is where users are searched from
+	// Required for ldap login
+	BaseDN string
+
+	// Filter finds the user entry, %s is replaced by the
+	// escaped username
+	// Optional. Default: "(uid=%s)"
+	// Active directory: "(sAMAccountName=%s)"
+	Filter string
+
+	// UsernameAttribute is used as username of local users
+	// Optional. Default: "uid"
+	UsernameAttribute string
+
+	// EmailAttribute is used as email of local users
+	// Optional. Default: "mail"
+	EmailAttribute string
+
+	// GroupAttribute lists groups of the user entry
+	// Optional. Default: "memberOf"
+	GroupAttribute string
+
+	// GroupRoles maps group dns to roles of local users,
+	// the first matched group of the user wins and dns
+	// are compared case-insensitively
+	// Optional. Default: nil
+	GroupRoles map[string]string
+
+	// Timeout limits dialing and each request
+	// Optional. Default: 5 seconds
+	Timeout time.Duration
+}
+
+type ldapStrategy struct {
+	LDAPConfig
+}
+
+// NewLDAPStrategy returns a Strategy which authenticates users
+// against a ldap server, entries are linked to local users by
+// their dns with Service.LinkUser
+func NewLDAPStrategy(cfg LDAPConfig) Strategy {
+	if cfg.Filter == "" {
+		cfg.Filter = "(uid=%s)"
+	}
+
+	if cfg.UsernameAttribute == "" {
+		cfg.UsernameAttribute = "uid"
+	}
+
+	if cfg.EmailAttribute == "" {
+		cfg.EmailAttribute = "mail"
+	}
+
+	if cfg.GroupAttribute == "" {
+		cfg.GroupAttribute = "memberOf"
+	}
+
+	if cfg.Timeout == 0 {
+		cfg.Timeout = time.Second * 5
+	}
+
+	return ldapStrategy{cfg}
+}
+
+func (l ldapStrategy) Authenticate(ctx context.Context, s ContextAccountService, username, pass string) (int, error) {
+	if l.URL == "" {
+		return 0, ErrLDAPUnavailable
+	}
+
+	// Binding with an empty password is an unauthenticated
+	// bind which always succeeds
+	if username == "" || pass == "" {
+		return 0, ErrInvalidCredentials
+	}
+
+	i, u, err := l.authenticate(ctx, username, pass)
+	if err != nil {
+		return 0, err
+	}
+
+	return s.LinkUserCtx(ctx, i, u)
+}
+
+// authenticate searches the user and binds as it, the dn
+// of the entry identifies the user
+func (l ldapStrategy) authenticate(ctx context.Context, username, pass string) (i Identity, u User, err error) {
+	conn, err := l.dial()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// Abort pending requests when the context is done
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	defer func() {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+	}()
+
+	if l.BindDN != "" {
+		if err = conn.Bind(l.BindDN, l.BindPassword); err != nil {
+			return
+		}
+	}
+
+	res, err := conn.Search(ldap.NewSearchRequest(
+		l.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(l.Timeout/time.Second), false,
+		fmt.Sprintf(l.Filter, ldap.EscapeFilter(username)),
+		[]string{l.UsernameAttribute, l.EmailAttribute, l.GroupAttribute},
+		nil,
+	))
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+			err = ErrInvalidCredentials
+		}
+		return
+	}
+
+	// Ambiguous filters must not pick a random entry
+	if len(res.Entries) != 1 {
+		return i, u, ErrInvalidCredentials
+	}
+
+	entry := res.Entries[0]
+
+	if err = conn.Bind(entry.DN, pass); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			err = ErrInvalidCredentials
+		}
+		return
+	}
+
+	// Roles are only synced if groups are mapped, otherwise
+	// they are managed locally
+	i = Identity{Provider: strategyLDAP, Subject: entry.DN, SyncRole: len(l.GroupRoles) > 0}
+	u = User{
+		Username: entry.GetAttributeValue(l.UsernameAttribute),
+		Email:    entry.GetAttributeValue(l.EmailAttribute),
+		Role:     l.role(entry.GetAttributeValues(l.GroupAttribute)),
+	}
+
+	if u.Username == "" {
+		u.Username = username
+	}
+
+	return
+}
+
+func (l ldapStrategy) dial() (conn *ldap.Conn, err error) {
+	var u *url.URL
+	if u, err = url.Parse(l.URL); err != nil {
+		return
+	}
+
+	tc := &tls.Config{
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: l.InsecureSkipVerify, //nolint:gosec
+	}
+
+	if conn, err = ldap.DialURL(l.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: l.Timeout}),
+		ldap.DialWithTLSConfig(tc),
+	); err != nil {
+		return
+	}
+
+	conn.SetTimeout(l.Timeout)
+
+	if l.StartTLS {
+		if err = conn.StartTLS(tc); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return
+}
+
+// role gets the role of the first group which is mapped
+func (l ldapStrategy) role(groups []string) string {
+	for _, g := range groups {
+		for dn, role := range l.GroupRoles {
+			if strings.EqualFold(dn, g) {
+				return role
+			}
+		}
+	}
+	return ""
+}
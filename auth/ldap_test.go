@@ -0,0 +1,362 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-dawn/module/auth/mocks"
+	"github.com/go-dawn/pkg/deck"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	ldapBindDN   = "cn=admin,dc=example,dc=com"
+	ldapBaseDN   = "dc=example,dc=com"
+	ldapAdminsDN = "cn=Admins,ou=groups,dc=example,dc=com"
+)
+
+func Test_Auth_LDAP_Strategy(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	srv := newLDAPServer(t)
+
+	strategy := NewLDAPStrategy(LDAPConfig{
+		URL:          srv.url(),
+		BindDN:       ldapBindDN,
+		BindPassword: "secret",
+		BaseDN:       ldapBaseDN,
+		GroupRoles:   map[string]string{strings.ToLower(ldapAdminsDN): "admin"},
+	})
+
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		s := new(mocks.ContextAccountService)
+		s.On("LinkUserCtx", ctx, Identity{Provider: "ldap", Subject: "uid=kiyon,ou=people,dc=example,dc=com", SyncRole: true},
+			User{Username: "kiyon", Email: "kiyon@example.com", Role: "admin"}).
+			Once().Return(1, nil)
+
+		id, err := strategy.Authenticate(ctx, s, "kiyon", "pass")
+		at.Nil(err)
+		at.Equal(1, id)
+
+		s.AssertExpectations(t)
+	})
+
+	t.Run("without role", func(t *testing.T) {
+		s := new(mocks.ContextAccountService)
+		s.On("LinkUserCtx", ctx, Identity{Provider: "ldap", Subject: "uid=bob,ou=people,dc=example,dc=com", SyncRole: true},
+			User{Username: "bob"}).
+			Once().Return(2, nil)
+
+		id, err := strategy.Authenticate(ctx, s, "bob", "pass")
+		at.Nil(err)
+		at.Equal(2, id)
+	})
+
+	t.Run("without group roles", func(t *testing.T) {
+		strategy := NewLDAPStrategy(LDAPConfig{
+			URL:          srv.url(),
+			BindDN:       ldapBindDN,
+			BindPassword: "secret",
+			BaseDN:       ldapBaseDN,
+		})
+
+		// Local roles are kept
+		s := new(mocks.ContextAccountService)
+		s.On("LinkUserCtx", ctx, Identity{Provider: "ldap", Subject: "uid=kiyon,ou=people,dc=example,dc=com"},
+			User{Username: "kiyon", Email: "kiyon@example.com"}).
+			Once().Return(1, nil)
+
+		id, err := strategy.Authenticate(ctx, s, "kiyon", "pass")
+		at.Nil(err)
+		at.Equal(1, id)
+	})
+
+	t.Run("failed to link user", func(t *testing.T) {
+		s := new(mocks.ContextAccountService)
+		s.On("LinkUserCtx", ctx, mock.Anything, mock.Anything).
+			Once().Return(0, ErrUserDisabled)
+
+		_, err := strategy.Authenticate(ctx, s, "bob", "pass")
+		at.True(errors.Is(err, ErrUserDisabled))
+	})
+
+	invalid := []struct {
+		name     string
+		username string
+		pass     string
+	}{
+		{"wrong password", "kiyon", "wrong"},
+		{"unknown user", "nobody", "pass"},
+		{"ambiguous user", "dup", "pass"},
+		{"empty password", "kiyon", ""},
+		{"injected filter", "*", "pass"},
+	}
+
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := strategy.Authenticate(ctx, new(mocks.ContextAccountService), tt.username, tt.pass)
+			at.True(errors.Is(err, ErrInvalidCredentials))
+		})
+	}
+
+	t.Run("service account rejected", func(t *testing.T) {
+		strategy := NewLDAPStrategy(LDAPConfig{
+			URL:          srv.url(),
+			BindDN:       ldapBindDN,
+			BindPassword: "wrong",
+			BaseDN:       ldapBaseDN,
+		})
+
+		_, err := strategy.Authenticate(ctx, new(mocks.ContextAccountService), "kiyon", "pass")
+		at.NotNil(err)
+		at.False(errors.Is(err, ErrInvalidCredentials))
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		_, err := NewLDAPStrategy(LDAPConfig{}).Authenticate(ctx, new(mocks.ContextAccountService), "kiyon", "pass")
+		at.Equal(ErrLDAPUnavailable, err)
+	})
+
+	t.Run("server down", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		at.Nil(err)
+		addr := ln.Addr().String()
+		at.Nil(ln.Close())
+
+		_, err = NewLDAPStrategy(LDAPConfig{URL: "ldap://" + addr, Timeout: time.Second}).
+			Authenticate(ctx, new(mocks.ContextAccountService), "kiyon", "pass")
+		at.NotNil(err)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := strategy.Authenticate(ctx, new(mocks.ContextAccountService), "kiyon", "pass")
+		at.True(errors.Is(err, context.Canceled))
+	})
+}
+
+func Test_Auth_LDAP_Defaults(t *testing.T) {
+	at := assert.New(t)
+
+	s := NewLDAPStrategy(LDAPConfig{}).(ldapStrategy)
+
+	at.Equal("(uid=%s)", s.Filter)
+	at.Equal("uid", s.UsernameAttribute)
+	at.Equal("mail", s.EmailAttribute)
+	at.Equal("memberOf", s.GroupAttribute)
+	at.Equal(time.Second*5, s.Timeout)
+}
+
+func Test_Auth_LDAP_Login(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	srv := newLDAPServer(t)
+
+	m, mockService := routeModule()
+	m.LDAP = LDAPConfig{
+		URL:        srv.url(),
+		BaseDN:     ldapBaseDN,
+		GroupRoles: map[string]string{ldapAdminsDN: "admin"},
+	}
+	m.enableStrategies()
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		app.Post("/login", m.login)
+	})
+
+	mockService.On("LinkUser", Identity{Provider: "ldap", Subject: "uid=kiyon,ou=people,dc=example,dc=com", SyncRole: true},
+		User{Username: "kiyon", Email: "kiyon@example.com", Role: "admin"}).
+		Once().Return(1, nil).
+		On("User", 1).
+		Once().Return(User{ID: 1, Username: "kiyon", Role: "admin"}, nil)
+
+	resp := e.POST("/login").
+		WithJSON(loginForm{Username: "kiyon", Code: "pass", Type: "ldap"}).
+		Expect().
+		Status(fiber.StatusOK)
+
+	raw := resp.JSON().Object().Value("data").String().Raw()
+	token, err := jwt.Parse(raw, func(*jwt.Token) (interface{}, error) {
+		return []byte(m.SigningKey), nil
+	})
+	at.Nil(err)
+	at.Equal("admin", token.Claims.(jwt.MapClaims)["role"])
+
+	e.POST("/login").
+		WithJSON(loginForm{Username: "kiyon", Code: "wrong", Type: "ldap"}).
+		Expect().
+		Status(fiber.StatusUnauthorized)
+
+	mockService.AssertExpectations(t)
+}
+
+// ldapServer is an in-process ldap stand-in which supports
+// simple binds and searches by equality filters
+type ldapServer struct {
+	ln        net.Listener
+	passwords map[string]string
+	entries   []ldapEntry
+}
+
+type ldapEntry struct {
+	dn    string
+	attrs map[string][]string
+}
+
+func newLDAPServer(t *testing.T) *ldapServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	s := &ldapServer{
+		ln: ln,
+		passwords: map[string]string{
+			ldapBindDN:                              "secret",
+			"uid=kiyon,ou=people,dc=example,dc=com": "pass",
+			"uid=bob,ou=people,dc=example,dc=com":   "pass",
+		},
+		entries: []ldapEntry{
+			{"uid=kiyon,ou=people,dc=example,dc=com", map[string][]string{
+				"uid":      {"kiyon"},
+				"mail":     {"kiyon@example.com"},
+				"memberOf": {"cn=staff,ou=groups,dc=example,dc=com", ldapAdminsDN},
+			}},
+			{"uid=bob,ou=people,dc=example,dc=com", map[string][]string{
+				"uid": {"bob"},
+			}},
+			{"uid=dup,ou=people,dc=example,dc=com", map[string][]string{
+				"uid": {"dup"},
+			}},
+			{"uid=dup,ou=others,dc=example,dc=com", map[string][]string{
+				"uid": {"dup"},
+			}},
+		},
+	}
+
+	go s.serve()
+
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+
+	return s
+}
+
+func (s *ldapServer) url() string {
+	return "ldap://" + s.ln.Addr().String()
+}
+
+func (s *ldapServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *ldapServer) handle(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	for {
+		p, err := ber.ReadPacket(conn)
+		if err != nil || len(p.Children) < 2 {
+			return
+		}
+
+		id := p.Children[0].Value.(int64)
+		op := p.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			code := ldap.LDAPResultInvalidCredentials
+			if pass, ok := s.passwords[op.Children[1].Data.String()]; ok && pass == op.Children[2].Data.String() {
+				code = ldap.LDAPResultSuccess
+			}
+			s.write(conn, id, ldapResult(ldap.ApplicationBindResponse, code))
+		case ldap.ApplicationSearchRequest:
+			filter, _ := ldap.DecompileFilter(op.Children[6])
+			for _, e := range s.entries {
+				if e.matches(filter) {
+					s.write(conn, id, e.packet())
+				}
+			}
+			s.write(conn, id, ldapResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+		default:
+			return
+		}
+	}
+}
+
+func (s *ldapServer) write(conn net.Conn, id int64, op *ber.Packet) {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+	p.AppendChild(op)
+	_, _ = conn.Write(p.Bytes())
+}
+
+// matches supports equality filters like (uid=kiyon)
+func (e ldapEntry) matches(filter string) bool {
+	kv := strings.SplitN(strings.Trim(filter, "()"), "=", 2)
+	if len(kv) != 2 {
+		return false
+	}
+
+	for _, v := range e.attrs[kv[0]] {
+		if strings.EqualFold(v, kv[1]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e ldapEntry) packet() *ber.Packet {
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, "DN"))
+
+	attrs := ber.NewSequence("Attributes")
+	for name, values := range e.attrs {
+		attr := ber.NewSequence("Attribute")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, v := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "Value"))
+		}
+
+		attr.AppendChild(set)
+		attrs.AppendChild(attr)
+	}
+
+	p.AppendChild(attrs)
+
+	return p
+}
+
+func ldapResult(tag ber.Tag, code int) *ber.Packet {
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return p
+}
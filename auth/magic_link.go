@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-dawn/dawn/fiberx"
+	"github.com/go-dawn/pkg/rand"
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	// ErrMagicLinkUnavailable occurs when storage or sender
+	// of magic links is not available
+	ErrMagicLinkUnavailable = errors.New("auth: magic link is not available")
+
+	// ErrInvalidMagicLink occurs when magic link is invalid,
+	// expired or has been used
+	ErrInvalidMagicLink = errors.New("auth: invalid or expired magic link")
+)
+
+var magicLinkPrefix = "auth:magic_link:"
+
+type magicLinkForm struct {
+	// Email is the address which receives the magic link
+	Email string `json:"email" validate:"required,email"`
+}
+
+func (m module) sendMagicLink(c *fiber.Ctx) (err error) {
+	var (
+		data magicLinkForm
+		u    User
+	)
+
+	if err = fiberx.ValidateBody(c, &data); err != nil {
+		return
+	}
+
+	if m.Storage == nil || m.Sender == nil || m.MagicLinkURL == "" {
+		return fiberx.Err(ErrMagicLinkUnavailable)
+	}
+
+	// Response the same message for unknown email addresses
+	// to avoid leaking registered users
+	if u, err = m.service(c).UserByEmailCtx(UserContext(c), data.Email); err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return fiberx.Message(c, "Magic link sent")
+		}
+		return
+	}
+
+	token := rand.String(32)
+
+	if err = m.Storage.SetCtx(UserContext(c), magicLinkPrefix+CodeKey(Tenant(c), token), []byte(strconv.Itoa(u.ID)), m.MagicLinkTTL); err != nil {
+		return
+	}
+
+	if err = m.Sender.Send(u.Email, m.magicLink(token)); err != nil {
+		return
+	}
+
+	return fiberx.Message(c, "Magic link sent")
+}
+
+func (m module) magicLinkCallback(c *fiber.Ctx) (err error) {
+	var (
+		token = c.Query("token")
+		b     []byte
+		id    int
+		u     User
+		t     string
+	)
+
+	if m.Storage == nil {
+		return fiberx.Err(ErrMagicLinkUnavailable)
+	}
+
+	if token == "" || !hmac.Equal([]byte(c.Query("sig")), []byte(m.signMagicLink(token))) {
+		return errResp(c, ErrInvalidMagicLink)
+	}
+
+	// Pull the token to make sure it can be used only once
+	if b, err = m.Storage.PullCtx(UserContext(c), magicLinkPrefix+CodeKey(Tenant(c), token)); err != nil {
+		return
+	}
+
+	if id, err = strconv.Atoi(string(b)); err != nil {
+		return errResp(c, ErrInvalidMagicLink)
+	}
+
+	// Links of other tenants are treated as invalid
+	if u, err = m.service(c).UserCtx(UserContext(c), id); err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return errResp(c, ErrInvalidMagicLink)
+		}
+		return
+	}
+
+	if u.Disabled() {
+		return errResp(c, ErrUserDisabled)
+	}
+
+	e := Event{Name: EventLogin, UserID: id, Tenant: Tenant(c), Type: "magic_link", Identity: u.Email, IP: c.IP()}
+	if err = m.Hooks.run(UserContext(c), e); err != nil {
+		return
+	}
+
+	if t, err = generateToken(m.SigningMethod, m.SigningKey, id, m.Expiration, userClaims(u), tenantClaims(c)); err != nil {
+		return
+	}
+
+	return fiberx.Data(c, t)
+}
+
+// magicLink builds the magic link with token and its signature
+func (m module) magicLink(token string) string {
+	q := url.Values{}
+	q.Set("token", token)
+	q.Set("sig", m.signMagicLink(token))
+
+	sep := "?"
+	if strings.Contains(m.MagicLinkURL, "?") {
+		sep = "&"
+	}
+
+	return m.MagicLinkURL + sep + q.Encode()
+}
+
+// signMagicLink signs the token with signing key
+func (m module) signMagicLink(token string) string {
+	h := hmac.New(sha256.New, []byte(m.SigningKey))
+	_, _ = h.Write([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
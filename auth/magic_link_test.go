@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gavv/httpexpect/v2"
+	"github.com/go-dawn/module/cache"
+	"github.com/go-dawn/pkg/deck"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Auth_MagicLink(t *testing.T) {
+	at := assert.New(t)
+
+	m, mockService := routeModule()
+	sender := &fakeSender{}
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		app.Post("/magic-link", m.sendMagicLink)
+		app.Get("/magic-link/callback", m.magicLinkCallback)
+	})
+
+	email := "kiyonlin@gmail.com"
+
+	t.Run("unavailable", func(t *testing.T) {
+		e.POST("/magic-link").
+			WithJSON(magicLinkForm{Email: email}).
+			Expect().
+			Status(fiber.StatusInternalServerError)
+
+		e.GET("/magic-link/callback").
+			Expect().
+			Status(fiber.StatusInternalServerError)
+	})
+
+	cache.New().Init()
+	m.Storage = cache.Storage()
+	m.Sender = sender
+	m.MagicLinkURL = "https://example.com/auth/magic-link/callback"
+	m.MagicLinkTTL = time.Minute
+
+	var logins []Event
+	m.Hooks = &Hooks{}
+	m.Hooks.OnLogin(func(ctx context.Context, e Event) error {
+		logins = append(logins, e)
+		return nil
+	})
+
+	t.Run("bad request", func(t *testing.T) {
+		e.POST("/magic-link").
+			WithJSON(magicLinkForm{Email: "invalid"}).
+			Expect().
+			Status(fiber.StatusUnprocessableEntity)
+	})
+
+	t.Run("unknown email", func(t *testing.T) {
+		mockService.On("UserByEmail", email).
+			Once().Return(User{}, ErrUserNotFound)
+
+		resp := e.POST("/magic-link").
+			WithJSON(magicLinkForm{Email: email}).
+			Expect().
+			Status(fiber.StatusOK)
+
+		deck.AssertRespMsg(resp, "Magic link sent")
+		at.Empty(sender.address)
+	})
+
+	t.Run("failed to get user", func(t *testing.T) {
+		mockService.On("UserByEmail", email).
+			Once().Return(User{}, errors.New("fake error"))
+
+		e.POST("/magic-link").
+			WithJSON(magicLinkForm{Email: email}).
+			Expect().
+			Status(fiber.StatusInternalServerError)
+	})
+
+	t.Run("failed to send", func(t *testing.T) {
+		sender.err = errors.New("fake error")
+		defer func() { sender.err = nil }()
+
+		mockService.On("UserByEmail", email).
+			Once().Return(User{ID: 1, Email: email}, nil)
+
+		e.POST("/magic-link").
+			WithJSON(magicLinkForm{Email: email}).
+			Expect().
+			Status(fiber.StatusInternalServerError)
+	})
+
+	t.Run("invalid signature", func(t *testing.T) {
+		resp := e.GET("/magic-link/callback").
+			WithQuery("token", "token").
+			WithQuery("sig", "sig").
+			Expect().
+			Status(fiber.StatusUnauthorized)
+
+		deck.AssertRespMsg(resp, "Invalid magic link")
+	})
+
+	t.Run("success", func(t *testing.T) {
+		mockService.On("UserByEmail", email).
+			Once().Return(User{ID: 1, Email: email}, nil).
+			On("User", 1).
+			Once().Return(User{ID: 1, Email: email}, nil)
+
+		e.POST("/magic-link").
+			WithJSON(magicLinkForm{Email: email}).
+			Expect().
+			Status(fiber.StatusOK)
+
+		at.Equal(email, sender.address)
+
+		u, err := url.Parse(sender.msg)
+		at.Nil(err)
+		at.Equal("/auth/magic-link/callback", u.Path)
+
+		query := u.Query()
+
+		resp := e.GET("/magic-link/callback").
+			WithQuery("token", query.Get("token")).
+			WithQuery("sig", query.Get("sig")).
+			Expect().
+			Status(fiber.StatusOK)
+
+		deck.AssertRespDataCheck(resp, func(v *httpexpect.Value) {
+			at.NotEmpty(v.Raw())
+		})
+
+		at.Len(logins, 1)
+		at.Equal("magic_link", logins[0].Type)
+		at.Equal(email, logins[0].Identity)
+
+		// magic link can only be used once
+		e.GET("/magic-link/callback").
+			WithQuery("token", query.Get("token")).
+			WithQuery("sig", query.Get("sig")).
+			Expect().
+			Status(fiber.StatusUnauthorized)
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		mockService.On("UserByEmail", email).
+			Once().Return(User{ID: 1, Email: email}, nil).
+			On("User", 1).
+			Once().Return(User{}, ErrUserNotFound)
+
+		e.POST("/magic-link").
+			WithJSON(magicLinkForm{Email: email}).
+			Expect().
+			Status(fiber.StatusOK)
+
+		query := linkQuery(t, sender.msg)
+
+		resp := e.GET("/magic-link/callback").
+			WithQuery("token", query.Get("token")).
+			WithQuery("sig", query.Get("sig")).
+			Expect().
+			Status(fiber.StatusUnauthorized)
+
+		deck.AssertRespMsg(resp, "Invalid magic link")
+	})
+
+	t.Run("user disabled", func(t *testing.T) {
+		now := time.Now()
+		mockService.On("UserByEmail", email).
+			Once().Return(User{ID: 1, Email: email}, nil).
+			On("User", 1).
+			Once().Return(User{ID: 1, DisabledAt: &now}, nil)
+
+		e.POST("/magic-link").
+			WithJSON(magicLinkForm{Email: email}).
+			Expect().
+			Status(fiber.StatusOK)
+
+		query := linkQuery(t, sender.msg)
+
+		resp := e.GET("/magic-link/callback").
+			WithQuery("token", query.Get("token")).
+			WithQuery("sig", query.Get("sig")).
+			Expect().
+			Status(fiber.StatusForbidden)
+
+		deck.AssertRespMsg(resp, "User disabled")
+	})
+}
+
+func linkQuery(t *testing.T, link string) url.Values {
+	u, err := url.Parse(link)
+	assert.Nil(t, err)
+	return u.Query()
+}
+
+func Test_Auth_MagicLink_Link(t *testing.T) {
+	t.Parallel()
+
+	m := module{Config: &Config{SigningKey: "test"}}
+
+	m.MagicLinkURL = "https://example.com/callback"
+	assert.Equal(t, "https://example.com/callback?sig="+m.signMagicLink("t")+"&token=t", m.magicLink("t"))
+
+	m.MagicLinkURL = "https://example.com/callback?from=email"
+	assert.Equal(t, "https://example.com/callback?from=email&sig="+m.signMagicLink("t")+"&token=t", m.magicLink("t"))
+}
+
+type fakeSender struct {
+	address, msg string
+	err          error
+}
+
+func (s *fakeSender) Send(address, msg string) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.address, s.msg = address, msg
+	return nil
+}
+
+func (s *fakeSender) Close() error {
+	return nil
+}
@@ -0,0 +1,611 @@
+// Code generated by mockery v0.0.0-dev. DO NOT EDIT.
+
+package mocks
+
+import (
+	time "time"
+
+	domain "github.com/go-dawn/module/auth/domain"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// AccountRepo is an autogenerated mock type for the AccountRepo type
+type AccountRepo struct {
+	mock.Mock
+}
+
+// AddAuditEvent provides a mock function with given fields: e
+func (_m *AccountRepo) AddAuditEvent(e domain.AuditEvent) error {
+	ret := _m.Called(e)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(domain.AuditEvent) error); ok {
+		r0 = rf(e)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// AddCredential provides a mock function with given fields: c
+func (_m *AccountRepo) AddCredential(c domain.Credential) error {
+	ret := _m.Called(c)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(domain.Credential) error); ok {
+		r0 = rf(c)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// AddInvitation provides a mock function with given fields: i
+func (_m *AccountRepo) AddInvitation(i domain.Invitation) (int, error) {
+	ret := _m.Called(i)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(domain.Invitation) int); ok {
+		r0 = rf(i)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(domain.Invitation) error); ok {
+		r1 = rf(i)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// AuditEvents provides a mock function with given fields: userID
+func (_m *AccountRepo) AuditEvents(userID int) ([]domain.AuditEvent, error) {
+	ret := _m.Called(userID)
+
+	var r0 []domain.AuditEvent
+	if rf, ok := ret.Get(0).(func(int) []domain.AuditEvent); ok {
+		r0 = rf(userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.AuditEvent)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ChangePassword provides a mock function with given fields: id, current, pass
+func (_m *AccountRepo) ChangePassword(id int, current string, pass string) error {
+	ret := _m.Called(id, current, pass)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, string, string) error); ok {
+		r0 = rf(id, current, pass)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Credential provides a mock function with given fields: id
+func (_m *AccountRepo) Credential(id string) (domain.Credential, error) {
+	ret := _m.Called(id)
+
+	var r0 domain.Credential
+	if rf, ok := ret.Get(0).(func(string) domain.Credential); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Get(0).(domain.Credential)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Credentials provides a mock function with given fields: userID
+func (_m *AccountRepo) Credentials(userID int) ([]domain.Credential, error) {
+	ret := _m.Called(userID)
+
+	var r0 []domain.Credential
+	if rf, ok := ret.Get(0).(func(int) []domain.Credential); ok {
+		r0 = rf(userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.Credential)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteUser provides a mock function with given fields: id
+func (_m *AccountRepo) DeleteUser(id int) error {
+	ret := _m.Called(id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DisableUser provides a mock function with given fields: id
+func (_m *AccountRepo) DisableUser(id int) error {
+	ret := _m.Called(id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// EnableUser provides a mock function with given fields: id
+func (_m *AccountRepo) EnableUser(id int) error {
+	ret := _m.Called(id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Identities provides a mock function with given fields: userID
+func (_m *AccountRepo) Identities(userID int) ([]domain.Identity, error) {
+	ret := _m.Called(userID)
+
+	var r0 []domain.Identity
+	if rf, ok := ret.Get(0).(func(int) []domain.Identity); ok {
+		r0 = rf(userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.Identity)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Invitation provides a mock function with given fields: id
+func (_m *AccountRepo) Invitation(id int) (domain.Invitation, error) {
+	ret := _m.Called(id)
+
+	var r0 domain.Invitation
+	if rf, ok := ret.Get(0).(func(int) domain.Invitation); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Get(0).(domain.Invitation)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// InvitationByToken provides a mock function with given fields: hash
+func (_m *AccountRepo) InvitationByToken(hash string) (domain.Invitation, error) {
+	ret := _m.Called(hash)
+
+	var r0 domain.Invitation
+	if rf, ok := ret.Get(0).(func(string) domain.Invitation); ok {
+		r0 = rf(hash)
+	} else {
+		r0 = ret.Get(0).(domain.Invitation)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(hash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LinkUser provides a mock function with given fields: i, u
+func (_m *AccountRepo) LinkUser(i domain.Identity, u domain.User) (int, error) {
+	ret := _m.Called(i, u)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(domain.Identity, domain.User) int); ok {
+		r0 = rf(i, u)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(domain.Identity, domain.User) error); ok {
+		r1 = rf(i, u)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LoginByEmail provides a mock function with given fields: email
+func (_m *AccountRepo) LoginByEmail(email string) (int, error) {
+	ret := _m.Called(email)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(string) int); ok {
+		r0 = rf(email)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(email)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LoginByMobile provides a mock function with given fields: mobile
+func (_m *AccountRepo) LoginByMobile(mobile string) (int, error) {
+	ret := _m.Called(mobile)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(string) int); ok {
+		r0 = rf(mobile)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(mobile)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LoginByPassword provides a mock function with given fields: username, pass
+func (_m *AccountRepo) LoginByPassword(username string, pass string) (int, error) {
+	ret := _m.Called(username, pass)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(string, string) int); ok {
+		r0 = rf(username, pass)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(username, pass)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PurgeDeletedUsers provides a mock function with given fields: before
+func (_m *AccountRepo) PurgeDeletedUsers(before time.Time) (int, error) {
+	ret := _m.Called(before)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(time.Time) int); ok {
+		r0 = rf(before)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(time.Time) error); ok {
+		r1 = rf(before)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RegisterByEmail provides a mock function with given fields: email
+func (_m *AccountRepo) RegisterByEmail(email string) (int, error) {
+	ret := _m.Called(email)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(string) int); ok {
+		r0 = rf(email)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(email)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RegisterByInvitation provides a mock function with given fields: id, username, pass
+func (_m *AccountRepo) RegisterByInvitation(id int, username string, pass string) (int, error) {
+	ret := _m.Called(id, username, pass)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(int, string, string) int); ok {
+		r0 = rf(id, username, pass)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int, string, string) error); ok {
+		r1 = rf(id, username, pass)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RegisterByMobile provides a mock function with given fields: mobile
+func (_m *AccountRepo) RegisterByMobile(mobile string) (int, error) {
+	ret := _m.Called(mobile)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(string) int); ok {
+		r0 = rf(mobile)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(mobile)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RegisterByPassword provides a mock function with given fields: username, pass
+func (_m *AccountRepo) RegisterByPassword(username string, pass string) (int, error) {
+	ret := _m.Called(username, pass)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(string, string) int); ok {
+		r0 = rf(username, pass)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(username, pass)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RenewInvitation provides a mock function with given fields: id, hash, expiresAt
+func (_m *AccountRepo) RenewInvitation(id int, hash string, expiresAt time.Time) error {
+	ret := _m.Called(id, hash, expiresAt)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, string, time.Time) error); ok {
+		r0 = rf(id, hash, expiresAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ResetPassword provides a mock function with given fields: id
+func (_m *AccountRepo) ResetPassword(id int) error {
+	ret := _m.Called(id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeInvitation provides a mock function with given fields: id
+func (_m *AccountRepo) RevokeInvitation(id int) error {
+	ret := _m.Called(id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetPassword provides a mock function with given fields: id, pass
+func (_m *AccountRepo) SetPassword(id int, pass string) error {
+	ret := _m.Called(id, pass)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, string) error); ok {
+		r0 = rf(id, pass)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateSignCount provides a mock function with given fields: id, count
+func (_m *AccountRepo) UpdateSignCount(id string, count uint32) error {
+	ret := _m.Called(id, count)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, uint32) error); ok {
+		r0 = rf(id, count)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// User provides a mock function with given fields: id
+func (_m *AccountRepo) User(id int) (domain.User, error) {
+	ret := _m.Called(id)
+
+	var r0 domain.User
+	if rf, ok := ret.Get(0).(func(int) domain.User); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Get(0).(domain.User)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UserByEmail provides a mock function with given fields: email
+func (_m *AccountRepo) UserByEmail(email string) (domain.User, error) {
+	ret := _m.Called(email)
+
+	var r0 domain.User
+	if rf, ok := ret.Get(0).(func(string) domain.User); ok {
+		r0 = rf(email)
+	} else {
+		r0 = ret.Get(0).(domain.User)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(email)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Users provides a mock function with given fields: q
+func (_m *AccountRepo) Users(q domain.UserQuery) ([]domain.User, int, error) {
+	ret := _m.Called(q)
+
+	var r0 []domain.User
+	if rf, ok := ret.Get(0).(func(domain.UserQuery) []domain.User); ok {
+		r0 = rf(q)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.User)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(domain.UserQuery) int); ok {
+		r1 = rf(q)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(domain.UserQuery) error); ok {
+		r2 = rf(q)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// VerifyEmail provides a mock function with given fields: id
+func (_m *AccountRepo) VerifyEmail(id int) error {
+	ret := _m.Called(id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// VerifyMobile provides a mock function with given fields: id
+func (_m *AccountRepo) VerifyMobile(id int) error {
+	ret := _m.Called(id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -0,0 +1,24 @@
+// Code generated by mockery v0.0.0-dev. DO NOT EDIT.
+
+package mocks
+
+import mock "github.com/stretchr/testify/mock"
+
+// CodeSender is an autogenerated mock type for the CodeSender type
+type CodeSender struct {
+	mock.Mock
+}
+
+// Make provides a mock function with given fields: address, key
+func (_m *CodeSender) Make(address string, key string) error {
+	ret := _m.Called(address, key)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(address, key)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
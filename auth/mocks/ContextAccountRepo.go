@@ -0,0 +1,612 @@
+// Code generated by mockery v0.0.0-dev. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	time "time"
+
+	domain "github.com/go-dawn/module/auth/domain"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// ContextAccountRepo is an autogenerated mock type for the ContextAccountRepo type
+type ContextAccountRepo struct {
+	mock.Mock
+}
+
+// AddAuditEventCtx provides a mock function with given fields: ctx, e
+func (_m *ContextAccountRepo) AddAuditEventCtx(ctx context.Context, e domain.AuditEvent) error {
+	ret := _m.Called(ctx, e)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.AuditEvent) error); ok {
+		r0 = rf(ctx, e)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// AddCredentialCtx provides a mock function with given fields: ctx, c
+func (_m *ContextAccountRepo) AddCredentialCtx(ctx context.Context, c domain.Credential) error {
+	ret := _m.Called(ctx, c)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.Credential) error); ok {
+		r0 = rf(ctx, c)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// AddInvitationCtx provides a mock function with given fields: ctx, i
+func (_m *ContextAccountRepo) AddInvitationCtx(ctx context.Context, i domain.Invitation) (int, error) {
+	ret := _m.Called(ctx, i)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, domain.Invitation) int); ok {
+		r0 = rf(ctx, i)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, domain.Invitation) error); ok {
+		r1 = rf(ctx, i)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// AuditEventsCtx provides a mock function with given fields: ctx, userID
+func (_m *ContextAccountRepo) AuditEventsCtx(ctx context.Context, userID int) ([]domain.AuditEvent, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 []domain.AuditEvent
+	if rf, ok := ret.Get(0).(func(context.Context, int) []domain.AuditEvent); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.AuditEvent)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ChangePasswordCtx provides a mock function with given fields: ctx, id, current, pass
+func (_m *ContextAccountRepo) ChangePasswordCtx(ctx context.Context, id int, current string, pass string) error {
+	ret := _m.Called(ctx, id, current, pass)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, string) error); ok {
+		r0 = rf(ctx, id, current, pass)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CredentialCtx provides a mock function with given fields: ctx, id
+func (_m *ContextAccountRepo) CredentialCtx(ctx context.Context, id string) (domain.Credential, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 domain.Credential
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.Credential); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(domain.Credential)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CredentialsCtx provides a mock function with given fields: ctx, userID
+func (_m *ContextAccountRepo) CredentialsCtx(ctx context.Context, userID int) ([]domain.Credential, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 []domain.Credential
+	if rf, ok := ret.Get(0).(func(context.Context, int) []domain.Credential); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.Credential)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteUserCtx provides a mock function with given fields: ctx, id
+func (_m *ContextAccountRepo) DeleteUserCtx(ctx context.Context, id int) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DisableUserCtx provides a mock function with given fields: ctx, id
+func (_m *ContextAccountRepo) DisableUserCtx(ctx context.Context, id int) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// EnableUserCtx provides a mock function with given fields: ctx, id
+func (_m *ContextAccountRepo) EnableUserCtx(ctx context.Context, id int) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// IdentitiesCtx provides a mock function with given fields: ctx, userID
+func (_m *ContextAccountRepo) IdentitiesCtx(ctx context.Context, userID int) ([]domain.Identity, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 []domain.Identity
+	if rf, ok := ret.Get(0).(func(context.Context, int) []domain.Identity); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.Identity)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// InvitationByTokenCtx provides a mock function with given fields: ctx, hash
+func (_m *ContextAccountRepo) InvitationByTokenCtx(ctx context.Context, hash string) (domain.Invitation, error) {
+	ret := _m.Called(ctx, hash)
+
+	var r0 domain.Invitation
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.Invitation); ok {
+		r0 = rf(ctx, hash)
+	} else {
+		r0 = ret.Get(0).(domain.Invitation)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, hash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// InvitationCtx provides a mock function with given fields: ctx, id
+func (_m *ContextAccountRepo) InvitationCtx(ctx context.Context, id int) (domain.Invitation, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 domain.Invitation
+	if rf, ok := ret.Get(0).(func(context.Context, int) domain.Invitation); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(domain.Invitation)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LinkUserCtx provides a mock function with given fields: ctx, i, u
+func (_m *ContextAccountRepo) LinkUserCtx(ctx context.Context, i domain.Identity, u domain.User) (int, error) {
+	ret := _m.Called(ctx, i, u)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, domain.Identity, domain.User) int); ok {
+		r0 = rf(ctx, i, u)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, domain.Identity, domain.User) error); ok {
+		r1 = rf(ctx, i, u)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LoginByEmailCtx provides a mock function with given fields: ctx, email
+func (_m *ContextAccountRepo) LoginByEmailCtx(ctx context.Context, email string) (int, error) {
+	ret := _m.Called(ctx, email)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string) int); ok {
+		r0 = rf(ctx, email)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, email)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LoginByMobileCtx provides a mock function with given fields: ctx, mobile
+func (_m *ContextAccountRepo) LoginByMobileCtx(ctx context.Context, mobile string) (int, error) {
+	ret := _m.Called(ctx, mobile)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string) int); ok {
+		r0 = rf(ctx, mobile)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, mobile)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LoginByPasswordCtx provides a mock function with given fields: ctx, username, pass
+func (_m *ContextAccountRepo) LoginByPasswordCtx(ctx context.Context, username string, pass string) (int, error) {
+	ret := _m.Called(ctx, username, pass)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int); ok {
+		r0 = rf(ctx, username, pass)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, username, pass)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PurgeDeletedUsersCtx provides a mock function with given fields: ctx, before
+func (_m *ContextAccountRepo) PurgeDeletedUsersCtx(ctx context.Context, before time.Time) (int, error) {
+	ret := _m.Called(ctx, before)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) int); ok {
+		r0 = rf(ctx, before)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, before)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RegisterByEmailCtx provides a mock function with given fields: ctx, email
+func (_m *ContextAccountRepo) RegisterByEmailCtx(ctx context.Context, email string) (int, error) {
+	ret := _m.Called(ctx, email)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string) int); ok {
+		r0 = rf(ctx, email)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, email)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RegisterByInvitationCtx provides a mock function with given fields: ctx, id, username, pass
+func (_m *ContextAccountRepo) RegisterByInvitationCtx(ctx context.Context, id int, username string, pass string) (int, error) {
+	ret := _m.Called(ctx, id, username, pass)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, string) int); ok {
+		r0 = rf(ctx, id, username, pass)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int, string, string) error); ok {
+		r1 = rf(ctx, id, username, pass)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RegisterByMobileCtx provides a mock function with given fields: ctx, mobile
+func (_m *ContextAccountRepo) RegisterByMobileCtx(ctx context.Context, mobile string) (int, error) {
+	ret := _m.Called(ctx, mobile)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string) int); ok {
+		r0 = rf(ctx, mobile)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, mobile)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RegisterByPasswordCtx provides a mock function with given fields: ctx, username, pass
+func (_m *ContextAccountRepo) RegisterByPasswordCtx(ctx context.Context, username string, pass string) (int, error) {
+	ret := _m.Called(ctx, username, pass)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int); ok {
+		r0 = rf(ctx, username, pass)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, username, pass)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RenewInvitationCtx provides a mock function with given fields: ctx, id, hash, expiresAt
+func (_m *ContextAccountRepo) RenewInvitationCtx(ctx context.Context, id int, hash string, expiresAt time.Time) error {
+	ret := _m.Called(ctx, id, hash, expiresAt)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, time.Time) error); ok {
+		r0 = rf(ctx, id, hash, expiresAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ResetPasswordCtx provides a mock function with given fields: ctx, id
+func (_m *ContextAccountRepo) ResetPasswordCtx(ctx context.Context, id int) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeInvitationCtx provides a mock function with given fields: ctx, id
+func (_m *ContextAccountRepo) RevokeInvitationCtx(ctx context.Context, id int) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetPasswordCtx provides a mock function with given fields: ctx, id, pass
+func (_m *ContextAccountRepo) SetPasswordCtx(ctx context.Context, id int, pass string) error {
+	ret := _m.Called(ctx, id, pass)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) error); ok {
+		r0 = rf(ctx, id, pass)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateSignCountCtx provides a mock function with given fields: ctx, id, count
+func (_m *ContextAccountRepo) UpdateSignCountCtx(ctx context.Context, id string, count uint32) error {
+	ret := _m.Called(ctx, id, count)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, uint32) error); ok {
+		r0 = rf(ctx, id, count)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UserByEmailCtx provides a mock function with given fields: ctx, email
+func (_m *ContextAccountRepo) UserByEmailCtx(ctx context.Context, email string) (domain.User, error) {
+	ret := _m.Called(ctx, email)
+
+	var r0 domain.User
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.User); ok {
+		r0 = rf(ctx, email)
+	} else {
+		r0 = ret.Get(0).(domain.User)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, email)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UserCtx provides a mock function with given fields: ctx, id
+func (_m *ContextAccountRepo) UserCtx(ctx context.Context, id int) (domain.User, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 domain.User
+	if rf, ok := ret.Get(0).(func(context.Context, int) domain.User); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(domain.User)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UsersCtx provides a mock function with given fields: ctx, q
+func (_m *ContextAccountRepo) UsersCtx(ctx context.Context, q domain.UserQuery) ([]domain.User, int, error) {
+	ret := _m.Called(ctx, q)
+
+	var r0 []domain.User
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserQuery) []domain.User); ok {
+		r0 = rf(ctx, q)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.User)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, domain.UserQuery) int); ok {
+		r1 = rf(ctx, q)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, domain.UserQuery) error); ok {
+		r2 = rf(ctx, q)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// VerifyEmailCtx provides a mock function with given fields: ctx, id
+func (_m *ContextAccountRepo) VerifyEmailCtx(ctx context.Context, id int) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// VerifyMobileCtx provides a mock function with given fields: ctx, id
+func (_m *ContextAccountRepo) VerifyMobileCtx(ctx context.Context, id int) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
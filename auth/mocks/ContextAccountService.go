@@ -0,0 +1,640 @@
+// Code generated by mockery v0.0.0-dev. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	time "time"
+
+	domain "github.com/go-dawn/module/auth/domain"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// ContextAccountService is an autogenerated mock type for the ContextAccountService type
+type ContextAccountService struct {
+	mock.Mock
+}
+
+// AddAuditEventCtx provides a mock function with given fields: ctx, e
+func (_m *ContextAccountService) AddAuditEventCtx(ctx context.Context, e domain.AuditEvent) error {
+	ret := _m.Called(ctx, e)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.AuditEvent) error); ok {
+		r0 = rf(ctx, e)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// AddCredentialCtx provides a mock function with given fields: ctx, c
+func (_m *ContextAccountService) AddCredentialCtx(ctx context.Context, c domain.Credential) error {
+	ret := _m.Called(ctx, c)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.Credential) error); ok {
+		r0 = rf(ctx, c)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// AuditEventsCtx provides a mock function with given fields: ctx, userID
+func (_m *ContextAccountService) AuditEventsCtx(ctx context.Context, userID int) ([]domain.AuditEvent, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 []domain.AuditEvent
+	if rf, ok := ret.Get(0).(func(context.Context, int) []domain.AuditEvent); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.AuditEvent)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ChangePasswordCtx provides a mock function with given fields: ctx, id, current, pass
+func (_m *ContextAccountService) ChangePasswordCtx(ctx context.Context, id int, current string, pass string) error {
+	ret := _m.Called(ctx, id, current, pass)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, string) error); ok {
+		r0 = rf(ctx, id, current, pass)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CredentialCtx provides a mock function with given fields: ctx, id
+func (_m *ContextAccountService) CredentialCtx(ctx context.Context, id string) (domain.Credential, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 domain.Credential
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.Credential); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(domain.Credential)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CredentialsCtx provides a mock function with given fields: ctx, userID
+func (_m *ContextAccountService) CredentialsCtx(ctx context.Context, userID int) ([]domain.Credential, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 []domain.Credential
+	if rf, ok := ret.Get(0).(func(context.Context, int) []domain.Credential); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.Credential)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteUserCtx provides a mock function with given fields: ctx, id
+func (_m *ContextAccountService) DeleteUserCtx(ctx context.Context, id int) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DisableUserCtx provides a mock function with given fields: ctx, id
+func (_m *ContextAccountService) DisableUserCtx(ctx context.Context, id int) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// EnableUserCtx provides a mock function with given fields: ctx, id
+func (_m *ContextAccountService) EnableUserCtx(ctx context.Context, id int) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// IdentitiesCtx provides a mock function with given fields: ctx, userID
+func (_m *ContextAccountService) IdentitiesCtx(ctx context.Context, userID int) ([]domain.Identity, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 []domain.Identity
+	if rf, ok := ret.Get(0).(func(context.Context, int) []domain.Identity); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.Identity)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// InvitationByTokenCtx provides a mock function with given fields: ctx, token
+func (_m *ContextAccountService) InvitationByTokenCtx(ctx context.Context, token string) (domain.Invitation, error) {
+	ret := _m.Called(ctx, token)
+
+	var r0 domain.Invitation
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.Invitation); ok {
+		r0 = rf(ctx, token)
+	} else {
+		r0 = ret.Get(0).(domain.Invitation)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// InviteCtx provides a mock function with given fields: ctx, i
+func (_m *ContextAccountService) InviteCtx(ctx context.Context, i domain.Invitation) (domain.Invitation, string, error) {
+	ret := _m.Called(ctx, i)
+
+	var r0 domain.Invitation
+	if rf, ok := ret.Get(0).(func(context.Context, domain.Invitation) domain.Invitation); ok {
+		r0 = rf(ctx, i)
+	} else {
+		r0 = ret.Get(0).(domain.Invitation)
+	}
+
+	var r1 string
+	if rf, ok := ret.Get(1).(func(context.Context, domain.Invitation) string); ok {
+		r1 = rf(ctx, i)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, domain.Invitation) error); ok {
+		r2 = rf(ctx, i)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// LinkUserCtx provides a mock function with given fields: ctx, i, u
+func (_m *ContextAccountService) LinkUserCtx(ctx context.Context, i domain.Identity, u domain.User) (int, error) {
+	ret := _m.Called(ctx, i, u)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, domain.Identity, domain.User) int); ok {
+		r0 = rf(ctx, i, u)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, domain.Identity, domain.User) error); ok {
+		r1 = rf(ctx, i, u)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LoginByEmailCodeCtx provides a mock function with given fields: ctx, email, code
+func (_m *ContextAccountService) LoginByEmailCodeCtx(ctx context.Context, email string, code string) (int, error) {
+	ret := _m.Called(ctx, email, code)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int); ok {
+		r0 = rf(ctx, email, code)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, email, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LoginByMobileCodeCtx provides a mock function with given fields: ctx, mobile, code
+func (_m *ContextAccountService) LoginByMobileCodeCtx(ctx context.Context, mobile string, code string) (int, error) {
+	ret := _m.Called(ctx, mobile, code)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int); ok {
+		r0 = rf(ctx, mobile, code)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, mobile, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LoginByPasswordCtx provides a mock function with given fields: ctx, username, pass
+func (_m *ContextAccountService) LoginByPasswordCtx(ctx context.Context, username string, pass string) (int, error) {
+	ret := _m.Called(ctx, username, pass)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int); ok {
+		r0 = rf(ctx, username, pass)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, username, pass)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PurgeDeletedUsersCtx provides a mock function with given fields: ctx, before
+func (_m *ContextAccountService) PurgeDeletedUsersCtx(ctx context.Context, before time.Time) (int, error) {
+	ret := _m.Called(ctx, before)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) int); ok {
+		r0 = rf(ctx, before)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, before)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RegisterByEmailCodeCtx provides a mock function with given fields: ctx, email, code
+func (_m *ContextAccountService) RegisterByEmailCodeCtx(ctx context.Context, email string, code string) (int, error) {
+	ret := _m.Called(ctx, email, code)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int); ok {
+		r0 = rf(ctx, email, code)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, email, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RegisterByInvitationCtx provides a mock function with given fields: ctx, token, username, pass
+func (_m *ContextAccountService) RegisterByInvitationCtx(ctx context.Context, token string, username string, pass string) (int, error) {
+	ret := _m.Called(ctx, token, username, pass)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) int); ok {
+		r0 = rf(ctx, token, username, pass)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string) error); ok {
+		r1 = rf(ctx, token, username, pass)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RegisterByMobileCodeCtx provides a mock function with given fields: ctx, mobile, code
+func (_m *ContextAccountService) RegisterByMobileCodeCtx(ctx context.Context, mobile string, code string) (int, error) {
+	ret := _m.Called(ctx, mobile, code)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int); ok {
+		r0 = rf(ctx, mobile, code)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, mobile, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RegisterByPasswordCtx provides a mock function with given fields: ctx, username, pass
+func (_m *ContextAccountService) RegisterByPasswordCtx(ctx context.Context, username string, pass string) (int, error) {
+	ret := _m.Called(ctx, username, pass)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int); ok {
+		r0 = rf(ctx, username, pass)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, username, pass)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ResendInvitationCtx provides a mock function with given fields: ctx, id, expiresAt
+func (_m *ContextAccountService) ResendInvitationCtx(ctx context.Context, id int, expiresAt time.Time) (domain.Invitation, string, error) {
+	ret := _m.Called(ctx, id, expiresAt)
+
+	var r0 domain.Invitation
+	if rf, ok := ret.Get(0).(func(context.Context, int, time.Time) domain.Invitation); ok {
+		r0 = rf(ctx, id, expiresAt)
+	} else {
+		r0 = ret.Get(0).(domain.Invitation)
+	}
+
+	var r1 string
+	if rf, ok := ret.Get(1).(func(context.Context, int, time.Time) string); ok {
+		r1 = rf(ctx, id, expiresAt)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, int, time.Time) error); ok {
+		r2 = rf(ctx, id, expiresAt)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ResetPasswordCtx provides a mock function with given fields: ctx, id
+func (_m *ContextAccountService) ResetPasswordCtx(ctx context.Context, id int) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeInvitationCtx provides a mock function with given fields: ctx, id
+func (_m *ContextAccountService) RevokeInvitationCtx(ctx context.Context, id int) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SendEmailVerificationCtx provides a mock function with given fields: ctx, id
+func (_m *ContextAccountService) SendEmailVerificationCtx(ctx context.Context, id int) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SendMobileVerificationCtx provides a mock function with given fields: ctx, id
+func (_m *ContextAccountService) SendMobileVerificationCtx(ctx context.Context, id int) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetPasswordCtx provides a mock function with given fields: ctx, id, pass
+func (_m *ContextAccountService) SetPasswordCtx(ctx context.Context, id int, pass string) error {
+	ret := _m.Called(ctx, id, pass)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) error); ok {
+		r0 = rf(ctx, id, pass)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateSignCountCtx provides a mock function with given fields: ctx, id, count
+func (_m *ContextAccountService) UpdateSignCountCtx(ctx context.Context, id string, count uint32) error {
+	ret := _m.Called(ctx, id, count)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, uint32) error); ok {
+		r0 = rf(ctx, id, count)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UserByEmailCtx provides a mock function with given fields: ctx, email
+func (_m *ContextAccountService) UserByEmailCtx(ctx context.Context, email string) (domain.User, error) {
+	ret := _m.Called(ctx, email)
+
+	var r0 domain.User
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.User); ok {
+		r0 = rf(ctx, email)
+	} else {
+		r0 = ret.Get(0).(domain.User)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, email)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UserCtx provides a mock function with given fields: ctx, id
+func (_m *ContextAccountService) UserCtx(ctx context.Context, id int) (domain.User, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 domain.User
+	if rf, ok := ret.Get(0).(func(context.Context, int) domain.User); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(domain.User)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UsersCtx provides a mock function with given fields: ctx, q
+func (_m *ContextAccountService) UsersCtx(ctx context.Context, q domain.UserQuery) ([]domain.User, int, error) {
+	ret := _m.Called(ctx, q)
+
+	var r0 []domain.User
+	if rf, ok := ret.Get(0).(func(context.Context, domain.UserQuery) []domain.User); ok {
+		r0 = rf(ctx, q)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.User)
+		}
+	}
+
+	var r1 int
+	if rf, ok := ret.Get(1).(func(context.Context, domain.UserQuery) int); ok {
+		r1 = rf(ctx, q)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, domain.UserQuery) error); ok {
+		r2 = rf(ctx, q)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// VerifyEmailCtx provides a mock function with given fields: ctx, id, code
+func (_m *ContextAccountService) VerifyEmailCtx(ctx context.Context, id int, code string) error {
+	ret := _m.Called(ctx, id, code)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) error); ok {
+		r0 = rf(ctx, id, code)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// VerifyMobileCtx provides a mock function with given fields: ctx, id, code
+func (_m *ContextAccountService) VerifyMobileCtx(ctx context.Context, id int, code string) error {
+	ret := _m.Called(ctx, id, code)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) error); ok {
+		r0 = rf(ctx, id, code)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -0,0 +1,140 @@
+// Code generated by mockery v0.0.0-dev. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// ContextRepo is an autogenerated mock type for the ContextRepo type
+type ContextRepo struct {
+	mock.Mock
+}
+
+// LoginByEmailCtx provides a mock function with given fields: ctx, email
+func (_m *ContextRepo) LoginByEmailCtx(ctx context.Context, email string) (int, error) {
+	ret := _m.Called(ctx, email)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string) int); ok {
+		r0 = rf(ctx, email)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, email)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LoginByMobileCtx provides a mock function with given fields: ctx, mobile
+func (_m *ContextRepo) LoginByMobileCtx(ctx context.Context, mobile string) (int, error) {
+	ret := _m.Called(ctx, mobile)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string) int); ok {
+		r0 = rf(ctx, mobile)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, mobile)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LoginByPasswordCtx provides a mock function with given fields: ctx, username, pass
+func (_m *ContextRepo) LoginByPasswordCtx(ctx context.Context, username string, pass string) (int, error) {
+	ret := _m.Called(ctx, username, pass)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int); ok {
+		r0 = rf(ctx, username, pass)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, username, pass)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RegisterByEmailCtx provides a mock function with given fields: ctx, email
+func (_m *ContextRepo) RegisterByEmailCtx(ctx context.Context, email string) (int, error) {
+	ret := _m.Called(ctx, email)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string) int); ok {
+		r0 = rf(ctx, email)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, email)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RegisterByMobileCtx provides a mock function with given fields: ctx, mobile
+func (_m *ContextRepo) RegisterByMobileCtx(ctx context.Context, mobile string) (int, error) {
+	ret := _m.Called(ctx, mobile)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string) int); ok {
+		r0 = rf(ctx, mobile)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, mobile)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RegisterByPasswordCtx provides a mock function with given fields: ctx, username, pass
+func (_m *ContextRepo) RegisterByPasswordCtx(ctx context.Context, username string, pass string) (int, error) {
+	ret := _m.Called(ctx, username, pass)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int); ok {
+		r0 = rf(ctx, username, pass)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, username, pass)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -0,0 +1,140 @@
+// Code generated by mockery v0.0.0-dev. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// ContextService is an autogenerated mock type for the ContextService type
+type ContextService struct {
+	mock.Mock
+}
+
+// LoginByEmailCodeCtx provides a mock function with given fields: ctx, email, code
+func (_m *ContextService) LoginByEmailCodeCtx(ctx context.Context, email string, code string) (int, error) {
+	ret := _m.Called(ctx, email, code)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int); ok {
+		r0 = rf(ctx, email, code)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, email, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LoginByMobileCodeCtx provides a mock function with given fields: ctx, mobile, code
+func (_m *ContextService) LoginByMobileCodeCtx(ctx context.Context, mobile string, code string) (int, error) {
+	ret := _m.Called(ctx, mobile, code)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int); ok {
+		r0 = rf(ctx, mobile, code)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, mobile, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LoginByPasswordCtx provides a mock function with given fields: ctx, username, pass
+func (_m *ContextService) LoginByPasswordCtx(ctx context.Context, username string, pass string) (int, error) {
+	ret := _m.Called(ctx, username, pass)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int); ok {
+		r0 = rf(ctx, username, pass)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, username, pass)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RegisterByEmailCodeCtx provides a mock function with given fields: ctx, email, code
+func (_m *ContextService) RegisterByEmailCodeCtx(ctx context.Context, email string, code string) (int, error) {
+	ret := _m.Called(ctx, email, code)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int); ok {
+		r0 = rf(ctx, email, code)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, email, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RegisterByMobileCodeCtx provides a mock function with given fields: ctx, mobile, code
+func (_m *ContextService) RegisterByMobileCodeCtx(ctx context.Context, mobile string, code string) (int, error) {
+	ret := _m.Called(ctx, mobile, code)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int); ok {
+		r0 = rf(ctx, mobile, code)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, mobile, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RegisterByPasswordCtx provides a mock function with given fields: ctx, username, pass
+func (_m *ContextService) RegisterByPasswordCtx(ctx context.Context, username string, pass string) (int, error) {
+	ret := _m.Called(ctx, username, pass)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int); ok {
+		r0 = rf(ctx, username, pass)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, username, pass)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -9,7 +9,7 @@ type Repo struct {
 	mock.Mock
 }
 
-// LoginByEmailCode provides a mock function with given fields: email
+// LoginByEmail provides a mock function with given fields: email
 func (_m *Repo) LoginByEmail(email string) (int, error) {
 	ret := _m.Called(email)
 
@@ -30,7 +30,7 @@ func (_m *Repo) LoginByEmail(email string) (int, error) {
 	return r0, r1
 }
 
-// LoginByMobileCode provides a mock function with given fields: mobile
+// LoginByMobile provides a mock function with given fields: mobile
 func (_m *Repo) LoginByMobile(mobile string) (int, error) {
 	ret := _m.Called(mobile)
 
@@ -72,7 +72,7 @@ func (_m *Repo) LoginByPassword(username string, pass string) (int, error) {
 	return r0, r1
 }
 
-// RegisterByEmailCode provides a mock function with given fields: email
+// RegisterByEmail provides a mock function with given fields: email
 func (_m *Repo) RegisterByEmail(email string) (int, error) {
 	ret := _m.Called(email)
 
@@ -93,7 +93,7 @@ func (_m *Repo) RegisterByEmail(email string) (int, error) {
 	return r0, r1
 }
 
-// RegisterByMobileCode provides a mock function with given fields: mobile
+// RegisterByMobile provides a mock function with given fields: mobile
 func (_m *Repo) RegisterByMobile(mobile string) (int, error) {
 	ret := _m.Called(mobile)
 
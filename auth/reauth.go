@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/go-dawn/dawn/fiberx"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrReauthRequired occurs when a sensitive operation is
+// requested with a token whose login is not recent enough
+var ErrReauthRequired = errors.New("auth: re-authentication is required")
+
+// RequireRecentAuth only allows tokens whose auth_time is within
+// maxAge, clients should get a new token from POST /auth/reauth
+// and retry. It must be used after jwt middleware
+func RequireRecentAuth(maxAge time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		authTime, _ := claims(c)["auth_time"].(float64)
+
+		if time.Since(time.Unix(int64(authTime), 0)) > maxAge {
+			return errResp(c, ErrReauthRequired)
+		}
+
+		return c.Next()
+	}
+}
+
+type reauthForm struct {
+	// Type can be password, mobile, email, webauthn, ldap or
+	// any type registered by RegisterStrategy
+	Type string `json:"type" validate:"required,auth_strategy"`
+	// Username is the key of the login type, it defaults to
+	// username, mobile number or email address of the user
+	Username string `json:"username"`
+	// Code can be password, sms code, email code or webauthn assertion
+	Code string `json:"code" validate:"required"`
+}
+
+// reauth checks the credentials of current user again and issues
+// a token with a new auth_time, other claims and the expiry of
+// current token are kept
+func (m module) reauth(c *fiber.Ctx) (err error) {
+	var (
+		data reauthForm
+		u    User
+		t    string
+	)
+
+	if err = fiberx.ValidateBody(c, &data); err != nil {
+		return
+	}
+
+	s := m.service(c)
+
+	if u, err = s.UserCtx(UserContext(c), UserID(c)); err != nil {
+		return errResp(c, err)
+	}
+
+	key := reauthKey(u, data)
+	e := Event{UserID: u.ID, Tenant: Tenant(c), Type: data.Type, Identity: key, IP: c.IP()}
+
+	if u.Disabled() {
+		return m.loginFailed(c, e, ErrUserDisabled)
+	}
+
+	if err = m.reauthenticate(tenantContext(c), s, u, data.Type, key, data.Code); err != nil {
+		return m.loginFailed(c, e, err)
+	}
+
+	if t, err = m.reissue(c); err != nil {
+		return
+	}
+
+	return fiberx.Data(c, t)
+}
+
+// reauthenticate checks the credentials belong to the user
+func (m module) reauthenticate(ctx context.Context, s ContextAccountService, u User, typ, key, code string) error {
+	id, err := m.authFunc(ctx, s, typ)(key, code)
+	if err != nil {
+		return err
+	}
+
+	if id != u.ID {
+		return ErrInvalidCredentials
+	}
+
+	return nil
+}
+
+// reauthKey gets the key of the login type, it defaults to
+// the identity of the user
+func reauthKey(u User, data reauthForm) string {
+	if data.Username != "" {
+		return data.Username
+	}
+
+	switch data.Type {
+	case "mobile":
+		return u.Mobile
+	case "email":
+		return u.Email
+	default:
+		return u.Username
+	}
+}
+
+// reissue issues a token with claims of current token,
+// it expires at the same time with current token
+func (m module) reissue(c *fiber.Ctx) (string, error) {
+	extra := jwt.MapClaims{}
+	for k, v := range claims(c) {
+		extra[k] = v
+	}
+
+	exp, _ := extra["exp"].(float64)
+
+	return generateToken(m.SigningMethod, m.SigningKey, UserID(c), time.Until(time.Unix(int64(exp), 0)), extra)
+}
@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/go-dawn/pkg/deck"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Auth_RequireRecentAuth(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	m, _ := routeModule()
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		app.Use(m.jwt(), RequireRecentAuth(time.Minute))
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendString("ok")
+		})
+	})
+
+	t.Run("recent", func(t *testing.T) {
+		token, err := generateToken("", m.SigningKey, 1, time.Hour)
+		at.Nil(err)
+
+		e.GET("/").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+token).
+			Expect().
+			Status(fiber.StatusOK)
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		resp := e.GET("/").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+staleToken(t, m.SigningKey, 1)).
+			Expect().
+			Status(fiber.StatusUnauthorized)
+
+		deck.AssertRespCode(resp, CodeReauthRequired)
+	})
+
+	t.Run("missing auth_time", func(t *testing.T) {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"id":  1,
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}).SignedString([]byte(m.SigningKey))
+		at.Nil(err)
+
+		e.GET("/").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+token).
+			Expect().
+			Status(fiber.StatusUnauthorized)
+	})
+}
+
+func Test_Auth_Reauth(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	var failed []Event
+	m, mockService := routeModule()
+	m.Hooks = &Hooks{}
+	m.Hooks.OnLoginFailed(func(ctx context.Context, e Event) error {
+		failed = append(failed, e)
+		return nil
+	})
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		app.Use(m.jwt())
+		app.Post("/reauth", m.reauth)
+	})
+
+	u := User{ID: 1, Username: "kiyon", Email: "kiyon@example.com"}
+	exp := time.Now().Add(time.Minute * 30).Unix()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"id":        1,
+		"role":      "editor",
+		"iat":       time.Now().Add(-time.Hour).Unix(),
+		"auth_time": time.Now().Add(-time.Hour).Unix(),
+		"exp":       exp,
+	}).SignedString([]byte(m.SigningKey))
+	at.Nil(err)
+	bearer := "Bearer " + token
+
+	t.Run("bad request", func(t *testing.T) {
+		e.POST("/reauth").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			WithJSON(reauthForm{Type: "password"}).
+			Expect().
+			Status(fiber.StatusUnprocessableEntity)
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		mockService.On("User", 1).
+			Once().Return(User{}, ErrUserNotFound)
+
+		e.POST("/reauth").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			WithJSON(reauthForm{Type: "password", Code: "pass"}).
+			Expect().
+			Status(fiber.StatusNotFound)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		now := time.Now()
+		mockService.On("User", 1).
+			Once().Return(User{ID: 1, DisabledAt: &now}, nil)
+
+		e.POST("/reauth").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			WithJSON(reauthForm{Type: "password", Code: "pass"}).
+			Expect().
+			Status(fiber.StatusForbidden)
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		failed = nil
+
+		mockService.On("User", 1).
+			Once().Return(u, nil).
+			On("LoginByPassword", "kiyon", "wrong").
+			Once().Return(0, ErrInvalidCredentials)
+
+		e.POST("/reauth").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			WithJSON(reauthForm{Type: "password", Code: "wrong"}).
+			Expect().
+			Status(fiber.StatusUnauthorized)
+
+		if at.Len(failed, 1) {
+			at.Equal(1, failed[0].UserID)
+			at.Equal("kiyon", failed[0].Identity)
+			at.Equal(ErrInvalidCredentials, failed[0].Err)
+		}
+	})
+
+	t.Run("credentials of another user", func(t *testing.T) {
+		mockService.On("User", 1).
+			Once().Return(u, nil).
+			On("LoginByPassword", "other", "pass").
+			Once().Return(2, nil)
+
+		e.POST("/reauth").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			WithJSON(reauthForm{Type: "password", Username: "other", Code: "pass"}).
+			Expect().
+			Status(fiber.StatusUnauthorized)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		mockService.On("User", 1).
+			Once().Return(u, nil).
+			On("LoginByEmailCode", u.Email, "code").
+			Once().Return(1, nil)
+
+		resp := e.POST("/reauth").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			WithJSON(reauthForm{Type: "email", Code: "code"}).
+			Expect().
+			Status(fiber.StatusOK)
+
+		raw := resp.JSON().Object().Value("data").String().Raw()
+		token, err := jwt.Parse(raw, func(*jwt.Token) (interface{}, error) {
+			return []byte(m.SigningKey), nil
+		})
+		at.Nil(err)
+
+		claims := token.Claims.(jwt.MapClaims)
+		at.Equal(float64(1), claims["id"])
+		at.Equal("editor", claims["role"])
+		at.InDelta(float64(time.Now().Unix()), claims["auth_time"], 2)
+		at.InDelta(float64(exp), claims["exp"], 2)
+	})
+
+	at.True(mockService.AssertExpectations(t))
+}
+
+func Test_Auth_GenerateToken_AuthTime(t *testing.T) {
+	at := assert.New(t)
+
+	raw, err := generateToken("", "test", 1, time.Hour, jwt.MapClaims{"auth_time": 1})
+	at.Nil(err)
+
+	token, err := jwt.Parse(raw, func(*jwt.Token) (interface{}, error) {
+		return []byte("test"), nil
+	})
+	at.Nil(err)
+
+	claims := token.Claims.(jwt.MapClaims)
+	at.Equal(claims["iat"], claims["auth_time"])
+}
+
+// staleToken gets a token whose login was an hour ago
+func staleToken(t *testing.T, key string, id int) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"id":        id,
+		"iat":       time.Now().Unix(),
+		"auth_time": time.Now().Add(-time.Hour).Unix(),
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(key))
+	assert.Nil(t, err)
+
+	return token
+}
@@ -1,12 +1,23 @@
 package auth
 
 import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-dawn/module/auth/domain"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 var bcryptCost = bcrypt.DefaultCost
 
+var (
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
+
 // Repo is the repository interface for auth behaviors
 type Repo interface {
 	// RegisterByPassword gets a new user by username and password
@@ -31,67 +42,1030 @@ type Repo interface {
 	LoginByEmail(email string) (int, error)
 }
 
+// AccountRepo is an optional interface of Repo for account features,
+// methods of AccountService return ErrUnsupported without it
+type AccountRepo interface {
+	Repo
+
+	// User gets the user by id
+	User(id int) (User, error)
+
+	// UserByEmail gets the user by email address
+	UserByEmail(email string) (User, error)
+
+	// VerifyEmail marks email address of the user as verified
+	VerifyEmail(id int) error
+
+	// VerifyMobile marks mobile number of the user as verified
+	VerifyMobile(id int) error
+
+	// AddCredential stores a new webauthn credential
+	AddCredential(c Credential) error
+
+	// Credential gets webauthn credential by credential id
+	Credential(id string) (Credential, error)
+
+	// Credentials gets all webauthn credentials of the user
+	Credentials(userID int) ([]Credential, error)
+
+	// UpdateSignCount updates signature counter of the credential
+	UpdateSignCount(id string, count uint32) error
+
+	// Users gets users matched with the query and total count
+	Users(q UserQuery) ([]User, int, error)
+
+	// DisableUser disables the user and prevents it from login
+	DisableUser(id int) error
+
+	// EnableUser enables the disabled user
+	EnableUser(id int) error
+
+	// ResetPassword clears password of the user, password login
+	// is rejected until a new password is set
+	ResetPassword(id int) error
+
+	// SetPassword sets a new password for the user
+	SetPassword(id int, pass string) error
+
+	// ChangePassword sets a new password if the current one
+	// is matched, users without password can set one
+	ChangePassword(id int, current, pass string) error
+
+	// DeleteUser soft deletes the user
+	DeleteUser(id int) error
+
+	// AddAuditEvent stores an audit event
+	AddAuditEvent(e AuditEvent) error
+
+	// LinkUser gets the local user linked to the external identity,
+	// an unlinked identity is linked to the user with the same
+	// verified email or a new user if open registration is allowed,
+	// otherwise ErrInvitationRequired is returned. Role of the user
+	// is synced
+	LinkUser(i Identity, u User) (int, error)
+
+	// AddInvitation stores a new invitation
+	AddInvitation(i Invitation) (int, error)
+
+	// Invitation gets the invitation by id
+	Invitation(id int) (Invitation, error)
+
+	// InvitationByToken gets the invitation by hash of its token
+	InvitationByToken(hash string) (Invitation, error)
+
+	// RenewInvitation replaces token hash and expiry of
+	// the pending invitation
+	RenewInvitation(id int, hash string, expiresAt time.Time) error
+
+	// RevokeInvitation revokes the pending invitation
+	RevokeInvitation(id int) error
+
+	// RegisterByInvitation gets a new user with email and role of
+	// the pending invitation and accepts it in one transaction
+	RegisterByInvitation(id int, username, pass string) (int, error)
+
+	// AuditEvents gets audit events performed by or on the user
+	AuditEvents(userID int) ([]AuditEvent, error)
+
+	// Identities gets external identities linked to the user
+	Identities(userID int) ([]Identity, error)
+
+	// PurgeDeletedUsers scrubs personal data of users of all tenants
+	// which were deleted before the time and returns the count
+	PurgeDeletedUsers(before time.Time) (int, error)
+}
+
+// TenantRepo is an optional interface of Repo for multi-tenancy
+type TenantRepo interface {
+	// WithTenant returns a Repo scoped to the tenant
+	WithTenant(tenant string) Repo
+}
+
+// ContextRepo is an optional interface of Repo whose methods
+// accept a context.Context for cancellation, deadlines and tracing
+type ContextRepo interface {
+	// RegisterByPasswordCtx gets a new user by username and password
+	RegisterByPasswordCtx(ctx context.Context, username, pass string) (int, error)
+
+	// RegisterByMobileCtx gets a new user by mobile
+	RegisterByMobileCtx(ctx context.Context, mobile string) (int, error)
+
+	// RegisterByEmailCtx gets a new user by email
+	RegisterByEmailCtx(ctx context.Context, email string) (int, error)
+
+	// LoginByPasswordCtx login system by username and password
+	// and return user id if authentication success
+	LoginByPasswordCtx(ctx context.Context, username, pass string) (int, error)
+
+	// LoginByMobileCtx login system by mobile number
+	// and return user id if authentication success
+	LoginByMobileCtx(ctx context.Context, mobile string) (int, error)
+
+	// LoginByEmailCtx login system by email address
+	// and return user id if authentication success
+	LoginByEmailCtx(ctx context.Context, email string) (int, error)
+}
+
+// ContextAccountRepo is an optional interface of Repo whose methods
+// are the context-aware version of AccountRepo
+type ContextAccountRepo interface {
+	ContextRepo
+
+	// UserCtx gets the user by id
+	UserCtx(ctx context.Context, id int) (User, error)
+
+	// UserByEmailCtx gets the user by email address
+	UserByEmailCtx(ctx context.Context, email string) (User, error)
+
+	// VerifyEmailCtx marks email address of the user as verified
+	VerifyEmailCtx(ctx context.Context, id int) error
+
+	// VerifyMobileCtx marks mobile number of the user as verified
+	VerifyMobileCtx(ctx context.Context, id int) error
+
+	// AddCredentialCtx stores a new webauthn credential
+	AddCredentialCtx(ctx context.Context, c Credential) error
+
+	// CredentialCtx gets webauthn credential by credential id
+	CredentialCtx(ctx context.Context, id string) (Credential, error)
+
+	// CredentialsCtx gets all webauthn credentials of the user
+	CredentialsCtx(ctx context.Context, userID int) ([]Credential, error)
+
+	// UpdateSignCountCtx updates signature counter of the credential
+	UpdateSignCountCtx(ctx context.Context, id string, count uint32) error
+
+	// UsersCtx gets users matched with the query and total count
+	UsersCtx(ctx context.Context, q UserQuery) ([]User, int, error)
+
+	// DisableUserCtx disables the user and prevents it from login
+	DisableUserCtx(ctx context.Context, id int) error
+
+	// EnableUserCtx enables the disabled user
+	EnableUserCtx(ctx context.Context, id int) error
+
+	// ResetPasswordCtx clears password of the user, password login
+	// is rejected until a new password is set
+	ResetPasswordCtx(ctx context.Context, id int) error
+
+	// SetPasswordCtx sets a new password for the user
+	SetPasswordCtx(ctx context.Context, id int, pass string) error
+
+	// ChangePasswordCtx sets a new password if the current one
+	// is matched, users without password can set one
+	ChangePasswordCtx(ctx context.Context, id int, current, pass string) error
+
+	// DeleteUserCtx soft deletes the user
+	DeleteUserCtx(ctx context.Context, id int) error
+
+	// AddAuditEventCtx stores an audit event
+	AddAuditEventCtx(ctx context.Context, e AuditEvent) error
+
+	// LinkUserCtx gets the local user linked to the external identity,
+	// an unlinked identity is linked to the user with the same
+	// verified email or a new user if open registration is allowed,
+	// otherwise ErrInvitationRequired is returned. Role of the user
+	// is synced
+	LinkUserCtx(ctx context.Context, i Identity, u User) (int, error)
+
+	// AddInvitationCtx stores a new invitation
+	AddInvitationCtx(ctx context.Context, i Invitation) (int, error)
+
+	// InvitationCtx gets the invitation by id
+	InvitationCtx(ctx context.Context, id int) (Invitation, error)
+
+	// InvitationByTokenCtx gets the invitation by hash of its token
+	InvitationByTokenCtx(ctx context.Context, hash string) (Invitation, error)
+
+	// RenewInvitationCtx replaces token hash and expiry of
+	// the pending invitation
+	RenewInvitationCtx(ctx context.Context, id int, hash string, expiresAt time.Time) error
+
+	// RevokeInvitationCtx revokes the pending invitation
+	RevokeInvitationCtx(ctx context.Context, id int) error
+
+	// RegisterByInvitationCtx gets a new user with email and role of
+	// the pending invitation and accepts it in one transaction
+	RegisterByInvitationCtx(ctx context.Context, id int, username, pass string) (int, error)
+
+	// AuditEventsCtx gets audit events performed by or on the user
+	AuditEventsCtx(ctx context.Context, userID int) ([]AuditEvent, error)
+
+	// IdentitiesCtx gets external identities linked to the user
+	IdentitiesCtx(ctx context.Context, userID int) ([]Identity, error)
+
+	// PurgeDeletedUsersCtx scrubs personal data of users of all tenants
+	// which were deleted before the time and returns the count
+	PurgeDeletedUsersCtx(ctx context.Context, before time.Time) (int, error)
+}
+
 // repository is an internal implement of Repo interface
 type repository struct {
-	db *gorm.DB
+	db     *gorm.DB
+	tenant string
+	hooks  *Hooks
+	// openRegistration allows LinkUser to create users
+	openRegistration bool
 }
 
-func (r repository) RegisterByPassword(username, pass string) (id int, err error) {
+func (r repository) RegisterByPasswordCtx(ctx context.Context, username, pass string) (id int, err error) {
 	u := &user{Username: username}
 
 	if u.Password, err = bcrypt.GenerateFromPassword([]byte(pass), bcryptCost); err != nil {
 		return
 	}
 
-	err, id = r.db.Create(u).Error, int(u.ID)
-
-	return
+	return r.create(ctx, u, "password")
 }
 
-func (r repository) RegisterByMobile(mobile string) (int, error) {
-	u := &user{Mobile: mobile}
-	err, id := r.db.Create(u).Error, int(u.ID)
-	return id, err
+func (r repository) RegisterByMobileCtx(ctx context.Context, mobile string) (int, error) {
+	now := time.Now()
+	return r.create(ctx, &user{Mobile: mobile, MobileVerifiedAt: &now}, "mobile")
 }
 
-func (r repository) RegisterByEmail(email string) (int, error) {
-	u := &user{Email: email}
-	err, id := r.db.Create(u).Error, int(u.ID)
-	return id, err
+func (r repository) RegisterByEmailCtx(ctx context.Context, email string) (int, error) {
+	now := time.Now()
+	return r.create(ctx, &user{Email: email, EmailVerifiedAt: &now}, "email")
 }
 
-func (r repository) LoginByPassword(username, pass string) (id int, err error) {
+func (r repository) LoginByPasswordCtx(ctx context.Context, username, pass string) (id int, err error) {
 	var u user
-	if err = r.db.First(&u, "username = ?", username).Error; err != nil {
+	if err = r.scope(ctx).First(&u, "username = ?", username).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Keep the same timing with existing users
+			compareDummy(pass)
+			err = ErrInvalidCredentials
+		}
 		return
 	}
 
+	if len(u.Password) == 0 {
+		compareDummy(pass)
+		return 0, ErrInvalidCredentials
+	}
+
 	if err = bcrypt.CompareHashAndPassword(u.Password, []byte(pass)); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			err = ErrInvalidCredentials
+		}
 		return
 	}
 
-	id = int(u.ID)
+	return u.loginID()
+}
 
-	return
+func (r repository) LoginByMobileCtx(ctx context.Context, mobile string) (int, error) {
+	var u user
+	if err := r.scope(ctx).First(&u, "mobile = ?", mobile).Error; err != nil {
+		return 0, notFound(err, ErrUserNotFound)
+	}
+	return u.loginID()
+}
+
+func (r repository) LoginByEmailCtx(ctx context.Context, email string) (int, error) {
+	var u user
+	if err := r.scope(ctx).First(&u, "email = ?", email).Error; err != nil {
+		return 0, notFound(err, ErrUserNotFound)
+	}
+	return u.loginID()
+}
+
+func (r repository) UserCtx(ctx context.Context, id int) (User, error) {
+	var u user
+	err := r.scope(ctx).First(&u, id).Error
+	return u.toUser(), notFound(err, ErrUserNotFound)
 }
 
-func (r repository) LoginByMobile(mobile string) (int, error) {
+func (r repository) UserByEmailCtx(ctx context.Context, email string) (User, error) {
 	var u user
-	err := r.db.First(&u, "mobile = ?", mobile).Error
-	return int(u.ID), err
+	err := r.scope(ctx).First(&u, "email = ?", email).Error
+	return u.toUser(), notFound(err, ErrUserNotFound)
+}
+
+func (r repository) VerifyEmailCtx(ctx context.Context, id int) error {
+	return r.scope(ctx).Model(&user{}).Where("id = ?", id).
+		Update("email_verified_at", time.Now()).Error
+}
+
+func (r repository) VerifyMobileCtx(ctx context.Context, id int) error {
+	return r.scope(ctx).Model(&user{}).Where("id = ?", id).
+		Update("mobile_verified_at", time.Now()).Error
+}
+
+func (r repository) AddCredentialCtx(ctx context.Context, c Credential) error {
+	err := r.db.WithContext(ctx).Create(&credential{
+		Tenant:       r.tenant,
+		CredentialID: c.ID,
+		UserID:       uint(c.UserID),
+		PublicKey:    c.PublicKey,
+		SignCount:    c.SignCount,
+	}).Error
+
+	if err != nil && isDuplicate(err) {
+		return &DuplicateError{Field: "credential"}
+	}
+
+	return err
+}
+
+func (r repository) CredentialCtx(ctx context.Context, id string) (Credential, error) {
+	var c credential
+	err := r.scope(ctx).First(&c, "credential_id = ?", id).Error
+	return c.toCredential(), notFound(err, ErrCredentialNotFound)
+}
+
+func (r repository) CredentialsCtx(ctx context.Context, userID int) ([]Credential, error) {
+	var cs []credential
+	if err := r.scope(ctx).Find(&cs, "user_id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+
+	credentials := make([]Credential, len(cs))
+	for i, c := range cs {
+		credentials[i] = c.toCredential()
+	}
+
+	return credentials, nil
+}
+
+func (r repository) UpdateSignCountCtx(ctx context.Context, id string, count uint32) error {
+	return r.scope(ctx).Model(&credential{}).Where("credential_id = ?", id).
+		Update("sign_count", count).Error
+}
+
+func (r repository) UsersCtx(ctx context.Context, q UserQuery) (users []User, total int, err error) {
+	var (
+		us    []user
+		count int64
+	)
+
+	query := func() *gorm.DB {
+		db := r.scope(ctx).Model(&user{})
+		if q.Search != "" {
+			like := "%" + q.Search + "%"
+			db = db.Where("(username LIKE ? OR mobile LIKE ? OR email LIKE ?)", like, like, like)
+		}
+		return db
+	}
+
+	if err = query().Count(&count).Error; err != nil {
+		return
+	}
+
+	if err = query().Order("id").
+		Offset((q.Page - 1) * q.PageSize).
+		Limit(q.PageSize).
+		Find(&us).Error; err != nil {
+		return
+	}
+
+	users = make([]User, len(us))
+	for i, u := range us {
+		users[i] = u.toUser()
+	}
+
+	return users, int(count), nil
+}
+
+func (r repository) DisableUserCtx(ctx context.Context, id int) error {
+	return affected(r.scope(ctx).Model(&user{}).Where("id = ?", id).
+		Update("disabled_at", time.Now()))
 }
 
-func (r repository) LoginByEmail(email string) (int, error) {
+func (r repository) EnableUserCtx(ctx context.Context, id int) error {
+	return affected(r.scope(ctx).Model(&user{}).Where("id = ?", id).
+		Update("disabled_at", nil))
+}
+
+func (r repository) ResetPasswordCtx(ctx context.Context, id int) error {
+	return r.updatePassword(ctx, id, nil, "reset")
+}
+
+func (r repository) SetPasswordCtx(ctx context.Context, id int, pass string) error {
+	return r.setPassword(ctx, id, pass, "set")
+}
+
+func (r repository) ChangePasswordCtx(ctx context.Context, id int, current, pass string) error {
 	var u user
-	err := r.db.First(&u, "email = ?", email).Error
-	return int(u.ID), err
+	if err := r.scope(ctx).First(&u, id).Error; err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+
+	// Users registered by codes or reset by admins have no password
+	if len(u.Password) != 0 {
+		if err := bcrypt.CompareHashAndPassword(u.Password, []byte(current)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				err = ErrInvalidCredentials
+			}
+			return err
+		}
+	}
+
+	return r.setPassword(ctx, id, pass, "change")
+}
+
+func (r repository) setPassword(ctx context.Context, id int, pass, typ string) error {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(pass), bcryptCost)
+	if err != nil {
+		return err
+	}
+
+	return r.updatePassword(ctx, id, hashed, typ)
+}
+
+// updatePassword updates password of the user, synchronous password
+// changed hooks run inside the same transaction and asynchronous
+// ones run after it's committed
+func (r repository) updatePassword(ctx context.Context, id int, password interface{}, typ string) error {
+	e := Event{Name: EventPasswordChanged, UserID: id, Tenant: r.tenant, Type: typ}
+
+	if err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := affected(r.tenantScope(tx).Model(&user{}).Where("id = ?", id).
+			Update("password", password)); err != nil {
+			return err
+		}
+
+		e.Tx = tx
+
+		return r.hooks.runSync(ctx, e)
+	}); err != nil {
+		return err
+	}
+
+	r.hooks.runAsync(e)
+
+	return nil
+}
+
+func (r repository) DeleteUserCtx(ctx context.Context, id int) error {
+	return affected(r.scope(ctx).Delete(&user{}, id))
+}
+
+func (r repository) AddAuditEventCtx(ctx context.Context, e AuditEvent) error {
+	return r.db.WithContext(ctx).Create(&auditEvent{
+		Tenant:  r.tenant,
+		Action:  e.Action,
+		ActorID: uint(e.ActorID),
+		UserID:  uint(e.UserID),
+		IP:      e.IP,
+	}).Error
+}
+
+func (r repository) LinkUserCtx(ctx context.Context, i Identity, u User) (id int, err error) {
+	var e Event
+
+	if err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		id, e, err = r.link(ctx, tx, i, u)
+		return
+	}); err != nil {
+		return 0, err
+	}
+
+	if e.Name != "" {
+		r.hooks.runAsync(e)
+	}
+
+	return
+}
+
+// link gets the user linked to the identity by tx, or links
+// the identity to the user with the same verified email or a
+// new user. Unverified emails may belong to anyone, so they
+// never link identities
+func (r repository) link(ctx context.Context, tx *gorm.DB, i Identity, u User) (int, Event, error) {
+	var (
+		ident    identity
+		existing user
+		e        Event
+	)
+
+	err := r.tenantScope(tx).First(&ident, "provider = ? AND subject = ?", i.Provider, i.Subject).Error
+	switch {
+	case err == nil:
+		if err = r.tenantScope(tx).First(&existing, ident.UserID).Error; err != nil {
+			return 0, e, notFound(err, ErrUserNotFound)
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if u.Email != "" {
+			err = r.tenantScope(tx).
+				First(&existing, "email = ? AND email_verified_at IS NOT NULL", u.Email).Error
+		}
+
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if !r.openRegistration {
+				return 0, e, ErrInvitationRequired
+			}
+
+			existing = user{Username: u.Username, Email: u.Email, Role: u.Role}
+			if u.Email != "" {
+				// Email addresses from external identities are trusted
+				now := time.Now()
+				existing.EmailVerifiedAt = &now
+			}
+			e, err = r.insert(ctx, tx, &existing, "link")
+		}
+
+		if err != nil {
+			return 0, e, err
+		}
+
+		if err = tx.Create(&identity{
+			Tenant:   r.tenant,
+			Provider: i.Provider,
+			Subject:  i.Subject,
+			UserID:   existing.ID,
+		}).Error; err != nil {
+			if isDuplicate(err) {
+				err = &DuplicateError{Field: "identity"}
+			}
+			return 0, e, err
+		}
+	default:
+		return 0, e, err
+	}
+
+	// The provider owns the role only if it tells, then the
+	// role is cleared once no group maps
+	if i.SyncRole && u.Role != existing.Role {
+		if err = tx.Model(&user{}).Where("id = ?", existing.ID).
+			Update("role", u.Role).Error; err != nil {
+			return 0, e, err
+		}
+	}
+
+	id, err := existing.loginID()
+	return id, e, err
+}
+
+func (r repository) AddInvitationCtx(ctx context.Context, i Invitation) (int, error) {
+	inv := &invitation{
+		Tenant:    r.tenant,
+		Email:     i.Email,
+		Role:      i.Role,
+		InviterID: uint(i.InviterID),
+		TokenHash: i.TokenHash,
+		ExpiresAt: i.ExpiresAt,
+	}
+
+	if err := r.db.WithContext(ctx).Create(inv).Error; err != nil {
+		return 0, err
+	}
+
+	return int(inv.ID), nil
+}
+
+func (r repository) InvitationCtx(ctx context.Context, id int) (Invitation, error) {
+	var i invitation
+	err := r.scope(ctx).First(&i, id).Error
+	return i.toInvitation(), notFound(err, ErrInvitationNotFound)
+}
+
+func (r repository) InvitationByTokenCtx(ctx context.Context, hash string) (Invitation, error) {
+	var i invitation
+	err := r.scope(ctx).First(&i, "token_hash = ?", hash).Error
+	return i.toInvitation(), notFound(err, ErrInvitationNotFound)
+}
+
+func (r repository) RenewInvitationCtx(ctx context.Context, id int, hash string, expiresAt time.Time) error {
+	return affectedOr(r.scope(ctx).Model(&invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{"token_hash": hash, "expires_at": expiresAt}), ErrInvitationNotFound)
+}
+
+func (r repository) RevokeInvitationCtx(ctx context.Context, id int) error {
+	return affectedOr(r.scope(ctx).Model(&invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()), ErrInvitationNotFound)
+}
+
+func (r repository) RegisterByInvitationCtx(ctx context.Context, id int, username, pass string) (userID int, err error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(pass), bcryptCost)
+	if err != nil {
+		return
+	}
+
+	var e Event
+
+	if err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		var i invitation
+		if err = r.tenantScope(tx).First(&i, id).Error; err != nil {
+			return notFound(err, ErrInvalidInvitation)
+		}
+
+		// Accept the invitation first so that it can't be
+		// used by concurrent registrations
+		now := time.Now()
+		if err = affectedOr(r.tenantScope(tx).Model(&invitation{}).
+			Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", id, now).
+			Update("accepted_at", now), ErrInvalidInvitation); err != nil {
+			return
+		}
+
+		u := &user{
+			Username:        username,
+			Password:        hashed,
+			Role:            i.Role,
+			Email:           i.Email,
+			EmailVerifiedAt: &now,
+		}
+
+		if e, err = r.insert(ctx, tx, u, "invitation"); err != nil {
+			return
+		}
+
+		userID = int(u.ID)
+
+		return
+	}); err != nil {
+		return 0, err
+	}
+
+	r.hooks.runAsync(e)
+
+	return
+}
+
+func (r repository) AuditEventsCtx(ctx context.Context, userID int) ([]AuditEvent, error) {
+	var es []auditEvent
+	if err := r.scope(ctx).Order("id").
+		Find(&es, "user_id = ? OR actor_id = ?", userID, userID).Error; err != nil {
+		return nil, err
+	}
+
+	events := make([]AuditEvent, len(es))
+	for i, e := range es {
+		events[i] = e.toAuditEvent()
+	}
+
+	return events, nil
+}
+
+func (r repository) IdentitiesCtx(ctx context.Context, userID int) ([]Identity, error) {
+	var is []identity
+	if err := r.scope(ctx).Order("id").
+		Find(&is, "user_id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+
+	identities := make([]Identity, len(is))
+	for i, ident := range is {
+		identities[i] = Identity{Provider: ident.Provider, Subject: ident.Subject}
+	}
+
+	return identities, nil
+}
+
+func (r repository) PurgeDeletedUsersCtx(ctx context.Context, before time.Time) (count int, err error) {
+	var us []user
+
+	// Scrubbed users have no identity left
+	if err = r.db.WithContext(ctx).Unscoped().
+		Where("deleted_at < ?", before).
+		Where("(username IS NOT NULL OR mobile IS NOT NULL OR email IS NOT NULL)").
+		Find(&us).Error; err != nil {
+		return
+	}
+
+	for _, u := range us {
+		if err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return scrub(tx, u)
+		}); err != nil {
+			return
+		}
+		count++
+	}
+
+	return
+}
+
+// scrub removes personal data of the deleted user, the row
+// is kept so that references to its id are still valid
+func scrub(tx *gorm.DB, u user) error {
+	if err := tx.Unscoped().Model(&user{}).Where("id = ?", u.ID).
+		Updates(map[string]interface{}{
+			"username":           nil,
+			"password":           nil,
+			"role":               "",
+			"mobile":             nil,
+			"mobile_verified_at": nil,
+			"email":              nil,
+			"email_verified_at":  nil,
+		}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Unscoped().Delete(&credential{}, "user_id = ?", u.ID).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Unscoped().Delete(&identity{}, "user_id = ?", u.ID).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(&auditEvent{}).Where("actor_id = ?", u.ID).
+		Update("ip", "").Error; err != nil {
+		return err
+	}
+
+	if u.Email == "" {
+		return nil
+	}
+
+	return tx.Model(&invitation{}).Where("tenant = ? AND email = ?", u.Tenant, u.Email).
+		Update("email", "").Error
+}
+
+// affected turns updates on missing users into
+// ErrUserNotFound
+func affected(tx *gorm.DB) error {
+	return affectedOr(tx, ErrUserNotFound)
+}
+
+// affectedOr turns updates which affect nothing
+// into the target
+func affectedOr(tx *gorm.DB, target error) error {
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	if tx.RowsAffected == 0 {
+		return target
+	}
+
+	return nil
+}
+
+// notFound turns gorm.ErrRecordNotFound into the target
+func notFound(err, target error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return target
+	}
+	return err
 }
 
+// compareDummy compares password with a dummy hash so that
+// missing users take the same time as existing ones
+func compareDummy(pass string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dawn"), bcryptCost)
+	})
+
+	_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(pass))
+}
+
+func (r repository) WithTenant(tenant string) Repo {
+	r.tenant = tenant
+	return r
+}
+
+// scope limits queries to the tenant of the repository
+func (r repository) scope(ctx context.Context) *gorm.DB {
+	return r.tenantScope(r.db.WithContext(ctx))
+}
+
+// tenantScope limits queries of db, e.g. a transaction, to
+// the tenant of the repository
+func (r repository) tenantScope(db *gorm.DB) *gorm.DB {
+	return db.Where("tenant = ?", r.tenant)
+}
+
+// create inserts the user into the tenant of the repository,
+// synchronous registered hooks run inside the same transaction
+// and asynchronous ones run after it's committed
+func (r repository) create(ctx context.Context, u *user, typ string) (int, error) {
+	var e Event
+
+	if err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		e, err = r.insert(ctx, tx, u, typ)
+		return
+	}); err != nil {
+		return 0, err
+	}
+
+	r.hooks.runAsync(e)
+
+	return int(u.ID), nil
+}
+
+// insert inserts the user by tx and runs synchronous registered
+// hooks with it, empty identities are left as NULL so that they
+// won't conflict with each other on unique indexes. The event is
+// returned for asynchronous hooks after tx is committed
+func (r repository) insert(ctx context.Context, tx *gorm.DB, u *user, typ string) (Event, error) {
+	var omit []string
+	if u.Username == "" {
+		omit = append(omit, "Username")
+	}
+	if u.Mobile == "" {
+		omit = append(omit, "Mobile")
+	}
+	if u.Email == "" {
+		omit = append(omit, "Email")
+	}
+
+	u.Tenant = r.tenant
+
+	if err := tx.Omit(omit...).Create(u).Error; err != nil {
+		if isDuplicate(err) {
+			err = &DuplicateError{Field: u.identity()}
+		}
+		return Event{}, err
+	}
+
+	e := Event{
+		Name:     EventRegistered,
+		UserID:   int(u.ID),
+		Tenant:   r.tenant,
+		Type:     typ,
+		Identity: u.identityValue(),
+		Tx:       tx,
+	}
+
+	if err := r.hooks.runSync(ctx, e); err != nil {
+		return Event{}, err
+	}
+
+	return e, nil
+}
+
+// user identities are unique per tenant
 type user struct {
 	gorm.Model
 
-	Username string `gorm:"uniqueIndex"`
-	Password []byte
-	Mobile   string `gorm:"uniqueIndex"`
-	Email    string `gorm:"uniqueIndex"`
+	Tenant           string `gorm:"size:64;default:'';uniqueIndex:idx_users_tenant_username;uniqueIndex:idx_users_tenant_mobile;uniqueIndex:idx_users_tenant_email"`
+	Username         string `gorm:"uniqueIndex:idx_users_tenant_username"`
+	Password         []byte
+	Role             string `gorm:"size:32;default:''"`
+	Mobile           string `gorm:"uniqueIndex:idx_users_tenant_mobile"`
+	MobileVerifiedAt *time.Time
+	Email            string `gorm:"uniqueIndex:idx_users_tenant_email"`
+	EmailVerifiedAt  *time.Time
+	DisabledAt       *time.Time
+}
+
+// identity gets the identity field which is set
+func (u user) identity() string {
+	switch {
+	case u.Username != "":
+		return "username"
+	case u.Mobile != "":
+		return "mobile"
+	default:
+		return "email"
+	}
+}
+
+// identityValue gets value of the identity field which is set
+func (u user) identityValue() string {
+	switch {
+	case u.Username != "":
+		return u.Username
+	case u.Mobile != "":
+		return u.Mobile
+	default:
+		return u.Email
+	}
+}
+
+// loginID returns id of the user if it's allowed to login
+func (u user) loginID() (int, error) {
+	if u.DisabledAt != nil {
+		return 0, ErrUserDisabled
+	}
+	return int(u.ID), nil
+}
+
+func (u user) toUser() User {
+	return User{
+		ID:               int(u.ID),
+		Tenant:           u.Tenant,
+		Username:         u.Username,
+		Role:             u.Role,
+		Mobile:           u.Mobile,
+		MobileVerifiedAt: u.MobileVerifiedAt,
+		Email:            u.Email,
+		EmailVerifiedAt:  u.EmailVerifiedAt,
+		DisabledAt:       u.DisabledAt,
+		CreatedAt:        u.CreatedAt,
+	}
+}
+
+// User is the domain object of an auth user
+type User = domain.User
+
+// UserQuery filters and paginates users
+type UserQuery = domain.UserQuery
+
+// credential is a webauthn credential linked to user
+type credential struct {
+	gorm.Model
+
+	Tenant       string `gorm:"size:64;default:'';index"`
+	CredentialID string `gorm:"size:255;uniqueIndex"`
+	UserID       uint   `gorm:"index"`
+	PublicKey    []byte
+	SignCount    uint32
+}
+
+// TableName overrides table name of credential
+func (credential) TableName() string {
+	return "webauthn_credentials"
+}
+
+func (c credential) toCredential() Credential {
+	return Credential{
+		ID:        c.CredentialID,
+		UserID:    int(c.UserID),
+		PublicKey: c.PublicKey,
+		SignCount: c.SignCount,
+		CreatedAt: c.CreatedAt,
+	}
+}
+
+// Credential is the domain object of a webauthn credential
+type Credential = domain.Credential
+
+// auditEvent is a record of sensitive actions
+type auditEvent struct {
+	gorm.Model
+
+	Tenant  string `gorm:"size:64;default:'';index"`
+	Action  string `gorm:"size:64;index"`
+	ActorID uint   `gorm:"index"`
+	UserID  uint   `gorm:"index"`
+	IP      string `gorm:"size:64"`
+}
+
+// TableName overrides table name of audit event
+func (auditEvent) TableName() string {
+	return "auth_audit_events"
+}
+
+func (e auditEvent) toAuditEvent() AuditEvent {
+	return AuditEvent{
+		ID:        int(e.ID),
+		Action:    e.Action,
+		ActorID:   int(e.ActorID),
+		UserID:    int(e.UserID),
+		IP:        e.IP,
+		CreatedAt: e.CreatedAt,
+	}
+}
+
+// AuditEvent is the domain object of an audit event
+type AuditEvent = domain.AuditEvent
+
+// identity links an external identity to a user
+type identity struct {
+	gorm.Model
+
+	Tenant   string `gorm:"size:64;default:'';uniqueIndex:idx_identities_tenant_provider_subject"`
+	Provider string `gorm:"size:32;uniqueIndex:idx_identities_tenant_provider_subject"`
+	Subject  string `gorm:"size:255;uniqueIndex:idx_identities_tenant_provider_subject"`
+	UserID   uint   `gorm:"index"`
+}
+
+// TableName overrides table name of identity
+func (identity) TableName() string {
+	return "auth_identities"
+}
+
+// Identity is the domain object of an external identity
+type Identity = domain.Identity
+
+// invitation invites someone to register with the email
+type invitation struct {
+	gorm.Model
+
+	Tenant     string `gorm:"size:64;default:'';index"`
+	Email      string `gorm:"size:255;index"`
+	Role       string `gorm:"size:32;default:''"`
+	InviterID  uint   `gorm:"index"`
+	TokenHash  string `gorm:"size:64;uniqueIndex"`
+	ExpiresAt  time.Time
+	AcceptedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+// TableName overrides table name of invitation
+func (invitation) TableName() string {
+	return "auth_invitations"
 }
+
+func (i invitation) toInvitation() Invitation {
+	return Invitation{
+		ID:         int(i.ID),
+		Tenant:     i.Tenant,
+		Email:      i.Email,
+		Role:       i.Role,
+		InviterID:  int(i.InviterID),
+		TokenHash:  i.TokenHash,
+		ExpiresAt:  i.ExpiresAt,
+		AcceptedAt: i.AcceptedAt,
+		RevokedAt:  i.RevokedAt,
+		CreatedAt:  i.CreatedAt,
+	}
+}
+
+// Invitation is the domain object of an invitation
+type Invitation = domain.Invitation
@@ -1,12 +1,14 @@
 package auth
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/go-dawn/pkg/deck"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/crypto/bcrypt"
-	"gorm.io/gorm"
 )
 
 func Test_Auth_Repo_RegisterByPassword(t *testing.T) {
@@ -31,7 +33,7 @@ func Test_Auth_Repo_RegisterByPassword(t *testing.T) {
 		repo.createUser(t, username, pass)
 
 		_, err := repo.RegisterByPassword(username, pass)
-		at.NotNil(err)
+		at.True(errors.Is(err, ErrDuplicate))
 		at.Contains(err.Error(), "username")
 	})
 }
@@ -47,6 +49,10 @@ func Test_Auth_Repo_RegisterByMobile(t *testing.T) {
 		id, err := repo.RegisterByMobile(mobile)
 		at.Nil(err)
 		at.Equal(1, id)
+
+		u, err := repo.User(id)
+		at.Nil(err)
+		at.True(u.MobileVerified())
 	})
 
 	t.Run("exist", func(t *testing.T) {
@@ -54,7 +60,7 @@ func Test_Auth_Repo_RegisterByMobile(t *testing.T) {
 		repo.createMobileUser(t, mobile)
 
 		_, err := repo.RegisterByMobile(mobile)
-		at.NotNil(err)
+		at.True(errors.Is(err, ErrDuplicate))
 		at.Contains(err.Error(), "mobile")
 	})
 }
@@ -70,6 +76,10 @@ func Test_Auth_Repo_RegisterByEmail(t *testing.T) {
 		id, err := repo.RegisterByEmail(email)
 		at.Nil(err)
 		at.Equal(1, id)
+
+		u, err := repo.User(id)
+		at.Nil(err)
+		at.True(u.EmailVerified())
 	})
 
 	t.Run("exist", func(t *testing.T) {
@@ -77,7 +87,7 @@ func Test_Auth_Repo_RegisterByEmail(t *testing.T) {
 		repo.createEmailUser(t, email)
 
 		_, err := repo.RegisterByEmail(email)
-		at.NotNil(err)
+		at.True(errors.Is(err, ErrDuplicate))
 		at.Contains(err.Error(), "email")
 	})
 }
@@ -96,7 +106,9 @@ func Test_Auth_Repo_LoginByPassword(t *testing.T) {
 
 		_, err := repo.LoginByPassword(username, pass)
 
-		at.Equal(gorm.ErrRecordNotFound, err)
+		at.Equal(ErrInvalidCredentials, err)
+		// A dummy hash is compared to keep the same timing
+		at.NotNil(dummyHash)
 	})
 
 	t.Run("wrong password", func(t *testing.T) {
@@ -105,7 +117,7 @@ func Test_Auth_Repo_LoginByPassword(t *testing.T) {
 		repo.createUser(t, username, pass)
 
 		_, err := repo.LoginByPassword(username, pass+"1")
-		at.Equal(bcrypt.ErrMismatchedHashAndPassword, err)
+		at.Equal(ErrInvalidCredentials, err)
 	})
 
 	t.Run("success", func(t *testing.T) {
@@ -130,7 +142,7 @@ func Test_Auth_Repo_LoginByMobile(t *testing.T) {
 
 		_, err := repo.LoginByMobile(mobile)
 
-		at.Equal(gorm.ErrRecordNotFound, err)
+		at.Equal(ErrUserNotFound, err)
 	})
 
 	t.Run("success", func(t *testing.T) {
@@ -155,7 +167,7 @@ func Test_Auth_Repo_LoginByEmail(t *testing.T) {
 
 		_, err := repo.LoginByEmail(email)
 
-		at.Equal(gorm.ErrRecordNotFound, err)
+		at.Equal(ErrUserNotFound, err)
 	})
 
 	t.Run("success", func(t *testing.T) {
@@ -169,9 +181,714 @@ func Test_Auth_Repo_LoginByEmail(t *testing.T) {
 	})
 }
 
+func Test_Auth_Repo_User(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	t.Run("non-exist", func(t *testing.T) {
+		repo := getRepo(t)
+
+		_, err := repo.User(1)
+
+		at.Equal(ErrUserNotFound, err)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		repo := getRepo(t)
+
+		repo.createUser(t, "username", "pass")
+
+		u, err := repo.User(1)
+		at.Nil(err)
+		at.Equal(1, u.ID)
+		at.Equal("username", u.Username)
+		at.False(u.EmailVerified())
+		at.False(u.MobileVerified())
+	})
+}
+
+func Test_Auth_Repo_UserByEmail(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+	email := "kiyonlin@gmail.com"
+
+	t.Run("non-exist", func(t *testing.T) {
+		repo := getRepo(t)
+
+		_, err := repo.UserByEmail(email)
+
+		at.Equal(ErrUserNotFound, err)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		repo := getRepo(t)
+
+		repo.createEmailUser(t, email)
+
+		u, err := repo.UserByEmail(email)
+		at.Nil(err)
+		at.Equal(1, u.ID)
+		at.Equal(email, u.Email)
+	})
+}
+
+func Test_Auth_Repo_VerifyEmail(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+	repo := getRepo(t)
+
+	u := repo.createEmailUser(t, "kiyonlin@gmail.com")
+
+	at.Nil(repo.VerifyEmail(int(u.ID)))
+
+	v, err := repo.User(int(u.ID))
+	at.Nil(err)
+	at.True(v.EmailVerified())
+}
+
+func Test_Auth_Repo_VerifyMobile(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+	repo := getRepo(t)
+
+	u := repo.createMobileUser(t, "13600008888")
+
+	at.Nil(repo.VerifyMobile(int(u.ID)))
+
+	v, err := repo.User(int(u.ID))
+	at.Nil(err)
+	at.True(v.MobileVerified())
+}
+
+func Test_Auth_Repo_Credential(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+	repo := getRepo(t)
+
+	_, err := repo.Credential("id")
+	at.Equal(ErrCredentialNotFound, err)
+
+	at.Nil(repo.AddCredential(Credential{ID: "id", UserID: 1, PublicKey: []byte("key"), SignCount: 1}))
+	err = repo.AddCredential(Credential{ID: "id", UserID: 2})
+	at.Equal(&DuplicateError{Field: "credential"}, err)
+
+	c, err := repo.Credential("id")
+	at.Nil(err)
+	at.Equal(1, c.UserID)
+	at.Equal([]byte("key"), c.PublicKey)
+	at.Equal(uint32(1), c.SignCount)
+
+	cs, err := repo.Credentials(1)
+	at.Nil(err)
+	at.Len(cs, 1)
+
+	cs, err = repo.Credentials(2)
+	at.Nil(err)
+	at.Len(cs, 0)
+
+	at.Nil(repo.UpdateSignCount("id", 10))
+
+	c, err = repo.Credential("id")
+	at.Nil(err)
+	at.Equal(uint32(10), c.SignCount)
+}
+
+func Test_Auth_Repo_Users(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+	repo := getRepo(t)
+
+	for _, name := range []string{"alice", "bob", "carol"} {
+		repo.createUser(t, name, "pass")
+	}
+	repo.createEmailUser(t, "bob@example.com")
+
+	users, total, err := repo.Users(UserQuery{Page: 1, PageSize: 2})
+	at.Nil(err)
+	at.Equal(4, total)
+	at.Len(users, 2)
+	at.Equal("alice", users[0].Username)
+
+	users, total, err = repo.Users(UserQuery{Page: 2, PageSize: 2})
+	at.Nil(err)
+	at.Equal(4, total)
+	at.Len(users, 2)
+
+	users, total, err = repo.Users(UserQuery{Search: "bob", Page: 1, PageSize: 10})
+	at.Nil(err)
+	at.Equal(2, total)
+	at.Len(users, 2)
+
+	_, total, err = repo.WithTenant("acme").(repository).Users(UserQuery{Page: 1, PageSize: 10})
+	at.Nil(err)
+	at.Equal(0, total)
+}
+
+func Test_Auth_Repo_DisableUser(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+	repo := getRepo(t)
+
+	at.Equal(ErrUserNotFound, repo.DisableUser(1))
+	at.Equal(ErrUserNotFound, repo.EnableUser(1))
+
+	u := repo.createUser(t, "username", "pass")
+	m := repo.createMobileUser(t, "13600008888")
+	e := repo.createEmailUser(t, "a@example.com")
+
+	for _, id := range []uint{u.ID, m.ID, e.ID} {
+		at.Nil(repo.DisableUser(int(id)))
+
+		du, err := repo.User(int(id))
+		at.Nil(err)
+		at.True(du.Disabled())
+	}
+
+	_, err := repo.LoginByPassword("username", "pass")
+	at.Equal(ErrUserDisabled, err)
+
+	_, err = repo.LoginByMobile("13600008888")
+	at.Equal(ErrUserDisabled, err)
+
+	_, err = repo.LoginByEmail("a@example.com")
+	at.Equal(ErrUserDisabled, err)
+
+	at.Nil(repo.EnableUser(int(u.ID)))
+
+	id, err := repo.LoginByPassword("username", "pass")
+	at.Nil(err)
+	at.Equal(int(u.ID), id)
+}
+
+func Test_Auth_Repo_ResetPassword(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+	repo := getRepo(t)
+
+	at.Equal(ErrUserNotFound, repo.ResetPassword(1))
+	at.Equal(ErrUserNotFound, repo.SetPassword(1, "new"))
+
+	u := repo.createUser(t, "username", "pass")
+
+	at.Nil(repo.ResetPassword(int(u.ID)))
+
+	_, err := repo.LoginByPassword("username", "pass")
+	at.Equal(ErrInvalidCredentials, err)
+
+	at.Nil(repo.SetPassword(int(u.ID), "new"))
+
+	_, err = repo.LoginByPassword("username", "pass")
+	at.NotNil(err)
+
+	id, err := repo.LoginByPassword("username", "new")
+	at.Nil(err)
+	at.Equal(int(u.ID), id)
+}
+
+func Test_Auth_Repo_ChangePassword(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+	repo := getRepo(t)
+
+	at.Equal(ErrUserNotFound, repo.ChangePassword(1, "pass", "new"))
+
+	u := repo.createUser(t, "username", "pass")
+
+	at.Equal(ErrInvalidCredentials, repo.ChangePassword(int(u.ID), "wrong", "new"))
+	at.Nil(repo.ChangePassword(int(u.ID), "pass", "new"))
+
+	id, err := repo.LoginByPassword("username", "new")
+	at.Nil(err)
+	at.Equal(int(u.ID), id)
+
+	// Users without password set one without the current one
+	at.Nil(repo.ResetPassword(int(u.ID)))
+	at.Nil(repo.ChangePassword(int(u.ID), "", "again"))
+
+	_, err = repo.LoginByPassword("username", "again")
+	at.Nil(err)
+}
+
+func Test_Auth_Repo_DeleteUser(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+	repo := getRepo(t)
+
+	at.Equal(ErrUserNotFound, repo.DeleteUser(1))
+
+	u := repo.createUser(t, "username", "pass")
+
+	at.Nil(repo.DeleteUser(int(u.ID)))
+
+	_, err := repo.User(int(u.ID))
+	at.Equal(ErrUserNotFound, err)
+
+	_, err = repo.LoginByPassword("username", "pass")
+	at.Equal(ErrInvalidCredentials, err)
+
+	var count int64
+	at.Nil(repo.db.Unscoped().Model(&user{}).Count(&count).Error)
+	at.Equal(int64(1), count)
+}
+
+func Test_Auth_Repo_AddAuditEvent(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+	repo := getRepo(t).WithTenant("acme").(repository)
+
+	at.Nil(repo.AddAuditEvent(AuditEvent{
+		Action:  "impersonate",
+		ActorID: 1,
+		UserID:  2,
+		IP:      "127.0.0.1",
+	}))
+
+	var e auditEvent
+	at.Nil(repo.db.First(&e).Error)
+	at.Equal("acme", e.Tenant)
+	at.Equal("impersonate", e.Action)
+	at.Equal(uint(1), e.ActorID)
+	at.Equal(uint(2), e.UserID)
+	at.Equal("127.0.0.1", e.IP)
+}
+
+func Test_Auth_Repo_AuditEvents(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+	repo := getRepo(t)
+
+	at.Nil(repo.AddAuditEvent(AuditEvent{Action: "impersonate", ActorID: 1, UserID: 2, IP: "127.0.0.1"}))
+	at.Nil(repo.AddAuditEvent(AuditEvent{Action: "impersonate", ActorID: 2, UserID: 3}))
+	at.Nil(repo.WithTenant("acme").(repository).AddAuditEvent(AuditEvent{Action: "impersonate", ActorID: 1, UserID: 2}))
+
+	events, err := repo.AuditEvents(2)
+	at.Nil(err)
+	at.Len(events, 2)
+	at.Equal(1, events[0].ActorID)
+	at.Equal("127.0.0.1", events[0].IP)
+	at.Equal(3, events[1].UserID)
+
+	events, err = repo.AuditEvents(4)
+	at.Nil(err)
+	at.Len(events, 0)
+}
+
+func Test_Auth_Repo_Identities(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+	repo := getRepo(t)
+	repo.openRegistration = true
+
+	id, err := repo.LinkUser(Identity{Provider: "ldap", Subject: "uid=kiyon"}, User{Username: "kiyon"})
+	at.Nil(err)
+	_, err = repo.LinkUser(Identity{Provider: "ldap", Subject: "uid=bob"}, User{Username: "bob"})
+	at.Nil(err)
+
+	identities, err := repo.Identities(id)
+	at.Nil(err)
+	at.Equal([]Identity{{Provider: "ldap", Subject: "uid=kiyon"}}, identities)
+
+	identities, err = repo.WithTenant("acme").(repository).Identities(id)
+	at.Nil(err)
+	at.Len(identities, 0)
+}
+
+func Test_Auth_Repo_PurgeDeletedUsers(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+	repo := getRepo(t)
+
+	deleted := repo.createUser(t, "deleted", "pass")
+	recent := repo.createUser(t, "recent", "pass")
+	active := repo.createUser(t, "active", "pass")
+
+	email := "deleted@example.com"
+	at.Nil(repo.db.Model(deleted).Update("email", email).Error)
+	at.Nil(repo.AddCredential(Credential{ID: "credential", UserID: int(deleted.ID)}))
+	at.Nil(repo.AddAuditEvent(AuditEvent{Action: "impersonate", ActorID: int(deleted.ID), UserID: int(active.ID), IP: "127.0.0.1"}))
+	_, err := repo.AddInvitation(Invitation{Email: email, TokenHash: "hash", ExpiresAt: time.Now()})
+	at.Nil(err)
+
+	at.Nil(repo.DeleteUser(int(deleted.ID)))
+	at.Nil(repo.DeleteUser(int(recent.ID)))
+
+	past := time.Now().Add(-time.Hour * 2)
+	at.Nil(repo.db.Unscoped().Model(deleted).Update("deleted_at", past).Error)
+
+	count, err := repo.PurgeDeletedUsers(time.Now().Add(-time.Hour))
+	at.Nil(err)
+	at.Equal(1, count)
+
+	var u user
+	at.Nil(repo.db.Unscoped().First(&u, deleted.ID).Error)
+	at.Empty(u.Username)
+	at.Empty(u.Email)
+	at.Empty(u.Password)
+	at.NotNil(u.DeletedAt)
+
+	var r user
+	at.Nil(repo.db.Unscoped().First(&r, recent.ID).Error)
+	at.Equal("recent", r.Username)
+
+	_, err = repo.Credential("credential")
+	at.Equal(ErrCredentialNotFound, err)
+
+	events, err := repo.AuditEvents(int(deleted.ID))
+	at.Nil(err)
+	at.Len(events, 1)
+	at.Empty(events[0].IP)
+
+	i, err := repo.Invitation(1)
+	at.Nil(err)
+	at.Empty(i.Email)
+
+	// Scrubbed users are skipped
+	count, err = repo.PurgeDeletedUsers(time.Now().Add(-time.Hour))
+	at.Nil(err)
+	at.Equal(0, count)
+
+	// The identity can be taken again
+	_, err = repo.RegisterByPassword("deleted", "pass")
+	at.Nil(err)
+}
+
+func Test_Auth_Repo_LinkUser(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+	repo := getRepo(t)
+	repo.openRegistration = true
+
+	identityOf := func(name string) Identity {
+		return Identity{Provider: "ldap", Subject: "uid=" + name + ",dc=example,dc=com"}
+	}
+
+	t.Run("create", func(t *testing.T) {
+		id, err := repo.LinkUser(identityOf("kiyon"), User{Username: "kiyon", Email: "kiyon@example.com", Role: "admin"})
+		at.Nil(err)
+
+		u, err := repo.User(id)
+		at.Nil(err)
+		at.Equal("kiyon", u.Username)
+		at.Equal("admin", u.Role)
+		at.True(u.EmailVerified())
+
+		_, err = repo.LoginByPassword("kiyon", "")
+		at.Equal(ErrInvalidCredentials, err)
+
+		// Linked by the identity even if the username is changed
+		linked, err := repo.LinkUser(identityOf("kiyon"), User{Username: "renamed", Role: "admin"})
+		at.Nil(err)
+		at.Equal(id, linked)
+	})
+
+	t.Run("sync role", func(t *testing.T) {
+		i := identityOf("carol")
+		i.SyncRole = true
+
+		id, err := repo.LinkUser(i, User{Username: "carol", Role: "editor"})
+		at.Nil(err)
+
+		_, err = repo.LinkUser(i, User{Username: "carol"})
+		at.Nil(err)
+
+		u, err := repo.User(id)
+		at.Nil(err)
+		at.Equal("", u.Role)
+	})
+
+	t.Run("keep local role", func(t *testing.T) {
+		id, err := repo.LinkUser(identityOf("frank"), User{Username: "frank"})
+		at.Nil(err)
+		at.Nil(repo.db.Model(&user{}).Where("id = ?", id).Update("role", "editor").Error)
+
+		_, err = repo.LinkUser(identityOf("frank"), User{Username: "frank"})
+		at.Nil(err)
+
+		u, err := repo.User(id)
+		at.Nil(err)
+		at.Equal("editor", u.Role)
+	})
+
+	t.Run("same username", func(t *testing.T) {
+		repo.createUser(t, "bob", "pass")
+
+		_, err := repo.LinkUser(identityOf("bob"), User{Username: "bob", Role: "editor"})
+		at.Equal(&DuplicateError{Field: "username"}, err)
+	})
+
+	t.Run("verified email", func(t *testing.T) {
+		u := repo.createEmailUser(t, "alice@example.com")
+		at.Nil(repo.VerifyEmail(int(u.ID)))
+
+		id, err := repo.LinkUser(identityOf("alice"), User{Username: "alice", Email: "alice@example.com"})
+		at.Nil(err)
+		at.Equal(int(u.ID), id)
+
+		linked, err := repo.LinkUser(identityOf("alice"), User{Username: "alice", Email: "alice@example.com"})
+		at.Nil(err)
+		at.Equal(id, linked)
+	})
+
+	t.Run("unverified email", func(t *testing.T) {
+		repo.createEmailUser(t, "dave@example.com")
+
+		// Unverified emails never link, so the taken email conflicts
+		_, err := repo.LinkUser(identityOf("dave"), User{Username: "dave", Email: "dave@example.com"})
+		at.True(errors.Is(err, ErrDuplicate))
+	})
+
+	t.Run("invite only", func(t *testing.T) {
+		closed := repo
+		closed.openRegistration = false
+
+		_, err := closed.LinkUser(identityOf("eve"), User{Username: "eve"})
+		at.Equal(ErrInvitationRequired, err)
+
+		// Linked identities still login
+		id, err := repo.LinkUser(identityOf("eve"), User{Username: "eve"})
+		at.Nil(err)
+
+		linked, err := closed.LinkUser(identityOf("eve"), User{Username: "eve"})
+		at.Nil(err)
+		at.Equal(id, linked)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		id, err := repo.LinkUser(identityOf("disabled"), User{Username: "disabled"})
+		at.Nil(err)
+		at.Nil(repo.DisableUser(id))
+
+		_, err = repo.LinkUser(identityOf("disabled"), User{Username: "disabled"})
+		at.Equal(ErrUserDisabled, err)
+	})
+}
+
+func Test_Auth_Repo_Invitation(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+	repo := getRepo(t)
+
+	_, err := repo.Invitation(1)
+	at.Equal(ErrInvitationNotFound, err)
+
+	_, err = repo.InvitationByToken("hash")
+	at.Equal(ErrInvitationNotFound, err)
+
+	at.Equal(ErrInvitationNotFound, repo.RenewInvitation(1, "hash", time.Now()))
+	at.Equal(ErrInvitationNotFound, repo.RevokeInvitation(1))
+
+	id, err := repo.AddInvitation(Invitation{
+		Email:     "kiyon@example.com",
+		Role:      "editor",
+		InviterID: 1,
+		TokenHash: "hash",
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	at.Nil(err)
+
+	i, err := repo.InvitationByToken("hash")
+	at.Nil(err)
+	at.Equal(id, i.ID)
+	at.Equal("kiyon@example.com", i.Email)
+	at.Equal("editor", i.Role)
+	at.Equal(1, i.InviterID)
+	at.True(i.Pending())
+
+	expiresAt := time.Now().Add(time.Hour * 2)
+	at.Nil(repo.RenewInvitation(id, "new", expiresAt))
+
+	_, err = repo.InvitationByToken("hash")
+	at.Equal(ErrInvitationNotFound, err)
+
+	i, err = repo.Invitation(id)
+	at.Nil(err)
+	at.Equal("new", i.TokenHash)
+	at.WithinDuration(expiresAt, i.ExpiresAt, time.Second)
+
+	at.Nil(repo.RevokeInvitation(id))
+
+	i, err = repo.Invitation(id)
+	at.Nil(err)
+	at.False(i.Pending())
+
+	at.Equal(ErrInvitationNotFound, repo.RevokeInvitation(id))
+	at.Equal(ErrInvitationNotFound, repo.RenewInvitation(id, "hash", expiresAt))
+}
+
+func Test_Auth_Repo_RegisterByInvitation(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+	repo := getRepo(t)
+
+	invite := func(email string, expiresAt time.Time) int {
+		id, err := repo.AddInvitation(Invitation{
+			Email:     email,
+			Role:      "editor",
+			TokenHash: email,
+			ExpiresAt: expiresAt,
+		})
+		at.Nil(err)
+		return id
+	}
+
+	t.Run("non-exist", func(t *testing.T) {
+		_, err := repo.RegisterByInvitation(100, "kiyon", "pass")
+		at.Equal(ErrInvalidInvitation, err)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		id := invite("kiyon@example.com", time.Now().Add(time.Hour))
+
+		userID, err := repo.RegisterByInvitation(id, "kiyon", "pass")
+		at.Nil(err)
+
+		u, err := repo.User(userID)
+		at.Nil(err)
+		at.Equal("kiyon", u.Username)
+		at.Equal("kiyon@example.com", u.Email)
+		at.Equal("editor", u.Role)
+		at.True(u.EmailVerified())
+
+		loginID, err := repo.LoginByPassword("kiyon", "pass")
+		at.Nil(err)
+		at.Equal(userID, loginID)
+
+		i, err := repo.Invitation(id)
+		at.Nil(err)
+		at.NotNil(i.AcceptedAt)
+
+		_, err = repo.RegisterByInvitation(id, "other", "pass")
+		at.Equal(ErrInvalidInvitation, err)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		id := invite("expired@example.com", time.Now().Add(-time.Hour))
+
+		_, err := repo.RegisterByInvitation(id, "expired", "pass")
+		at.Equal(ErrInvalidInvitation, err)
+	})
+
+	t.Run("duplicate username", func(t *testing.T) {
+		repo.createUser(t, "taken", "pass")
+		id := invite("taken@example.com", time.Now().Add(time.Hour))
+
+		_, err := repo.RegisterByInvitation(id, "taken", "pass")
+		at.True(errors.Is(err, ErrDuplicate))
+
+		i, err := repo.Invitation(id)
+		at.Nil(err)
+		at.True(i.Pending())
+	})
+
+	t.Run("hooks", func(t *testing.T) {
+		var (
+			h     Hooks
+			async = make(chan Event, 1)
+		)
+
+		hooked := repo
+		hooked.hooks = &h
+
+		h.OnRegistered(func(ctx context.Context, e Event) error {
+			// The invitation is accepted in the same transaction
+			var i invitation
+			if err := e.Tx.First(&i, "email = ?", "hooked@example.com").Error; err != nil {
+				return err
+			}
+			if i.AcceptedAt == nil {
+				return errors.New("invitation is not accepted")
+			}
+			return nil
+		})
+		h.OnRegistered(func(ctx context.Context, e Event) error {
+			async <- e
+			return nil
+		}, Async())
+
+		id := invite("hooked@example.com", time.Now().Add(time.Hour))
+
+		userID, err := hooked.RegisterByInvitation(id, "hooked", "pass")
+		at.Nil(err)
+
+		select {
+		case e := <-async:
+			at.Equal(userID, e.UserID)
+			at.Equal("invitation", e.Type)
+			at.Nil(e.Tx)
+		case <-time.After(time.Second):
+			at.Fail("async hook is not called")
+		}
+	})
+}
+
+func Test_Auth_Repo_WithTenant(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+	repo := getRepo(t)
+	acme := repo.WithTenant("acme").(repository)
+
+	id, err := repo.RegisterByPassword("admin", "pass")
+	at.Nil(err)
+
+	acmeID, err := acme.RegisterByPassword("admin", "pass")
+	at.Nil(err)
+	at.NotEqual(id, acmeID)
+
+	_, err = acme.RegisterByPassword("admin", "pass")
+	at.NotNil(err)
+
+	// Empty identities should not conflict with each other
+	_, err = acme.RegisterByPassword("guest", "pass")
+	at.Nil(err)
+	_, err = acme.RegisterByMobile("13600008888")
+	at.Nil(err)
+	_, err = acme.RegisterByEmail("a@example.com")
+	at.Nil(err)
+
+	loginID, err := acme.LoginByPassword("admin", "pass")
+	at.Nil(err)
+	at.Equal(acmeID, loginID)
+
+	u, err := acme.User(acmeID)
+	at.Nil(err)
+	at.Equal("acme", u.Tenant)
+
+	_, err = acme.User(id)
+	at.Equal(ErrUserNotFound, err)
+
+	_, err = repo.LoginByMobile("13600008888")
+	at.Equal(ErrUserNotFound, err)
+
+	at.Nil(acme.AddCredential(Credential{ID: "id", UserID: acmeID}))
+
+	_, err = acme.Credential("id")
+	at.Nil(err)
+
+	_, err = repo.Credential("id")
+	at.Equal(ErrCredentialNotFound, err)
+}
+
 func getRepo(t *testing.T) repository {
-	gdb := deck.SetupGormDB(t, &user{})
-	return repository{gdb}
+	gdb := deck.SetupGormDB(t, &user{}, &credential{}, &auditEvent{}, &invitation{}, &identity{})
+	return repository{db: gdb}
 }
 
 func (r repository) createUser(t *testing.T, username, pass string) *user {
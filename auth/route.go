@@ -1,11 +1,17 @@
 package auth
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/form3tech-oss/jwt-go"
 	"github.com/go-dawn/dawn/fiberx"
+	"github.com/go-dawn/pkg/rand"
 	"github.com/gofiber/fiber/v2"
 	jwtware "github.com/gofiber/jwt/v2"
 )
@@ -13,6 +19,13 @@ import (
 func (m module) jwt() fiber.Handler {
 	return jwtware.New(jwtware.Config{
 		SigningKey: []byte(m.SigningKey),
+		SuccessHandler: func(c *fiber.Ctx) error {
+			if err := m.checkRevoked(UserContext(c), Tenant(c), claims(c)); err != nil {
+				return errResp(c, err)
+			}
+
+			return checkTenant(c)
+		},
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			if err.Error() == "Missing or malformed JWT" {
 				return fiberx.CodeErr(fiber.StatusBadRequest, err)
@@ -23,19 +36,92 @@ func (m module) jwt() fiber.Handler {
 	})
 }
 
+const revokedPrefix = "auth:revoked:"
+
+// revokeTokens revokes all tokens of the user which are issued
+// until now in milliseconds except the one with the kept jti, the
+// mark lives as long as the longest token. Revocation is disabled
+// without Storage
+func (m module) revokeTokens(ctx context.Context, tenant string, id int, keep ...string) error {
+	if m.Storage == nil {
+		return nil
+	}
+
+	ttl := m.Expiration
+	if m.ImpersonationExpiration > ttl {
+		ttl = m.ImpersonationExpiration
+	}
+
+	mark := strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+	if len(keep) > 0 && keep[0] != "" {
+		mark += ":" + keep[0]
+	}
+
+	return m.Storage.SetCtx(ctx, revokedKey(tenant, id), []byte(mark), ttl)
+}
+
+// checkRevoked rejects tokens issued before the revocation of
+// the user, tokens without iat are treated as revoked
+func (m module) checkRevoked(ctx context.Context, tenant string, cl jwt.MapClaims) error {
+	if m.Storage == nil {
+		return nil
+	}
+
+	id, _ := cl["id"].(float64)
+
+	b, err := m.Storage.GetCtx(ctx, revokedKey(tenant, int(id)))
+	if err != nil || len(b) == 0 {
+		return err
+	}
+
+	mark := strings.SplitN(string(b), ":", 2)
+
+	revokedAt, err := strconv.ParseInt(mark[0], 10, 64)
+	if err != nil {
+		return err
+	}
+
+	if jti, _ := cl["jti"].(string); len(mark) == 2 && jti == mark[1] {
+		return nil
+	}
+
+	if iat, _ := cl["iat"].(float64); int64(math.Round(iat*1000)) <= revokedAt {
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
+
+func revokedKey(tenant string, id int) string {
+	return revokedPrefix + tenant + ":" + strconv.Itoa(id)
+}
+
 type loginForm struct {
-	// Username is account username, mobile number or email address
+	// Username is account username, mobile number, email address
+	// or webauthn credential id
 	Username string `json:"username" validate:"required"`
-	// Type can be password, mobile or email
-	Type string `json:"type" validate:"required,oneof=password mobile email"`
-	// Code can be password, sms code or email code
+	// Type can be password, mobile, email, webauthn, ldap or
+	// any type registered by RegisterStrategy
+	Type string `json:"type" validate:"required,auth_strategy"`
+	// Code can be password, sms code, email code or webauthn assertion
 	Code string `json:"code" validate:"required"`
 }
 
+var (
+	// ErrEmailNotVerified occurs when password login is blocked
+	// by an unverified email address
+	ErrEmailNotVerified = errors.New("auth: email address is not verified")
+
+	// ErrTokenRevoked occurs when the token is issued before
+	// tokens of the user are revoked
+	ErrTokenRevoked = errors.New("auth: token has been revoked")
+)
+
 func (m module) login(c *fiber.Ctx) (err error) {
 	var (
 		data loginForm
 		id   int
+		u    User
 		t    string
 	)
 
@@ -43,31 +129,191 @@ func (m module) login(c *fiber.Ctx) (err error) {
 		return
 	}
 
-	if id, err = m.authFunc(data.Type)(data.Username, data.Code); err != nil {
-		return fiberx.CodeErr(fiber.StatusUnauthorized, err, "Failed to authenticate")
+	s := m.service(c)
+	e := Event{Tenant: Tenant(c), Type: data.Type, Identity: data.Username, IP: c.IP()}
+
+	if id, err = m.authFunc(tenantContext(c), s, data.Type)(data.Username, data.Code); err != nil {
+		return m.loginFailed(c, e, err)
+	}
+
+	if u, err = s.UserCtx(UserContext(c), id); err != nil {
+		return errResp(c, err)
+	}
+
+	e.UserID = id
+
+	if u.Disabled() {
+		return m.loginFailed(c, e, ErrUserDisabled)
+	}
+
+	if m.RequireVerifiedEmail && data.Type == "password" && !u.EmailVerified() {
+		return m.loginFailed(c, e, ErrEmailNotVerified)
+	}
+
+	e.Name = EventLogin
+	if err = m.Hooks.run(UserContext(c), e); err != nil {
+		return
 	}
 
 	// Generate encoded token and send it as response.
-	if t, err = generateToken(m.SigningMethod, m.SigningKey, id, m.Expiration); err != nil {
+	if t, err = generateToken(m.SigningMethod, m.SigningKey, id, m.Expiration, userClaims(u), tenantClaims(c)); err != nil {
 		return err
 	}
 
 	return fiberx.Data(c, t)
 }
 
-func (m module) authFunc(tpy string) authenticate {
-	switch tpy {
-	case "password":
-		return m.LoginByPassword
-	case "mobile":
-		return m.LoginByMobileCode
-	case "email":
-		return m.LoginByEmailCode
-	default:
-		return func(username, code string) (i int, err error) {
-			return 0, fmt.Errorf("auth: invalid authenticate type %s", tpy)
+// loginFailed runs login failed hooks and responses the error
+func (m module) loginFailed(c *fiber.Ctx, e Event, err error) error {
+	e.Name, e.Err = EventLoginFailed, err
+
+	if hookErr := m.Hooks.run(UserContext(c), e); hookErr != nil {
+		return hookErr
+	}
+
+	return errResp(c, err)
+}
+
+// logout only runs logout hooks since tokens are stateless,
+// hooks can revoke sessions or tokens kept by the app
+func (m module) logout(c *fiber.Ctx) error {
+	e := Event{Name: EventLogout, UserID: UserID(c), Tenant: Tenant(c), IP: c.IP()}
+
+	if err := m.Hooks.run(UserContext(c), e); err != nil {
+		return err
+	}
+
+	return fiberx.Message(c, "Logged out")
+}
+
+type passwordForm struct {
+	// Current is the current password, it's ignored if
+	// the user doesn't have a password
+	Current string `json:"current_password"`
+	// Password is the new password
+	Password string `json:"password" validate:"required"`
+}
+
+// changePassword changes password of current user and revokes
+// other tokens of the user, current token is kept
+func (m module) changePassword(c *fiber.Ctx) (err error) {
+	var (
+		data passwordForm
+		id   = UserID(c)
+	)
+
+	// Impersonators can't leave a password behind
+	if RealUserID(c) != id {
+		return errResp(c, ErrPermissionDenied)
+	}
+
+	if err = fiberx.ValidateBody(c, &data); err != nil {
+		return
+	}
+
+	if err = m.service(c).ChangePasswordCtx(UserContext(c), id, data.Current, data.Password); err != nil {
+		return errResp(c, err)
+	}
+
+	jti, _ := claims(c)["jti"].(string)
+	if err = m.revokeTokens(UserContext(c), Tenant(c), id, jti); err != nil {
+		return
+	}
+
+	return fiberx.Message(c, "Password changed")
+}
+
+type verifyForm struct {
+	// Code is the verification code
+	Code string `json:"code" validate:"required"`
+}
+
+func (m module) sendEmailVerification(c *fiber.Ctx) error {
+	if err := m.service(c).SendEmailVerificationCtx(UserContext(c), UserID(c)); err != nil {
+		return errResp(c, err)
+	}
+
+	return fiberx.Message(c, "Verification code sent")
+}
+
+func (m module) verifyEmail(c *fiber.Ctx) (err error) {
+	var data verifyForm
+
+	if err = fiberx.ValidateBody(c, &data); err != nil {
+		return
+	}
+
+	if err = m.service(c).VerifyEmailCtx(UserContext(c), UserID(c), data.Code); err != nil {
+		return errResp(c, err)
+	}
+
+	return fiberx.Message(c, "Email verified")
+}
+
+func (m module) sendMobileVerification(c *fiber.Ctx) error {
+	if err := m.service(c).SendMobileVerificationCtx(UserContext(c), UserID(c)); err != nil {
+		return errResp(c, err)
+	}
+
+	return fiberx.Message(c, "Verification code sent")
+}
+
+func (m module) verifyMobile(c *fiber.Ctx) (err error) {
+	var data verifyForm
+
+	if err = fiberx.ValidateBody(c, &data); err != nil {
+		return
+	}
+
+	if err = m.service(c).VerifyMobileCtx(UserContext(c), UserID(c), data.Code); err != nil {
+		return errResp(c, err)
+	}
+
+	return fiberx.Message(c, "Mobile verified")
+}
+
+// UserID gets user id from the jwt token of current request,
+// 0 will be returned if there is no valid token
+func UserID(c *fiber.Ctx) int {
+	if id, ok := claims(c)["id"].(float64); ok {
+		return int(id)
+	}
+	return 0
+}
+
+func claims(c *fiber.Ctx) jwt.MapClaims {
+	if token, ok := c.Locals("user").(*jwt.Token); ok {
+		if claims, ok := token.Claims.(jwt.MapClaims); ok {
+			return claims
 		}
 	}
+	return nil
+}
+
+func userClaims(u User) jwt.MapClaims {
+	claims := jwt.MapClaims{
+		"email_verified":  u.EmailVerified(),
+		"mobile_verified": u.MobileVerified(),
+	}
+	if u.Role != "" {
+		claims["role"] = u.Role
+	}
+	return claims
+}
+
+// authFunc gets the authenticate function of the login type
+// from registered strategies
+func (m module) authFunc(ctx context.Context, s ContextAccountService, tpy string) authenticate {
+	strategy, ok := m.strategy(tpy)
+	if !ok {
+		return func(key, code string) (int, error) {
+			return 0, fmt.Errorf("%w %s", ErrInvalidAuthType, tpy)
+		}
+	}
+
+	return func(key, code string) (int, error) {
+		return strategy.Authenticate(ctx, s, key, code)
+	}
 }
 
 func signingMethod(method string) jwt.SigningMethod {
@@ -95,15 +341,30 @@ func signingMethod(method string) jwt.SigningMethod {
 	}
 }
 
-func generateToken(method, key string, id int, expiration time.Duration) (t string, err error) {
+func generateToken(method, key string, id int, expiration time.Duration, extra ...jwt.MapClaims) (t string, err error) {
 	// Create token
 	token := jwt.New(signingMethod(method))
 
 	// Set claims
 	claims := token.Claims.(jwt.MapClaims)
+	for _, e := range extra {
+		for k, v := range e {
+			claims[k] = v
+		}
+	}
+	now := time.Now()
 	claims["id"] = id
-	claims["exp"] = time.Now().Add(expiration).Unix()
+	claims["jti"] = rand.String(16)
+	claims["iat"] = numericDate(now)
+	claims["auth_time"] = numericDate(now)
+	claims["exp"] = now.Add(expiration).Unix()
 
 	// Generate encoded token and send it as response.
 	return token.SignedString([]byte(key))
 }
+
+// numericDate gets seconds of the time in milliseconds precision,
+// so tokens reissued right after a revocation are still valid
+func numericDate(t time.Time) float64 {
+	return float64(t.UnixNano()/int64(time.Millisecond)) / 1000
+}
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"testing"
@@ -10,9 +11,11 @@ import (
 	"github.com/gavv/httpexpect/v2"
 	"github.com/go-dawn/dawn/fiberx"
 	"github.com/go-dawn/module/auth/mocks"
+	"github.com/go-dawn/module/cache"
 	"github.com/go-dawn/pkg/deck"
 	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
 )
 
 func Test_Auth_Route_Login(t *testing.T) {
@@ -40,7 +43,9 @@ func Test_Auth_Route_Login(t *testing.T) {
 
 	t.Run("success", func(t *testing.T) {
 		mockRepo.On("LoginByPassword", username, code).
-			Once().Return(1, nil)
+			Once().Return(1, nil).
+			On("User", 1).
+			Once().Return(User{ID: 1}, nil)
 
 		resp := e.POST("/login").WithJSON(loginForm{
 			Username: username,
@@ -57,9 +62,107 @@ func Test_Auth_Route_Login(t *testing.T) {
 		})
 	})
 
+	t.Run("code login", func(t *testing.T) {
+		for _, err := range []error{ErrCodeMismatch, ErrUserNotFound} {
+			mockRepo.On("LoginByMobileCode", "10086", "123456").
+				Once().Return(0, err)
+
+			resp := e.POST("/login").WithJSON(loginForm{
+				Username: "10086",
+				Code:     "123456",
+				Type:     "mobile",
+			}).Expect()
+
+			// Unknown accounts look the same with wrong codes
+			resp.Status(fiber.StatusUnauthorized)
+			deck.AssertRespCode(resp, CodeInvalidCredentials)
+		}
+	})
+
+	t.Run("failed to get user", func(t *testing.T) {
+		mockRepo.On("LoginByPassword", username, code).
+			Once().Return(1, nil).
+			On("User", 1).
+			Once().Return(User{}, errors.New("fake error"))
+
+		resp := e.POST("/login").WithJSON(loginForm{
+			Username: username,
+			Code:     code,
+			Type:     typ,
+		}).Expect()
+
+		resp.Status(fiber.StatusInternalServerError)
+	})
+
+	t.Run("email not verified", func(t *testing.T) {
+		m.RequireVerifiedEmail = true
+		defer func() { m.RequireVerifiedEmail = false }()
+
+		mockRepo.On("LoginByPassword", username, code).
+			Once().Return(1, nil).
+			On("User", 1).
+			Once().Return(User{ID: 1}, nil)
+
+		resp := e.POST("/login").WithJSON(loginForm{
+			Username: username,
+			Code:     code,
+			Type:     typ,
+		}).Expect()
+
+		resp.Status(fiber.StatusForbidden)
+		deck.AssertRespMsg(resp, "Email not verified")
+	})
+
+	t.Run("user disabled", func(t *testing.T) {
+		now := time.Now()
+		mockRepo.On("LoginByPassword", username, code).
+			Once().Return(1, nil).
+			On("User", 1).
+			Once().Return(User{ID: 1, DisabledAt: &now}, nil)
+
+		resp := e.POST("/login").WithJSON(loginForm{
+			Username: username,
+			Code:     code,
+			Type:     typ,
+		}).Expect()
+
+		resp.Status(fiber.StatusForbidden)
+		deck.AssertRespMsg(resp, "User disabled")
+	})
+
+	t.Run("verified claims", func(t *testing.T) {
+		m.RequireVerifiedEmail = true
+		defer func() { m.RequireVerifiedEmail = false }()
+
+		now := time.Now()
+		mockRepo.On("LoginByPassword", username, code).
+			Once().Return(1, nil).
+			On("User", 1).
+			Once().Return(User{ID: 1, EmailVerifiedAt: &now}, nil)
+
+		resp := e.POST("/login").WithJSON(loginForm{
+			Username: username,
+			Code:     code,
+			Type:     typ,
+		}).Expect()
+
+		resp.Status(fiber.StatusOK)
+
+		deck.AssertRespDataCheck(resp, func(v *httpexpect.Value) {
+			token, err := jwt.Parse(v.Raw().(string), func(*jwt.Token) (interface{}, error) {
+				return []byte(m.SigningKey), nil
+			})
+			at.Nil(err)
+
+			claims := token.Claims.(jwt.MapClaims)
+			at.Equal(true, claims["email_verified"])
+			at.Equal(false, claims["mobile_verified"])
+		})
+	})
+
 	t.Run("unauthorized", func(t *testing.T) {
 		mockRepo.On("LoginByPassword", username, code).
-			Once().Return(0, errors.New("invalid"))
+			Once().Return(0, ErrInvalidCredentials)
 
 		resp := e.POST("/login").WithJSON(loginForm{
 			Username: username,
@@ -68,7 +171,21 @@ func Test_Auth_Route_Login(t *testing.T) {
 		}).Expect()
 
 		resp.Status(fiber.StatusUnauthorized)
-		deck.AssertRespMsg(resp, "Failed to authenticate")
+		deck.AssertRespCode(resp, CodeInvalidCredentials)
+		deck.AssertRespMsg(resp, "Invalid credentials")
+	})
+
+	t.Run("failed to authenticate", func(t *testing.T) {
+		mockRepo.On("LoginByPassword", username, code).
+			Once().Return(0, errors.New("fake error"))
+
+		resp := e.POST("/login").WithJSON(loginForm{
+			Username: username,
+			Code:     code,
+			Type:     typ,
+		}).Expect()
+
+		resp.Status(fiber.StatusInternalServerError)
 	})
 }
 
@@ -117,15 +234,129 @@ func Test_Auth_Route_Jwt_Middleware(t *testing.T) {
 	})
 }
 
+func Test_Auth_Route_Verification(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	m, mockService := routeModule()
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		app.Use(m.jwt())
+		app.Post("/verify/email", m.sendEmailVerification)
+		app.Post("/verify/email/confirm", m.verifyEmail)
+		app.Post("/verify/mobile", m.sendMobileVerification)
+		app.Post("/verify/mobile/confirm", m.verifyMobile)
+	})
+
+	token, err := generateToken("", m.SigningKey, 1, time.Hour)
+	at.Nil(err)
+
+	var (
+		bearer  = "Bearer " + token
+		code    = "123456"
+		mockErr = errors.New("fake error")
+	)
+
+	tests := []struct {
+		channel string
+		send    string
+		verify  string
+		message string
+		noAddr  error
+	}{
+		{"email", "SendEmailVerification", "VerifyEmail", "Email verified", ErrNoEmail},
+		{"mobile", "SendMobileVerification", "VerifyMobile", "Mobile verified", ErrNoMobile},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.channel+" no address", func(t *testing.T) {
+			mockService.On(tc.send, 1).Once().Return(tc.noAddr)
+
+			e.POST("/verify/"+tc.channel).
+				WithHeader(fiber.HeaderAuthorization, bearer).
+				Expect().
+				Status(fiber.StatusBadRequest)
+		})
+
+		t.Run(tc.channel+" send failed", func(t *testing.T) {
+			mockService.On(tc.send, 1).Once().Return(mockErr)
+
+			e.POST("/verify/"+tc.channel).
+				WithHeader(fiber.HeaderAuthorization, bearer).
+				Expect().
+				Status(fiber.StatusInternalServerError)
+		})
+
+		t.Run(tc.channel+" send success", func(t *testing.T) {
+			mockService.On(tc.send, 1).Once().Return(nil)
+
+			resp := e.POST("/verify/"+tc.channel).
+				WithHeader(fiber.HeaderAuthorization, bearer).
+				Expect().
+				Status(fiber.StatusOK)
+
+			deck.AssertRespMsg(resp, "Verification code sent")
+		})
+
+		t.Run(tc.channel+" bad request", func(t *testing.T) {
+			e.POST("/verify/"+tc.channel+"/confirm").
+				WithHeader(fiber.HeaderAuthorization, bearer).
+				Expect().
+				Status(fiber.StatusBadRequest)
+		})
+
+		t.Run(tc.channel+" code mismatch", func(t *testing.T) {
+			mockService.On(tc.verify, 1, code).Once().Return(ErrCodeMismatch)
+
+			resp := e.POST("/verify/"+tc.channel+"/confirm").
+				WithHeader(fiber.HeaderAuthorization, bearer).
+				WithJSON(verifyForm{Code: code}).
+				Expect().
+				Status(fiber.StatusBadRequest)
+
+			deck.AssertRespCode(resp, CodeCodeMismatch)
+		})
+
+		t.Run(tc.channel+" verify success", func(t *testing.T) {
+			mockService.On(tc.verify, 1, code).Once().Return(nil)
+
+			resp := e.POST("/verify/"+tc.channel+"/confirm").
+				WithHeader(fiber.HeaderAuthorization, bearer).
+				WithJSON(verifyForm{Code: code}).
+				Expect().
+				Status(fiber.StatusOK)
+
+			deck.AssertRespMsg(resp, tc.message)
+		})
+	}
+}
+
+func Test_Auth_UserID(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	app := fiber.New()
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+
+	at.Equal(0, UserID(c))
+
+	c.Locals("user", &jwt.Token{Claims: jwt.MapClaims{"id": float64(1)}})
+	at.Equal(1, UserID(c))
+}
+
 func Test_Auth_Module_AuthFunc(t *testing.T) {
 	at := assert.New(t)
 
-	m, _ := routeModule()
+	m, mockService := routeModule()
+	ctx, s := context.Background(), contextService(mockService)
 
-	at.NotNil(m.authFunc("mobile"))
-	at.NotNil(m.authFunc("email"))
+	at.NotNil(m.authFunc(ctx, s, "mobile"))
+	at.NotNil(m.authFunc(ctx, s, "email"))
 
-	fn := m.authFunc("invalid")
+	fn := m.authFunc(ctx, s, "invalid")
 	at.NotNil(fn)
 
 	_, err := fn("", "")
@@ -159,8 +390,98 @@ func Test_Auth_SigningMethod(t *testing.T) {
 	}
 }
 
-func routeModule() (module, *mocks.Service) {
-	mockService := new(mocks.Service)
+func Test_Auth_Route_ChangePassword(t *testing.T) {
+	at := assert.New(t)
+
+	m, mockService := routeModule()
+
+	cache.New().Init()
+	m.Storage = cache.Storage()
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		app.Use(m.jwt())
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendString("ok")
+		})
+		app.Put("/password", m.changePassword)
+	})
+
+	token, err := generateToken("", m.SigningKey, 1, time.Hour)
+	at.Nil(err)
+	bearer := "Bearer " + token
+
+	t.Run("impersonator", func(t *testing.T) {
+		token, err := generateToken("", m.SigningKey, 1, time.Hour, actorClaims(2))
+		at.Nil(err)
+
+		e.PUT("/password").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+token).
+			Expect().
+			Status(fiber.StatusForbidden)
+	})
+
+	t.Run("unprocessable entity", func(t *testing.T) {
+		e.PUT("/password").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			WithJSON(passwordForm{}).
+			Expect().
+			Status(fiber.StatusUnprocessableEntity)
+	})
+
+	t.Run("wrong current password", func(t *testing.T) {
+		mockService.On("ChangePassword", 1, "wrong", "new").
+			Once().Return(ErrInvalidCredentials)
+
+		resp := e.PUT("/password").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			WithJSON(passwordForm{Current: "wrong", Password: "new"}).
+			Expect().
+			Status(fiber.StatusUnauthorized)
+
+		deck.AssertRespCode(resp, CodeInvalidCredentials)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		mockService.On("ChangePassword", 1, "old", "new").
+			Once().Return(errors.New("fake error"))
+
+		e.PUT("/password").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			WithJSON(passwordForm{Current: "old", Password: "new"}).
+			Expect().
+			Status(fiber.StatusInternalServerError)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		other, err := generateToken("", m.SigningKey, 1, time.Hour)
+		at.Nil(err)
+
+		mockService.On("ChangePassword", 1, "old", "new").
+			Once().Return(nil)
+
+		resp := e.PUT("/password").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			WithJSON(passwordForm{Current: "old", Password: "new"}).
+			Expect().
+			Status(fiber.StatusOK)
+
+		deck.AssertRespMsg(resp, "Password changed")
+
+		// Other sessions are revoked and current one is kept
+		e.GET("/").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+other).
+			Expect().
+			Status(fiber.StatusUnauthorized)
+
+		e.GET("/").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusOK)
+	})
+}
+
+func routeModule() (module, *mocks.AccountService) {
+	mockService := new(mocks.AccountService)
 	return module{Config: &Config{
 		Service:    mockService,
 		SigningKey: "test",
@@ -1,5 +1,26 @@
 package auth
 
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/go-dawn/pkg/rand"
+)
+
+var (
+	// ErrNoEmail occurs when user has no email address to be verified
+	ErrNoEmail = errors.New("auth: user has no email address")
+
+	// ErrNoMobile occurs when user has no mobile number to be verified
+	ErrNoMobile = errors.New("auth: user has no mobile number")
+
+	// ErrNoSender occurs when no code sender is available
+	ErrNoSender = errors.New("auth: code sender is not available")
+)
+
 // Service defines auth behaviors
 type Service interface {
 	// RegisterByPassword gets a new user by username and password
@@ -24,54 +45,524 @@ type Service interface {
 	LoginByEmailCode(email, code string) (int, error)
 }
 
+// AccountService is an optional interface of Service for account
+// features, e.g. verification, passkeys, user administration and
+// invitations. Routes of these features respond ErrUnsupported
+// if the service doesn't implement it
+type AccountService interface {
+	Service
+
+	// User gets the user by id
+	User(id int) (User, error)
+
+	// UserByEmail gets the user by email address
+	UserByEmail(email string) (User, error)
+
+	// SendEmailVerification sends a verification code to
+	// the email address of the user
+	SendEmailVerification(id int) error
+
+	// VerifyEmail validates the code and marks email address
+	// of the user as verified
+	VerifyEmail(id int, code string) error
+
+	// SendMobileVerification sends a verification code to
+	// the mobile number of the user
+	SendMobileVerification(id int) error
+
+	// VerifyMobile validates the code and marks mobile number
+	// of the user as verified
+	VerifyMobile(id int, code string) error
+
+	// AddCredential stores a new webauthn credential
+	AddCredential(c Credential) error
+
+	// Credential gets webauthn credential by credential id
+	Credential(id string) (Credential, error)
+
+	// Credentials gets all webauthn credentials of the user
+	Credentials(userID int) ([]Credential, error)
+
+	// UpdateSignCount updates signature counter of the credential
+	UpdateSignCount(id string, count uint32) error
+
+	// Users gets users matched with the query and total count
+	Users(q UserQuery) ([]User, int, error)
+
+	// DisableUser disables the user and prevents it from login
+	DisableUser(id int) error
+
+	// EnableUser enables the disabled user
+	EnableUser(id int) error
+
+	// ResetPassword clears password of the user, password login
+	// is rejected until a new password is set
+	ResetPassword(id int) error
+
+	// SetPassword sets a new password for the user
+	SetPassword(id int, pass string) error
+
+	// ChangePassword sets a new password if the current one
+	// is matched, users without password can set one
+	ChangePassword(id int, current, pass string) error
+
+	// DeleteUser soft deletes the user
+	DeleteUser(id int) error
+
+	// AddAuditEvent stores an audit event
+	AddAuditEvent(e AuditEvent) error
+
+	// LinkUser gets or creates the local user of an
+	// external identity, e.g. a directory account
+	LinkUser(i Identity, u User) (int, error)
+
+	// Invite creates the invitation and returns it with
+	// the token which should be sent to the email
+	Invite(i Invitation) (Invitation, string, error)
+
+	// ResendInvitation replaces the token and expiry of the
+	// pending invitation and returns it with the new token
+	ResendInvitation(id int, expiresAt time.Time) (Invitation, string, error)
+
+	// RevokeInvitation revokes the pending invitation
+	RevokeInvitation(id int) error
+
+	// InvitationByToken gets the pending invitation by its token
+	InvitationByToken(token string) (Invitation, error)
+
+	// RegisterByInvitation gets a new user by the token of
+	// a pending invitation, username and password
+	RegisterByInvitation(token, username, pass string) (int, error)
+
+	// AuditEvents gets audit events performed by or on the user
+	AuditEvents(userID int) ([]AuditEvent, error)
+
+	// Identities gets external identities linked to the user
+	Identities(userID int) ([]Identity, error)
+
+	// PurgeDeletedUsers scrubs personal data of users of all tenants
+	// which were deleted before the time and returns the count
+	PurgeDeletedUsers(before time.Time) (int, error)
+}
+
+// ContextService is an optional interface of Service whose methods
+// accept a context.Context for cancellation, deadlines and tracing
+type ContextService interface {
+	// RegisterByPasswordCtx gets a new user by username and password
+	RegisterByPasswordCtx(ctx context.Context, username, pass string) (int, error)
+
+	// RegisterByMobileCodeCtx gets a new user by mobile and code
+	RegisterByMobileCodeCtx(ctx context.Context, mobile, code string) (int, error)
+
+	// RegisterByEmailCodeCtx gets a new user by email and code
+	RegisterByEmailCodeCtx(ctx context.Context, email, code string) (int, error)
+
+	// LoginByPasswordCtx login system by username and password
+	// and return user id if authentication success
+	LoginByPasswordCtx(ctx context.Context, username, pass string) (int, error)
+
+	// LoginByMobileCodeCtx login system by mobile number and validate code
+	// and return user id if authentication success
+	LoginByMobileCodeCtx(ctx context.Context, mobile, code string) (int, error)
+
+	// LoginByEmailCodeCtx login system by email address and validate code
+	// and return user id if authentication success
+	LoginByEmailCodeCtx(ctx context.Context, email, code string) (int, error)
+}
+
+// ContextAccountService is an optional interface of Service whose
+// methods are the context-aware version of AccountService
+type ContextAccountService interface {
+	ContextService
+
+	// UserCtx gets the user by id
+	UserCtx(ctx context.Context, id int) (User, error)
+
+	// UserByEmailCtx gets the user by email address
+	UserByEmailCtx(ctx context.Context, email string) (User, error)
+
+	// SendEmailVerificationCtx sends a verification code to
+	// the email address of the user
+	SendEmailVerificationCtx(ctx context.Context, id int) error
+
+	// VerifyEmailCtx validates the code and marks email address
+	// of the user as verified
+	VerifyEmailCtx(ctx context.Context, id int, code string) error
+
+	// SendMobileVerificationCtx sends a verification code to
+	// the mobile number of the user
+	SendMobileVerificationCtx(ctx context.Context, id int) error
+
+	// VerifyMobileCtx validates the code and marks mobile number
+	// of the user as verified
+	VerifyMobileCtx(ctx context.Context, id int, code string) error
+
+	// AddCredentialCtx stores a new webauthn credential
+	AddCredentialCtx(ctx context.Context, c Credential) error
+
+	// CredentialCtx gets webauthn credential by credential id
+	CredentialCtx(ctx context.Context, id string) (Credential, error)
+
+	// CredentialsCtx gets all webauthn credentials of the user
+	CredentialsCtx(ctx context.Context, userID int) ([]Credential, error)
+
+	// UpdateSignCountCtx updates signature counter of the credential
+	UpdateSignCountCtx(ctx context.Context, id string, count uint32) error
+
+	// UsersCtx gets users matched with the query and total count
+	UsersCtx(ctx context.Context, q UserQuery) ([]User, int, error)
+
+	// DisableUserCtx disables the user and prevents it from login
+	DisableUserCtx(ctx context.Context, id int) error
+
+	// EnableUserCtx enables the disabled user
+	EnableUserCtx(ctx context.Context, id int) error
+
+	// ResetPasswordCtx clears password of the user, password login
+	// is rejected until a new password is set
+	ResetPasswordCtx(ctx context.Context, id int) error
+
+	// SetPasswordCtx sets a new password for the user
+	SetPasswordCtx(ctx context.Context, id int, pass string) error
+
+	// ChangePasswordCtx sets a new password if the current one
+	// is matched, users without password can set one
+	ChangePasswordCtx(ctx context.Context, id int, current, pass string) error
+
+	// DeleteUserCtx soft deletes the user
+	DeleteUserCtx(ctx context.Context, id int) error
+
+	// AddAuditEventCtx stores an audit event
+	AddAuditEventCtx(ctx context.Context, e AuditEvent) error
+
+	// LinkUserCtx gets or creates the local user of an
+	// external identity, e.g. a directory account
+	LinkUserCtx(ctx context.Context, i Identity, u User) (int, error)
+
+	// InviteCtx creates the invitation and returns it with
+	// the token which should be sent to the email
+	InviteCtx(ctx context.Context, i Invitation) (Invitation, string, error)
+
+	// ResendInvitationCtx replaces the token and expiry of the
+	// pending invitation and returns it with the new token
+	ResendInvitationCtx(ctx context.Context, id int, expiresAt time.Time) (Invitation, string, error)
+
+	// RevokeInvitationCtx revokes the pending invitation
+	RevokeInvitationCtx(ctx context.Context, id int) error
+
+	// InvitationByTokenCtx gets the pending invitation by its token
+	InvitationByTokenCtx(ctx context.Context, token string) (Invitation, error)
+
+	// RegisterByInvitationCtx gets a new user by the token of
+	// a pending invitation, username and password
+	RegisterByInvitationCtx(ctx context.Context, token, username, pass string) (int, error)
+
+	// AuditEventsCtx gets audit events performed by or on the user
+	AuditEventsCtx(ctx context.Context, userID int) ([]AuditEvent, error)
+
+	// IdentitiesCtx gets external identities linked to the user
+	IdentitiesCtx(ctx context.Context, userID int) ([]Identity, error)
+
+	// PurgeDeletedUsersCtx scrubs personal data of users of all tenants
+	// which were deleted before the time and returns the count
+	PurgeDeletedUsersCtx(ctx context.Context, before time.Time) (int, error)
+}
+
+// TenantService is an optional interface of Service for multi-tenancy,
+// it's required when TenantLookup is set
+type TenantService interface {
+	// WithTenant returns a Service scoped to the tenant
+	WithTenant(tenant string) Service
+}
+
 // CodeValidator defences behaviors of a code validator
 type CodeValidator interface {
 	// Validate validates whether the code matched with the key
 	Validate(key, code string) error
 }
 
+// CodeSender defines behaviors of a code sender
+type CodeSender interface {
+	// Make generates code related with the key and sends
+	// it to the address
+	Make(address, key string) error
+}
+
 // service is an internal implement of Service interface
 type service struct {
-	repo Repo
-	v    CodeValidator
+	repo   Repo
+	v      CodeValidator
+	email  CodeSender
+	mobile CodeSender
+	// tenant scopes keys of codes
+	tenant string
+}
+
+// validate checks the code sent to the address,
+// ErrNoSender is returned without a validator
+func (s service) validate(address, code string) error {
+	if s.v == nil {
+		return ErrNoSender
+	}
+
+	return s.v.Validate(CodeKey(s.tenant, address), code)
 }
 
-func (s service) RegisterByPassword(username, pass string) (int, error) {
-	return s.repo.RegisterByPassword(username, pass)
+func (s service) RegisterByPasswordCtx(ctx context.Context, username, pass string) (int, error) {
+	return s.contextRepo().RegisterByPasswordCtx(ctx, username, pass)
 }
 
-func (s service) RegisterByMobileCode(mobile, code string) (int, error) {
-	if err := s.v.Validate(mobile, code); err != nil {
+func (s service) RegisterByMobileCodeCtx(ctx context.Context, mobile, code string) (int, error) {
+	if err := s.validate(mobile, code); err != nil {
 		return 0, err
 	}
 
-	return s.repo.RegisterByMobile(mobile)
+	return s.contextRepo().RegisterByMobileCtx(ctx, mobile)
 }
 
-func (s service) RegisterByEmailCode(email, code string) (int, error) {
-	if err := s.v.Validate(email, code); err != nil {
+func (s service) RegisterByEmailCodeCtx(ctx context.Context, email, code string) (int, error) {
+	if err := s.validate(email, code); err != nil {
 		return 0, err
 	}
 
-	return s.repo.RegisterByEmail(email)
+	return s.contextRepo().RegisterByEmailCtx(ctx, email)
 }
 
-func (s service) LoginByPassword(username, pass string) (int, error) {
-	return s.repo.LoginByPassword(username, pass)
+func (s service) LoginByPasswordCtx(ctx context.Context, username, pass string) (int, error) {
+	return s.contextRepo().LoginByPasswordCtx(ctx, username, pass)
 }
 
-func (s service) LoginByMobileCode(mobile, code string) (int, error) {
-	if err := s.v.Validate(mobile, code); err != nil {
+func (s service) LoginByMobileCodeCtx(ctx context.Context, mobile, code string) (int, error) {
+	if err := s.validate(mobile, code); err != nil {
 		return 0, err
 	}
 
-	return s.repo.LoginByMobile(mobile)
+	return s.contextRepo().LoginByMobileCtx(ctx, mobile)
 }
 
-func (s service) LoginByEmailCode(email, code string) (int, error) {
-	if err := s.v.Validate(email, code); err != nil {
+func (s service) LoginByEmailCodeCtx(ctx context.Context, email, code string) (int, error) {
+	if err := s.validate(email, code); err != nil {
 		return 0, err
 	}
 
-	return s.repo.LoginByEmail(email)
+	return s.contextRepo().LoginByEmailCtx(ctx, email)
+}
+
+func (s service) UserCtx(ctx context.Context, id int) (User, error) {
+	return s.contextRepo().UserCtx(ctx, id)
+}
+
+func (s service) UserByEmailCtx(ctx context.Context, email string) (User, error) {
+	return s.contextRepo().UserByEmailCtx(ctx, email)
+}
+
+func (s service) SendEmailVerificationCtx(ctx context.Context, id int) error {
+	u, err := s.contextRepo().UserCtx(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if u.Email == "" {
+		return ErrNoEmail
+	}
+
+	if s.email == nil {
+		return ErrNoSender
+	}
+
+	return s.email.Make(u.Email, CodeKey(s.tenant, u.Email))
+}
+
+func (s service) VerifyEmailCtx(ctx context.Context, id int, code string) error {
+	u, err := s.contextRepo().UserCtx(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if u.Email == "" {
+		return ErrNoEmail
+	}
+
+	if err = s.validate(u.Email, code); err != nil {
+		return err
+	}
+
+	return s.contextRepo().VerifyEmailCtx(ctx, id)
+}
+
+func (s service) SendMobileVerificationCtx(ctx context.Context, id int) error {
+	u, err := s.contextRepo().UserCtx(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if u.Mobile == "" {
+		return ErrNoMobile
+	}
+
+	if s.mobile == nil {
+		return ErrNoSender
+	}
+
+	return s.mobile.Make(u.Mobile, CodeKey(s.tenant, u.Mobile))
+}
+
+func (s service) VerifyMobileCtx(ctx context.Context, id int, code string) error {
+	u, err := s.contextRepo().UserCtx(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if u.Mobile == "" {
+		return ErrNoMobile
+	}
+
+	if err = s.validate(u.Mobile, code); err != nil {
+		return err
+	}
+
+	return s.contextRepo().VerifyMobileCtx(ctx, id)
+}
+
+func (s service) AddCredentialCtx(ctx context.Context, c Credential) error {
+	return s.contextRepo().AddCredentialCtx(ctx, c)
+}
+
+func (s service) CredentialCtx(ctx context.Context, id string) (Credential, error) {
+	return s.contextRepo().CredentialCtx(ctx, id)
+}
+
+func (s service) CredentialsCtx(ctx context.Context, userID int) ([]Credential, error) {
+	return s.contextRepo().CredentialsCtx(ctx, userID)
+}
+
+func (s service) UpdateSignCountCtx(ctx context.Context, id string, count uint32) error {
+	return s.contextRepo().UpdateSignCountCtx(ctx, id, count)
+}
+
+func (s service) UsersCtx(ctx context.Context, q UserQuery) ([]User, int, error) {
+	return s.contextRepo().UsersCtx(ctx, q)
+}
+
+func (s service) DisableUserCtx(ctx context.Context, id int) error {
+	return s.contextRepo().DisableUserCtx(ctx, id)
+}
+
+func (s service) EnableUserCtx(ctx context.Context, id int) error {
+	return s.contextRepo().EnableUserCtx(ctx, id)
+}
+
+func (s service) ResetPasswordCtx(ctx context.Context, id int) error {
+	return s.contextRepo().ResetPasswordCtx(ctx, id)
+}
+
+func (s service) SetPasswordCtx(ctx context.Context, id int, pass string) error {
+	return s.contextRepo().SetPasswordCtx(ctx, id, pass)
+}
+
+func (s service) ChangePasswordCtx(ctx context.Context, id int, current, pass string) error {
+	return s.contextRepo().ChangePasswordCtx(ctx, id, current, pass)
+}
+
+func (s service) DeleteUserCtx(ctx context.Context, id int) error {
+	return s.contextRepo().DeleteUserCtx(ctx, id)
+}
+
+func (s service) AddAuditEventCtx(ctx context.Context, e AuditEvent) error {
+	return s.contextRepo().AddAuditEventCtx(ctx, e)
+}
+
+func (s service) LinkUserCtx(ctx context.Context, i Identity, u User) (int, error) {
+	return s.contextRepo().LinkUserCtx(ctx, i, u)
+}
+
+func (s service) InviteCtx(ctx context.Context, i Invitation) (Invitation, string, error) {
+	// Registered users can't be invited again
+	if _, err := s.contextRepo().UserByEmailCtx(ctx, i.Email); err == nil {
+		return Invitation{}, "", &DuplicateError{Field: "email"}
+	} else if !errors.Is(err, ErrUserNotFound) {
+		return Invitation{}, "", err
+	}
+
+	token := rand.String(32)
+	i.TokenHash = hashToken(token)
+
+	id, err := s.contextRepo().AddInvitationCtx(ctx, i)
+	if err != nil {
+		return Invitation{}, "", err
+	}
+
+	i, err = s.contextRepo().InvitationCtx(ctx, id)
+	return i, token, err
+}
+
+func (s service) ResendInvitationCtx(ctx context.Context, id int, expiresAt time.Time) (Invitation, string, error) {
+	token := rand.String(32)
+
+	if err := s.contextRepo().RenewInvitationCtx(ctx, id, hashToken(token), expiresAt); err != nil {
+		return Invitation{}, "", err
+	}
+
+	i, err := s.contextRepo().InvitationCtx(ctx, id)
+	return i, token, err
+}
+
+func (s service) RevokeInvitationCtx(ctx context.Context, id int) error {
+	return s.contextRepo().RevokeInvitationCtx(ctx, id)
+}
+
+func (s service) InvitationByTokenCtx(ctx context.Context, token string) (Invitation, error) {
+	i, err := s.contextRepo().InvitationByTokenCtx(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, ErrInvitationNotFound) {
+			err = ErrInvalidInvitation
+		}
+		return Invitation{}, err
+	}
+
+	if !i.Pending() {
+		return Invitation{}, ErrInvalidInvitation
+	}
+
+	return i, nil
+}
+
+func (s service) RegisterByInvitationCtx(ctx context.Context, token, username, pass string) (int, error) {
+	i, err := s.InvitationByTokenCtx(ctx, token)
+	if err != nil {
+		return 0, err
+	}
+
+	return s.contextRepo().RegisterByInvitationCtx(ctx, i.ID, username, pass)
+}
+
+func (s service) AuditEventsCtx(ctx context.Context, userID int) ([]AuditEvent, error) {
+	return s.contextRepo().AuditEventsCtx(ctx, userID)
+}
+
+func (s service) IdentitiesCtx(ctx context.Context, userID int) ([]Identity, error) {
+	return s.contextRepo().IdentitiesCtx(ctx, userID)
+}
+
+func (s service) PurgeDeletedUsersCtx(ctx context.Context, before time.Time) (int, error) {
+	return s.contextRepo().PurgeDeletedUsersCtx(ctx, before)
+}
+
+// hashToken hashes tokens before storing so that leaked
+// records can't be used
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s service) WithTenant(tenant string) Service {
+	r, ok := s.repo.(TenantRepo)
+	if !ok {
+		panic("auth: repo does not support multi-tenancy")
+	}
+
+	s.repo = r.WithTenant(tenant)
+	s.tenant = tenant
+
+	return s
 }
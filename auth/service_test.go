@@ -3,9 +3,11 @@ package auth
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/go-dawn/module/auth/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func Test_Auth_Service_RegisterByPassword(t *testing.T) {
@@ -35,6 +37,15 @@ func Test_Auth_Service_RegisterByMobileCode(t *testing.T) {
 		mockErr = errors.New("fake error")
 	)
 
+	t.Run("no validator", func(t *testing.T) {
+		s := s
+		s.v = nil
+
+		_, err := s.RegisterByMobileCode(mobile, code)
+
+		at.Equal(ErrNoSender, err)
+	})
+
 	t.Run("wrong code", func(t *testing.T) {
 		mockValidator.On("Validate", mobile, code).
 			Once().Return(mockErr)
@@ -78,6 +89,15 @@ func Test_Auth_Service_RegisterByEmailCode(t *testing.T) {
 		mockErr = errors.New("fake error")
 	)
 
+	t.Run("no validator", func(t *testing.T) {
+		s := s
+		s.v = nil
+
+		_, err := s.RegisterByEmailCode(email, code)
+
+		at.Equal(ErrNoSender, err)
+	})
+
 	t.Run("wrong code", func(t *testing.T) {
 		mockValidator.On("Validate", email, code).
 			Once().Return(mockErr)
@@ -138,6 +158,15 @@ func Test_Auth_Service_LoginByMobileCode(t *testing.T) {
 		mockErr = errors.New("fake error")
 	)
 
+	t.Run("no validator", func(t *testing.T) {
+		s := s
+		s.v = nil
+
+		_, err := s.LoginByMobileCode(mobile, code)
+
+		at.Equal(ErrNoSender, err)
+	})
+
 	t.Run("wrong code", func(t *testing.T) {
 		mockValidator.On("Validate", mobile, code).
 			Once().Return(mockErr)
@@ -181,6 +210,15 @@ func Test_Auth_Service_LoginByEmailCode(t *testing.T) {
 		mockErr = errors.New("fake error")
 	)
 
+	t.Run("no validator", func(t *testing.T) {
+		s := s
+		s.v = nil
+
+		_, err := s.LoginByEmailCode(email, code)
+
+		at.Equal(ErrNoSender, err)
+	})
+
 	t.Run("wrong code", func(t *testing.T) {
 		mockValidator.On("Validate", email, code).
 			Once().Return(mockErr)
@@ -214,7 +252,566 @@ func Test_Auth_Service_LoginByEmailCode(t *testing.T) {
 	})
 }
 
-func getService() (service, *mocks.Repo, *mocks.CodeValidator) {
-	repo, v := new(mocks.Repo), new(mocks.CodeValidator)
+func Test_Auth_Service_User(t *testing.T) {
+	at := assert.New(t)
+
+	s, mockRepo, _ := getService()
+	mockRepo.On("User", 1).
+		Once().Return(User{ID: 1}, nil)
+
+	u, err := s.User(1)
+
+	at.Nil(err)
+	at.Equal(1, u.ID)
+}
+
+func Test_Auth_Service_UserByEmail(t *testing.T) {
+	at := assert.New(t)
+
+	s, mockRepo, _ := getService()
+	email := "kiyonlin@gmail.com"
+	mockRepo.On("UserByEmail", email).
+		Once().Return(User{ID: 1, Email: email}, nil)
+
+	u, err := s.UserByEmail(email)
+
+	at.Nil(err)
+	at.Equal(1, u.ID)
+}
+
+func Test_Auth_Service_SendEmailVerification(t *testing.T) {
+	at := assert.New(t)
+
+	s, mockRepo, _ := getService()
+	var (
+		email   = "kiyonlin@gmail.com"
+		mockErr = errors.New("fake error")
+	)
+
+	t.Run("failed to get user", func(t *testing.T) {
+		mockRepo.On("User", 1).
+			Once().Return(User{}, mockErr)
+
+		at.Equal(mockErr, s.SendEmailVerification(1))
+	})
+
+	t.Run("no email", func(t *testing.T) {
+		mockRepo.On("User", 1).
+			Once().Return(User{ID: 1}, nil)
+
+		at.Equal(ErrNoEmail, s.SendEmailVerification(1))
+	})
+
+	t.Run("no sender", func(t *testing.T) {
+		mockRepo.On("User", 1).
+			Once().Return(User{ID: 1, Email: email}, nil)
+
+		at.Equal(ErrNoSender, s.SendEmailVerification(1))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		mockSender := new(mocks.CodeSender)
+		s.email = mockSender
+
+		mockRepo.On("User", 1).
+			Once().Return(User{ID: 1, Email: email}, nil)
+		mockSender.On("Make", email, email).
+			Once().Return(nil)
+
+		at.Nil(s.SendEmailVerification(1))
+	})
+}
+
+func Test_Auth_Service_VerifyEmail(t *testing.T) {
+	at := assert.New(t)
+
+	s, mockRepo, mockValidator := getService()
+	var (
+		email   = "kiyonlin@gmail.com"
+		code    = "123456"
+		mockErr = errors.New("fake error")
+	)
+
+	t.Run("failed to get user", func(t *testing.T) {
+		mockRepo.On("User", 1).
+			Once().Return(User{}, mockErr)
+
+		at.Equal(mockErr, s.VerifyEmail(1, code))
+	})
+
+	t.Run("no email", func(t *testing.T) {
+		mockRepo.On("User", 1).
+			Once().Return(User{ID: 1}, nil)
+
+		at.Equal(ErrNoEmail, s.VerifyEmail(1, code))
+	})
+
+	t.Run("no validator", func(t *testing.T) {
+		mockRepo.On("User", 1).
+			Once().Return(User{ID: 1, Email: email}, nil)
+
+		s := s
+		s.v = nil
+
+		at.Equal(ErrNoSender, s.VerifyEmail(1, code))
+	})
+
+	t.Run("wrong code", func(t *testing.T) {
+		mockRepo.On("User", 1).
+			Once().Return(User{ID: 1, Email: email}, nil)
+		mockValidator.On("Validate", email, code).
+			Once().Return(mockErr)
+
+		at.Equal(mockErr, s.VerifyEmail(1, code))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo.On("User", 1).
+			Once().Return(User{ID: 1, Email: email}, nil).
+			On("VerifyEmail", 1).
+			Once().Return(nil)
+		mockValidator.On("Validate", email, code).
+			Once().Return(nil)
+
+		at.Nil(s.VerifyEmail(1, code))
+	})
+}
+
+func Test_Auth_Service_SendMobileVerification(t *testing.T) {
+	at := assert.New(t)
+
+	s, mockRepo, _ := getService()
+	var (
+		mobile  = "13600008888"
+		mockErr = errors.New("fake error")
+	)
+
+	t.Run("failed to get user", func(t *testing.T) {
+		mockRepo.On("User", 1).
+			Once().Return(User{}, mockErr)
+
+		at.Equal(mockErr, s.SendMobileVerification(1))
+	})
+
+	t.Run("no mobile", func(t *testing.T) {
+		mockRepo.On("User", 1).
+			Once().Return(User{ID: 1}, nil)
+
+		at.Equal(ErrNoMobile, s.SendMobileVerification(1))
+	})
+
+	t.Run("no sender", func(t *testing.T) {
+		mockRepo.On("User", 1).
+			Once().Return(User{ID: 1, Mobile: mobile}, nil)
+
+		at.Equal(ErrNoSender, s.SendMobileVerification(1))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		mockSender := new(mocks.CodeSender)
+		s.mobile = mockSender
+
+		mockRepo.On("User", 1).
+			Once().Return(User{ID: 1, Mobile: mobile}, nil)
+		mockSender.On("Make", mobile, mobile).
+			Once().Return(nil)
+
+		at.Nil(s.SendMobileVerification(1))
+	})
+}
+
+func Test_Auth_Service_VerifyMobile(t *testing.T) {
+	at := assert.New(t)
+
+	s, mockRepo, mockValidator := getService()
+	var (
+		mobile  = "13600008888"
+		code    = "123456"
+		mockErr = errors.New("fake error")
+	)
+
+	t.Run("failed to get user", func(t *testing.T) {
+		mockRepo.On("User", 1).
+			Once().Return(User{}, mockErr)
+
+		at.Equal(mockErr, s.VerifyMobile(1, code))
+	})
+
+	t.Run("no mobile", func(t *testing.T) {
+		mockRepo.On("User", 1).
+			Once().Return(User{ID: 1}, nil)
+
+		at.Equal(ErrNoMobile, s.VerifyMobile(1, code))
+	})
+
+	t.Run("no validator", func(t *testing.T) {
+		mockRepo.On("User", 1).
+			Once().Return(User{ID: 1, Mobile: mobile}, nil)
+
+		s := s
+		s.v = nil
+
+		at.Equal(ErrNoSender, s.VerifyMobile(1, code))
+	})
+
+	t.Run("wrong code", func(t *testing.T) {
+		mockRepo.On("User", 1).
+			Once().Return(User{ID: 1, Mobile: mobile}, nil)
+		mockValidator.On("Validate", mobile, code).
+			Once().Return(mockErr)
+
+		at.Equal(mockErr, s.VerifyMobile(1, code))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo.On("User", 1).
+			Once().Return(User{ID: 1, Mobile: mobile}, nil).
+			On("VerifyMobile", 1).
+			Once().Return(nil)
+		mockValidator.On("Validate", mobile, code).
+			Once().Return(nil)
+
+		at.Nil(s.VerifyMobile(1, code))
+	})
+}
+
+func Test_Auth_Service_Credential(t *testing.T) {
+	at := assert.New(t)
+
+	s, mockRepo, _ := getService()
+	c := Credential{ID: "id", UserID: 1}
+
+	mockRepo.On("AddCredential", c).
+		Once().Return(nil).
+		On("Credential", "id").
+		Once().Return(c, nil).
+		On("Credentials", 1).
+		Once().Return([]Credential{c}, nil).
+		On("UpdateSignCount", "id", uint32(2)).
+		Once().Return(nil)
+
+	at.Nil(s.AddCredential(c))
+
+	got, err := s.Credential("id")
+	at.Nil(err)
+	at.Equal(c, got)
+
+	cs, err := s.Credentials(1)
+	at.Nil(err)
+	at.Len(cs, 1)
+
+	at.Nil(s.UpdateSignCount("id", 2))
+}
+
+func getService() (service, *mocks.AccountRepo, *mocks.CodeValidator) {
+	repo, v := new(mocks.AccountRepo), new(mocks.CodeValidator)
 	return service{repo: repo, v: v}, repo, v
 }
+
+func Test_Auth_Service_UserAdmin(t *testing.T) {
+	at := assert.New(t)
+
+	s, mockRepo, _ := getService()
+	q := UserQuery{Search: "kiyon", Page: 1, PageSize: 15}
+
+	mockRepo.On("Users", q).
+		Once().Return([]User{{ID: 1}}, 1, nil).
+		On("DisableUser", 1).
+		Once().Return(nil).
+		On("EnableUser", 1).
+		Once().Return(nil).
+		On("ResetPassword", 1).
+		Once().Return(nil).
+		On("SetPassword", 1, "pass").
+		Once().Return(nil).
+		On("DeleteUser", 1).
+		Once().Return(nil)
+
+	users, total, err := s.Users(q)
+	at.Nil(err)
+	at.Equal(1, total)
+	at.Len(users, 1)
+
+	at.Nil(s.DisableUser(1))
+	at.Nil(s.EnableUser(1))
+	at.Nil(s.ResetPassword(1))
+	at.Nil(s.SetPassword(1, "pass"))
+	at.Nil(s.DeleteUser(1))
+
+	mockRepo.AssertExpectations(t)
+}
+
+func Test_Auth_Service_AddAuditEvent(t *testing.T) {
+	at := assert.New(t)
+
+	s, mockRepo, _ := getService()
+	e := AuditEvent{Action: "impersonate", ActorID: 1, UserID: 2}
+
+	mockRepo.On("AddAuditEvent", e).
+		Once().Return(nil)
+
+	at.Nil(s.AddAuditEvent(e))
+}
+
+func Test_Auth_Service_LinkUser(t *testing.T) {
+	at := assert.New(t)
+
+	s, mockRepo, _ := getService()
+	i := Identity{Provider: "ldap", Subject: "uid=kiyon,dc=example,dc=com"}
+	u := User{Username: "kiyon", Role: "admin"}
+
+	mockRepo.On("LinkUser", i, u).
+		Once().Return(1, nil)
+
+	id, err := s.LinkUser(i, u)
+	at.Nil(err)
+	at.Equal(1, id)
+}
+
+func Test_Auth_Service_AuditEvents(t *testing.T) {
+	at := assert.New(t)
+
+	s, mockRepo, _ := getService()
+	events := []AuditEvent{{ID: 1, Action: "impersonate", ActorID: 1, UserID: 2}}
+
+	mockRepo.On("AuditEvents", 2).
+		Once().Return(events, nil)
+
+	es, err := s.AuditEvents(2)
+	at.Nil(err)
+	at.Equal(events, es)
+}
+
+func Test_Auth_Service_Identities(t *testing.T) {
+	at := assert.New(t)
+
+	s, mockRepo, _ := getService()
+	identities := []Identity{{Provider: "ldap", Subject: "uid=kiyon"}}
+
+	mockRepo.On("Identities", 1).
+		Once().Return(identities, nil)
+
+	is, err := s.Identities(1)
+	at.Nil(err)
+	at.Equal(identities, is)
+}
+
+func Test_Auth_Service_PurgeDeletedUsers(t *testing.T) {
+	at := assert.New(t)
+
+	s, mockRepo, _ := getService()
+	before := time.Now()
+
+	mockRepo.On("PurgeDeletedUsers", before).
+		Once().Return(2, nil)
+
+	count, err := s.PurgeDeletedUsers(before)
+	at.Nil(err)
+	at.Equal(2, count)
+}
+
+func Test_Auth_Service_Invite(t *testing.T) {
+	at := assert.New(t)
+
+	email := "kiyon@example.com"
+	i := Invitation{Email: email, Role: "editor", InviterID: 1, ExpiresAt: time.Now().Add(time.Hour)}
+
+	t.Run("success", func(t *testing.T) {
+		s, mockRepo, _ := getService()
+
+		var hash string
+		mockRepo.On("UserByEmail", email).
+			Once().Return(User{}, ErrUserNotFound).
+			On("AddInvitation", mock.MatchedBy(func(ai Invitation) bool {
+				hash = ai.TokenHash
+				return ai.Email == email && ai.Role == "editor" && ai.InviterID == 1
+			})).
+			Once().Return(1, nil).
+			On("Invitation", 1).
+			Once().Return(Invitation{ID: 1, Email: email}, nil)
+
+		inv, token, err := s.Invite(i)
+		at.Nil(err)
+		at.Equal(1, inv.ID)
+		at.NotEmpty(token)
+		at.Equal(hashToken(token), hash)
+	})
+
+	t.Run("registered", func(t *testing.T) {
+		s, mockRepo, _ := getService()
+
+		mockRepo.On("UserByEmail", email).
+			Once().Return(User{ID: 1}, nil)
+
+		_, _, err := s.Invite(i)
+		at.True(errors.Is(err, ErrDuplicate))
+	})
+
+	t.Run("failed to get user", func(t *testing.T) {
+		s, mockRepo, _ := getService()
+
+		mockRepo.On("UserByEmail", email).
+			Once().Return(User{}, errors.New("fake error"))
+
+		_, _, err := s.Invite(i)
+		at.NotNil(err)
+	})
+
+	t.Run("failed to add", func(t *testing.T) {
+		s, mockRepo, _ := getService()
+
+		mockRepo.On("UserByEmail", email).
+			Once().Return(User{}, ErrUserNotFound).
+			On("AddInvitation", mock.Anything).
+			Once().Return(0, errors.New("fake error"))
+
+		_, _, err := s.Invite(i)
+		at.NotNil(err)
+	})
+}
+
+func Test_Auth_Service_ResendInvitation(t *testing.T) {
+	at := assert.New(t)
+
+	expiresAt := time.Now().Add(time.Hour)
+
+	t.Run("success", func(t *testing.T) {
+		s, mockRepo, _ := getService()
+
+		var hash string
+		mockRepo.On("RenewInvitation", 1, mock.MatchedBy(func(h string) bool {
+			hash = h
+			return true
+		}), expiresAt).
+			Once().Return(nil).
+			On("Invitation", 1).
+			Once().Return(Invitation{ID: 1}, nil)
+
+		i, token, err := s.ResendInvitation(1, expiresAt)
+		at.Nil(err)
+		at.Equal(1, i.ID)
+		at.Equal(hashToken(token), hash)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		s, mockRepo, _ := getService()
+
+		mockRepo.On("RenewInvitation", 1, mock.Anything, expiresAt).
+			Once().Return(ErrInvitationNotFound)
+
+		_, _, err := s.ResendInvitation(1, expiresAt)
+		at.Equal(ErrInvitationNotFound, err)
+	})
+}
+
+func Test_Auth_Service_RevokeInvitation(t *testing.T) {
+	at := assert.New(t)
+
+	s, mockRepo, _ := getService()
+
+	mockRepo.On("RevokeInvitation", 1).
+		Once().Return(nil)
+
+	at.Nil(s.RevokeInvitation(1))
+}
+
+func Test_Auth_Service_InvitationByToken(t *testing.T) {
+	at := assert.New(t)
+
+	var (
+		token = "token"
+		hash  = hashToken(token)
+		now   = time.Now()
+	)
+
+	tests := []struct {
+		name string
+		i    Invitation
+		err  error
+		want error
+	}{
+		{"pending", Invitation{ID: 1, ExpiresAt: now.Add(time.Hour)}, nil, nil},
+		{"not found", Invitation{}, ErrInvitationNotFound, ErrInvalidInvitation},
+		{"expired", Invitation{ID: 1, ExpiresAt: now.Add(-time.Hour)}, nil, ErrInvalidInvitation},
+		{"accepted", Invitation{ID: 1, ExpiresAt: now.Add(time.Hour), AcceptedAt: &now}, nil, ErrInvalidInvitation},
+		{"revoked", Invitation{ID: 1, ExpiresAt: now.Add(time.Hour), RevokedAt: &now}, nil, ErrInvalidInvitation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mockRepo, _ := getService()
+
+			mockRepo.On("InvitationByToken", hash).
+				Once().Return(tt.i, tt.err)
+
+			i, err := s.InvitationByToken(token)
+			at.Equal(tt.want, err)
+			if tt.want == nil {
+				at.Equal(1, i.ID)
+			}
+		})
+	}
+}
+
+func Test_Auth_Service_RegisterByInvitation(t *testing.T) {
+	at := assert.New(t)
+
+	t.Run("success", func(t *testing.T) {
+		s, mockRepo, _ := getService()
+
+		mockRepo.On("InvitationByToken", hashToken("token")).
+			Once().Return(Invitation{ID: 1, ExpiresAt: time.Now().Add(time.Hour)}, nil).
+			On("RegisterByInvitation", 1, "kiyon", "pass").
+			Once().Return(2, nil)
+
+		id, err := s.RegisterByInvitation("token", "kiyon", "pass")
+		at.Nil(err)
+		at.Equal(2, id)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		s, mockRepo, _ := getService()
+
+		mockRepo.On("InvitationByToken", hashToken("token")).
+			Once().Return(Invitation{}, ErrInvitationNotFound)
+
+		_, err := s.RegisterByInvitation("token", "kiyon", "pass")
+		at.Equal(ErrInvalidInvitation, err)
+	})
+}
+
+func Test_Auth_Service_WithTenant(t *testing.T) {
+	at := assert.New(t)
+
+	s, _, _ := getService()
+
+	at.Panics(func() {
+		s.WithTenant("acme")
+	})
+
+	s.repo = repository{}
+
+	scoped := s.WithTenant("acme").(service)
+	at.Equal("acme", scoped.repo.(repository).tenant)
+	at.Equal("acme", scoped.tenant)
+
+	t.Run("codes are scoped", func(t *testing.T) {
+		s, _, mockValidator := getService()
+		s.tenant = "acme"
+
+		mockValidator.On("Validate", "acme:kiyonlin@gmail.com", "123456").
+			Once().Return(ErrCodeMismatch)
+
+		_, err := s.LoginByEmailCode("kiyonlin@gmail.com", "123456")
+		at.Equal(ErrCodeMismatch, err)
+	})
+}
+
+func Test_Auth_CodeKey(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	at.Equal("kiyonlin@gmail.com", CodeKey("", "kiyonlin@gmail.com"))
+	at.Equal("acme:kiyonlin@gmail.com", CodeKey("acme", "kiyonlin@gmail.com"))
+}
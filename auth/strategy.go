@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/go-dawn/dawn/fiberx"
+	"github.com/go-playground/validator/v10"
+)
+
+// Strategy authenticates a login request of a type, it can be
+// registered by RegisterStrategy to support custom login types,
+// e.g. LDAP or SSO
+type Strategy interface {
+	// Authenticate checks the key and code, then returns the id of
+	// the authenticated user. Tenant is got by TenantFromContext
+	Authenticate(ctx context.Context, s ContextAccountService, key, code string) (int, error)
+}
+
+// StrategyFunc is an adapter to allow the use of ordinary
+// functions as strategies
+type StrategyFunc func(ctx context.Context, s ContextAccountService, key, code string) (int, error)
+
+// Authenticate calls f(ctx, s, key, code)
+func (f StrategyFunc) Authenticate(ctx context.Context, s ContextAccountService, key, code string) (int, error) {
+	return f(ctx, s, key, code)
+}
+
+// strategyWebAuthn is bound to the module config, so it is used
+// when no strategy is registered with the same name
+const strategyWebAuthn = "webauthn"
+
+var (
+	strategiesMu sync.RWMutex
+	strategies   = map[string]Strategy{}
+	// moduleStrategies are names of strategies bound to the
+	// module config, they are enabled once configured
+	moduleStrategies = map[string]bool{}
+)
+
+func init() {
+	RegisterStrategy("password", StrategyFunc(func(ctx context.Context, s ContextAccountService, username, pass string) (int, error) {
+		return s.LoginByPasswordCtx(ctx, username, pass)
+	}))
+	RegisterStrategy("mobile", StrategyFunc(func(ctx context.Context, s ContextAccountService, mobile, code string) (int, error) {
+		return codeCredentials(s.LoginByMobileCodeCtx(ctx, mobile, code))
+	}))
+	RegisterStrategy("email", StrategyFunc(func(ctx context.Context, s ContextAccountService, email, code string) (int, error) {
+		return codeCredentials(s.LoginByEmailCodeCtx(ctx, email, code))
+	}))
+
+	_ = fiberx.V.RegisterValidation("auth_strategy", strategyRule)
+}
+
+// codeCredentials reports wrong codes and unknown accounts of code
+// login as invalid credentials alike, so accounts can't be probed
+func codeCredentials(id int, err error) (int, error) {
+	if errors.Is(err, ErrCodeMismatch) || errors.Is(err, ErrUserNotFound) {
+		err = credentialsError{err}
+	}
+	return id, err
+}
+
+// RegisterStrategy makes a login strategy available by the name,
+// the strategy replaces any previous one with the same name.
+// It panics if name is empty or strategy is nil
+func RegisterStrategy(name string, strategy Strategy) {
+	if name == "" {
+		panic("auth: strategy name is empty")
+	}
+
+	if strategy == nil {
+		panic("auth: strategy " + name + " is nil")
+	}
+
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+
+	strategies[name] = strategy
+}
+
+// lookupStrategy gets the registered strategy by name
+func lookupStrategy(name string) (s Strategy, ok bool) {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+
+	s, ok = strategies[name]
+	return
+}
+
+// enableStrategies enables strategies bound to the module
+// config if they are configured
+func (m module) enableStrategies() {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+
+	if m.webAuthnReady() {
+		moduleStrategies[strategyWebAuthn] = true
+	}
+
+	if m.LDAP.URL != "" {
+		moduleStrategies[strategyLDAP] = true
+	}
+}
+
+// strategy gets the strategy of the login type
+func (m module) strategy(name string) (Strategy, bool) {
+	if s, ok := lookupStrategy(name); ok {
+		return s, true
+	}
+
+	switch {
+	case name == strategyWebAuthn && m.webAuthnReady():
+		return StrategyFunc(m.loginByWebAuthn), true
+	case name == strategyLDAP && m.LDAP.URL != "":
+		return NewLDAPStrategy(m.LDAP), true
+	default:
+		return nil, false
+	}
+}
+
+// strategyRule validates the login type is supported
+func strategyRule(fl validator.FieldLevel) bool {
+	name := fl.Field().String()
+
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+
+	_, ok := strategies[name]
+	return ok || moduleStrategies[name]
+}
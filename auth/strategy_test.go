@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-dawn/module/cache"
+	"github.com/go-dawn/pkg/deck"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Auth_Strategy_Register(t *testing.T) {
+	at := assert.New(t)
+
+	at.Panics(func() {
+		RegisterStrategy("", StrategyFunc(nil))
+	})
+
+	at.Panics(func() {
+		RegisterStrategy("nil", nil)
+	})
+
+	for _, name := range []string{"password", "mobile", "email"} {
+		_, ok := lookupStrategy(name)
+		at.True(ok, name)
+	}
+
+	_, ok := lookupStrategy(strategyWebAuthn)
+	at.False(ok)
+
+	m := module{Config: &Config{}}
+
+	// Strategies bound to the config are off until configured
+	_, ok = m.strategy(strategyWebAuthn)
+	at.False(ok)
+
+	_, ok = m.strategy(strategyLDAP)
+	at.False(ok)
+
+	cache.New().Init()
+	m.Storage = cache.Storage()
+	m.WebAuthn.RPID = "localhost"
+	m.LDAP.URL = "ldap://localhost"
+
+	_, ok = m.strategy(strategyWebAuthn)
+	at.True(ok)
+
+	_, ok = m.strategy(strategyLDAP)
+	at.True(ok)
+
+	_, ok = m.strategy("invalid")
+	at.False(ok)
+}
+
+func Test_Auth_Strategy_Login(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	RegisterStrategy("test_ldap", StrategyFunc(func(ctx context.Context, s ContextAccountService, username, pass string) (int, error) {
+		if username == "kiyon" && pass == "pass" {
+			return 1, nil
+		}
+		return 0, ErrInvalidCredentials
+	}))
+
+	m, mockService := routeModule()
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		app.Post("/login", m.login)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		mockService.On("User", 1).
+			Once().Return(User{ID: 1}, nil)
+
+		e.POST("/login").
+			WithJSON(loginForm{Username: "kiyon", Code: "pass", Type: "test_ldap"}).
+			Expect().
+			Status(fiber.StatusOK)
+	})
+
+	t.Run("invalid credentials", func(t *testing.T) {
+		resp := e.POST("/login").
+			WithJSON(loginForm{Username: "kiyon", Code: "wrong", Type: "test_ldap"}).
+			Expect().
+			Status(fiber.StatusUnauthorized)
+
+		deck.AssertRespCode(resp, CodeInvalidCredentials)
+	})
+
+	t.Run("unregistered type", func(t *testing.T) {
+		e.POST("/login").
+			WithJSON(loginForm{Username: "kiyon", Code: "pass", Type: "test_sso"}).
+			Expect().
+			Status(fiber.StatusUnprocessableEntity)
+	})
+
+	at.True(mockService.AssertExpectations(t))
+}
+
+func Test_Auth_StrategyFunc(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	fakeErr := errors.New("fake error")
+
+	var s Strategy = StrategyFunc(func(ctx context.Context, s ContextAccountService, key, code string) (int, error) {
+		return 2, fakeErr
+	})
+
+	id, err := s.Authenticate(context.Background(), nil, "key", "code")
+	at.Equal(2, id)
+	at.Equal(fakeErr, err)
+}
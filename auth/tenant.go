@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/go-dawn/dawn/fiberx"
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	// ErrMissingTenant occurs when tenant can't be resolved
+	// from the request
+	ErrMissingTenant = errors.New("auth: missing tenant")
+
+	// ErrTenantMismatch occurs when token is used against
+	// another tenant
+	ErrTenantMismatch = errors.New("auth: token does not belong to the tenant")
+)
+
+// tenant resolves tenant of the request by TenantLookup
+// and stores it in locals
+func (m module) tenant() fiber.Handler {
+	lookup := tenantExtractor(m.TenantLookup)
+
+	return func(c *fiber.Ctx) error {
+		if lookup == nil {
+			return c.Next()
+		}
+
+		t := lookup(c)
+		if t == "" {
+			return fiberx.CodeErr(fiber.StatusBadRequest, ErrMissingTenant, "Missing tenant")
+		}
+
+		c.Locals("tenant", t)
+
+		return c.Next()
+	}
+}
+
+// tenantExtractor parses the lookup string, nil will be
+// returned if multi-tenancy is disabled
+func tenantExtractor(lookup string) func(c *fiber.Ctx) string {
+	if lookup == "" {
+		return nil
+	}
+
+	parts := strings.SplitN(lookup, ":", 2)
+	source, name := parts[0], ""
+	if len(parts) == 2 {
+		name = parts[1]
+	}
+
+	switch {
+	case source == "header" && name != "":
+		return func(c *fiber.Ctx) string {
+			return c.Get(name)
+		}
+	case source == "param" && name != "":
+		return func(c *fiber.Ctx) string {
+			return c.Params(name)
+		}
+	case source == "host":
+		return func(c *fiber.Ctx) string {
+			return c.Hostname()
+		}
+	case source == "subdomain":
+		return func(c *fiber.Ctx) string {
+			if subdomains := c.Subdomains(); len(subdomains) > 0 {
+				return subdomains[0]
+			}
+			return ""
+		}
+	default:
+		panic("auth: invalid tenant lookup " + lookup)
+	}
+}
+
+// Tenant gets tenant of current request, empty string
+// will be returned if multi-tenancy is disabled
+func Tenant(c *fiber.Ctx) string {
+	t, _ := c.Locals("tenant").(string)
+	return t
+}
+
+type tenantCtxKey struct{}
+
+// tenantContext gets the context of current request
+// which carries its tenant
+func tenantContext(c *fiber.Ctx) context.Context {
+	return context.WithValue(UserContext(c), tenantCtxKey{}, Tenant(c))
+}
+
+// TenantFromContext gets tenant of the context passed
+// to strategies, empty string will be returned if
+// multi-tenancy is disabled
+func TenantFromContext(ctx context.Context) string {
+	t, _ := ctx.Value(tenantCtxKey{}).(string)
+	return t
+}
+
+// CodeKey gets the key of codes and one-time tokens sent to the
+// address, so they can't be used against other tenants. Codes
+// of LoginByMobileCode and LoginByEmailCode must be made with it
+func CodeKey(tenant, address string) string {
+	if tenant == "" {
+		return address
+	}
+	return tenant + ":" + address
+}
+
+// service gets the context-aware service which is scoped
+// to the tenant of current request
+func (m module) service(c *fiber.Ctx) ContextAccountService {
+	s := m.Service
+	if t := Tenant(c); t != "" {
+		s = s.(TenantService).WithTenant(t)
+	}
+
+	return contextService(s)
+}
+
+// checkTenant rejects tokens issued for other tenants
+func checkTenant(c *fiber.Ctx) error {
+	if t, _ := claims(c)["tenant"].(string); t != Tenant(c) {
+		return errResp(c, ErrTenantMismatch)
+	}
+
+	return c.Next()
+}
+
+func tenantClaims(c *fiber.Ctx) jwt.MapClaims {
+	if t := Tenant(c); t != "" {
+		return jwt.MapClaims{"tenant": t}
+	}
+	return nil
+}
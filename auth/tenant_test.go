@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/go-dawn/dawn/fiberx"
+	"github.com/go-dawn/module/auth/mocks"
+	"github.com/go-dawn/pkg/deck"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Auth_Tenant_Lookup(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	at.Nil(tenantExtractor(""))
+
+	for _, lookup := range []string{"header", "param", "query:tenant", "invalid"} {
+		at.Panics(func() {
+			tenantExtractor(lookup)
+		}, lookup)
+	}
+
+	tests := []struct {
+		lookup string
+		route  string
+		path   string
+		host   string
+		header string
+	}{
+		{"header:X-Tenant", "/", "/", "example.com", "acme"},
+		{"param:tenant", "/:tenant", "/acme", "example.com", ""},
+		{"host", "/", "/", "acme", ""},
+		{"subdomain", "/", "/", "acme.example.com", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lookup, func(t *testing.T) {
+			m := module{Config: &Config{TenantLookup: tt.lookup}}
+
+			e := deck.SetupServer(t, func(app *fiber.App) {
+				app.Get(tt.route, m.tenant(), func(c *fiber.Ctx) error {
+					return fiberx.Message(c, Tenant(c))
+				})
+			})
+
+			resp := e.GET(tt.path).
+				WithURL("http://"+tt.host).
+				WithHeader("X-Tenant", tt.header).
+				Expect().
+				Status(fiber.StatusOK)
+
+			deck.AssertRespMsg(resp, "acme")
+		})
+	}
+
+	t.Run("missing tenant", func(t *testing.T) {
+		m := module{Config: &Config{TenantLookup: "subdomain"}}
+
+		e := deck.SetupServer(t, func(app *fiber.App) {
+			app.Get("/", m.tenant(), func(c *fiber.Ctx) error {
+				return fiberx.Message(c, Tenant(c))
+			})
+		})
+
+		resp := e.GET("/").
+			WithURL("http://example.com").
+			Expect().
+			Status(fiber.StatusBadRequest)
+
+		deck.AssertRespMsg(resp, "Missing tenant")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		m := module{Config: &Config{}}
+
+		e := deck.SetupServer(t, func(app *fiber.App) {
+			app.Get("/", m.tenant(), func(c *fiber.Ctx) error {
+				return fiberx.Message(c, Tenant(c))
+			})
+		})
+
+		resp := e.GET("/").Expect().Status(fiber.StatusOK)
+
+		deck.AssertRespMsg(resp, "")
+	})
+}
+
+func Test_Auth_Tenant_JWT(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	m, _ := routeModule()
+	m.TenantLookup = "header:X-Tenant"
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		app.Get("/", m.tenant(), m.jwt(), func(c *fiber.Ctx) error {
+			return fiberx.Message(c, Tenant(c))
+		})
+	})
+
+	token, err := generateToken("", m.SigningKey, 1, time.Hour, jwt.MapClaims{"tenant": "acme"})
+	at.Nil(err)
+
+	t.Run("success", func(t *testing.T) {
+		resp := e.GET("/").
+			WithHeader("X-Tenant", "acme").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+token).
+			Expect().
+			Status(fiber.StatusOK)
+
+		deck.AssertRespMsg(resp, "acme")
+	})
+
+	t.Run("wrong tenant", func(t *testing.T) {
+		resp := e.GET("/").
+			WithHeader("X-Tenant", "other").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+token).
+			Expect().
+			Status(fiber.StatusUnauthorized)
+
+		deck.AssertRespMsg(resp, "Invalid tenant")
+	})
+
+	t.Run("token without tenant", func(t *testing.T) {
+		token, err := generateToken("", m.SigningKey, 1, time.Hour)
+		at.Nil(err)
+
+		resp := e.GET("/").
+			WithHeader("X-Tenant", "acme").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+token).
+			Expect().
+			Status(fiber.StatusUnauthorized)
+
+		deck.AssertRespMsg(resp, "Invalid tenant")
+	})
+}
+
+func Test_Auth_Tenant_Login(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	acme := new(mocks.AccountService)
+	m := module{Config: &Config{
+		Service:      tenantService{AccountService: new(mocks.AccountService), tenants: map[string]Service{"acme": acme}},
+		SigningKey:   "test",
+		TenantLookup: "header:X-Tenant",
+	}}
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		app.Post("/login", m.tenant(), m.login)
+	})
+
+	acme.On("LoginByPassword", "admin", "pass").
+		Once().Return(1, nil).
+		On("User", 1).
+		Once().Return(User{ID: 1, Tenant: "acme"}, nil)
+
+	resp := e.POST("/login").
+		WithHeader("X-Tenant", "acme").
+		WithJSON(loginForm{Username: "admin", Code: "pass", Type: "password"}).
+		Expect().
+		Status(fiber.StatusOK)
+
+	raw := resp.JSON().Object().Value("data").String().Raw()
+	token, err := jwt.Parse(raw, func(*jwt.Token) (interface{}, error) {
+		return []byte(m.SigningKey), nil
+	})
+	at.Nil(err)
+	at.Equal("acme", token.Claims.(jwt.MapClaims)["tenant"])
+
+	acme.AssertExpectations(t)
+}
+
+func Test_Auth_Tenant_Init(t *testing.T) {
+	at := assert.New(t)
+
+	at.Panics(func() {
+		module{Config: &Config{
+			Service:      new(mocks.AccountService),
+			SigningKey:   "test",
+			TenantLookup: "header:X-Tenant",
+		}}.Init()
+	})
+}
+
+// tenantService is a fake TenantService backed by mocks
+type tenantService struct {
+	*mocks.AccountService
+	tenants map[string]Service
+}
+
+func (s tenantService) WithTenant(tenant string) Service {
+	return s.tenants[tenant]
+}
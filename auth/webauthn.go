@@ -0,0 +1,480 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/go-dawn/dawn/fiberx"
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	// ErrWebAuthnUnavailable occurs when relying party or storage
+	// of webauthn is not available
+	ErrWebAuthnUnavailable = errors.New("auth: webauthn is not available")
+
+	// ErrWebAuthnVerification occurs when a webauthn ceremony fails
+	ErrWebAuthnVerification = errors.New("auth: webauthn verification failed")
+
+	// ErrSignCount occurs when signature counter of a credential
+	// does not increase, which means the authenticator may be cloned
+	ErrSignCount = errors.New("auth: webauthn sign count is not increased")
+)
+
+var webAuthnPrefix = "auth:webauthn:"
+
+const (
+	// coseAlgES256 is ECDSA w/ SHA-256 in COSE algorithm registry
+	coseAlgES256 = -7
+	// coseKtyEC2 is elliptic curve key type in COSE key registry
+	coseKtyEC2 = 2
+	// coseCrvP256 is P-256 curve in COSE elliptic curve registry
+	coseCrvP256 = 1
+
+	flagUserPresent  = 0x01
+	flagAttestedData = 0x40
+
+	challengeLogin    = "login"
+	challengeRegister = "register:"
+)
+
+type attestationForm struct {
+	// ID is the base64url encoded credential id
+	ID string `json:"id" validate:"required"`
+	// ClientDataJSON is the base64url encoded client data
+	ClientDataJSON string `json:"clientDataJSON" validate:"required"`
+	// AttestationObject is the base64url encoded attestation object
+	AttestationObject string `json:"attestationObject" validate:"required"`
+}
+
+// assertion is carried by the code of webauthn login form
+type assertion struct {
+	// ClientDataJSON is the base64url encoded client data
+	ClientDataJSON string `json:"clientDataJSON"`
+	// AuthenticatorData is the base64url encoded authenticator data
+	AuthenticatorData string `json:"authenticatorData"`
+	// Signature is the base64url encoded signature
+	Signature string `json:"signature"`
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+type attestationObject struct {
+	Fmt      string          `cbor:"fmt"`
+	AttStmt  cbor.RawMessage `cbor:"attStmt"`
+	AuthData []byte          `cbor:"authData"`
+}
+
+type authenticatorData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	credentialID []byte
+	publicKey    []byte
+}
+
+type coseKey struct {
+	Kty int    `cbor:"1,keyasint"`
+	Alg int    `cbor:"3,keyasint"`
+	Crv int    `cbor:"-1,keyasint"`
+	X   []byte `cbor:"-2,keyasint"`
+	Y   []byte `cbor:"-3,keyasint"`
+}
+
+func (m module) webAuthnRegisterOptions(c *fiber.Ctx) (err error) {
+	var (
+		id          = UserID(c)
+		u           User
+		credentials []Credential
+		challenge   string
+	)
+
+	// Impersonators can't leave a passkey behind
+	if RealUserID(c) != id {
+		return errResp(c, ErrPermissionDenied)
+	}
+
+	// Unavailable is told before any lookup of the user
+	if !m.webAuthnReady() {
+		return webAuthnErr(ErrWebAuthnUnavailable)
+	}
+
+	s := m.service(c)
+
+	if u, err = s.UserCtx(UserContext(c), id); err != nil {
+		return errResp(c, err)
+	}
+
+	if credentials, err = s.CredentialsCtx(UserContext(c), id); err != nil {
+		return
+	}
+
+	if challenge, err = m.webAuthnChallenge(UserContext(c), Tenant(c), challengeRegister+strconv.Itoa(id)); err != nil {
+		return
+	}
+
+	exclude := make([]fiber.Map, len(credentials))
+	for i, credential := range credentials {
+		exclude[i] = fiber.Map{"type": "public-key", "id": credential.ID}
+	}
+
+	return fiberx.Data(c, fiber.Map{
+		"challenge": challenge,
+		"rp":        fiber.Map{"id": m.WebAuthn.RPID, "name": m.WebAuthn.RPName},
+		"user": fiber.Map{
+			"id":          base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id))),
+			"name":        displayName(u),
+			"displayName": displayName(u),
+		},
+		"pubKeyCredParams":   []fiber.Map{{"type": "public-key", "alg": coseAlgES256}},
+		"timeout":            m.WebAuthn.Timeout.Milliseconds(),
+		"excludeCredentials": exclude,
+		"attestation":        "none",
+	})
+}
+
+func (m module) webAuthnRegister(c *fiber.Ctx) (err error) {
+	var (
+		data  attestationForm
+		id    = UserID(c)
+		cd    clientData
+		obj   attestationObject
+		ad    authenticatorData
+		token []byte
+	)
+
+	if RealUserID(c) != id {
+		return errResp(c, ErrPermissionDenied)
+	}
+
+	if !m.webAuthnReady() {
+		return webAuthnErr(ErrWebAuthnUnavailable)
+	}
+
+	if err = fiberx.ValidateBody(c, &data); err != nil {
+		return
+	}
+
+	if _, cd, err = m.parseClientData(data.ClientDataJSON, "webauthn.create"); err != nil {
+		return webAuthnErr(err)
+	}
+
+	if err = m.consumeChallenge(UserContext(c), Tenant(c), cd.Challenge, challengeRegister+strconv.Itoa(id)); err != nil {
+		return webAuthnErr(err)
+	}
+
+	if token, err = base64.RawURLEncoding.DecodeString(data.AttestationObject); err != nil {
+		return webAuthnErr(err)
+	}
+
+	if err = cbor.Unmarshal(token, &obj); err != nil {
+		return webAuthnErr(err)
+	}
+
+	if ad, err = m.parseAuthenticatorData(obj.AuthData); err != nil {
+		return webAuthnErr(err)
+	}
+
+	if ad.flags&flagAttestedData == 0 {
+		return webAuthnErr(fmt.Errorf("%w: missing attested credential data", ErrWebAuthnVerification))
+	}
+
+	if base64.RawURLEncoding.EncodeToString(ad.credentialID) != data.ID {
+		return webAuthnErr(fmt.Errorf("%w: credential id mismatched", ErrWebAuthnVerification))
+	}
+
+	if _, err = parsePublicKey(ad.publicKey); err != nil {
+		return webAuthnErr(err)
+	}
+
+	// Attestation statement is not verified since
+	// attestation conveyance is none
+	if err = m.service(c).AddCredentialCtx(UserContext(c), Credential{
+		ID:        data.ID,
+		UserID:    id,
+		PublicKey: ad.publicKey,
+		SignCount: ad.signCount,
+	}); err != nil {
+		return errResp(c, err)
+	}
+
+	return fiberx.Message(c, "Passkey registered")
+}
+
+func (m module) webAuthnLoginOptions(c *fiber.Ctx) (err error) {
+	var challenge string
+
+	if !m.webAuthnReady() {
+		return webAuthnErr(ErrWebAuthnUnavailable)
+	}
+
+	if challenge, err = m.webAuthnChallenge(UserContext(c), Tenant(c), challengeLogin); err != nil {
+		return
+	}
+
+	return fiberx.Data(c, fiber.Map{
+		"challenge":        challenge,
+		"rpId":             m.WebAuthn.RPID,
+		"timeout":          m.WebAuthn.Timeout.Milliseconds(),
+		"userVerification": "preferred",
+	})
+}
+
+// loginByWebAuthn verifies the assertion of the credential
+// and returns user id of the credential owner
+func (m module) loginByWebAuthn(ctx context.Context, s ContextAccountService, id, code string) (userID int, err error) {
+	var (
+		a          assertion
+		raw        []byte
+		cd         clientData
+		authData   []byte
+		ad         authenticatorData
+		sig        []byte
+		credential Credential
+		key        *ecdsa.PublicKey
+	)
+
+	if !m.webAuthnReady() {
+		return 0, ErrWebAuthnUnavailable
+	}
+
+	// Failures of the assertion are reported as invalid credentials
+	if err = json.Unmarshal([]byte(code), &a); err != nil {
+		return 0, credentialsError{err}
+	}
+
+	if raw, cd, err = m.parseClientData(a.ClientDataJSON, "webauthn.get"); err != nil {
+		return 0, credentialsError{err}
+	}
+
+	if err = m.consumeChallenge(ctx, TenantFromContext(ctx), cd.Challenge, challengeLogin); err != nil {
+		if errors.Is(err, ErrWebAuthnVerification) {
+			err = credentialsError{err}
+		}
+		return
+	}
+
+	if authData, err = base64.RawURLEncoding.DecodeString(a.AuthenticatorData); err != nil {
+		return 0, credentialsError{err}
+	}
+
+	if ad, err = m.parseAuthenticatorData(authData); err != nil {
+		return 0, credentialsError{err}
+	}
+
+	if credential, err = s.CredentialCtx(ctx, id); err != nil {
+		if errors.Is(err, ErrCredentialNotFound) {
+			err = credentialsError{err}
+		}
+		return
+	}
+
+	if key, err = parsePublicKey(credential.PublicKey); err != nil {
+		return 0, credentialsError{err}
+	}
+
+	if sig, err = base64.RawURLEncoding.DecodeString(a.Signature); err != nil {
+		return 0, credentialsError{err}
+	}
+
+	if !verifySignature(key, authData, raw, sig) {
+		return 0, credentialsError{fmt.Errorf("%w: invalid signature", ErrWebAuthnVerification)}
+	}
+
+	// Authenticators which do not support signature counter
+	// always report zero
+	if (ad.signCount != 0 || credential.SignCount != 0) && ad.signCount <= credential.SignCount {
+		return 0, credentialsError{ErrSignCount}
+	}
+
+	if err = s.UpdateSignCountCtx(ctx, id, ad.signCount); err != nil {
+		return
+	}
+
+	return credential.UserID, nil
+}
+
+// webAuthnReady reports whether relying party and storage are set
+func (m module) webAuthnReady() bool {
+	return m.Storage != nil && m.WebAuthn.RPID != ""
+}
+
+// webAuthnChallenge generates a random challenge and stores
+// it with the purpose in the tenant
+func (m module) webAuthnChallenge(ctx context.Context, tenant, purpose string) (string, error) {
+	if !m.webAuthnReady() {
+		return "", ErrWebAuthnUnavailable
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	challenge := base64.RawURLEncoding.EncodeToString(b)
+
+	return challenge, m.Storage.SetCtx(ctx, webAuthnPrefix+CodeKey(tenant, challenge), []byte(purpose), m.WebAuthn.Timeout)
+}
+
+// consumeChallenge makes sure the challenge is issued for
+// the purpose in the tenant and can be used only once
+func (m module) consumeChallenge(ctx context.Context, tenant, challenge, purpose string) error {
+	b, err := m.Storage.PullCtx(ctx, webAuthnPrefix+CodeKey(tenant, challenge))
+	if err != nil {
+		return err
+	}
+
+	if string(b) != purpose {
+		return fmt.Errorf("%w: invalid challenge", ErrWebAuthnVerification)
+	}
+
+	return nil
+}
+
+func (m module) parseClientData(s, typ string) (raw []byte, cd clientData, err error) {
+	if raw, err = base64.RawURLEncoding.DecodeString(s); err != nil {
+		return
+	}
+
+	if err = json.Unmarshal(raw, &cd); err != nil {
+		return
+	}
+
+	if cd.Type != typ {
+		err = fmt.Errorf("%w: unexpected type %s", ErrWebAuthnVerification, cd.Type)
+		return
+	}
+
+	for _, origin := range m.WebAuthn.Origins {
+		if cd.Origin == origin {
+			return
+		}
+	}
+
+	err = fmt.Errorf("%w: unexpected origin %s", ErrWebAuthnVerification, cd.Origin)
+
+	return
+}
+
+func (m module) parseAuthenticatorData(b []byte) (ad authenticatorData, err error) {
+	if len(b) < 37 {
+		err = fmt.Errorf("%w: authenticator data is too short", ErrWebAuthnVerification)
+		return
+	}
+
+	ad.rpIDHash = b[:32]
+	ad.flags = b[32]
+	ad.signCount = binary.BigEndian.Uint32(b[33:37])
+
+	if rpIDHash := sha256.Sum256([]byte(m.WebAuthn.RPID)); !bytes.Equal(rpIDHash[:], ad.rpIDHash) {
+		err = fmt.Errorf("%w: rp id hash mismatched", ErrWebAuthnVerification)
+		return
+	}
+
+	if ad.flags&flagUserPresent == 0 {
+		err = fmt.Errorf("%w: user is not present", ErrWebAuthnVerification)
+		return
+	}
+
+	if ad.flags&flagAttestedData == 0 {
+		return
+	}
+
+	// aaguid(16) + credential id length(2)
+	rest := b[37:]
+	if len(rest) < 18 {
+		err = fmt.Errorf("%w: attested credential data is too short", ErrWebAuthnVerification)
+		return
+	}
+
+	l := int(binary.BigEndian.Uint16(rest[16:18]))
+	if rest = rest[18:]; len(rest) < l {
+		err = fmt.Errorf("%w: credential id is too short", ErrWebAuthnVerification)
+		return
+	}
+
+	ad.credentialID, rest = rest[:l], rest[l:]
+
+	// credential public key may be followed by extensions
+	var key cbor.RawMessage
+	if err = cbor.NewDecoder(bytes.NewReader(rest)).Decode(&key); err != nil {
+		return
+	}
+
+	ad.publicKey = key
+
+	return
+}
+
+// parsePublicKey parses COSE encoded ES256 public key
+func parsePublicKey(b []byte) (*ecdsa.PublicKey, error) {
+	var k coseKey
+	if err := cbor.Unmarshal(b, &k); err != nil {
+		return nil, err
+	}
+
+	if k.Kty != coseKtyEC2 || k.Alg != coseAlgES256 || k.Crv != coseCrvP256 {
+		return nil, fmt.Errorf("%w: unsupported public key", ErrWebAuthnVerification)
+	}
+
+	key := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(k.X),
+		Y:     new(big.Int).SetBytes(k.Y),
+	}
+
+	if !key.Curve.IsOnCurve(key.X, key.Y) {
+		return nil, fmt.Errorf("%w: invalid public key", ErrWebAuthnVerification)
+	}
+
+	return key, nil
+}
+
+// verifySignature verifies the signature over authenticator
+// data and hash of client data
+func verifySignature(key *ecdsa.PublicKey, authData, clientData, sig []byte) bool {
+	var s struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(sig, &s); err != nil {
+		return false
+	}
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+
+	return ecdsa.Verify(key, digest[:], s.R, s.S)
+}
+
+func webAuthnErr(err error) error {
+	if errors.Is(err, ErrWebAuthnUnavailable) {
+		return fiberx.CodeErr(fiber.StatusServiceUnavailable, err)
+	}
+	return fiberx.CodeErr(fiber.StatusBadRequest, err, "Failed to verify passkey")
+}
+
+func displayName(u User) string {
+	switch {
+	case u.Username != "":
+		return u.Username
+	case u.Email != "":
+		return u.Email
+	default:
+		return u.Mobile
+	}
+}
@@ -0,0 +1,410 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/gavv/httpexpect/v2"
+	"github.com/go-dawn/module/auth/mocks"
+	"github.com/go-dawn/module/cache"
+	"github.com/go-dawn/pkg/deck"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var (
+	testRPID   = "example.com"
+	testOrigin = "https://example.com"
+)
+
+func Test_Auth_WebAuthn_Unavailable(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	m, mockService := routeModule()
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		app.Post("/webauthn/login", m.webAuthnLoginOptions)
+		app.Post("/webauthn/register", m.webAuthnRegisterOptions)
+		app.Post("/webauthn/register/finish", m.webAuthnRegister)
+	})
+
+	e.POST("/webauthn/login").Expect().Status(fiber.StatusServiceUnavailable)
+	// No user is looked up by the service
+	e.POST("/webauthn/register").Expect().Status(fiber.StatusServiceUnavailable)
+	e.POST("/webauthn/register/finish").Expect().Status(fiber.StatusServiceUnavailable)
+	mockService.AssertExpectations(t)
+
+	_, err := m.loginByWebAuthn(context.Background(), contextService(mockService), "id", "{}")
+	at.Equal(ErrWebAuthnUnavailable, err)
+}
+
+func Test_Auth_WebAuthn_Register(t *testing.T) {
+	at := assert.New(t)
+
+	m, mockService := webAuthnModule()
+	a := newSoftAuthenticator(t)
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		app.Use(m.jwt())
+		app.Post("/webauthn/register", m.webAuthnRegisterOptions)
+		app.Post("/webauthn/register/finish", m.webAuthnRegister)
+	})
+
+	token, err := generateToken("", m.SigningKey, 1, time.Hour)
+	at.Nil(err)
+	bearer := "Bearer " + token
+
+	options := func() string {
+		mockService.On("User", 1).
+			Once().Return(User{ID: 1, Username: "kiyon"}, nil).
+			On("Credentials", 1).
+			Once().Return([]Credential{{ID: "exist"}}, nil)
+
+		resp := e.POST("/webauthn/register").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusOK)
+
+		var challenge string
+		deck.AssertRespDataCheck(resp, func(v *httpexpect.Value) {
+			data := v.Object()
+			data.Path("$.rp.id").Equal(testRPID)
+			data.Path("$.user.name").Equal("kiyon")
+			data.Path("$.excludeCredentials[0].id").Equal("exist")
+			challenge = data.Value("challenge").String().Raw()
+		})
+
+		return challenge
+	}
+
+	t.Run("impersonator", func(t *testing.T) {
+		token, err := generateToken("", m.SigningKey, 1, time.Hour, actorClaims(2))
+		at.Nil(err)
+
+		e.POST("/webauthn/register").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+token).
+			Expect().
+			Status(fiber.StatusForbidden)
+
+		e.POST("/webauthn/register/finish").
+			WithHeader(fiber.HeaderAuthorization, "Bearer "+token).
+			WithJSON(a.create(options())).
+			Expect().
+			Status(fiber.StatusForbidden)
+	})
+
+	t.Run("failed to get user", func(t *testing.T) {
+		mockService.On("User", 1).
+			Once().Return(User{}, errors.New("fake error"))
+
+		e.POST("/webauthn/register").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusInternalServerError)
+	})
+
+	t.Run("bad request", func(t *testing.T) {
+		e.POST("/webauthn/register/finish").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			Expect().
+			Status(fiber.StatusBadRequest)
+	})
+
+	t.Run("unexpected origin", func(t *testing.T) {
+		a.origin = "https://evil.com"
+		defer func() { a.origin = testOrigin }()
+
+		resp := e.POST("/webauthn/register/finish").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			WithJSON(a.create(options())).
+			Expect().
+			Status(fiber.StatusBadRequest)
+
+		deck.AssertRespMsg(resp, "Failed to verify passkey")
+	})
+
+	t.Run("invalid challenge", func(t *testing.T) {
+		e.POST("/webauthn/register/finish").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			WithJSON(a.create("invalid")).
+			Expect().
+			Status(fiber.StatusBadRequest)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		form := a.create(options())
+
+		mockService.On("AddCredential", mock.MatchedBy(func(c Credential) bool {
+			return c.ID == form.ID && c.UserID == 1 && c.SignCount == a.count
+		})).Once().Return(nil)
+
+		resp := e.POST("/webauthn/register/finish").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			WithJSON(form).
+			Expect().
+			Status(fiber.StatusOK)
+
+		deck.AssertRespMsg(resp, "Passkey registered")
+
+		// challenge can only be used once
+		e.POST("/webauthn/register/finish").
+			WithHeader(fiber.HeaderAuthorization, bearer).
+			WithJSON(form).
+			Expect().
+			Status(fiber.StatusBadRequest)
+	})
+}
+
+func Test_Auth_WebAuthn_Login(t *testing.T) {
+	at := assert.New(t)
+
+	m, mockService := webAuthnModule()
+	a := newSoftAuthenticator(t)
+
+	e := deck.SetupServer(t, func(app *fiber.App) {
+		app.Post("/webauthn/login", m.webAuthnLoginOptions)
+		app.Post("/login", m.login)
+	})
+
+	credential := Credential{
+		ID:        a.credentialID(),
+		UserID:    1,
+		PublicKey: a.publicKey(),
+	}
+
+	options := func() string {
+		resp := e.POST("/webauthn/login").Expect().Status(fiber.StatusOK)
+
+		var challenge string
+		deck.AssertRespDataCheck(resp, func(v *httpexpect.Value) {
+			data := v.Object()
+			data.Value("rpId").Equal(testRPID)
+			challenge = data.Value("challenge").String().Raw()
+		})
+
+		return challenge
+	}
+
+	t.Run("invalid signature", func(t *testing.T) {
+		mockService.On("Credential", credential.ID).
+			Once().Return(Credential{ID: credential.ID, UserID: 1, PublicKey: newSoftAuthenticator(t).publicKey()}, nil)
+
+		_, err := m.loginByWebAuthn(context.Background(), contextService(mockService), credential.ID, a.get(options()))
+		at.True(errors.Is(err, ErrWebAuthnVerification))
+	})
+
+	t.Run("invalid challenge", func(t *testing.T) {
+		_, err := m.loginByWebAuthn(context.Background(), contextService(mockService), credential.ID, a.get("invalid"))
+		at.True(errors.Is(err, ErrWebAuthnVerification))
+	})
+
+	t.Run("challenge of other tenant", func(t *testing.T) {
+		challenge, err := m.webAuthnChallenge(context.Background(), "acme", challengeLogin)
+		at.Nil(err)
+
+		err = m.consumeChallenge(context.Background(), "other", challenge, challengeLogin)
+		at.True(errors.Is(err, ErrWebAuthnVerification))
+		at.Nil(m.consumeChallenge(context.Background(), "acme", challenge, challengeLogin))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		mockService.On("Credential", credential.ID).
+			Once().Return(credential, nil).
+			On("UpdateSignCount", credential.ID, a.count+1).
+			Once().Return(nil).
+			On("User", 1).
+			Once().Return(User{ID: 1}, nil)
+
+		resp := e.POST("/login").WithJSON(loginForm{
+			Username: credential.ID,
+			Code:     a.get(options()),
+			Type:     "webauthn",
+		}).Expect().Status(fiber.StatusOK)
+
+		deck.AssertRespDataCheck(resp, func(v *httpexpect.Value) {
+			at.NotEmpty(v.Raw())
+		})
+	})
+
+	t.Run("sign count not increased", func(t *testing.T) {
+		mockService.On("Credential", credential.ID).
+			Once().Return(Credential{ID: credential.ID, UserID: 1, PublicKey: credential.PublicKey, SignCount: a.count + 1}, nil)
+
+		_, err := m.loginByWebAuthn(context.Background(), contextService(mockService), credential.ID, a.get(options()))
+		at.True(errors.Is(err, ErrSignCount))
+		at.True(errors.Is(err, ErrInvalidCredentials))
+	})
+}
+
+func Test_Auth_WebAuthn_ParseAuthenticatorData(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	m, _ := webAuthnModule()
+	a := newSoftAuthenticator(t)
+
+	_, err := m.parseAuthenticatorData([]byte("short"))
+	at.True(errors.Is(err, ErrWebAuthnVerification))
+
+	b := a.authenticatorData(false)
+	b[0]++
+	_, err = m.parseAuthenticatorData(b)
+	at.True(errors.Is(err, ErrWebAuthnVerification))
+
+	b = a.authenticatorData(false)
+	b[32] = 0
+	_, err = m.parseAuthenticatorData(b)
+	at.True(errors.Is(err, ErrWebAuthnVerification))
+
+	b = a.authenticatorData(true)
+	_, err = m.parseAuthenticatorData(b[:40])
+	at.True(errors.Is(err, ErrWebAuthnVerification))
+
+	ad, err := m.parseAuthenticatorData(a.authenticatorData(true))
+	at.Nil(err)
+	at.Equal(a.id, ad.credentialID)
+	at.Equal(a.publicKey(), ad.publicKey)
+}
+
+func Test_Auth_WebAuthn_ParsePublicKey(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	_, err := parsePublicKey([]byte("invalid"))
+	at.NotNil(err)
+
+	b, err := cbor.Marshal(coseKey{Kty: coseKtyEC2, Alg: -257, Crv: coseCrvP256})
+	at.Nil(err)
+	_, err = parsePublicKey(b)
+	at.True(errors.Is(err, ErrWebAuthnVerification))
+
+	b, err = cbor.Marshal(coseKey{Kty: coseKtyEC2, Alg: coseAlgES256, Crv: coseCrvP256, X: []byte{1}, Y: []byte{1}})
+	at.Nil(err)
+	_, err = parsePublicKey(b)
+	at.True(errors.Is(err, ErrWebAuthnVerification))
+}
+
+func webAuthnModule() (module, *mocks.AccountService) {
+	m, s := routeModule()
+
+	cache.New().Init()
+	m.Storage = cache.Storage()
+	m.WebAuthn = WebAuthnConfig{
+		RPID:    testRPID,
+		RPName:  "Example",
+		Origins: []string{testOrigin},
+		Timeout: time.Minute,
+	}
+	m.enableStrategies()
+
+	return m, s
+}
+
+// softAuthenticator is a software authenticator for testing
+type softAuthenticator struct {
+	key    *ecdsa.PrivateKey
+	id     []byte
+	origin string
+	count  uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	id := make([]byte, 16)
+	_, err = rand.Read(id)
+	assert.Nil(t, err)
+
+	return &softAuthenticator{key: key, id: id, origin: testOrigin, count: 1}
+}
+
+func (a *softAuthenticator) credentialID() string {
+	return base64.RawURLEncoding.EncodeToString(a.id)
+}
+
+func (a *softAuthenticator) publicKey() []byte {
+	b, _ := cbor.Marshal(coseKey{
+		Kty: coseKtyEC2,
+		Alg: coseAlgES256,
+		Crv: coseCrvP256,
+		X:   a.key.X.FillBytes(make([]byte, 32)),
+		Y:   a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	return b
+}
+
+func (a *softAuthenticator) authenticatorData(attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+
+	b := append([]byte{}, rpIDHash[:]...)
+	flags := byte(flagUserPresent)
+	if attested {
+		flags |= flagAttestedData
+	}
+	b = append(b, flags)
+	b = append(b, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[33:], a.count)
+
+	if attested {
+		b = append(b, make([]byte, 16)...)
+		b = append(b, byte(len(a.id)>>8), byte(len(a.id)))
+		b = append(b, a.id...)
+		b = append(b, a.publicKey()...)
+	}
+
+	return b
+}
+
+func (a *softAuthenticator) clientData(typ, challenge string) []byte {
+	b, _ := json.Marshal(clientData{Type: typ, Challenge: challenge, Origin: a.origin})
+	return b
+}
+
+func (a *softAuthenticator) create(challenge string) attestationForm {
+	obj, _ := cbor.Marshal(attestationObject{
+		Fmt:      "none",
+		AttStmt:  cbor.RawMessage{0xa0},
+		AuthData: a.authenticatorData(true),
+	})
+
+	return attestationForm{
+		ID:                a.credentialID(),
+		ClientDataJSON:    base64.RawURLEncoding.EncodeToString(a.clientData("webauthn.create", challenge)),
+		AttestationObject: base64.RawURLEncoding.EncodeToString(obj),
+	}
+}
+
+// get signs an assertion with an increased signature counter
+func (a *softAuthenticator) get(challenge string) string {
+	a.count++
+	authData := a.authenticatorData(false)
+	cd := a.clientData("webauthn.get", challenge)
+
+	clientDataHash := sha256.Sum256(cd)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	sig, _ := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+
+	b, _ := json.Marshal(assertion{
+		ClientDataJSON:    base64.RawURLEncoding.EncodeToString(cd),
+		AuthenticatorData: base64.RawURLEncoding.EncodeToString(authData),
+		Signature:         base64.RawURLEncoding.EncodeToString(sig),
+	})
+
+	return string(b)
+}
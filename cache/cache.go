@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -18,6 +19,7 @@ var (
 type Module struct {
 	dawn.Module
 	storage  map[string]Cacher
+	codecs   map[string]Codec
 	fallback string
 }
 
@@ -32,6 +34,7 @@ func (m *Module) String() string {
 
 func (m *Module) Init() dawn.Cleanup {
 	m.storage = make(map[string]Cacher)
+	m.codecs = make(map[string]Codec)
 
 	// extract cache config
 	c := config.Sub("cache")
@@ -42,12 +45,35 @@ func (m *Module) Init() dawn.Cleanup {
 
 	if len(storeConfig) == 0 {
 		m.storage[m.fallback] = build(m.fallback, config.New())
+		m.codecs[m.fallback] = buildCodec(m.fallback, config.New())
 	}
 
-	// build each storage in config
+	// build each storage in config, tiered storages are
+	// built after storages they refer to
+	var tiered []string
 	for name := range storeConfig {
 		cfg := c.Sub("storage." + name)
+		if strings.EqualFold(cfg.GetString("driver"), "tiered") {
+			tiered = append(tiered, name)
+			continue
+		}
 		m.storage[name] = build(name, cfg)
+		m.codecs[name] = buildCodec(name, cfg)
+	}
+
+	for _, name := range tiered {
+		cfg := c.Sub("storage." + name)
+		// Tiers of a tiered storage can't be tiered, since
+		// the order they're built in is undefined
+		for _, tier := range []string{cfg.GetString("l1"), cfg.GetString("l2")} {
+			for _, t := range tiered {
+				if tier == t {
+					panic(fmt.Sprintf("dawn:cache tier %s of %s can't be tiered", tier, name))
+				}
+			}
+		}
+		m.storage[name] = newTiered(name, cfg, m.storage)
+		m.codecs[name] = buildCodec(name, cfg)
 	}
 
 	return m.cleanup
@@ -80,53 +106,180 @@ func (m *Module) cleanup() {
 	}
 }
 
-// Cacher interface defines cache behaviors.
+// Cacher interface defines cache behaviors. Methods without
+// context use context.Background().
 type Cacher interface {
 	// Has determines if an entry exists in the cache.
 	Has(key string) (bool, error)
 
+	// HasCtx determines if an entry exists in the cache.
+	HasCtx(ctx context.Context, key string) (bool, error)
+
 	// Get retrieves an entry from the cache for the given key.
 	Get(key string) ([]byte, error)
 
+	// GetCtx retrieves an entry from the cache for the given key.
+	GetCtx(ctx context.Context, key string) ([]byte, error)
+
 	// GetWithDefault retrieves an entry from the cache for the
 	// given key. Returns default value if value is not found.
 	GetWithDefault(key string, defaultValue []byte) ([]byte, error)
 
+	// GetWithDefaultCtx retrieves an entry from the cache for the
+	// given key. Returns default value if value is not found.
+	GetWithDefaultCtx(ctx context.Context, key string, defaultValue []byte) ([]byte, error)
+
 	// Many retrieves multiple db from the cache by key.
 	// Items not found in the cache will have a empty string.
 	Many(keys []string) ([][]byte, error)
 
+	// ManyCtx retrieves multiple db from the cache by key.
+	// Items not found in the cache will have a empty string.
+	ManyCtx(ctx context.Context, keys []string) ([][]byte, error)
+
 	// Set stores an entry in the cache for a given number of ttl.
 	Set(key string, value []byte, ttl time.Duration) error
 
+	// SetCtx stores an entry in the cache for a given number of ttl.
+	SetCtx(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
 	// Pull retrieves an entry from the cache and removes it in the cache.
 	Pull(key string) ([]byte, error)
 
+	// PullCtx retrieves an entry from the cache and removes it in the cache.
+	PullCtx(ctx context.Context, key string) ([]byte, error)
+
 	// PullWithDefault retrieves an entry from the cache and removes it in
 	// the cache. Returns default value if value is not found.
 	PullWithDefault(key string, defaultValue []byte) ([]byte, error)
 
+	// PullWithDefaultCtx retrieves an entry from the cache and removes it in
+	// the cache. Returns default value if value is not found.
+	PullWithDefaultCtx(ctx context.Context, key string, defaultValue []byte) ([]byte, error)
+
 	// Forever stores an entry in the cache indefinitely.
 	Forever(key string, value []byte) error
 
+	// ForeverCtx stores an entry in the cache indefinitely.
+	ForeverCtx(ctx context.Context, key string, value []byte) error
+
 	// Remember gets an entry from the cache, or stores an entry from
 	// the closure in the cache for a given number of ttl.
 	Remember(key string, ttl time.Duration, valueFunc func() ([]byte, error)) ([]byte, error)
 
+	// RememberCtx gets an entry from the cache, or stores an entry from
+	// the closure in the cache for a given number of ttl.
+	RememberCtx(ctx context.Context, key string, ttl time.Duration, valueFunc func() ([]byte, error)) ([]byte, error)
+
 	// RememberForever gets an entry from the cache, or stores an entry
 	// from the closure in the cache forever.
 	RememberForever(key string, valueFunc func() ([]byte, error)) ([]byte, error)
 
+	// RememberForeverCtx gets an entry from the cache, or stores an entry
+	// from the closure in the cache forever.
+	RememberForeverCtx(ctx context.Context, key string, valueFunc func() ([]byte, error)) ([]byte, error)
+
+	// RememberSWR gets an entry from the cache, or stores an entry from
+	// the closure. The entry gets stale after freshTTL, stale entries are
+	// returned at once while the closure refreshes them in background,
+	// and are removed after staleTTL more.
+	RememberSWR(key string, freshTTL, staleTTL time.Duration, valueFunc func() ([]byte, error)) ([]byte, error)
+
+	// RememberSWRCtx gets an entry from the cache, or stores an entry from
+	// the closure. The entry gets stale after freshTTL, stale entries are
+	// returned at once while the closure refreshes them in background,
+	// and are removed after staleTTL more.
+	RememberSWRCtx(ctx context.Context, key string, freshTTL, staleTTL time.Duration, valueFunc func() ([]byte, error)) ([]byte, error)
+
+	// RememberXFetch gets an entry from the cache, or stores an entry from
+	// the closure for a given number of ttl. The entry is recomputed
+	// before it expires with probability growing as the expiry approaches,
+	// beta larger than 1 favors earlier recomputation.
+	RememberXFetch(key string, ttl time.Duration, beta float64, valueFunc func() ([]byte, error)) ([]byte, error)
+
+	// RememberXFetchCtx gets an entry from the cache, or stores an entry from
+	// the closure for a given number of ttl. The entry is recomputed
+	// before it expires with probability growing as the expiry approaches,
+	// beta larger than 1 favors earlier recomputation.
+	RememberXFetchCtx(ctx context.Context, key string, ttl time.Duration, beta float64, valueFunc func() ([]byte, error)) ([]byte, error)
+
 	// Delete removes an entry from the cache.
 	Delete(key string) error
 
+	// DeleteCtx removes an entry from the cache.
+	DeleteCtx(ctx context.Context, key string) error
+
+	// Increment adds delta to the counter of the key and returns the
+	// new value. Missing counters start from 0 and expire after ttl,
+	// the ttl of existing counters is kept. Counters are stored in
+	// decimal, ErrNotInteger is returned for other entries.
+	Increment(key string, delta int64, ttl time.Duration) (int64, error)
+
+	// IncrementCtx adds delta to the counter of the key and returns the
+	// new value. Missing counters start from 0 and expire after ttl,
+	// the ttl of existing counters is kept. Counters are stored in
+	// decimal, ErrNotInteger is returned for other entries.
+	IncrementCtx(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+
+	// Decrement subtracts delta from the counter of the key
+	// and returns the new value, the same as Increment.
+	Decrement(key string, delta int64, ttl time.Duration) (int64, error)
+
+	// DecrementCtx subtracts delta from the counter of the key
+	// and returns the new value, the same as IncrementCtx.
+	DecrementCtx(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+
+	// Add stores an entry in the cache for a given number of ttl only
+	// if the key is missing, and reports whether it's stored. Entries
+	// without positive ttl never expire.
+	Add(key string, value []byte, ttl time.Duration) (bool, error)
+
+	// AddCtx stores an entry in the cache for a given number of ttl only
+	// if the key is missing, and reports whether it's stored.
+	AddCtx(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+
+	// CompareAndSwap stores new value in the cache for a given number of
+	// ttl only if the entry equals old value, and reports whether it's
+	// swapped. Missing entries are never swapped, and entries without
+	// positive ttl never expire.
+	CompareAndSwap(key string, old, new []byte, ttl time.Duration) (bool, error)
+
+	// CompareAndSwapCtx stores new value in the cache for a given number of
+	// ttl only if the entry equals old value, and reports whether it's
+	// swapped. Missing entries are never swapped.
+	CompareAndSwapCtx(ctx context.Context, key string, old, new []byte, ttl time.Duration) (bool, error)
+
 	// Reset removes all data from the cache.
 	Reset() error
 
+	// ResetCtx removes all data from the cache.
+	ResetCtx(ctx context.Context) error
+
+	// Tags links entries stored by the returned cache to the tags.
+	Tags(tags ...string) TaggedCacher
+
 	// Close closes the cache
 	Close() error
 
 	gc()
+
+	// tag links the key stored for ttl to the tags,
+	// zero ttl means the key is stored indefinitely
+	tag(ctx context.Context, key string, tags []string, ttl time.Duration) error
+
+	// flush removes entries linked to any of the tags,
+	// and returns keys of the entries
+	flush(ctx context.Context, tags []string) ([]string, error)
+
+	// acquire holds the lock of the key by the token for ttl
+	// if it's free, and reports whether it's acquired
+	acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+
+	// release frees the lock of the key if it's held by the token
+	release(ctx context.Context, key, token string) (bool, error)
+
+	// extend resets the ttl of the lock of the key if it's held by the token
+	extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
 }
 
 // Storage gets cache storage by specific name or fallback.
@@ -35,6 +35,15 @@ func Test_Cache_Module_Init(t *testing.T) {
 			m.Init()
 		})
 	})
+
+	t.Run("tiered tier", func(t *testing.T) {
+		config.Load("./", "nested")
+		m := &Module{}
+
+		at.PanicsWithValue("dawn:cache tier near of nearer can't be tiered", func() {
+			m.Init()
+		})
+	})
 }
 
 func Test_Cache_Module_Build(t *testing.T) {
@@ -0,0 +1,180 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-dawn/dawn/config"
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ErrInvalidPayload occurs when a compressed entry can't be decoded
+var ErrInvalidPayload = errors.New("dawn:cache invalid payload")
+
+// Codec encodes values stored in the cache
+type Codec interface {
+	// Marshal encodes v
+	Marshal(v interface{}) ([]byte, error)
+
+	// Unmarshal decodes data into v
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+// gobCodec needs concrete types of interface values
+// to be registered by gob.Register
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// Headers of entries encoded by compressCodec
+const (
+	rawPayload byte = iota
+	zstdPayload
+	snappyPayload
+)
+
+// compressCodec compresses encoded values which are larger than
+// threshold. Every entry starts with a header byte, so entries
+// can't be shared with storages using another compression
+type compressCodec struct {
+	Codec
+	header    byte
+	threshold int
+}
+
+func (c compressCodec) Marshal(v interface{}) ([]byte, error) {
+	b, err := c.Codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) < c.threshold {
+		return append([]byte{rawPayload}, b...), nil
+	}
+
+	switch c.header {
+	case zstdPayload:
+		return zstdEncoder().EncodeAll(b, []byte{zstdPayload}), nil
+	default:
+		return append([]byte{snappyPayload}, snappy.Encode(nil, b)...), nil
+	}
+}
+
+func (c compressCodec) Unmarshal(data []byte, v interface{}) (err error) {
+	if len(data) == 0 {
+		return ErrInvalidPayload
+	}
+
+	b := data[1:]
+
+	switch data[0] {
+	case rawPayload:
+	case zstdPayload:
+		if b, err = zstdDecoder().DecodeAll(b, nil); err != nil {
+			return
+		}
+	case snappyPayload:
+		if b, err = snappy.Decode(nil, b); err != nil {
+			return
+		}
+	default:
+		return ErrInvalidPayload
+	}
+
+	return c.Codec.Unmarshal(b, v)
+}
+
+var (
+	zstdOnce sync.Once
+	zstdEnc  *zstd.Encoder
+	zstdDec  *zstd.Decoder
+)
+
+// zstdEncoder and zstdDecoder are shared by all storages,
+// EncodeAll and DecodeAll are safe for concurrent use
+func zstdEncoder() *zstd.Encoder {
+	zstdOnce.Do(initZstd)
+	return zstdEnc
+}
+
+func zstdDecoder() *zstd.Decoder {
+	zstdOnce.Do(initZstd)
+	return zstdDec
+}
+
+func initZstd() {
+	zstdEnc, _ = zstd.NewWriter(nil)
+	zstdDec, _ = zstd.NewReader(nil)
+}
+
+// buildCodec creates the codec of the storage by codec,
+// compression and compressionThreshold in config
+func buildCodec(name string, c *config.Config) Codec {
+	var codec Codec
+
+	switch typ := c.GetString("codec", "json"); strings.ToLower(typ) {
+	case "json":
+		codec = jsonCodec{}
+	case "msgpack":
+		codec = msgpackCodec{}
+	case "gob":
+		codec = gobCodec{}
+	default:
+		panic(fmt.Sprintf("dawn:cache unknown codec %s of %s", typ, name))
+	}
+
+	cc := compressCodec{
+		Codec:     codec,
+		threshold: c.GetInt("compressionThreshold", 1024),
+	}
+
+	switch compression := c.GetString("compression"); strings.ToLower(compression) {
+	case "":
+		return codec
+	case "zstd":
+		cc.header = zstdPayload
+	case "snappy":
+		cc.header = snappyPayload
+	default:
+		panic(fmt.Sprintf("dawn:cache unknown compression %s of %s", compression, name))
+	}
+
+	return cc
+}
@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-dawn/dawn/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type codecEntry struct {
+	Name string
+	Tags []string
+}
+
+func Test_Cache_Codec(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	in := codecEntry{Name: "dawn", Tags: []string{"cache"}}
+
+	for _, codec := range []Codec{jsonCodec{}, msgpackCodec{}, gobCodec{}} {
+		b, err := codec.Marshal(in)
+		at.Nil(err)
+
+		var out codecEntry
+		at.Nil(codec.Unmarshal(b, &out))
+		at.Equal(in, out)
+	}
+}
+
+func Test_Cache_Codec_Compress(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	large := codecEntry{Name: string(bytes.Repeat([]byte("dawn"), 512))}
+
+	for _, header := range []byte{zstdPayload, snappyPayload} {
+		c := compressCodec{Codec: jsonCodec{}, header: header, threshold: 64}
+
+		t.Run("small", func(t *testing.T) {
+			b, err := c.Marshal(codecEntry{Name: "dawn"})
+			at.Nil(err)
+			at.Equal(rawPayload, b[0])
+
+			var out codecEntry
+			at.Nil(c.Unmarshal(b, &out))
+			at.Equal("dawn", out.Name)
+		})
+
+		t.Run("large", func(t *testing.T) {
+			b, err := c.Marshal(large)
+			at.Nil(err)
+			at.Equal(header, b[0])
+			at.Less(len(b), len(large.Name))
+
+			var out codecEntry
+			at.Nil(c.Unmarshal(b, &out))
+			at.Equal(large, out)
+		})
+	}
+
+	t.Run("marshal error", func(t *testing.T) {
+		c := compressCodec{Codec: jsonCodec{}, header: zstdPayload}
+
+		_, err := c.Marshal(make(chan int))
+		at.NotNil(err)
+	})
+
+	t.Run("invalid payload", func(t *testing.T) {
+		c := compressCodec{Codec: jsonCodec{}, header: zstdPayload}
+
+		var out codecEntry
+		at.Equal(ErrInvalidPayload, c.Unmarshal(nil, &out))
+		at.Equal(ErrInvalidPayload, c.Unmarshal([]byte{9, '{', '}'}, &out))
+		at.NotNil(c.Unmarshal([]byte{zstdPayload, 1}, &out))
+		at.NotNil(c.Unmarshal([]byte{snappyPayload, 0xff}, &out))
+	})
+}
+
+func Test_Cache_BuildCodec(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	c := config.New()
+	at.Equal(jsonCodec{}, buildCodec("default", c))
+
+	c.Set("codec", "gob")
+	at.Equal(gobCodec{}, buildCodec("gob", c))
+
+	c.Set("codec", "msgpack")
+	c.Set("compression", "snappy")
+	c.Set("compressionThreshold", 10)
+	at.Equal(compressCodec{Codec: msgpackCodec{}, header: snappyPayload, threshold: 10}, buildCodec("snappy", c))
+
+	c.Set("compression", "zstd")
+	at.Equal(zstdPayload, buildCodec("zstd", c).(compressCodec).header)
+
+	c.Set("compression", "invalid")
+	at.Panics(func() {
+		buildCodec("invalid", c)
+	})
+
+	c.Set("codec", "invalid")
+	at.Panics(func() {
+		buildCodec("invalid", c)
+	})
+}
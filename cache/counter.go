@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// ErrNotInteger is returned when a counter is changed
+// but the entry isn't a decimal integer.
+var ErrNotInteger = errors.New("dawn:cache value is not an integer")
+
+// parseCounter parses the counter stored in decimal like redis does
+func parseCounter(b []byte) (int64, error) {
+	n, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return 0, ErrNotInteger
+	}
+
+	return n, nil
+}
+
+func formatCounter(n int64) []byte {
+	return strconv.AppendInt(nil, n, 10)
+}
+
+// ttlExpiry gets the unix expiry of entries stored for ttl
+// by counters, Add and CompareAndSwap. Entries without
+// positive ttl never expire like they do in redis
+func ttlExpiry(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+
+	return time.Now().Add(ttl).Unix()
+}
@@ -0,0 +1,25 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Cache_Counter(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	n, err := parseCounter(formatCounter(-42))
+	at.Nil(err)
+	at.Equal(int64(-42), n)
+
+	_, err = parseCounter([]byte("v1"))
+	at.Equal(ErrNotInteger, err)
+
+	at.Equal(int64(0), ttlExpiry(0))
+	at.Equal(int64(0), ttlExpiry(-time.Second))
+	at.InDelta(time.Now().Add(time.Minute).Unix(), ttlExpiry(time.Minute), 1)
+}
@@ -0,0 +1,380 @@
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+// evictionPolicy orders keys of memStore for eviction,
+// it's guarded by the lock of the store
+type evictionPolicy interface {
+	// add records a new key
+	add(key string)
+	// access records a hit of the key
+	access(key string)
+	// remove forgets the key
+	remove(key string)
+	// victim gets the key to evict
+	victim() (string, bool)
+}
+
+// newEvictionPolicy creates the policy by name, capacity is used
+// to size the frequency sketch of tinylfu
+func newEvictionPolicy(name string, capacity int) evictionPolicy {
+	switch strings.ToLower(name) {
+	case "lru":
+		return newLRU()
+	case "lfu":
+		return newLFU()
+	case "tinylfu", "w-tinylfu":
+		return newTinyLFU(capacity)
+	default:
+		panic(fmt.Sprintf("dawn:cache unknown eviction %s", name))
+	}
+}
+
+// lruPolicy evicts the least recently used key
+type lruPolicy struct {
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+func newLRU() *lruPolicy {
+	return &lruPolicy{ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (p *lruPolicy) add(key string) {
+	p.items[key] = p.ll.PushFront(key)
+}
+
+func (p *lruPolicy) access(key string) {
+	if el, ok := p.items[key]; ok {
+		p.ll.MoveToFront(el)
+	}
+}
+
+func (p *lruPolicy) remove(key string) {
+	if el, ok := p.items[key]; ok {
+		p.ll.Remove(el)
+		delete(p.items, key)
+	}
+}
+
+func (p *lruPolicy) victim() (string, bool) {
+	if el := p.ll.Back(); el != nil {
+		return el.Value.(string), true
+	}
+	return "", false
+}
+
+// lfuPolicy evicts the key with the lowest priority, the least
+// recently used one among keys with the same priority. Priority
+// is the frequency plus the age when the key is added, and the
+// age is the priority of the last victim, so frequencies of old
+// keys fade out (LFU-DA). The newest key is evicted last, so a
+// stored entry survives the eviction it causes. Buckets of
+// priorities are kept in ascending order, so all operations
+// are O(1)
+type lfuPolicy struct {
+	buckets *list.List
+	items   map[string]*lfuItem
+	age     int
+	newest  string
+}
+
+type lfuBucket struct {
+	priority int
+	keys     *list.List
+}
+
+type lfuItem struct {
+	bucket *list.Element
+	el     *list.Element
+}
+
+func newLFU() *lfuPolicy {
+	return &lfuPolicy{buckets: list.New(), items: make(map[string]*lfuItem)}
+}
+
+func (p *lfuPolicy) add(key string) {
+	priority := p.age + 1
+
+	// Priorities below the age are rare, since only the
+	// newest key can be skipped by victim
+	at := p.buckets.Front()
+	for at != nil && at.Value.(*lfuBucket).priority < priority {
+		at = at.Next()
+	}
+
+	switch {
+	case at == nil:
+		at = p.buckets.PushBack(&lfuBucket{priority: priority, keys: list.New()})
+	case at.Value.(*lfuBucket).priority != priority:
+		at = p.buckets.InsertBefore(&lfuBucket{priority: priority, keys: list.New()}, at)
+	}
+
+	p.items[key] = &lfuItem{
+		bucket: at,
+		el:     at.Value.(*lfuBucket).keys.PushFront(key),
+	}
+	p.newest = key
+}
+
+func (p *lfuPolicy) access(key string) {
+	item, ok := p.items[key]
+	if !ok {
+		return
+	}
+
+	cur := item.bucket.Value.(*lfuBucket)
+
+	next := item.bucket.Next()
+	if next == nil || next.Value.(*lfuBucket).priority != cur.priority+1 {
+		next = p.buckets.InsertAfter(&lfuBucket{priority: cur.priority + 1, keys: list.New()}, item.bucket)
+	}
+
+	p.unlink(item)
+
+	item.bucket = next
+	item.el = next.Value.(*lfuBucket).keys.PushFront(key)
+}
+
+func (p *lfuPolicy) remove(key string) {
+	if item, ok := p.items[key]; ok {
+		p.unlink(item)
+		delete(p.items, key)
+	}
+}
+
+func (p *lfuPolicy) victim() (string, bool) {
+	for b := p.buckets.Front(); b != nil; b = b.Next() {
+		bucket := b.Value.(*lfuBucket)
+		for el := bucket.keys.Back(); el != nil; el = el.Prev() {
+			if key := el.Value.(string); key != p.newest || len(p.items) == 1 {
+				p.age = bucket.priority
+				return key, true
+			}
+		}
+	}
+	return "", false
+}
+
+// unlink removes the item from its bucket,
+// and removes the bucket if it's empty
+func (p *lfuPolicy) unlink(item *lfuItem) {
+	b := item.bucket.Value.(*lfuBucket)
+	b.keys.Remove(item.el)
+	if b.keys.Len() == 0 {
+		p.buckets.Remove(item.bucket)
+	}
+}
+
+// Segments of tinyLFU
+const (
+	windowSegment = iota
+	probationSegment
+	protectedSegment
+)
+
+// tinyLFUPolicy is W-TinyLFU. New keys enter a small lru window,
+// and the main space is a segmented lru with probation and
+// protected segments. The key pushed out of the window becomes
+// the candidate on probation, on eviction it competes with the
+// victim of the main space by the frequencies in a count-min
+// sketch, and the loser is evicted. Keys hit in probation are
+// promoted to protected, which keeps 80% of the main space
+type tinyLFUPolicy struct {
+	segments  [3]*list.List
+	items     map[string]*list.Element
+	sketch    *cmSketch
+	candidate string
+}
+
+type tinyLFUItem struct {
+	key     string
+	segment int
+}
+
+func newTinyLFU(capacity int) *tinyLFUPolicy {
+	p := &tinyLFUPolicy{
+		items:  make(map[string]*list.Element),
+		sketch: newCMSketch(capacity),
+	}
+
+	for i := range p.segments {
+		p.segments[i] = list.New()
+	}
+
+	return p
+}
+
+func (p *tinyLFUPolicy) add(key string) {
+	p.sketch.increment(key)
+	p.items[key] = p.segments[windowSegment].PushFront(&tinyLFUItem{key: key})
+
+	// Keep the window at 1% of entries, the key
+	// leaving the window is the next candidate
+	window := p.segments[windowSegment]
+	for window.Len() > 1 && window.Len()*100 > len(p.items) {
+		p.candidate = p.move(window.Back(), probationSegment).key
+	}
+}
+
+func (p *tinyLFUPolicy) access(key string) {
+	el, ok := p.items[key]
+	if !ok {
+		return
+	}
+
+	p.sketch.increment(key)
+
+	switch item := el.Value.(*tinyLFUItem); item.segment {
+	case probationSegment:
+		p.move(el, protectedSegment)
+
+		protected := p.segments[protectedSegment]
+		main := protected.Len() + p.segments[probationSegment].Len()
+		for protected.Len() > 1 && protected.Len()*5 > main*4 {
+			p.move(protected.Back(), probationSegment)
+		}
+	default:
+		p.segments[item.segment].MoveToFront(el)
+	}
+}
+
+func (p *tinyLFUPolicy) remove(key string) {
+	if el, ok := p.items[key]; ok {
+		p.segments[el.Value.(*tinyLFUItem).segment].Remove(el)
+		delete(p.items, key)
+	}
+}
+
+func (p *tinyLFUPolicy) victim() (string, bool) {
+	if key := p.admit(); key != "" {
+		return key, true
+	}
+
+	// Without a candidate the main space goes first
+	for _, segment := range []int{probationSegment, protectedSegment, windowSegment} {
+		if el := p.segments[segment].Back(); el != nil {
+			return el.Value.(*tinyLFUItem).key, true
+		}
+	}
+
+	return "", false
+}
+
+// admit lets the candidate compete with the victim of the main
+// space and returns the loser, ties favor the victim. Candidates
+// which are promoted or removed don't compete
+func (p *tinyLFUPolicy) admit() string {
+	ck := p.candidate
+	p.candidate = ""
+
+	candidate, ok := p.items[ck]
+	if !ok || candidate.Value.(*tinyLFUItem).segment != probationSegment {
+		return ""
+	}
+
+	victim := p.segments[probationSegment].Back()
+	if victim == candidate {
+		victim = p.segments[protectedSegment].Back()
+	}
+	if victim == nil {
+		return ck
+	}
+
+	vk := victim.Value.(*tinyLFUItem).key
+	if p.sketch.estimate(ck) > p.sketch.estimate(vk) {
+		return vk
+	}
+
+	return ck
+}
+
+// move puts the key at the front of the segment
+func (p *tinyLFUPolicy) move(el *list.Element, segment int) *tinyLFUItem {
+	item := el.Value.(*tinyLFUItem)
+	p.segments[item.segment].Remove(el)
+
+	item.segment = segment
+	p.items[item.key] = p.segments[segment].PushFront(item)
+
+	return item
+}
+
+// cmSketch is a count-min sketch with 4 rows of 4-bit counters
+// stored in bytes. Counters are halved after 10 times of width
+// increments, so frequencies of old keys fade out
+type cmSketch struct {
+	rows      [4][]byte
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+func newCMSketch(capacity int) *cmSketch {
+	// Stores bounded by bytes only have no capacity
+	if capacity <= 0 {
+		capacity = 4096
+	}
+
+	width := 16
+	for width < capacity {
+		width <<= 1
+	}
+
+	s := &cmSketch{mask: uint64(width - 1), resetAt: width * 10}
+	for i := range s.rows {
+		s.rows[i] = make([]byte, width)
+	}
+
+	return s
+}
+
+func (s *cmSketch) increment(key string) {
+	h1, h2 := sketchHash(key)
+	for i := range s.rows {
+		if c := &s.rows[i][(h1+uint64(i)*h2)&s.mask]; *c < 15 {
+			*c++
+		}
+	}
+
+	if s.additions++; s.additions >= s.resetAt {
+		s.reset()
+	}
+}
+
+func (s *cmSketch) estimate(key string) byte {
+	h1, h2 := sketchHash(key)
+
+	min := byte(15)
+	for i := range s.rows {
+		if c := s.rows[i][(h1+uint64(i)*h2)&s.mask]; c < min {
+			min = c
+		}
+	}
+
+	return min
+}
+
+func (s *cmSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
+
+// sketchHash derives two hashes for double hashing,
+// the second one is odd to reach all counters
+func sketchHash(key string) (uint64, uint64) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	sum := h.Sum64()
+
+	return sum, (sum>>32 | sum<<32) | 1
+}
@@ -0,0 +1,229 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Cache_NewEvictionPolicy(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	at.IsType(&lruPolicy{}, newEvictionPolicy("LRU", 0))
+	at.IsType(&lfuPolicy{}, newEvictionPolicy("lfu", 0))
+	at.IsType(&tinyLFUPolicy{}, newEvictionPolicy("tinylfu", 0))
+	at.IsType(&tinyLFUPolicy{}, newEvictionPolicy("w-tinylfu", 0))
+
+	at.Panics(func() {
+		newEvictionPolicy("invalid", 0)
+	})
+}
+
+func Test_Cache_LRU(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	p := newLRU()
+
+	_, ok := p.victim()
+	at.False(ok)
+
+	p.add("k1")
+	p.add("k2")
+	p.add("k3")
+	p.access("k1")
+	p.access("k4")
+
+	assertVictims(at, p, "k2", "k3", "k1")
+}
+
+func Test_Cache_LFU(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	p := newLFU()
+
+	_, ok := p.victim()
+	at.False(ok)
+
+	p.add("k1")
+	p.add("k2")
+	p.add("k3")
+	p.add("k4")
+	p.add("k5")
+
+	p.access("k1")
+	p.access("k1")
+	p.access("k2")
+	p.access("k3")
+	p.access("k6")
+
+	// k3 was used after k2 with the same frequency,
+	// and k5 is the newest
+	assertVictims(at, p, "k4", "k2", "k3", "k1", "k5")
+	at.Equal(0, p.buckets.Len())
+
+	t.Run("aging", func(t *testing.T) {
+		p := newLFU()
+
+		p.add("hot")
+		for i := 0; i < 5; i++ {
+			p.access("hot")
+		}
+
+		var evicted bool
+		for i := 0; i < 20 && !evicted; i++ {
+			p.add(strconv.Itoa(i))
+
+			victim, ok := p.victim()
+			at.True(ok)
+			at.NotEqual(strconv.Itoa(i), victim)
+			p.remove(victim)
+
+			evicted = victim == "hot"
+		}
+
+		// New keys catch up with the old frequency
+		at.True(evicted)
+	})
+}
+
+func Test_Cache_TinyLFU(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	t.Run("empty", func(t *testing.T) {
+		_, ok := newTinyLFU(0).victim()
+		at.False(ok)
+	})
+
+	t.Run("only window", func(t *testing.T) {
+		p := newTinyLFU(10)
+		p.add("k1")
+
+		assertVictims(at, p, "k1")
+	})
+
+	t.Run("admission", func(t *testing.T) {
+		p := newTinyLFU(10)
+
+		p.add("hot")
+		p.access("hot")
+		p.access("hot")
+		p.add("cold")
+		p.add("new")
+
+		// cold is pushed out of the window by new, and can't beat hot
+		victim, ok := p.victim()
+		at.True(ok)
+		at.Equal("cold", victim)
+		p.remove(victim)
+
+		p.access("new")
+		p.access("new")
+		p.access("new")
+		p.add("newer")
+
+		// new is pushed out of the window, and is used more often than hot
+		victim, _ = p.victim()
+		at.Equal("hot", victim)
+		p.remove(victim)
+		at.Equal(probationSegment, p.items["new"].Value.(*tinyLFUItem).segment)
+
+		// Without a candidate the main space goes first
+		assertVictims(at, p, "new", "newer")
+	})
+
+	t.Run("lone candidate", func(t *testing.T) {
+		p := newTinyLFU(1)
+
+		p.add("old")
+		p.access("old")
+		p.add("new")
+
+		// There is nothing to compete with, so the candidate goes
+		assertVictims(at, p, "old", "new")
+	})
+
+	t.Run("promoted candidate", func(t *testing.T) {
+		p := newTinyLFU(10)
+
+		p.add("k1")
+		p.add("k2")
+		p.access("k1")
+
+		assertVictims(at, p, "k1", "k2")
+	})
+
+	t.Run("protected", func(t *testing.T) {
+		p := newTinyLFU(1000)
+
+		for i := 0; i < 200; i++ {
+			p.add(strconv.Itoa(i))
+		}
+
+		for i := 0; i < 200; i++ {
+			p.access(strconv.Itoa(i))
+		}
+
+		at.Equal(2, p.segments[windowSegment].Len())
+		at.True(p.segments[protectedSegment].Len()*5 <= (p.segments[protectedSegment].Len()+p.segments[probationSegment].Len())*4)
+
+		p.access("198")
+		at.Equal("198", p.segments[windowSegment].Front().Value.(*tinyLFUItem).key)
+
+		for i := 0; i < 200; i++ {
+			p.remove(strconv.Itoa(i))
+		}
+		at.Len(p.items, 0)
+	})
+}
+
+func Test_Cache_CMSketch(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s := newCMSketch(0)
+	at.Equal(uint64(4095), s.mask)
+
+	for i := 0; i < 20; i++ {
+		s.increment("k1")
+	}
+	s.increment("k2")
+
+	at.Equal(byte(15), s.estimate("k1"))
+	at.Equal(byte(1), s.estimate("k2"))
+	at.Equal(byte(0), s.estimate("k3"))
+
+	s.reset()
+	at.Equal(byte(7), s.estimate("k1"))
+	at.Equal(byte(0), s.estimate("k2"))
+
+	t.Run("aging", func(t *testing.T) {
+		s := newCMSketch(16)
+		for i := 0; i < 160; i++ {
+			s.increment("k" + strconv.Itoa(i%2))
+		}
+		at.True(s.estimate("k0") < 15)
+		at.Equal(80, s.additions)
+	})
+}
+
+func assertVictims(at *assert.Assertions, p evictionPolicy, keys ...string) {
+	for _, key := range keys {
+		victim, ok := p.victim()
+		at.True(ok)
+		at.Equal(key, victim)
+		p.remove(victim)
+	}
+
+	_, ok := p.victim()
+	at.False(ok)
+}
@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// remember coalesces concurrent misses of the same key in process,
+// so valueFunc is only called by one of them and the others share
+// its result, including its error. The entry is looked up again in
+// the flight since a previous flight may store it just before.
+// The flight runs with a detached ctx, so a canceled caller never
+// fails the others, and each caller only stops waiting on its own
+// ctx. The shared result must not be modified by callers
+func remember(ctx context.Context, g *singleflight.Group, key string, get, compute func(context.Context) ([]byte, error)) ([]byte, error) {
+	flightCtx := detach(ctx)
+
+	ch := g.DoChan(key, func() (interface{}, error) {
+		if b, err := get(flightCtx); err != nil || b != nil {
+			return b, err
+		}
+
+		return compute(flightCtx)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-ch:
+		b, _ := r.Val.([]byte)
+		return b, r.Err
+	}
+}
+
+// detach returns a ctx which keeps the values of ctx but is never
+// canceled, contexts which can't be canceled are returned as is
+func detach(ctx context.Context) context.Context {
+	if ctx.Done() == nil {
+		return ctx
+	}
+
+	return detachedCtx{ctx}
+}
+
+type detachedCtx struct {
+	parent context.Context
+}
+
+func (detachedCtx) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedCtx) Done() <-chan struct{} { return nil }
+
+func (detachedCtx) Err() error { return nil }
+
+func (c detachedCtx) Value(key interface{}) interface{} { return c.parent.Value(key) }
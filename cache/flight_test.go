@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sync/singleflight"
+)
+
+func Test_Cache_Remember_Singleflight(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	var (
+		g       singleflight.Group
+		calls   int32
+		stored  atomic.Value
+		wg      sync.WaitGroup
+		release = make(chan struct{})
+	)
+
+	get := func(context.Context) ([]byte, error) {
+		b, _ := stored.Load().([]byte)
+		return b, nil
+	}
+
+	compute := func(context.Context) ([]byte, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		b := []byte("v")
+		stored.Store(b)
+		return b, nil
+	}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b, err := remember(cb, &g, "k", get, compute)
+			at.Nil(err)
+			at.Equal("v", string(b))
+		}()
+	}
+
+	time.Sleep(time.Millisecond * 20)
+	close(release)
+	wg.Wait()
+
+	at.Equal(int32(1), atomic.LoadInt32(&calls))
+
+	t.Run("stored by previous flight", func(t *testing.T) {
+		b, err := remember(cb, &g, "k", get, func(context.Context) ([]byte, error) {
+			return nil, mockErr
+		})
+		at.Nil(err)
+		at.Equal("v", string(b))
+	})
+}
+
+func Test_Cache_Remember_Canceled(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	var (
+		g       singleflight.Group
+		started = make(chan struct{})
+		release = make(chan struct{})
+		done    = make(chan struct{})
+	)
+
+	ctx, cancel := context.WithCancel(context.WithValue(cb, ctxKey{}, "v"))
+
+	go func() {
+		defer close(done)
+		_, err := remember(ctx, &g, "k", func(context.Context) ([]byte, error) {
+			return nil, nil
+		}, func(ctx context.Context) ([]byte, error) {
+			close(started)
+			<-release
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			return []byte(ctx.Value(ctxKey{}).(string)), nil
+		})
+		at.Equal(context.Canceled, err)
+	}()
+
+	<-started
+
+	waiter := make(chan []byte)
+	go func() {
+		b, err := remember(cb, &g, "k", nil, nil)
+		at.Nil(err)
+		waiter <- b
+	}()
+
+	time.Sleep(time.Millisecond * 20)
+	cancel()
+	<-done
+	close(release)
+
+	select {
+	case b := <-waiter:
+		at.Equal("v", string(b))
+	case <-time.After(time.Second):
+		at.Fail("waiter is not released")
+	}
+}
+
+type ctxKey struct{}
+
+func Test_Cache_Memory_Remember_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s := getMemStorage()
+
+	var (
+		calls int32
+		wg    sync.WaitGroup
+	)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b, err := s.Remember("concurrent", time.Minute, func() ([]byte, error) {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(time.Millisecond * 20)
+				return []byte("v"), nil
+			})
+			at.Nil(err)
+			at.Equal("v", string(b))
+		}()
+	}
+
+	wg.Wait()
+
+	at.Equal(int32(1), atomic.LoadInt32(&calls))
+}
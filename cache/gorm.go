@@ -1,19 +1,25 @@
 package cache
 
 import (
+	"context"
+	"strings"
 	"time"
 
 	"gorm.io/gorm/clause"
 
 	"github.com/go-dawn/dawn/config"
 	"github.com/go-dawn/dawn/db/sql"
+	"golang.org/x/sync/singleflight"
 	"gorm.io/gorm"
 )
 
 type gormStorage struct {
 	db         *gorm.DB
+	tags       *gorm.DB // join table of tags and keys
+	locks      *gorm.DB // locks are kept apart from entries
 	table      string
 	prefix     string
+	flight     singleflight.Group
 	gcInterval time.Duration
 	done       chan struct{}
 }
@@ -31,9 +37,19 @@ func newGorm(c *config.Config) *gormStorage {
 
 func (s *gormStorage) setup() *gormStorage {
 	if s.db != nil {
+		s.tags = s.db.Clauses(clause.OnConflict{DoNothing: true}).
+			Table(s.table + "_tags").
+			Session(&gorm.Session{})
+
+		_ = s.tags.AutoMigrate(&gormTag{})
+
+		s.locks = s.db.Table(s.table + "_locks").Session(&gorm.Session{})
+
+		_ = s.locks.AutoMigrate(&gormLock{})
+
 		s.db = s.db.Clauses(clause.OnConflict{
 			Columns:   []clause.Column{{Name: "key"}},
-			DoUpdates: clause.AssignmentColumns([]string{"value", "expiry"}),
+			DoUpdates: clause.AssignmentColumns([]string{"value", "expiry", "soft_expiry", "delta"}),
 		}).
 			Table(s.table).
 			Session(&gorm.Session{})
@@ -44,9 +60,13 @@ func (s *gormStorage) setup() *gormStorage {
 	return s
 }
 
-func (s *gormStorage) Has(key string) (ok bool, err error) {
+func (s *gormStorage) Has(key string) (bool, error) {
+	return s.HasCtx(context.Background(), key)
+}
+
+func (s *gormStorage) HasCtx(ctx context.Context, key string) (ok bool, err error) {
 	var e gormEntry
-	if err = s.db.Debug().First(&e, "key = ?", s.prefixedKey(key)).Error; err == nil {
+	if err = s.db.WithContext(ctx).First(&e, "key = ?", s.prefixedKey(key)).Error; err == nil {
 		ok = e.valid()
 		return
 	}
@@ -58,19 +78,31 @@ func (s *gormStorage) Has(key string) (ok bool, err error) {
 	return
 }
 
-func (s *gormStorage) Get(key string) (b []byte, err error) {
-	return s.value(key)
+func (s *gormStorage) Get(key string) ([]byte, error) {
+	return s.GetCtx(context.Background(), key)
+}
+
+func (s *gormStorage) GetCtx(ctx context.Context, key string) ([]byte, error) {
+	return s.value(ctx, key)
+}
+
+func (s *gormStorage) GetWithDefault(key string, defaultValue []byte) ([]byte, error) {
+	return s.GetWithDefaultCtx(context.Background(), key, defaultValue)
 }
 
-func (s *gormStorage) GetWithDefault(key string, defaultValue []byte) (b []byte, err error) {
-	if b, err = s.value(key); err == nil && b == nil {
+func (s *gormStorage) GetWithDefaultCtx(ctx context.Context, key string, defaultValue []byte) (b []byte, err error) {
+	if b, err = s.value(ctx, key); err == nil && b == nil {
 		b = defaultValue
 	}
 
 	return
 }
 
-func (s *gormStorage) Many(keys []string) (b [][]byte, err error) {
+func (s *gormStorage) Many(keys []string) ([][]byte, error) {
+	return s.ManyCtx(context.Background(), keys)
+}
+
+func (s *gormStorage) ManyCtx(ctx context.Context, keys []string) (b [][]byte, err error) {
 	l := len(keys)
 	in := make([]string, l)
 	for i := 0; i < l; i++ {
@@ -78,7 +110,7 @@ func (s *gormStorage) Many(keys []string) (b [][]byte, err error) {
 	}
 
 	var entries []gormEntry
-	if err = s.db.Find(&entries, "key in ?", in).Error; err == nil {
+	if err = s.db.WithContext(ctx).Find(&entries, "key in ?", in).Error; err == nil {
 		b = make([][]byte, l)
 		for i := 0; i < l; i++ {
 			for _, e := range entries {
@@ -94,59 +126,279 @@ func (s *gormStorage) Many(keys []string) (b [][]byte, err error) {
 }
 
 func (s *gormStorage) Set(key string, value []byte, ttl time.Duration) error {
-	return s.set(key, value, time.Now().Add(ttl).Unix())
+	return s.SetCtx(context.Background(), key, value, ttl)
+}
+
+func (s *gormStorage) SetCtx(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.set(ctx, key, value, time.Now().Add(ttl).Unix())
+}
+
+func (s *gormStorage) Pull(key string) ([]byte, error) {
+	return s.PullCtx(context.Background(), key)
+}
+
+func (s *gormStorage) PullCtx(ctx context.Context, key string) ([]byte, error) {
+	return s.pull(ctx, key, nil)
+}
+
+func (s *gormStorage) PullWithDefault(key string, defaultValue []byte) ([]byte, error) {
+	return s.PullWithDefaultCtx(context.Background(), key, defaultValue)
+}
+
+func (s *gormStorage) PullWithDefaultCtx(ctx context.Context, key string, defaultValue []byte) ([]byte, error) {
+	return s.pull(ctx, key, defaultValue)
 }
 
-func (s *gormStorage) Pull(key string) (b []byte, err error) {
-	if b, err = s.value(key); err == nil && b != nil {
-		err = s.Delete(key)
+// pull deletes the entry only if it's unchanged since it's read,
+// so concurrent pulls of the same entry only succeed once
+func (s *gormStorage) pull(ctx context.Context, key string, defaultValue []byte) ([]byte, error) {
+	var e gormEntry
+	err := s.db.WithContext(ctx).First(&e, "key = ?", s.prefixedKey(key)).Error
+	if err == gorm.ErrRecordNotFound || err == nil && !e.valid() {
+		return defaultValue, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := s.db.WithContext(ctx).
+		Where("key = ? AND value = ? AND expiry = ?", e.Key, e.Value, e.Expiry).
+		Delete(&gormEntry{})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return defaultValue, nil
 	}
 
-	return
+	return e.Value, nil
 }
 
-func (s *gormStorage) PullWithDefault(key string, defaultValue []byte) (b []byte, err error) {
-	if b, err = s.value(key); err == nil {
-		if b != nil {
-			err = s.Delete(key)
-		} else {
-			b = defaultValue
-		}
+func (s *gormStorage) Forever(key string, value []byte) error {
+	return s.ForeverCtx(context.Background(), key, value)
+}
+
+func (s *gormStorage) ForeverCtx(ctx context.Context, key string, value []byte) error {
+	return s.set(ctx, key, value, 0)
+}
+
+func (s *gormStorage) Remember(key string, ttl time.Duration, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return s.RememberCtx(context.Background(), key, ttl, valueFunc)
+}
+
+func (s *gormStorage) RememberCtx(ctx context.Context, key string, ttl time.Duration, valueFunc func() ([]byte, error)) (b []byte, err error) {
+	if b, err = s.value(ctx, key); err == nil && b == nil {
+		b, err = s.remember(ctx, key, time.Now().Add(ttl).Unix(), valueFunc)
 	}
 
 	return
 }
 
-func (s *gormStorage) Forever(key string, value []byte) error {
-	return s.set(key, value, 0)
+func (s *gormStorage) RememberForever(key string, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return s.RememberForeverCtx(context.Background(), key, valueFunc)
 }
 
-func (s *gormStorage) Remember(key string, ttl time.Duration, valueFunc func() ([]byte, error)) (b []byte, err error) {
-	if b, err = s.value(key); err == nil && b == nil {
-		if b, err = valueFunc(); err == nil {
-			err = s.set(key, b, time.Now().Add(ttl).Unix())
-		}
+func (s *gormStorage) RememberForeverCtx(ctx context.Context, key string, valueFunc func() ([]byte, error)) (b []byte, err error) {
+	if b, err = s.value(ctx, key); err == nil && b == nil {
+		b, err = s.remember(ctx, key, 0, valueFunc)
 	}
 
 	return
 }
 
-func (s *gormStorage) RememberForever(key string, valueFunc func() ([]byte, error)) (b []byte, err error) {
-	if b, err = s.value(key); err == nil && b == nil {
-		if b, err = valueFunc(); err == nil {
-			err = s.set(key, b, 0)
+func (s *gormStorage) RememberSWR(key string, freshTTL, staleTTL time.Duration, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return s.RememberSWRCtx(context.Background(), key, freshTTL, staleTTL, valueFunc)
+}
+
+func (s *gormStorage) RememberSWRCtx(ctx context.Context, key string, freshTTL, staleTTL time.Duration, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return rememberSWR(ctx, s, &s.flight, key, freshTTL, staleTTL, valueFunc)
+}
+
+func (s *gormStorage) RememberXFetch(key string, ttl time.Duration, beta float64, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return s.RememberXFetchCtx(context.Background(), key, ttl, beta, valueFunc)
+}
+
+func (s *gormStorage) RememberXFetchCtx(ctx context.Context, key string, ttl time.Duration, beta float64, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return rememberXFetch(ctx, s, &s.flight, key, ttl, beta, valueFunc)
+}
+
+func (s *gormStorage) Delete(key string) error {
+	return s.DeleteCtx(context.Background(), key)
+}
+
+func (s *gormStorage) DeleteCtx(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Delete(&gormEntry{}, "key = ?", s.prefixedKey(key)).Error
+}
+
+func (s *gormStorage) Increment(key string, delta int64, ttl time.Duration) (int64, error) {
+	return s.IncrementCtx(context.Background(), key, delta, ttl)
+}
+
+// IncrementCtx changes the counter by one atomic update, so
+// concurrent changes are never lost. Missing or expired counters
+// are created and the first of concurrent creators wins, the
+// others update its counter. Zero delta only reads the counter
+func (s *gormStorage) IncrementCtx(ctx context.Context, key string, delta int64, ttl time.Duration) (n int64, err error) {
+	if delta == 0 {
+		var b []byte
+		if b, err = s.GetCtx(ctx, key); err != nil || b == nil {
+			return
+		}
+		return parseCounter(b)
+	}
+
+	key = s.prefixedKey(key)
+
+	for {
+		var updated bool
+		if n, updated, err = s.incr(ctx, key, delta); err != nil || updated {
+			return
+		}
+
+		// The counter is missing, expired or not an integer
+		if err = s.db.WithContext(ctx).
+			Delete(&gormEntry{}, "key = ? AND expiry <> 0 AND expiry < ?", key, time.Now().Unix()).Error; err != nil {
+			return
+		}
+
+		res := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&gormEntry{Key: key, Value: formatCounter(delta), Expiry: ttlExpiry(ttl)})
+		if res.Error != nil || res.RowsAffected != 0 {
+			return delta, res.Error
+		}
+
+		// Another instance may create the counter first
+		var e gormEntry
+		if err = s.db.WithContext(ctx).First(&e, "key = ?", key).Error; err == gorm.ErrRecordNotFound {
+			continue
+		}
+		if err != nil {
+			return
+		}
+
+		if e.valid() {
+			if _, err = parseCounter(e.Value); err != nil {
+				return
+			}
 		}
 	}
+}
+
+// incr adds delta to the valid integer counter in the database
+// and reads the result in the same transaction
+func (s *gormStorage) incr(ctx context.Context, key string, delta int64) (n int64, updated bool, err error) {
+	sum, integer := counterSQL(s.db.Dialector.Name())
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("key = ? AND (expiry = 0 OR expiry >= ?)", key, time.Now().Unix()).
+			Where(integer).
+			Updates(map[string]interface{}{
+				"value":       gorm.Expr(sum, delta),
+				"soft_expiry": 0,
+				"delta":       0,
+			})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+
+		var e gormEntry
+		if err := tx.First(&e, "key = ?", key).Error; err != nil {
+			return err
+		}
+
+		updated = true
+		n, err = parseCounter(e.Value)
+		return err
+	})
 
 	return
 }
 
-func (s *gormStorage) Delete(key string) error {
-	return s.db.Delete(&gormEntry{}, "key = ?", s.prefixedKey(key)).Error
+// counterPattern matches decimal integers
+const counterPattern = "^-{0,1}[0-9]+$"
+
+// counterSQL gets the expression which adds a delta to the counter
+// and keeps it in decimal, and the condition which only matches
+// counters in the dialect
+func counterSQL(dialect string) (sum string, integer clause.Expr) {
+	switch dialect {
+	case "mysql":
+		return "CAST(CAST(value AS SIGNED) + ? AS BINARY)",
+			gorm.Expr("CAST(value AS CHAR) REGEXP ?", counterPattern)
+	case "postgres":
+		return "convert_to((convert_from(value, 'UTF8')::bigint + ?)::text, 'UTF8')",
+			gorm.Expr("convert_from(value, 'UTF8') ~ ?", counterPattern)
+	default:
+		// sqlite has no regexp, values which are kept
+		// after casting to integer are counters
+		return "CAST(CAST(value AS INTEGER) + ? AS BLOB)",
+			gorm.Expr("CAST(CAST(value AS INTEGER) AS BLOB) = value")
+	}
+}
+
+func (s *gormStorage) Decrement(key string, delta int64, ttl time.Duration) (int64, error) {
+	return s.DecrementCtx(context.Background(), key, delta, ttl)
+}
+
+func (s *gormStorage) DecrementCtx(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return s.IncrementCtx(ctx, key, -delta, ttl)
+}
+
+func (s *gormStorage) Add(key string, value []byte, ttl time.Duration) (bool, error) {
+	return s.AddCtx(context.Background(), key, value, ttl)
+}
+
+// AddCtx replaces the row only if it's expired, or inserts
+// it and ignores the conflict of the primary key
+func (s *gormStorage) AddCtx(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	e := gormEntry{Key: s.prefixedKey(key), Value: value, Expiry: ttlExpiry(ttl)}
+
+	res := s.db.WithContext(ctx).
+		Where("key = ? AND expiry <> 0 AND expiry < ?", e.Key, time.Now().Unix()).
+		Updates(map[string]interface{}{
+			"value":       e.Value,
+			"expiry":      e.Expiry,
+			"soft_expiry": 0,
+			"delta":       0,
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		res = s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&e)
+	}
+
+	return res.Error == nil && res.RowsAffected != 0, res.Error
+}
+
+func (s *gormStorage) CompareAndSwap(key string, old, new []byte, ttl time.Duration) (bool, error) {
+	return s.CompareAndSwapCtx(context.Background(), key, old, new, ttl)
+}
+
+func (s *gormStorage) CompareAndSwapCtx(ctx context.Context, key string, old, new []byte, ttl time.Duration) (bool, error) {
+	res := s.db.WithContext(ctx).
+		Where("key = ? AND value = ? AND (expiry = 0 OR expiry >= ?)", s.prefixedKey(key), old, time.Now().Unix()).
+		Updates(map[string]interface{}{
+			"value":       new,
+			"expiry":      ttlExpiry(ttl),
+			"soft_expiry": 0,
+			"delta":       0,
+		})
+
+	return res.Error == nil && res.RowsAffected != 0, res.Error
 }
 
 func (s *gormStorage) Reset() error {
-	return s.db.Delete(&gormEntry{}, "key like ?", s.prefix+"%").Error
+	return s.ResetCtx(context.Background())
+}
+
+func (s *gormStorage) ResetCtx(ctx context.Context) error {
+	return s.db.WithContext(ctx).Delete(&gormEntry{}, "key like ?", s.prefix+"%").Error
+}
+
+func (s *gormStorage) Tags(tags ...string) TaggedCacher {
+	return TaggedCacher{s: s, tags: tags}
 }
 
 func (s *gormStorage) Close() error {
@@ -163,7 +415,10 @@ func (s *gormStorage) gc() {
 		case <-s.done:
 			return
 		case t := <-ticker.C:
-			s.db.Delete(&gormEntry{}, "expiry < ?", t.Unix())
+			s.db.Delete(&gormEntry{}, "expiry <> 0 AND expiry < ?", t.Unix())
+			// Remove links of removed entries
+			s.tags.Where("key NOT IN (?)", s.db.Model(&gormEntry{}).Select("key")).Delete(&gormTag{})
+			s.locks.Delete(&gormLock{}, "expiry < ?", lockTime(t))
 		}
 	}
 }
@@ -172,11 +427,119 @@ func (s *gormStorage) prefixedKey(key string) string {
 	return s.prefix + key
 }
 
-func (s *gormStorage) value(key string) (b []byte, err error) {
+func (s *gormStorage) remember(ctx context.Context, key string, expiry int64, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return remember(ctx, &s.flight, key, func(ctx context.Context) ([]byte, error) {
+		return s.value(ctx, key)
+	}, func(ctx context.Context) (b []byte, err error) {
+		if b, err = valueFunc(); err == nil {
+			err = s.set(ctx, key, b, expiry)
+		}
+		return
+	})
+}
+
+func (s *gormStorage) value(ctx context.Context, key string) (b []byte, err error) {
+	b, _, err = s.getMeta(ctx, key)
+	return
+}
+
+// tag links the key to the tags in the prefix of the storage, so
+// storages sharing the table never flush entries of the others.
+// Links of removed entries are removed by gc
+func (s *gormStorage) tag(ctx context.Context, key string, tags []string, _ time.Duration) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	links := make([]gormTag, len(tags))
+	for i, tag := range tags {
+		links[i] = gormTag{Tag: s.prefixedKey(tag), Key: s.prefixedKey(key)}
+	}
+
+	return s.tags.WithContext(ctx).Create(&links).Error
+}
+
+func (s *gormStorage) flush(ctx context.Context, tags []string) (keys []string, err error) {
+	var (
+		prefixed     []string
+		prefixedTags = make([]string, len(tags))
+	)
+
+	for i, tag := range tags {
+		prefixedTags[i] = s.prefixedKey(tag)
+	}
+
+	if err = s.tags.WithContext(ctx).Model(&gormTag{}).
+		Where("tag IN ?", prefixedTags).
+		Distinct().Pluck("key", &prefixed).Error; err != nil || len(prefixed) == 0 {
+		return
+	}
+
+	if err = s.db.WithContext(ctx).Delete(&gormEntry{}, "key IN ?", prefixed).Error; err != nil {
+		return
+	}
+
+	if err = s.tags.WithContext(ctx).Delete(&gormTag{}, "key IN ?", prefixed).Error; err != nil {
+		return
+	}
+
+	for _, key := range prefixed {
+		keys = append(keys, strings.TrimPrefix(key, s.prefix))
+	}
+
+	return
+}
+
+// acquire takes over the expired lock, or inserts it
+// and ignores the conflict of the primary key
+func (s *gormStorage) acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	l := gormLock{Key: s.prefixedKey(key), Token: token, Expiry: lockTime(now.Add(ttl))}
+
+	res := s.locks.WithContext(ctx).
+		Where("key = ? AND expiry < ?", l.Key, lockTime(now)).
+		Updates(map[string]interface{}{"token": l.Token, "expiry": l.Expiry})
+	if res.Error != nil || res.RowsAffected != 0 {
+		return res.Error == nil, res.Error
+	}
+
+	res = s.locks.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&l)
+
+	return res.Error == nil && res.RowsAffected != 0, res.Error
+}
+
+func (s *gormStorage) release(ctx context.Context, key, token string) (bool, error) {
+	res := s.locks.WithContext(ctx).
+		Delete(&gormLock{}, "key = ? AND token = ? AND expiry >= ?", s.prefixedKey(key), token, lockTime(time.Now()))
+
+	return res.Error == nil && res.RowsAffected != 0, res.Error
+}
+
+// extend resets the expiry of the lock held by the token. Some
+// dialects only report changed rows, so the lock is looked up
+// when the expiry is unchanged
+func (s *gormStorage) extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	query := s.locks.WithContext(ctx).Model(&gormLock{}).
+		Where("key = ? AND token = ? AND expiry >= ?", s.prefixedKey(key), token, lockTime(now))
+
+	res := query.Session(&gorm.Session{}).Update("expiry", lockTime(now.Add(ttl)))
+	if res.Error != nil || res.RowsAffected != 0 {
+		return res.Error == nil, res.Error
+	}
+
+	var matched int64
+	err := query.Session(&gorm.Session{}).Count(&matched).Error
+
+	return matched != 0, err
+}
+
+func (s *gormStorage) getMeta(ctx context.Context, key string) (b []byte, meta entryMeta, err error) {
 	var e gormEntry
-	if err = s.db.First(&e, "key = ?", s.prefixedKey(key)).Error; err == nil {
+	if err = s.db.WithContext(ctx).First(&e, "key = ?", s.prefixedKey(key)).Error; err == nil {
 		if e.valid() {
 			b = e.Value
+			meta = entryMeta{softExpiry: e.SoftExpiry, delta: e.Delta}
 			return
 		}
 	}
@@ -188,8 +551,18 @@ func (s *gormStorage) value(key string) (b []byte, err error) {
 	return
 }
 
-func (s *gormStorage) set(key string, value []byte, expiry int64) error {
-	return s.db.Create(&gormEntry{
+func (s *gormStorage) setMeta(ctx context.Context, key string, value []byte, meta entryMeta, ttl time.Duration) error {
+	return s.db.WithContext(ctx).Create(&gormEntry{
+		Key:        s.prefixedKey(key),
+		Value:      value,
+		Expiry:     time.Now().Add(ttl).Unix(),
+		SoftExpiry: meta.softExpiry,
+		Delta:      meta.delta,
+	}).Error
+}
+
+func (s *gormStorage) set(ctx context.Context, key string, value []byte, expiry int64) error {
+	return s.db.WithContext(ctx).Create(&gormEntry{
 		Key:    s.prefixedKey(key),
 		Value:  value,
 		Expiry: expiry,
@@ -200,6 +573,27 @@ type gormEntry struct {
 	Key    string `gorm:"primarykey"`
 	Value  []byte
 	Expiry int64
+	// SoftExpiry and Delta are metadata of RememberSWR and RememberXFetch
+	SoftExpiry int64
+	Delta      int64
+}
+
+// gormLock expires in milliseconds, so locks
+// never outlive their ttl like entries do
+type gormLock struct {
+	Key    string `gorm:"primarykey"`
+	Token  string
+	Expiry int64
+}
+
+func lockTime(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// gormTag links the key to the tag
+type gormTag struct {
+	Tag string `gorm:"primarykey"`
+	Key string `gorm:"primarykey"`
 }
 
 func (e gormEntry) valid() bool {
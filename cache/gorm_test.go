@@ -1,6 +1,11 @@
 package cache
 
 import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -122,6 +127,29 @@ func Test_Cache_Gorm_Pull(t *testing.T) {
 	at.Nil(err)
 	at.Nil(b2)
 	at.Equal(nil, s.db.First(&e, "key = ?", "k2").Error)
+
+	t.Run("race", func(t *testing.T) {
+		at.Nil(s.Set("race", []byte("v"), time.Minute))
+
+		var (
+			wg     sync.WaitGroup
+			pulled int32
+		)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				b, err := s.Pull("race")
+				at.Nil(err)
+				if b != nil {
+					atomic.AddInt32(&pulled, 1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		at.Equal(int32(1), pulled)
+	})
 }
 
 func Test_Cache_Gorm_PullWithDefault(t *testing.T) {
@@ -207,6 +235,54 @@ func Test_Cache_Gorm_RememberForever(t *testing.T) {
 	at.InDelta(0, e.Expiry, 1)
 }
 
+func Test_Cache_Gorm_RememberSWR(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s := getGormStorage(t)
+
+	b, err := s.RememberSWR("k3", time.Minute, time.Minute, func() ([]byte, error) {
+		return []byte("v3"), nil
+	})
+	at.Nil(err)
+	at.Equal("v3", string(b))
+
+	var e gormEntry
+	at.Nil(s.db.First(&e, "key = ?", "k3").Error)
+	at.Equal("v3", string(e.Value))
+	at.InDelta(time.Now().Add(time.Minute*2).Unix(), e.Expiry, 1)
+	at.InDelta(time.Now().Add(time.Minute).UnixNano(), e.SoftExpiry, float64(time.Second))
+
+	// Plain sets clear metadata
+	at.Nil(s.Set("k3", []byte("v33"), time.Minute))
+
+	b, meta, err := s.getMeta(cb, "k3")
+	at.Nil(err)
+	at.Equal("v33", string(b))
+	at.Equal(entryMeta{}, meta)
+}
+
+func Test_Cache_Gorm_RememberXFetch(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s := getGormStorage(t)
+
+	b, err := s.RememberXFetch("k3", time.Minute, 1, func() ([]byte, error) {
+		return []byte("v3"), nil
+	})
+	at.Nil(err)
+	at.Equal("v3", string(b))
+
+	b, err = s.RememberXFetch("k3", time.Minute, 1, func() ([]byte, error) {
+		return []byte("v33"), nil
+	})
+	at.Nil(err)
+	at.Equal("v3", string(b))
+}
+
 func Test_Cache_Gorm_Delete(t *testing.T) {
 	t.Parallel()
 
@@ -237,6 +313,329 @@ func Test_Cache_Gorm_Reset(t *testing.T) {
 	at.Equal(gorm.ErrRecordNotFound, s.db.First(&e, "key = ?", "k2").Error)
 }
 
+func Test_Cache_Gorm_Increment(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s := getGormStorage(t)
+
+	n, err := s.Increment("counter", 5, time.Minute)
+	at.Nil(err)
+	at.Equal(int64(5), n)
+
+	n, err = s.Decrement("counter", 7, time.Hour)
+	at.Nil(err)
+	at.Equal(int64(-2), n)
+
+	var e gormEntry
+	at.Nil(s.db.First(&e, "key = ?", "counter").Error)
+	at.Equal("-2", string(e.Value))
+	at.InDelta(time.Now().Add(time.Minute).Unix(), e.Expiry, 1)
+
+	// Expired k2 restarts from 0
+	n, err = s.Increment("k2", 1, 0)
+	at.Nil(err)
+	at.Equal(int64(1), n)
+
+	var e2 gormEntry
+	at.Nil(s.db.First(&e2, "key = ?", "k2").Error)
+	at.Equal(int64(0), e2.Expiry)
+
+	_, err = s.Increment("k1", 1, time.Minute)
+	at.Equal(ErrNotInteger, err)
+
+	t.Run("concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := s.Increment("concurrent", 1, time.Minute)
+				at.Nil(err)
+			}()
+		}
+		wg.Wait()
+
+		b, err := s.Get("concurrent")
+		at.Nil(err)
+		at.Equal("20", string(b))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := s.IncrementCtx(ctx, "counter", 1, time.Minute)
+		at.NotNil(err)
+	})
+
+	t.Run("zero delta", func(t *testing.T) {
+		n, err := s.Increment("counter", 0, time.Minute)
+		at.Nil(err)
+		at.Equal(int64(-2), n)
+
+		n, err = s.Increment("missing", 0, time.Minute)
+		at.Nil(err)
+		at.Equal(int64(0), n)
+
+		has, err := s.Has("missing")
+		at.Nil(err)
+		at.False(has)
+
+		_, err = s.Increment("k1", 0, time.Minute)
+		at.Equal(ErrNotInteger, err)
+	})
+}
+
+func Test_Cache_Gorm_Add(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s := getGormStorage(t)
+
+	ok, err := s.Add("k1", []byte("v11"), time.Minute)
+	at.Nil(err)
+	at.False(ok)
+
+	// Expired k2 is replaced
+	ok, err = s.Add("k2", []byte("v22"), time.Minute)
+	at.Nil(err)
+	at.True(ok)
+
+	b, err := s.Get("k2")
+	at.Nil(err)
+	at.Equal("v22", string(b))
+
+	t.Run("no ttl", func(t *testing.T) {
+		ok, err := s.Add("forever", []byte("v"), 0)
+		at.Nil(err)
+		at.True(ok)
+
+		var e gormEntry
+		at.Nil(s.db.First(&e, "key = ?", "forever").Error)
+		at.Equal(int64(0), e.Expiry)
+	})
+
+	t.Run("race", func(t *testing.T) {
+		var (
+			wg    sync.WaitGroup
+			added int32
+		)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ok, err := s.Add("race", []byte("v"), time.Minute)
+				at.Nil(err)
+				if ok {
+					atomic.AddInt32(&added, 1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		at.Equal(int32(1), added)
+	})
+}
+
+func Test_Cache_Gorm_CompareAndSwap(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s := getGormStorage(t)
+
+	// Expired and missing entries are never swapped
+	for _, key := range []string{"k2", "k3"} {
+		ok, err := s.CompareAndSwap(key, []byte("v2"), []byte("v"), time.Minute)
+		at.Nil(err)
+		at.False(ok)
+	}
+
+	ok, err := s.CompareAndSwap("k1", []byte("v2"), []byte("v11"), time.Minute)
+	at.Nil(err)
+	at.False(ok)
+
+	ok, err = s.CompareAndSwap("k1", []byte("v1"), []byte("v11"), time.Minute)
+	at.Nil(err)
+	at.True(ok)
+
+	b, err := s.Get("k1")
+	at.Nil(err)
+	at.Equal("v11", string(b))
+
+	t.Run("no ttl", func(t *testing.T) {
+		at.Nil(s.Set("forever", []byte("v"), time.Minute))
+
+		ok, err := s.CompareAndSwap("forever", []byte("v"), []byte("v1"), 0)
+		at.Nil(err)
+		at.True(ok)
+
+		var e gormEntry
+		at.Nil(s.db.First(&e, "key = ?", "forever").Error)
+		at.Equal(int64(0), e.Expiry)
+	})
+
+	t.Run("race", func(t *testing.T) {
+		var (
+			wg      sync.WaitGroup
+			swapped int32
+		)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				ok, err := s.CompareAndSwap("k1", []byte("v11"), []byte(strconv.Itoa(i)), time.Minute)
+				at.Nil(err)
+				if ok {
+					atomic.AddInt32(&swapped, 1)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		at.Equal(int32(1), swapped)
+	})
+}
+
+func Test_Cache_Gorm_Lock(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s := getGormStorage(t)
+
+	l1 := newLocker("job", time.Minute, s)
+	l2 := newLocker("job", time.Minute, s)
+
+	ok, err := l1.TryAcquire()
+	at.Nil(err)
+	at.True(ok)
+
+	ok, err = l2.TryAcquire()
+	at.Nil(err)
+	at.False(ok)
+
+	at.Equal(ErrLockNotHeld, l2.Extend())
+	at.Equal(ErrLockNotHeld, l2.Release())
+
+	at.Nil(l1.Extend())
+	at.Nil(l1.Release())
+
+	ok, err = l2.TryAcquire()
+	at.Nil(err)
+	at.True(ok)
+
+	// Locks are kept apart from entries
+	has, err := s.Has("lock:job")
+	at.Nil(err)
+	at.False(has)
+	at.Nil(s.Reset())
+	at.True(l2.Held())
+	at.Nil(l2.Extend())
+
+	t.Run("expired", func(t *testing.T) {
+		l1 := newLocker("expired", time.Millisecond*50, s)
+		l2 := newLocker("expired", time.Minute, s)
+
+		ok, err := l1.TryAcquire()
+		at.Nil(err)
+		at.True(ok)
+
+		time.Sleep(time.Millisecond * 60)
+		at.Equal(ErrLockNotHeld, l1.Extend())
+
+		ok, err = l2.TryAcquire()
+		at.Nil(err)
+		at.True(ok)
+
+		at.Equal(ErrLockNotHeld, l1.Release())
+		at.Nil(l2.Release())
+	})
+
+	t.Run("race", func(t *testing.T) {
+		var (
+			wg       sync.WaitGroup
+			acquired int32
+		)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ok, err := newLocker("race", time.Minute, s).TryAcquire()
+				at.Nil(err)
+				if ok {
+					atomic.AddInt32(&acquired, 1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		at.Equal(int32(1), acquired)
+	})
+}
+
+func Test_Cache_Gorm_Tags(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s := getGormStorage(t)
+	s.prefix = "p_"
+
+	at.Nil(s.Tags("t1", "t2").Set("k3", []byte("v3"), time.Minute))
+	at.Nil(s.Tags("t1").Forever("k3", []byte("v3")))
+	at.Nil(s.Tags("t2").Set("k4", []byte("v4"), time.Minute))
+	at.Nil(s.Tags("t3").Set("k5", []byte("v5"), time.Minute))
+
+	keys, err := s.flush(context.Background(), []string{"t1", "t2"})
+	at.Nil(err)
+	at.ElementsMatch([]string{"k3", "k4"}, keys)
+
+	var e gormEntry
+	at.Equal(gorm.ErrRecordNotFound, s.db.First(&e, "key = ?", "p_k3").Error)
+	at.Equal(gorm.ErrRecordNotFound, s.db.First(&e, "key = ?", "p_k4").Error)
+	at.Nil(s.db.First(&e, "key = ?", "p_k5").Error)
+
+	var count int64
+	at.Nil(s.tags.Model(&gormTag{}).Count(&count).Error)
+	at.Equal(int64(1), count)
+
+	keys, err = s.flush(context.Background(), []string{"t1"})
+	at.Nil(err)
+	at.Empty(keys)
+
+	t.Run("prefix", func(t *testing.T) {
+		other := &gormStorage{db: s.db, tags: s.tags, prefix: "o_"}
+		at.Nil(other.Tags("t3").Set("k5", []byte("v5"), time.Minute))
+
+		keys, err := other.flush(context.Background(), []string{"t3"})
+		at.Nil(err)
+		at.Equal([]string{"k5"}, keys)
+
+		// Entries of the storage sharing the table are kept
+		var e gormEntry
+		at.Nil(s.db.First(&e, "key = ?", "p_k5").Error)
+		at.Equal(gorm.ErrRecordNotFound, s.db.First(&e, "key = ?", "o_k5").Error)
+	})
+
+	t.Run("gc", func(t *testing.T) {
+		at.Nil(s.Tags("t4").Set("k6", []byte("v6"), -time.Minute))
+
+		s := &gormStorage{db: s.db, tags: s.tags, locks: s.locks, gcInterval: time.Millisecond * 10, done: make(chan struct{})}
+		go s.gc()
+		defer close(s.done)
+
+		assert.Eventually(t, func() bool {
+			var keys []string
+			s.tags.Model(&gormTag{}).Pluck("key", &keys)
+			return len(keys) == 1 && keys[0] == "p_k5"
+		}, time.Second, time.Millisecond*10)
+	})
+}
+
 func Test_Cache_Gorm_Close(t *testing.T) {
 	t.Parallel()
 
@@ -256,6 +655,10 @@ func Test_Cache_Gorm_GC(t *testing.T) {
 
 	s := getGormStorage(t)
 
+	// Counters without ttl never expire
+	_, err := s.Increment("forever", 1, 0)
+	assert.Nil(t, err)
+
 	go s.gc()
 
 	time.Sleep(time.Millisecond * 15)
@@ -268,6 +671,35 @@ func Test_Cache_Gorm_GC(t *testing.T) {
 		b2 := s.db.First(&e, "key = ?", "k2").Error == gorm.ErrRecordNotFound
 		return b1 && b2
 	}, time.Second, time.Millisecond*10)
+
+	b, err := s.Get("forever")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", string(b))
+}
+
+func Test_Cache_Gorm_Ctx(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s := getGormStorage(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := s.GetCtx(ctx, "k1")
+	at.True(errors.Is(err, context.Canceled))
+
+	at.True(errors.Is(s.SetCtx(ctx, "k3", []byte("v3"), time.Minute), context.Canceled))
+	at.True(errors.Is(s.DeleteCtx(ctx, "k1"), context.Canceled))
+
+	b, err := s.GetCtx(context.Background(), "k1")
+	at.Nil(err)
+	at.Equal("v1", string(b))
+
+	ok, err := s.Has("k3")
+	at.Nil(err)
+	at.False(ok)
 }
 
 func getGormStorage(t *testing.T) *gormStorage {
@@ -0,0 +1,221 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrLockNotHeld is returned when a lock is released or
+// extended but it's expired or held by another owner.
+var ErrLockNotHeld = errors.New("dawn:cache lock not held")
+
+// lockRetry is the interval of Acquire attempts
+const lockRetry = time.Millisecond * 50
+
+// Locker is a distributed lock held by an owner token. Locks on
+// several storages follow Redlock, the lock is held once most of
+// storages are acquired within the ttl.
+type Locker struct {
+	key    string
+	ttl    time.Duration
+	token  string
+	stores []Cacher
+
+	mu sync.Mutex
+	// until is when the lock is considered expired by the owner
+	until time.Time
+}
+
+// Lock gets the lock of the name on the storages, or on the
+// fallback storage. Every lock has its own owner token, so
+// it can only be released by itself. It panics if ttl
+// isn't positive.
+func Lock(name string, ttl time.Duration, storage ...string) *Locker {
+	if len(storage) == 0 {
+		storage = []string{m.fallback}
+	}
+
+	stores := make([]Cacher, len(storage))
+	for i, n := range storage {
+		if stores[i] = Storage(n); stores[i] == nil {
+			panic(fmt.Sprintf("dawn:cache unknown storage %s of lock %s", n, name))
+		}
+	}
+
+	return newLocker(name, ttl, stores...)
+}
+
+func newLocker(name string, ttl time.Duration, stores ...Cacher) *Locker {
+	if ttl <= 0 {
+		panic(fmt.Sprintf("dawn:cache ttl of lock %s must be positive", name))
+	}
+
+	token, err := lockToken()
+	if err != nil {
+		panic(fmt.Sprintf("dawn:cache failed to generate token of lock %s: %s", name, err))
+	}
+
+	return &Locker{
+		key:    "lock:" + name,
+		ttl:    ttl,
+		token:  token,
+		stores: stores,
+	}
+}
+
+// Token gets the owner token of the lock.
+func (l *Locker) Token() string {
+	return l.token
+}
+
+// Held reports whether the lock is held as far as the owner
+// knows, it's false once the ttl less clock drift passes.
+func (l *Locker) Held() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return time.Now().Before(l.until)
+}
+
+// Acquire waits until the lock is acquired or ctx is done.
+func (l *Locker) Acquire(ctx context.Context) error {
+	for {
+		if ok, err := l.TryAcquireCtx(ctx); ok || err != nil {
+			return err
+		}
+
+		// Jitter avoids owners retrying at the same time
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockRetry/2 + time.Duration(rand.Int63n(int64(lockRetry)))):
+		}
+	}
+}
+
+// TryAcquire acquires the lock once and reports whether it's acquired.
+func (l *Locker) TryAcquire() (bool, error) {
+	return l.TryAcquireCtx(context.Background())
+}
+
+// TryAcquireCtx acquires the lock once and reports whether it's acquired.
+func (l *Locker) TryAcquireCtx(ctx context.Context) (bool, error) {
+	// Locks are not reentrant, and the lock held
+	// must not be released by a failed attempt
+	if l.Held() {
+		return false, nil
+	}
+
+	start := time.Now()
+
+	acquired, err := l.each(func(s Cacher) (bool, error) {
+		return s.acquire(ctx, l.key, l.token, l.ttl)
+	})
+
+	if l.valid(start, len(acquired)) {
+		return true, nil
+	}
+
+	// Stores which failed may still be acquired, so the lock is
+	// released on every store. Stores held by other owners are
+	// untouched since the token doesn't match
+	for _, s := range l.stores {
+		_, _ = s.release(context.Background(), l.key, l.token)
+	}
+
+	return false, err
+}
+
+// Release releases the lock, ErrLockNotHeld is returned
+// if the lock is expired or held by another owner.
+func (l *Locker) Release() error {
+	return l.ReleaseCtx(context.Background())
+}
+
+// ReleaseCtx releases the lock, ErrLockNotHeld is returned
+// if the lock is expired or held by another owner.
+func (l *Locker) ReleaseCtx(ctx context.Context) error {
+	l.mu.Lock()
+	l.until = time.Time{}
+	l.mu.Unlock()
+
+	released, err := l.each(func(s Cacher) (bool, error) {
+		return s.release(ctx, l.key, l.token)
+	})
+
+	return l.held(len(released), err)
+}
+
+// Extend resets the ttl of the lock, ErrLockNotHeld is returned
+// if the lock is expired or held by another owner.
+func (l *Locker) Extend() error {
+	return l.ExtendCtx(context.Background())
+}
+
+// ExtendCtx resets the ttl of the lock, ErrLockNotHeld is returned
+// if the lock is expired or held by another owner.
+func (l *Locker) ExtendCtx(ctx context.Context) error {
+	start := time.Now()
+
+	extended, err := l.each(func(s Cacher) (bool, error) {
+		return s.extend(ctx, l.key, l.token, l.ttl)
+	})
+
+	if l.valid(start, len(extended)) {
+		return nil
+	}
+
+	return l.held(0, err)
+}
+
+// each calls f on every store, and returns stores where f
+// succeeds. Errors are tolerated as long as quorum is met
+func (l *Locker) each(f func(s Cacher) (bool, error)) (succeeded []Cacher, err error) {
+	for _, s := range l.stores {
+		ok, e := f(s)
+		if e != nil {
+			err = e
+			continue
+		}
+		if ok {
+			succeeded = append(succeeded, s)
+		}
+	}
+
+	return
+}
+
+// valid reports whether n stores since start hold the lock, it
+// takes clock drift of stores off the ttl like Redlock does
+func (l *Locker) valid(start time.Time, n int) bool {
+	until := start.Add(l.ttl - l.ttl/100 - time.Millisecond*2)
+	if n < l.quorum() || !time.Now().Before(until) {
+		return false
+	}
+
+	l.mu.Lock()
+	l.until = until
+	l.mu.Unlock()
+
+	return true
+}
+
+func (l *Locker) held(n int, err error) error {
+	if n >= l.quorum() {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return ErrLockNotHeld
+}
+
+func (l *Locker) quorum() int {
+	return len(l.stores)/2 + 1
+}
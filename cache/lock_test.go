@@ -0,0 +1,245 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-dawn/dawn/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Cache_Lock(t *testing.T) {
+	m = &Module{
+		fallback: fallback,
+		storage: map[string]Cacher{
+			fallback: newMemory(config.New()),
+			"other":  newMemory(config.New()),
+		},
+	}
+
+	at := assert.New(t)
+
+	l := Lock("job", time.Minute)
+	at.Equal("lock:job", l.key)
+	at.Equal(time.Minute, l.ttl)
+	at.Len(l.token, 32)
+	at.Equal([]Cacher{m.storage[fallback]}, l.stores)
+
+	at.NotEqual(l.Token(), Lock("job", time.Minute).Token())
+
+	l = Lock("job", time.Minute, fallback, "other")
+	at.Len(l.stores, 2)
+
+	at.Panics(func() {
+		Lock("job", time.Minute, "invalid")
+	})
+
+	at.PanicsWithValue("dawn:cache ttl of lock job must be positive", func() {
+		Lock("job", 0)
+	})
+}
+
+func Test_Cache_Locker(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s := newMemory(config.New())
+
+	l1 := newLocker("job", time.Minute, s)
+	l2 := newLocker("job", time.Minute, s)
+
+	ok, err := l1.TryAcquire()
+	at.Nil(err)
+	at.True(ok)
+	at.True(l1.Held())
+
+	// Locks are not reentrant
+	ok, err = l1.TryAcquire()
+	at.Nil(err)
+	at.False(ok)
+	at.True(l1.Held())
+
+	ok, err = l2.TryAcquire()
+	at.Nil(err)
+	at.False(ok)
+	at.False(l2.Held())
+
+	// Only the owner releases and extends the lock
+	at.Equal(ErrLockNotHeld, l2.Release())
+	at.Equal(ErrLockNotHeld, l2.Extend())
+
+	at.Nil(l1.Extend())
+	at.Nil(l1.Release())
+	at.False(l1.Held())
+	at.Equal(ErrLockNotHeld, l1.Release())
+
+	ok, err = l2.TryAcquire()
+	at.Nil(err)
+	at.True(ok)
+
+	t.Run("expired", func(t *testing.T) {
+		s := newMemory(config.New())
+
+		l1 := newLocker("job", time.Millisecond*50, s)
+		l2 := newLocker("job", time.Minute, s)
+
+		ok, err := l1.TryAcquire()
+		at.Nil(err)
+		at.True(ok)
+
+		time.Sleep(time.Millisecond * 60)
+		at.False(l1.Held())
+		at.Equal(ErrLockNotHeld, l1.Extend())
+
+		ok, err = l2.TryAcquire()
+		at.Nil(err)
+		at.True(ok)
+
+		at.Equal(ErrLockNotHeld, l1.Release())
+		at.True(l2.Held())
+	})
+
+	t.Run("error", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		l := newLocker("job", time.Minute, newMemory(config.New()))
+
+		ok, err := l.TryAcquireCtx(ctx)
+		at.Equal(context.Canceled, err)
+		at.False(ok)
+
+		at.Equal(context.Canceled, l.ReleaseCtx(ctx))
+		at.Equal(context.Canceled, l.ExtendCtx(ctx))
+		at.Equal(context.Canceled, l.Acquire(ctx))
+	})
+}
+
+func Test_Cache_Locker_Acquire(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s := newMemory(config.New())
+
+	var (
+		wg      sync.WaitGroup
+		running int32
+		runs    int32
+	)
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			l := newLocker("job", time.Minute, s)
+			at.Nil(l.Acquire(context.Background()))
+
+			at.Equal(int32(1), atomic.AddInt32(&running, 1))
+			time.Sleep(time.Millisecond * 10)
+			atomic.AddInt32(&running, -1)
+			atomic.AddInt32(&runs, 1)
+
+			at.Nil(l.Release())
+		}()
+	}
+	wg.Wait()
+
+	at.Equal(int32(5), runs)
+
+	t.Run("timeout", func(t *testing.T) {
+		ok, err := newLocker("job", time.Minute, s).TryAcquire()
+		at.Nil(err)
+		at.True(ok)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+		defer cancel()
+
+		at.Equal(context.DeadlineExceeded, newLocker("job", time.Minute, s).Acquire(ctx))
+	})
+}
+
+func Test_Cache_Locker_Quorum(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	stores := []Cacher{
+		newMemory(config.New()),
+		newMemory(config.New()),
+		newMemory(config.New()),
+	}
+
+	other := newLocker("job", time.Minute, stores[0])
+	ok, err := other.TryAcquire()
+	at.Nil(err)
+	at.True(ok)
+
+	// Most of stores are acquired
+	l := newLocker("job", time.Minute, stores...)
+	ok, err = l.TryAcquire()
+	at.Nil(err)
+	at.True(ok)
+	at.Nil(l.Extend())
+	at.Nil(l.Release())
+	at.Nil(other.Release())
+
+	// Acquired stores are released without quorum
+	other = newLocker("job", time.Minute, stores[1:]...)
+	ok, err = other.TryAcquire()
+	at.Nil(err)
+	at.True(ok)
+
+	ok, err = l.TryAcquire()
+	at.Nil(err)
+	at.False(ok)
+
+	ok, err = stores[0].acquire(context.Background(), "lock:job", "token", time.Minute)
+	at.Nil(err)
+	at.True(ok)
+
+	t.Run("failed store", func(t *testing.T) {
+		stores := []Cacher{
+			failedAcquire{newMemory(config.New())},
+			newMemory(config.New()),
+			newMemory(config.New()),
+		}
+
+		other := newLocker("job", time.Minute, stores[1:]...)
+		ok, err := other.TryAcquire()
+		at.Nil(err)
+		at.True(ok)
+
+		ok, err = newLocker("job", time.Minute, stores...).TryAcquire()
+		at.Equal(mockErr, err)
+		at.False(ok)
+
+		// The lock which is stored in spite of the error is released
+		ok, err = stores[0].(failedAcquire).Cacher.acquire(context.Background(), "lock:job", "token", time.Minute)
+		at.Nil(err)
+		at.True(ok)
+	})
+
+	t.Run("validity", func(t *testing.T) {
+		l := newLocker("validity", time.Millisecond, stores...)
+		ok, err := l.TryAcquire()
+		at.Nil(err)
+		at.False(ok)
+	})
+}
+
+// failedAcquire reports an error after the lock is acquired,
+// like a store which times out after it's written
+type failedAcquire struct {
+	Cacher
+}
+
+func (s failedAcquire) acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	_, _ = s.Cacher.acquire(ctx, key, token, ttl)
+	return false, mockErr
+}
@@ -1,33 +1,71 @@
 package cache
 
 import (
+	"bytes"
+	"context"
 	"sync"
 	"time"
 
 	"github.com/go-dawn/dawn/config"
+	"golang.org/x/sync/singleflight"
 )
 
 type memEntry struct {
 	data   []byte
 	expiry int64
+	meta   entryMeta
 }
 
+func (e memEntry) valid() bool {
+	return e.expiry == 0 || e.expiry >= time.Now().Unix()
+}
+
+// memLock is a lock held by the token until expiry
+type memLock struct {
+	token  string
+	expiry time.Time
+}
+
+// memStorage never blocks, so contexts are only
+// checked before operations
 type memStorage struct {
-	db         sync.Map
+	db     memStore
+	flight singleflight.Group
+	// locks are kept apart from entries, so they're never evicted
+	locksMu    sync.Mutex
+	locks      map[string]memLock
 	gcInterval time.Duration
 	done       chan struct{}
 }
 
-func newMemory(c *config.Config) memStorage {
-	return memStorage{
+func newMemory(c *config.Config) *memStorage {
+	s := &memStorage{
 		gcInterval: c.GetDuration("GCInterval", time.Second*10),
 		done:       make(chan struct{}),
 	}
+
+	// Entries are only evicted when any limit is set
+	s.db.maxEntries = c.GetInt("maxEntries")
+	s.db.maxBytes = c.GetInt64("maxBytes")
+
+	if s.db.maxEntries > 0 || s.db.maxBytes > 0 {
+		s.db.policy = newEvictionPolicy(c.GetString("eviction", "lru"), s.db.maxEntries)
+	}
+
+	return s
 }
 
-func (s memStorage) Has(key string) (bool, error) {
+func (s *memStorage) Has(key string) (bool, error) {
+	return s.HasCtx(context.Background(), key)
+}
+
+func (s *memStorage) HasCtx(ctx context.Context, key string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
 	if v, ok := s.db.Load(key); ok {
-		if i := v.(memEntry); i.expiry >= time.Now().Unix() {
+		if v.expiry >= time.Now().Unix() {
 			return true, nil
 		}
 		// Delete expired entry
@@ -36,11 +74,27 @@ func (s memStorage) Has(key string) (bool, error) {
 	return false, nil
 }
 
-func (s memStorage) Get(key string) ([]byte, error) {
+func (s *memStorage) Get(key string) ([]byte, error) {
+	return s.GetCtx(context.Background(), key)
+}
+
+func (s *memStorage) GetCtx(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	return s.value(key), nil
 }
 
-func (s memStorage) GetWithDefault(key string, defaultValue []byte) ([]byte, error) {
+func (s *memStorage) GetWithDefault(key string, defaultValue []byte) ([]byte, error) {
+	return s.GetWithDefaultCtx(context.Background(), key, defaultValue)
+}
+
+func (s *memStorage) GetWithDefaultCtx(ctx context.Context, key string, defaultValue []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	v := s.value(key)
 
 	if v == nil {
@@ -50,7 +104,15 @@ func (s memStorage) GetWithDefault(key string, defaultValue []byte) ([]byte, err
 	return v, nil
 }
 
-func (s memStorage) Many(keys []string) (values [][]byte, err error) {
+func (s *memStorage) Many(keys []string) ([][]byte, error) {
+	return s.ManyCtx(context.Background(), keys)
+}
+
+func (s *memStorage) ManyCtx(ctx context.Context, keys []string) (values [][]byte, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
 	for _, key := range keys {
 		values = append(values, s.value(key))
 	}
@@ -58,71 +120,228 @@ func (s memStorage) Many(keys []string) (values [][]byte, err error) {
 	return
 }
 
-func (s memStorage) Set(key string, value []byte, ttl time.Duration) error {
+func (s *memStorage) Set(key string, value []byte, ttl time.Duration) error {
+	return s.SetCtx(context.Background(), key, value, ttl)
+}
+
+func (s *memStorage) SetCtx(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	s.db.Store(key, memEntry{data: value, expiry: time.Now().Add(ttl).Unix()})
 	return nil
 }
 
-func (s memStorage) Pull(key string) ([]byte, error) {
-	v := s.value(key)
-	if v != nil {
-		s.db.Delete(key)
+func (s *memStorage) Pull(key string) ([]byte, error) {
+	return s.PullCtx(context.Background(), key)
+}
+
+func (s *memStorage) PullCtx(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-	return v, nil
+
+	return s.take(key), nil
 }
 
-func (s memStorage) PullWithDefault(key string, defaultValue []byte) ([]byte, error) {
-	v := s.value(key)
-	if v != nil {
-		s.db.Delete(key)
-	} else {
+func (s *memStorage) PullWithDefault(key string, defaultValue []byte) ([]byte, error) {
+	return s.PullWithDefaultCtx(context.Background(), key, defaultValue)
+}
+
+func (s *memStorage) PullWithDefaultCtx(ctx context.Context, key string, defaultValue []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	v := s.take(key)
+	if v == nil {
 		v = defaultValue
 	}
 	return v, nil
 }
 
-func (s memStorage) Forever(key string, value []byte) error {
+func (s *memStorage) Forever(key string, value []byte) error {
+	return s.ForeverCtx(context.Background(), key, value)
+}
+
+func (s *memStorage) ForeverCtx(ctx context.Context, key string, value []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	s.db.Store(key, memEntry{data: value, expiry: 0})
 	return nil
 }
 
-func (s memStorage) Remember(key string, ttl time.Duration, valueFunc func() ([]byte, error)) (v []byte, err error) {
+func (s *memStorage) Remember(key string, ttl time.Duration, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return s.RememberCtx(context.Background(), key, ttl, valueFunc)
+}
+
+func (s *memStorage) RememberCtx(ctx context.Context, key string, ttl time.Duration, valueFunc func() ([]byte, error)) (v []byte, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
 	if v = s.value(key); v == nil {
-		if v, err = valueFunc(); err == nil {
-			s.db.Store(key, memEntry{data: v, expiry: time.Now().Add(ttl).Unix()})
-		}
+		v, err = s.remember(ctx, key, time.Now().Add(ttl).Unix(), valueFunc)
 	}
 	return
 }
 
-func (s memStorage) RememberForever(key string, valueFunc func() ([]byte, error)) (v []byte, err error) {
+func (s *memStorage) RememberForever(key string, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return s.RememberForeverCtx(context.Background(), key, valueFunc)
+}
+
+func (s *memStorage) RememberForeverCtx(ctx context.Context, key string, valueFunc func() ([]byte, error)) (v []byte, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
 	if v = s.value(key); v == nil {
-		if v, err = valueFunc(); err == nil {
-			s.db.Store(key, memEntry{data: v, expiry: 0})
-		}
+		v, err = s.remember(ctx, key, 0, valueFunc)
 	}
 	return
 }
 
-func (s memStorage) Delete(key string) error {
+func (s *memStorage) RememberSWR(key string, freshTTL, staleTTL time.Duration, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return s.RememberSWRCtx(context.Background(), key, freshTTL, staleTTL, valueFunc)
+}
+
+func (s *memStorage) RememberSWRCtx(ctx context.Context, key string, freshTTL, staleTTL time.Duration, valueFunc func() ([]byte, error)) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return rememberSWR(ctx, s, &s.flight, key, freshTTL, staleTTL, valueFunc)
+}
+
+func (s *memStorage) RememberXFetch(key string, ttl time.Duration, beta float64, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return s.RememberXFetchCtx(context.Background(), key, ttl, beta, valueFunc)
+}
+
+func (s *memStorage) RememberXFetchCtx(ctx context.Context, key string, ttl time.Duration, beta float64, valueFunc func() ([]byte, error)) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return rememberXFetch(ctx, s, &s.flight, key, ttl, beta, valueFunc)
+}
+
+func (s *memStorage) Delete(key string) error {
+	return s.DeleteCtx(context.Background(), key)
+}
+
+func (s *memStorage) DeleteCtx(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	s.db.Delete(key)
 	return nil
 }
 
-func (s memStorage) Reset() error {
-	s.db.Range(func(key, _ interface{}) bool {
+func (s *memStorage) Increment(key string, delta int64, ttl time.Duration) (int64, error) {
+	return s.IncrementCtx(context.Background(), key, delta, ttl)
+}
+
+func (s *memStorage) IncrementCtx(ctx context.Context, key string, delta int64, ttl time.Duration) (n int64, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	s.db.Update(key, func(e memEntry, ok bool) (memEntry, bool) {
+		if !ok || !e.valid() {
+			e = memEntry{expiry: ttlExpiry(ttl)}
+		} else if n, err = parseCounter(e.data); err != nil {
+			return e, false
+		}
+
+		n += delta
+		e.data, e.meta = formatCounter(n), entryMeta{}
+		return e, true
+	})
+
+	return
+}
+
+func (s *memStorage) Decrement(key string, delta int64, ttl time.Duration) (int64, error) {
+	return s.DecrementCtx(context.Background(), key, delta, ttl)
+}
+
+func (s *memStorage) DecrementCtx(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return s.IncrementCtx(ctx, key, -delta, ttl)
+}
+
+func (s *memStorage) Add(key string, value []byte, ttl time.Duration) (bool, error) {
+	return s.AddCtx(context.Background(), key, value, ttl)
+}
+
+func (s *memStorage) AddCtx(ctx context.Context, key string, value []byte, ttl time.Duration) (added bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	s.db.Update(key, func(e memEntry, ok bool) (memEntry, bool) {
+		if added = !ok || !e.valid(); added {
+			e = memEntry{data: value, expiry: ttlExpiry(ttl)}
+		}
+		return e, added
+	})
+
+	return
+}
+
+func (s *memStorage) CompareAndSwap(key string, old, new []byte, ttl time.Duration) (bool, error) {
+	return s.CompareAndSwapCtx(context.Background(), key, old, new, ttl)
+}
+
+func (s *memStorage) CompareAndSwapCtx(ctx context.Context, key string, old, new []byte, ttl time.Duration) (swapped bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	s.db.Update(key, func(e memEntry, ok bool) (memEntry, bool) {
+		if swapped = ok && e.valid() && bytes.Equal(e.data, old); swapped {
+			e = memEntry{data: new, expiry: ttlExpiry(ttl)}
+		}
+		return e, swapped
+	})
+
+	return
+}
+
+func (s *memStorage) Reset() error {
+	return s.ResetCtx(context.Background())
+}
+
+func (s *memStorage) ResetCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.db.Range(func(key string, _ memEntry) bool {
 		s.db.Delete(key)
 		return true
 	})
 	return nil
 }
 
-func (s memStorage) Close() error {
+func (s *memStorage) Tags(tags ...string) TaggedCacher {
+	return TaggedCacher{s: s, tags: tags}
+}
+
+// Stats gets entries, bytes and evictions of the storage
+func (s *memStorage) Stats() Stats {
+	return s.db.Stats()
+}
+
+func (s *memStorage) Close() error {
 	close(s.done)
 	return nil
 }
 
-func (s memStorage) gc() {
+func (s *memStorage) gc() {
 	ticker := time.NewTicker(s.gcInterval)
 	defer ticker.Stop()
 
@@ -132,23 +351,136 @@ func (s memStorage) gc() {
 			return
 		case t := <-ticker.C:
 			now := t.Unix()
-			s.db.Range(func(key, value interface{}) bool {
-				if e := value.(memEntry); e.expiry != 0 && e.expiry < now {
+			s.db.Range(func(key string, e memEntry) bool {
+				if e.expiry != 0 && e.expiry < now {
 					s.db.Delete(key)
 				}
 				return true
 			})
+
+			s.locksMu.Lock()
+			for key, l := range s.locks {
+				if !t.Before(l.expiry) {
+					delete(s.locks, key)
+				}
+			}
+			s.locksMu.Unlock()
 		}
 	}
 }
 
-func (s memStorage) value(key string) []byte {
-	if v, ok := s.db.Load(key); ok {
-		if e := v.(memEntry); e.expiry == 0 || e.expiry >= time.Now().Unix() {
-			return e.data
+func (s *memStorage) remember(ctx context.Context, key string, expiry int64, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return remember(ctx, &s.flight, key, func(context.Context) ([]byte, error) {
+		return s.value(key), nil
+	}, func(context.Context) (v []byte, err error) {
+		if v, err = valueFunc(); err == nil {
+			s.db.Store(key, memEntry{data: v, expiry: expiry})
+		}
+		return
+	})
+}
+
+func (s *memStorage) value(key string) []byte {
+	return s.entry(key).data
+}
+
+// take deletes the key and returns its value if it's valid,
+// concurrent takes of the same entry only succeed once
+func (s *memStorage) take(key string) []byte {
+	if e, ok := s.db.LoadAndDelete(key); ok && e.valid() {
+		return e.data
+	}
+	return nil
+}
+
+func (s *memStorage) entry(key string) memEntry {
+	if e, ok := s.db.Load(key); ok {
+		if e.valid() {
+			return e
 		}
 		// Delete expired entry
 		s.db.Delete(key)
 	}
+	return memEntry{}
+}
+
+func (s *memStorage) tag(ctx context.Context, key string, tags []string, _ time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.db.Tag(key, tags)
+	return nil
+}
+
+func (s *memStorage) flush(ctx context.Context, tags []string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.db.Flush(tags), nil
+}
+
+func (s *memStorage) acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	s.locksMu.Lock()
+	defer s.locksMu.Unlock()
+
+	if l, ok := s.locks[key]; ok && time.Now().Before(l.expiry) {
+		return false, nil
+	}
+
+	if s.locks == nil {
+		s.locks = make(map[string]memLock)
+	}
+
+	s.locks[key] = memLock{token: token, expiry: time.Now().Add(ttl)}
+	return true, nil
+}
+
+func (s *memStorage) release(ctx context.Context, key, token string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	s.locksMu.Lock()
+	defer s.locksMu.Unlock()
+
+	l, ok := s.locks[key]
+	if !ok || l.token != token {
+		return false, nil
+	}
+
+	delete(s.locks, key)
+	return time.Now().Before(l.expiry), nil
+}
+
+func (s *memStorage) extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	s.locksMu.Lock()
+	defer s.locksMu.Unlock()
+
+	l, ok := s.locks[key]
+	if !ok || l.token != token || !time.Now().Before(l.expiry) {
+		return false, nil
+	}
+
+	s.locks[key] = memLock{token: token, expiry: time.Now().Add(ttl)}
+	return true, nil
+}
+
+func (s *memStorage) getMeta(_ context.Context, key string) ([]byte, entryMeta, error) {
+	e := s.entry(key)
+	return e.data, e.meta, nil
+}
+
+func (s *memStorage) setMeta(_ context.Context, key string, value []byte, meta entryMeta, ttl time.Duration) error {
+	s.db.Store(key, memEntry{data: value, expiry: time.Now().Add(ttl).Unix(), meta: meta})
 	return nil
 }
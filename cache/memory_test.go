@@ -1,6 +1,10 @@
 package cache
 
 import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -15,6 +19,38 @@ func Test_Cache_Memory_New(t *testing.T) {
 
 	at.Equal(time.Second*10, s.gcInterval)
 	at.NotNil(s.done)
+	at.Nil(s.db.policy)
+
+	c := config.New()
+	c.Set("maxEntries", 100)
+	c.Set("maxBytes", 1024)
+	c.Set("eviction", "tinylfu")
+
+	s = newMemory(c)
+	at.Equal(100, s.db.maxEntries)
+	at.Equal(int64(1024), s.db.maxBytes)
+	at.IsType(&tinyLFUPolicy{}, s.db.policy)
+
+	c.Set("eviction", "")
+	at.Panics(func() {
+		newMemory(c)
+	})
+}
+
+func Test_Cache_Memory_Stats(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	c := config.New()
+	c.Set("maxEntries", 1)
+
+	var s StatsCacher = newMemory(c)
+
+	at.Nil(s.(Cacher).Set("k1", []byte("v1"), time.Minute))
+	at.Nil(s.(Cacher).Set("k2", []byte("v2"), time.Minute))
+
+	at.Equal(Stats{Entries: 1, Bytes: 4 + memEntryOverhead, Evictions: 1}, s.Stats())
 }
 
 func Test_Cache_Memory_Has(t *testing.T) {
@@ -90,8 +126,8 @@ func Test_Cache_Memory_Set(t *testing.T) {
 
 	v, ok := s.db.Load("k1")
 	at.True(ok)
-	at.Equal("v11", string(v.(memEntry).data))
-	at.InDelta(time.Now().Unix(), v.(memEntry).expiry, 1)
+	at.Equal("v11", string(v.data))
+	at.InDelta(time.Now().Unix(), v.expiry, 1)
 }
 
 func Test_Cache_Memory_Pull(t *testing.T) {
@@ -106,6 +142,29 @@ func Test_Cache_Memory_Pull(t *testing.T) {
 
 	_, ok := s.db.Load("k2")
 	at.False(ok)
+
+	t.Run("race", func(t *testing.T) {
+		at.Nil(s.Set("race", []byte("v"), time.Minute))
+
+		var (
+			wg     sync.WaitGroup
+			pulled int32
+		)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				b, err := s.Pull("race")
+				at.Nil(err)
+				if b != nil {
+					atomic.AddInt32(&pulled, 1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		at.Equal(int32(1), pulled)
+	})
 }
 
 func Test_Cache_Memory_PullWithDefault(t *testing.T) {
@@ -140,8 +199,8 @@ func Test_Cache_Memory_Forever(t *testing.T) {
 
 	v, ok := s.db.Load("k1")
 	at.True(ok)
-	at.Equal("v11", string(v.(memEntry).data))
-	at.Equal(int64(0), v.(memEntry).expiry)
+	at.Equal("v11", string(v.data))
+	at.Equal(int64(0), v.expiry)
 }
 
 func Test_Cache_Memory_Remember(t *testing.T) {
@@ -158,8 +217,8 @@ func Test_Cache_Memory_Remember(t *testing.T) {
 
 	v, ok := s.db.Load("k1")
 	at.True(ok)
-	at.Equal("v11", string(v.(memEntry).data))
-	at.InDelta(time.Now().Unix(), v.(memEntry).expiry, 1)
+	at.Equal("v11", string(v.data))
+	at.InDelta(time.Now().Unix(), v.expiry, 1)
 
 	b2, err := s.Remember("k2", time.Second, func() (bytes []byte, err error) {
 		return []byte("v22"), nil
@@ -182,8 +241,8 @@ func Test_Cache_Memory_RememberForever(t *testing.T) {
 
 	v, ok := s.db.Load("k1")
 	at.True(ok)
-	at.Equal("v11", string(v.(memEntry).data))
-	at.Equal(int64(0), v.(memEntry).expiry)
+	at.Equal("v11", string(v.data))
+	at.Equal(int64(0), v.expiry)
 
 	b2, err := s.RememberForever("k2", func() (bytes []byte, err error) {
 		return []byte("v22"), nil
@@ -205,6 +264,170 @@ func Test_Cache_Memory_Delete(t *testing.T) {
 	at.False(ok)
 }
 
+func Test_Cache_Memory_Increment(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s := getMemStorage()
+
+	// Expired k1 restarts from 0
+	n, err := s.Increment("k1", 5, time.Minute)
+	at.Nil(err)
+	at.Equal(int64(5), n)
+
+	n, err = s.Decrement("k1", 2, time.Hour)
+	at.Nil(err)
+	at.Equal(int64(3), n)
+
+	e, _ := s.db.Load("k1")
+	at.Equal("3", string(e.data))
+	at.InDelta(time.Now().Add(time.Minute).Unix(), e.expiry, 1)
+
+	n, err = s.Increment("forever", 1, 0)
+	at.Nil(err)
+	at.Equal(int64(1), n)
+
+	e, _ = s.db.Load("forever")
+	at.Equal(int64(0), e.expiry)
+
+	_, err = s.Increment("k2", 1, time.Minute)
+	at.Equal(ErrNotInteger, err)
+
+	b, err := s.Get("k2")
+	at.Nil(err)
+	at.Equal("v2", string(b))
+
+	t.Run("concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _ = s.Increment("concurrent", 1, time.Minute)
+			}()
+		}
+		wg.Wait()
+
+		b, err := s.Get("concurrent")
+		at.Nil(err)
+		at.Equal("100", string(b))
+	})
+}
+
+func Test_Cache_Memory_Add(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s := getMemStorage()
+
+	// Expired k1 is replaced
+	ok, err := s.Add("k1", []byte("v11"), time.Minute)
+	at.Nil(err)
+	at.True(ok)
+
+	ok, err = s.Add("k2", []byte("v22"), time.Minute)
+	at.Nil(err)
+	at.False(ok)
+
+	b, err := s.Get("k2")
+	at.Nil(err)
+	at.Equal("v2", string(b))
+
+	t.Run("no ttl", func(t *testing.T) {
+		ok, err := s.Add("forever", []byte("v"), 0)
+		at.Nil(err)
+		at.True(ok)
+		at.Equal(int64(0), s.entry("forever").expiry)
+	})
+
+	t.Run("race", func(t *testing.T) {
+		var (
+			wg    sync.WaitGroup
+			added int32
+		)
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if ok, _ := s.Add("race", []byte("v"), time.Minute); ok {
+					atomic.AddInt32(&added, 1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		at.Equal(int32(1), added)
+	})
+}
+
+func Test_Cache_Memory_CompareAndSwap(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s := getMemStorage()
+
+	// Expired and missing entries are never swapped
+	for _, key := range []string{"k1", "k3"} {
+		ok, err := s.CompareAndSwap(key, nil, []byte("v"), time.Minute)
+		at.Nil(err)
+		at.False(ok)
+	}
+
+	ok, err := s.CompareAndSwap("k2", []byte("v1"), []byte("v22"), time.Minute)
+	at.Nil(err)
+	at.False(ok)
+
+	ok, err = s.CompareAndSwap("k2", []byte("v2"), []byte("v22"), time.Minute)
+	at.Nil(err)
+	at.True(ok)
+
+	b, err := s.Get("k2")
+	at.Nil(err)
+	at.Equal("v22", string(b))
+
+	t.Run("no ttl", func(t *testing.T) {
+		at.Nil(s.Set("forever", []byte("v"), time.Minute))
+
+		ok, err := s.CompareAndSwap("forever", []byte("v"), []byte("v1"), 0)
+		at.Nil(err)
+		at.True(ok)
+		at.Equal(int64(0), s.entry("forever").expiry)
+	})
+
+	t.Run("race", func(t *testing.T) {
+		var (
+			wg      sync.WaitGroup
+			swapped int32
+		)
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if ok, _ := s.CompareAndSwap("k2", []byte("v22"), []byte(strconv.Itoa(i)), time.Minute); ok {
+					atomic.AddInt32(&swapped, 1)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		at.Equal(int32(1), swapped)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := s.AddCtx(ctx, "k", []byte("v"), time.Minute)
+		at.Equal(context.Canceled, err)
+
+		_, err = s.CompareAndSwapCtx(ctx, "k", nil, []byte("v"), time.Minute)
+		at.Equal(context.Canceled, err)
+	})
+}
+
 func Test_Cache_Memory_Reset(t *testing.T) {
 	t.Parallel()
 
@@ -239,12 +462,21 @@ func Test_Cache_Memory_GC(t *testing.T) {
 
 	s := getMemStorage()
 
+	_, _ = s.acquire(context.Background(), "l1", "token", -time.Minute)
+	_, _ = s.acquire(context.Background(), "l2", "token", time.Minute)
+
 	go s.gc()
 
 	assert.Eventually(t, func() bool {
 		_, b1 := s.db.Load("k1")
 		_, b2 := s.db.Load("k2")
-		return !b1 && b2
+
+		s.locksMu.Lock()
+		defer s.locksMu.Unlock()
+		_, l1 := s.locks["l1"]
+		_, l2 := s.locks["l2"]
+
+		return !b1 && b2 && !l1 && l2
 	}, time.Second, time.Millisecond*10)
 
 	close(s.done)
@@ -263,14 +495,66 @@ func Benchmark_Cache_Memory_Get(b *testing.B) {
 	})
 }
 
-func getMemStorage() memStorage {
-	s := memStorage{
+func Test_Cache_Memory_Ctx(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s := getMemStorage()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := s.HasCtx(ctx, "k1")
+	at.Equal(context.Canceled, err)
+
+	_, err = s.GetCtx(ctx, "k1")
+	at.Equal(context.Canceled, err)
+
+	_, err = s.GetWithDefaultCtx(ctx, "k1", nil)
+	at.Equal(context.Canceled, err)
+
+	_, err = s.ManyCtx(ctx, []string{"k1"})
+	at.Equal(context.Canceled, err)
+
+	at.Equal(context.Canceled, s.SetCtx(ctx, "k1", []byte("v"), time.Minute))
+
+	_, err = s.PullCtx(ctx, "k1")
+	at.Equal(context.Canceled, err)
+
+	_, err = s.PullWithDefaultCtx(ctx, "k1", nil)
+	at.Equal(context.Canceled, err)
+
+	at.Equal(context.Canceled, s.ForeverCtx(ctx, "k1", []byte("v")))
+
+	_, err = s.RememberCtx(ctx, "k3", time.Minute, func() ([]byte, error) {
+		return []byte("v3"), nil
+	})
+	at.Equal(context.Canceled, err)
+
+	_, err = s.RememberForeverCtx(ctx, "k3", func() ([]byte, error) {
+		return []byte("v3"), nil
+	})
+	at.Equal(context.Canceled, err)
+
+	at.Equal(context.Canceled, s.DeleteCtx(ctx, "k1"))
+	at.Equal(context.Canceled, s.ResetCtx(ctx))
+
+	// Nothing is changed
+	b, err := s.Get("k2")
+	at.Nil(err)
+	at.Equal("v2", string(b))
+	at.Nil(s.value("k3"))
+}
+
+func getMemStorage() *memStorage {
+	s := &memStorage{
 		gcInterval: time.Millisecond * 10,
 		done:       make(chan struct{}),
 	}
 
-	s.db.Store("k1", memEntry{[]byte("v1"), time.Now().Add(-time.Minute).Unix()})
-	s.db.Store("k2", memEntry{[]byte("v2"), time.Now().Add(time.Minute).Unix()})
+	s.db.Store("k1", memEntry{data: []byte("v1"), expiry: time.Now().Add(-time.Minute).Unix()})
+	s.db.Store("k2", memEntry{data: []byte("v2"), expiry: time.Now().Add(time.Minute).Unix()})
 
 	return s
 }
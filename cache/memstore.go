@@ -0,0 +1,236 @@
+package cache
+
+import "sync"
+
+// memEntryOverhead approximates bytes used by map
+// and eviction bookkeeping of every entry
+const memEntryOverhead = 64
+
+// Stats are counters of storages.
+type Stats struct {
+	// Entries is the number of entries including expired
+	// ones which are not collected yet
+	Entries int
+	// Bytes is the estimated size of entries
+	Bytes int64
+	// Evictions is the number of entries evicted
+	// to stay under MaxEntries and MaxBytes
+	Evictions uint64
+}
+
+// StatsCacher is implemented by storages which report stats.
+type StatsCacher interface {
+	Stats() Stats
+}
+
+// memStore is a map bounded by entries and bytes, entries are
+// evicted by the policy once a limit is exceeded. Zero limits
+// mean no limit, and the zero value is an unbounded store
+type memStore struct {
+	mu      sync.RWMutex
+	entries map[string]memEntry
+	policy  evictionPolicy
+	// tags and keyTags index keys linked to tags both ways
+	tags       map[string]map[string]struct{}
+	keyTags    map[string]map[string]struct{}
+	maxEntries int
+	maxBytes   int64
+	bytes      int64
+	evictions  uint64
+}
+
+// Load only takes the read lock without a policy,
+// since there are no accesses to record
+func (s *memStore) Load(key string) (e memEntry, ok bool) {
+	if s.policy == nil {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		e, ok = s.entries[key]
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok = s.entries[key]; ok && s.policy != nil {
+		s.policy.access(key)
+	}
+
+	return
+}
+
+func (s *memStore) Store(key string, e memEntry) {
+	s.Update(key, func(memEntry, bool) (memEntry, bool) {
+		return e, true
+	})
+}
+
+// Update replaces the entry with the result of f under the lock,
+// ok reports whether the key exists. Nothing is stored if f
+// returns false
+func (s *memStore) Update(key string, f func(e memEntry, ok bool) (memEntry, bool)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.entries[key]
+
+	e, store := f(old, ok)
+	if !store {
+		return
+	}
+
+	if s.entries == nil {
+		s.entries = make(map[string]memEntry)
+	}
+
+	if ok {
+		s.bytes -= entrySize(key, old)
+		if s.policy != nil {
+			s.policy.access(key)
+		}
+	} else if s.policy != nil {
+		s.policy.add(key)
+	}
+
+	s.entries[key] = e
+	s.bytes += entrySize(key, e)
+
+	s.evict()
+}
+
+// Tag links the key to the tags if it exists
+func (s *memStore) Tag(key string, tags []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[key]; !ok {
+		return
+	}
+
+	if s.tags == nil {
+		s.tags = make(map[string]map[string]struct{})
+		s.keyTags = make(map[string]map[string]struct{})
+	}
+
+	for _, tag := range tags {
+		link(s.tags, tag, key)
+		link(s.keyTags, key, tag)
+	}
+}
+
+// Flush deletes entries linked to any of the tags
+func (s *memStore) Flush(tags []string) (keys []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tag := range tags {
+		for key := range s.tags[tag] {
+			keys = append(keys, key)
+			s.delete(key)
+		}
+	}
+
+	return
+}
+
+// LoadAndDelete deletes the key and returns its entry if
+// any, so only one caller can take the entry
+func (s *memStore) LoadAndDelete(key string) (e memEntry, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok = s.entries[key]; ok {
+		s.delete(key)
+	}
+
+	return
+}
+
+func (s *memStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.delete(key)
+}
+
+// Range calls f for a snapshot of entries, so f can modify the store
+func (s *memStore) Range(f func(key string, e memEntry) bool) {
+	s.mu.RLock()
+	entries := make(map[string]memEntry, len(s.entries))
+	for k, e := range s.entries {
+		entries[k] = e
+	}
+	s.mu.RUnlock()
+
+	for k, e := range entries {
+		if !f(k, e) {
+			return
+		}
+	}
+}
+
+func (s *memStore) Stats() Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return Stats{
+		Entries:   len(s.entries),
+		Bytes:     s.bytes,
+		Evictions: s.evictions,
+	}
+}
+
+func (s *memStore) delete(key string) {
+	if e, ok := s.entries[key]; ok {
+		delete(s.entries, key)
+		s.bytes -= entrySize(key, e)
+		for tag := range s.keyTags[key] {
+			unlink(s.tags, tag, key)
+		}
+		delete(s.keyTags, key)
+		if s.policy != nil {
+			s.policy.remove(key)
+		}
+	}
+}
+
+// evict removes victims of the policy until limits are met,
+// the stored entry itself is evicted if it exceeds MaxBytes
+func (s *memStore) evict() {
+	if s.policy == nil {
+		return
+	}
+
+	for s.exceeded() {
+		key, ok := s.policy.victim()
+		if !ok {
+			return
+		}
+		s.delete(key)
+		s.evictions++
+	}
+}
+
+func (s *memStore) exceeded() bool {
+	return (s.maxEntries > 0 && len(s.entries) > s.maxEntries) ||
+		(s.maxBytes > 0 && s.bytes > s.maxBytes)
+}
+
+func entrySize(key string, e memEntry) int64 {
+	return int64(len(key) + len(e.data) + memEntryOverhead)
+}
+
+func link(index map[string]map[string]struct{}, from, to string) {
+	if index[from] == nil {
+		index[from] = make(map[string]struct{})
+	}
+	index[from][to] = struct{}{}
+}
+
+func unlink(index map[string]map[string]struct{}, from, to string) {
+	delete(index[from], to)
+	if len(index[from]) == 0 {
+		delete(index, from)
+	}
+}
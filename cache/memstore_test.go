@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Cache_MemStore(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	t.Run("unbounded", func(t *testing.T) {
+		var s memStore
+
+		_, ok := s.Load("k1")
+		at.False(ok)
+
+		s.Store("k1", memEntry{data: []byte("v1")})
+		s.Store("k1", memEntry{data: []byte("v11")})
+		s.Store("k2", memEntry{data: []byte("v2")})
+
+		e, ok := s.Load("k1")
+		at.True(ok)
+		at.Equal("v11", string(e.data))
+
+		at.Equal(Stats{Entries: 2, Bytes: 9 + memEntryOverhead*2}, s.Stats())
+
+		s.Delete("k1")
+		s.Delete("k3")
+		at.Equal(Stats{Entries: 1, Bytes: 4 + memEntryOverhead}, s.Stats())
+	})
+
+	t.Run("max entries", func(t *testing.T) {
+		s := memStore{policy: newLRU(), maxEntries: 2}
+
+		s.Store("k1", memEntry{})
+		s.Store("k2", memEntry{})
+		s.Load("k1")
+		s.Store("k3", memEntry{})
+
+		_, ok := s.Load("k2")
+		at.False(ok)
+		_, ok = s.Load("k1")
+		at.True(ok)
+		at.Equal(uint64(1), s.Stats().Evictions)
+	})
+
+	t.Run("max bytes", func(t *testing.T) {
+		s := memStore{policy: newLFU(), maxBytes: memEntryOverhead*2 + 10}
+
+		s.Store("k1", memEntry{data: []byte("v1")})
+		s.Store("k2", memEntry{data: []byte("v2")})
+		s.Load("k1")
+		s.Store("k3", memEntry{data: []byte("v3")})
+
+		_, ok := s.Load("k2")
+		at.False(ok)
+		at.Equal(Stats{Entries: 2, Bytes: memEntryOverhead*2 + 8, Evictions: 1}, s.Stats())
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		s := memStore{policy: newTinyLFU(0), maxBytes: memEntryOverhead + 10}
+
+		s.Store("k1", memEntry{data: make([]byte, 10)})
+
+		_, ok := s.Load("k1")
+		at.False(ok)
+		at.Equal(Stats{Evictions: 1}, s.Stats())
+	})
+
+	t.Run("set then get on full store", func(t *testing.T) {
+		for _, policy := range []string{"lru", "lfu", "tinylfu"} {
+			s := memStore{policy: newEvictionPolicy(policy, 3), maxEntries: 3}
+
+			for i := 0; i < 10; i++ {
+				key := "k" + strconv.Itoa(i)
+				s.Store(key, memEntry{})
+
+				_, ok := s.Load(key)
+				at.True(ok, "%s %s", policy, key)
+
+				// Frequent keys must not keep new ones out
+				s.Load(key)
+				s.Load(key)
+			}
+
+			at.Equal(3, s.Stats().Entries, policy)
+		}
+	})
+
+	t.Run("range", func(t *testing.T) {
+		var s memStore
+
+		s.Store("k1", memEntry{})
+		s.Store("k2", memEntry{})
+
+		s.Range(func(key string, _ memEntry) bool {
+			s.Delete(key)
+			return true
+		})
+		at.Equal(0, s.Stats().Entries)
+
+		s.Store("k1", memEntry{})
+		s.Store("k2", memEntry{})
+
+		n := 0
+		s.Range(func(string, memEntry) bool {
+			n++
+			return false
+		})
+		at.Equal(1, n)
+	})
+}
+
+func Test_Cache_MemStore_Tags(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s := &memStore{maxEntries: 2, policy: newLRU()}
+
+	// Missing keys are not linked
+	s.Tag("k1", []string{"t1"})
+	at.Nil(s.tags)
+
+	s.Store("k1", memEntry{data: []byte("v1")})
+	s.Store("k2", memEntry{data: []byte("v2")})
+	s.Tag("k1", []string{"t1", "t2"})
+	s.Tag("k2", []string{"t2"})
+
+	// Deleted and evicted keys are unlinked
+	s.Delete("k2")
+	at.NotContains(s.tags["t2"], "k2")
+	at.NotContains(s.keyTags, "k2")
+
+	s.Store("k3", memEntry{data: []byte("v3")})
+	s.Store("k4", memEntry{data: []byte("v4")})
+	at.Empty(s.tags)
+	at.Empty(s.keyTags)
+
+	s.Tag("k3", []string{"t1"})
+	s.Tag("k4", []string{"t2"})
+
+	at.Equal([]string{"k3"}, s.Flush([]string{"t1"}))
+	at.Nil(s.Flush([]string{"t1"}))
+
+	_, ok := s.Load("k4")
+	at.True(ok)
+}
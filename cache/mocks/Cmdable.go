@@ -4,11 +4,10 @@ package mocks
 
 import (
 	context "context"
+	time "time"
 
 	redis "github.com/go-redis/redis/v8"
 	mock "github.com/stretchr/testify/mock"
-
-	time "time"
 )
 
 // Cmdable is an autogenerated mock type for the Cmdable type
@@ -39,6 +38,25 @@ func (_m *Cmdable) Del(ctx context.Context, keys ...string) *redis.IntCmd {
 	return r0
 }
 
+// Eval provides a mock function with given fields: ctx, script, keys, args
+func (_m *Cmdable) Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd {
+	var _ca []interface{}
+	_ca = append(_ca, ctx, script, keys)
+	_ca = append(_ca, args...)
+	ret := _m.Called(_ca...)
+
+	var r0 *redis.Cmd
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string, ...interface{}) *redis.Cmd); ok {
+		r0 = rf(ctx, script, keys, args...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*redis.Cmd)
+		}
+	}
+
+	return r0
+}
+
 // Exists provides a mock function with given fields: ctx, keys
 func (_m *Cmdable) Exists(ctx context.Context, keys ...string) *redis.IntCmd {
 	_va := make([]interface{}, len(keys))
@@ -78,20 +96,36 @@ func (_m *Cmdable) Get(ctx context.Context, key string) *redis.StringCmd {
 	return r0
 }
 
-// MGet provides a mock function with given fields: ctx, keys
-func (_m *Cmdable) MGet(ctx context.Context, keys ...string) *redis.SliceCmd {
-	_va := make([]interface{}, len(keys))
-	for _i := range keys {
-		_va[_i] = keys[_i]
+// HGet provides a mock function with given fields: ctx, key, field
+func (_m *Cmdable) HGet(ctx context.Context, key string, field string) *redis.StringCmd {
+	ret := _m.Called(ctx, key, field)
+
+	var r0 *redis.StringCmd
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *redis.StringCmd); ok {
+		r0 = rf(ctx, key, field)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*redis.StringCmd)
+		}
+	}
+
+	return r0
+}
+
+// HMGet provides a mock function with given fields: ctx, key, fields
+func (_m *Cmdable) HMGet(ctx context.Context, key string, fields ...string) *redis.SliceCmd {
+	_va := make([]interface{}, len(fields))
+	for _i := range fields {
+		_va[_i] = fields[_i]
 	}
 	var _ca []interface{}
-	_ca = append(_ca, ctx)
+	_ca = append(_ca, ctx, key)
 	_ca = append(_ca, _va...)
 	ret := _m.Called(_ca...)
 
 	var r0 *redis.SliceCmd
-	if rf, ok := ret.Get(0).(func(context.Context, ...string) *redis.SliceCmd); ok {
-		r0 = rf(ctx, keys...)
+	if rf, ok := ret.Get(0).(func(context.Context, string, ...string) *redis.SliceCmd); ok {
+		r0 = rf(ctx, key, fields...)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*redis.SliceCmd)
@@ -101,6 +135,22 @@ func (_m *Cmdable) MGet(ctx context.Context, keys ...string) *redis.SliceCmd {
 	return r0
 }
 
+// SMembers provides a mock function with given fields: ctx, key
+func (_m *Cmdable) SMembers(ctx context.Context, key string) *redis.StringSliceCmd {
+	ret := _m.Called(ctx, key)
+
+	var r0 *redis.StringSliceCmd
+	if rf, ok := ret.Get(0).(func(context.Context, string) *redis.StringSliceCmd); ok {
+		r0 = rf(ctx, key)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*redis.StringSliceCmd)
+		}
+	}
+
+	return r0
+}
+
 // Scan provides a mock function with given fields: ctx, cursor, match, count
 func (_m *Cmdable) Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd {
 	ret := _m.Called(ctx, cursor, match, count)
@@ -132,3 +182,19 @@ func (_m *Cmdable) Set(ctx context.Context, key string, value interface{}, expir
 
 	return r0
 }
+
+// SetNX provides a mock function with given fields: ctx, key, value, expiration
+func (_m *Cmdable) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	ret := _m.Called(ctx, key, value, expiration)
+
+	var r0 *redis.BoolCmd
+	if rf, ok := ret.Get(0).(func(context.Context, string, interface{}, time.Duration) *redis.BoolCmd); ok {
+		r0 = rf(ctx, key, value, expiration)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*redis.BoolCmd)
+		}
+	}
+
+	return r0
+}
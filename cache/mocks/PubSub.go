@@ -0,0 +1,54 @@
+// Code generated by mockery v0.0.0-dev. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	redis "github.com/go-redis/redis/v8"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// PubSub is an autogenerated mock type for the PubSub type
+type PubSub struct {
+	mock.Mock
+}
+
+// Publish provides a mock function with given fields: ctx, channel, message
+func (_m *PubSub) Publish(ctx context.Context, channel string, message interface{}) *redis.IntCmd {
+	ret := _m.Called(ctx, channel, message)
+
+	var r0 *redis.IntCmd
+	if rf, ok := ret.Get(0).(func(context.Context, string, interface{}) *redis.IntCmd); ok {
+		r0 = rf(ctx, channel, message)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*redis.IntCmd)
+		}
+	}
+
+	return r0
+}
+
+// Subscribe provides a mock function with given fields: ctx, channels
+func (_m *PubSub) Subscribe(ctx context.Context, channels ...string) *redis.PubSub {
+	_va := make([]interface{}, len(channels))
+	for _i := range channels {
+		_va[_i] = channels[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *redis.PubSub
+	if rf, ok := ret.Get(0).(func(context.Context, ...string) *redis.PubSub); ok {
+		r0 = rf(ctx, channels...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*redis.PubSub)
+		}
+	}
+
+	return r0
+}
@@ -2,39 +2,166 @@ package cache
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-dawn/dawn/config"
 	dawnRedis "github.com/go-dawn/dawn/db/redis"
 	"github.com/go-redis/redis/v8"
+	"golang.org/x/sync/singleflight"
 )
 
-var cb = context.Background()
-
 // Cmdable is a subset of go-redis Cmdable
 type Cmdable interface {
 	Del(ctx context.Context, keys ...string) *redis.IntCmd
 	Exists(ctx context.Context, keys ...string) *redis.IntCmd
 	Get(ctx context.Context, key string) *redis.StringCmd
-	MGet(ctx context.Context, keys ...string) *redis.SliceCmd
+	HGet(ctx context.Context, key, field string) *redis.StringCmd
+	HMGet(ctx context.Context, key string, fields ...string) *redis.SliceCmd
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd
+	SMembers(ctx context.Context, key string) *redis.StringSliceCmd
 	Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd
 }
 
+// unlockScript deletes the lock only if it's still held by the token
+const unlockScript = `if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0`
+
+// valueFunc is prepended to scripts which read entries, values of
+// hashes stored by setMeta are read from their value field
+const valueFunc = `local function value(key)
+	if redis.call("type", key).ok == "hash" then
+		return redis.call("hget", key, "value")
+	end
+	return redis.call("get", key)
+end
+`
+
+// manyScript gets values of the keys, entries stored by setMeta
+// included
+const manyScript = valueFunc + `local values = {}
+for i, key in ipairs(KEYS) do
+	values[i] = value(key)
+end
+return values`
+
+// pullScript deletes the key and returns its value, so only
+// one caller can take the entry
+const pullScript = valueFunc + `local v = value(KEYS[1])
+if v then
+	redis.call("del", KEYS[1])
+end
+return v`
+
+// incrScript increments the counter and sets its ttl if it's
+// created, so new counters never miss their ttl. Hashes stored
+// by setMeta are replaced by their value and keep their ttl
+const incrScript = `local t = redis.call("type", KEYS[1]).ok
+if t == "hash" then
+	local v = redis.call("hget", KEYS[1], "value")
+	if not v or not string.match(v, "^-?%d+$") then
+		return redis.error_reply("ERR value is not an integer or out of range")
+	end
+	local pttl = redis.call("pttl", KEYS[1])
+	redis.call("del", KEYS[1])
+	redis.call("set", KEYS[1], v)
+	if pttl > 0 then
+		redis.call("pexpire", KEYS[1], pttl)
+	end
+end
+local n = redis.call("incrby", KEYS[1], ARGV[1])
+if t == "none" and tonumber(ARGV[2]) > 0 then
+	redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return n`
+
+// casScript sets the value only if the entry equals the old one
+const casScript = valueFunc + `if value(KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call("set", KEYS[1], ARGV[2], "px", ARGV[3])
+else
+	redis.call("set", KEYS[1], ARGV[2])
+end
+return 1`
+
+// setMetaScript replaces the entry with a hash of the value and
+// its metadata, and sets its ttl in the same call
+const setMetaScript = `redis.call("del", KEYS[1])
+redis.call("hset", KEYS[1], "value", ARGV[1], "soft_expiry", ARGV[2], "delta", ARGV[3])
+if tonumber(ARGV[4]) > 0 then
+	redis.call("pexpire", KEYS[1], ARGV[4])
+end
+return 1`
+
+// tagScript adds the key to sets of the tags, and makes sure the
+// sets live as long as the key. Sets created for keys stored
+// indefinitely don't expire, and expiring sets are only extended
+const tagScript = `local ttl = tonumber(ARGV[2])
+for _, set in ipairs(KEYS) do
+	local created = redis.call("exists", set) == 0
+	redis.call("sadd", set, ARGV[1])
+	if ttl <= 0 then
+		redis.call("persist", set)
+	else
+		local pttl = redis.call("pttl", set)
+		if created or (pttl >= 0 and pttl < ttl) then
+			redis.call("pexpire", set, ttl)
+		end
+	end
+end
+return 1`
+
+// extendScript resets the ttl of the lock only if it's held by the token
+const extendScript = `if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0`
+
 type redisStorage struct {
 	db     Cmdable
 	prefix string
+	flight *singleflight.Group
+	// lock makes Remember recompute a key on one instance
+	// across the cluster, the others wait for the value
+	lock bool
+	// lockPrefix keeps locks apart from the entries,
+	// so they never collide with keys or get flushed
+	lockPrefix string
+	lockTTL    time.Duration
+	lockWait   time.Duration
+	lockRetry  time.Duration
 }
 
 func newRedis(c *config.Config) redisStorage {
+	lockTTL := c.GetDuration("lockTTL", time.Second*10)
+
 	return redisStorage{
-		db:     dawnRedis.Conn(c.GetString("connection")),
-		prefix: c.GetString("prefix", "dawn_cache_"),
+		db:         dawnRedis.Conn(c.GetString("connection")),
+		prefix:     c.GetString("prefix", "dawn_cache_"),
+		flight:     &singleflight.Group{},
+		lock:       c.GetBool("lock"),
+		lockPrefix: c.GetString("lockPrefix", "dawn_lock:"),
+		lockTTL:    lockTTL,
+		lockWait:   c.GetDuration("lockWait", lockTTL),
+		lockRetry:  c.GetDuration("lockRetry", time.Millisecond*50),
 	}
 }
 
 func (s redisStorage) Has(key string) (bool, error) {
-	i, err := s.db.Exists(cb, s.prefixedKey(key)).Result()
+	return s.HasCtx(context.Background(), key)
+}
+
+func (s redisStorage) HasCtx(ctx context.Context, key string) (bool, error) {
+	i, err := s.db.Exists(ctx, s.prefixedKey(key)).Result()
 	if err != nil {
 		return false, err
 	}
@@ -42,39 +169,44 @@ func (s redisStorage) Has(key string) (bool, error) {
 	return i != 0, nil
 }
 
-func (s redisStorage) Get(key string) (b []byte, err error) {
-	b, err = s.db.Get(cb, s.prefixedKey(key)).Bytes()
-	if err == redis.Nil {
-		err = nil
-	}
+func (s redisStorage) Get(key string) ([]byte, error) {
+	return s.GetCtx(context.Background(), key)
+}
 
-	return
+func (s redisStorage) GetCtx(ctx context.Context, key string) ([]byte, error) {
+	return s.value(ctx, s.prefixedKey(key))
 }
 
-func (s redisStorage) GetWithDefault(key string, defaultValue []byte) (b []byte, err error) {
-	b, err = s.db.Get(cb, s.prefixedKey(key)).Bytes()
-	if err == redis.Nil {
-		err = nil
+func (s redisStorage) GetWithDefault(key string, defaultValue []byte) ([]byte, error) {
+	return s.GetWithDefaultCtx(context.Background(), key, defaultValue)
+}
+
+func (s redisStorage) GetWithDefaultCtx(ctx context.Context, key string, defaultValue []byte) (b []byte, err error) {
+	if b, err = s.value(ctx, s.prefixedKey(key)); err == nil && b == nil {
 		b = defaultValue
 	}
 
 	return
 }
 
-func (s redisStorage) Many(keys []string) (b [][]byte, err error) {
+func (s redisStorage) Many(keys []string) ([][]byte, error) {
+	return s.ManyCtx(context.Background(), keys)
+}
+
+func (s redisStorage) ManyCtx(ctx context.Context, keys []string) (b [][]byte, err error) {
 	for i := 0; i < len(keys); i++ {
 		keys[i] = s.prefixedKey(keys[i])
 	}
-	var values []interface{}
-	if values, err = s.db.MGet(cb, keys...).Result(); err != nil {
+	var res interface{}
+	if res, err = s.db.Eval(ctx, manyScript, keys).Result(); err != nil {
 		return
 	}
 
-	for _, v := range values {
-		if v != nil {
-			b = append(b, []byte(v.(string)))
-		} else {
-			b = append(b, nil)
+	values, _ := res.([]interface{})
+	b = make([][]byte, len(keys))
+	for i, v := range values {
+		if v, ok := v.(string); ok {
+			b[i] = []byte(v)
 		}
 	}
 
@@ -82,77 +214,155 @@ func (s redisStorage) Many(keys []string) (b [][]byte, err error) {
 }
 
 func (s redisStorage) Set(key string, value []byte, ttl time.Duration) error {
-	return s.db.Set(cb, s.prefixedKey(key), value, ttl).Err()
+	return s.SetCtx(context.Background(), key, value, ttl)
 }
 
-func (s redisStorage) Pull(key string) (b []byte, err error) {
-	key = s.prefixedKey(key)
-	if b, err = s.db.Get(cb, key).Bytes(); err == nil {
-		_, err = s.db.Del(cb, key).Result()
-		return
-	}
+func (s redisStorage) SetCtx(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.db.Set(ctx, s.prefixedKey(key), value, ttl).Err()
+}
 
-	if err == redis.Nil {
-		err = nil
-	}
+func (s redisStorage) Pull(key string) ([]byte, error) {
+	return s.PullCtx(context.Background(), key)
+}
 
-	return
+func (s redisStorage) PullCtx(ctx context.Context, key string) ([]byte, error) {
+	return s.pull(ctx, key, nil)
 }
 
-func (s redisStorage) PullWithDefault(key string, defaultValue []byte) (b []byte, err error) {
-	key = s.prefixedKey(key)
-	if b, err = s.db.Get(cb, key).Bytes(); err == nil {
-		_, err = s.db.Del(cb, key).Result()
-		return
-	}
+func (s redisStorage) PullWithDefault(key string, defaultValue []byte) ([]byte, error) {
+	return s.PullWithDefaultCtx(context.Background(), key, defaultValue)
+}
+
+func (s redisStorage) PullWithDefaultCtx(ctx context.Context, key string, defaultValue []byte) ([]byte, error) {
+	return s.pull(ctx, key, defaultValue)
+}
 
+// pull gets and deletes the key in one script
+func (s redisStorage) pull(ctx context.Context, key string, defaultValue []byte) ([]byte, error) {
+	v, err := s.db.Eval(ctx, pullScript, []string{s.prefixedKey(key)}).Text()
 	if err == redis.Nil {
-		err = nil
-		b = defaultValue
+		return defaultValue, nil
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return
+	return []byte(v), nil
 }
 
 func (s redisStorage) Forever(key string, value []byte) error {
-	return s.db.Set(cb, s.prefixedKey(key), value, 0).Err()
+	return s.ForeverCtx(context.Background(), key, value)
+}
+
+func (s redisStorage) ForeverCtx(ctx context.Context, key string, value []byte) error {
+	return s.db.Set(ctx, s.prefixedKey(key), value, 0).Err()
 }
 
-func (s redisStorage) Remember(key string, ttl time.Duration, valueFunc func() ([]byte, error)) (b []byte, err error) {
+func (s redisStorage) Remember(key string, ttl time.Duration, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return s.RememberCtx(context.Background(), key, ttl, valueFunc)
+}
+
+func (s redisStorage) RememberCtx(ctx context.Context, key string, ttl time.Duration, valueFunc func() ([]byte, error)) (b []byte, err error) {
 	key = s.prefixedKey(key)
-	if b, err = s.db.Get(cb, key).Bytes(); err == nil {
+	if b, err = s.value(ctx, key); err != nil || b != nil {
 		return
 	}
 
-	if err == redis.Nil {
-		if b, err = valueFunc(); err == nil {
-			_, err = s.db.Set(cb, key, b, ttl).Result()
+	return remember(ctx, s.flight, key, func(ctx context.Context) ([]byte, error) {
+		return s.value(ctx, key)
+	}, func(ctx context.Context) ([]byte, error) {
+		if s.lock {
+			return s.computeLocked(ctx, key, ttl, valueFunc)
 		}
-	}
+		return s.compute(ctx, key, ttl, valueFunc)
+	})
+}
 
-	return
+func (s redisStorage) RememberForever(key string, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return s.RememberForeverCtx(context.Background(), key, valueFunc)
+}
+
+func (s redisStorage) RememberForeverCtx(ctx context.Context, key string, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return s.RememberCtx(ctx, key, 0, valueFunc)
+}
+
+func (s redisStorage) RememberSWR(key string, freshTTL, staleTTL time.Duration, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return s.RememberSWRCtx(context.Background(), key, freshTTL, staleTTL, valueFunc)
+}
+
+// RememberSWRCtx keeps the value and its metadata in a hash, so
+// other reads only get its value field
+func (s redisStorage) RememberSWRCtx(ctx context.Context, key string, freshTTL, staleTTL time.Duration, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return rememberSWR(ctx, s, s.flight, key, freshTTL, staleTTL, valueFunc)
+}
+
+func (s redisStorage) RememberXFetch(key string, ttl time.Duration, beta float64, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return s.RememberXFetchCtx(context.Background(), key, ttl, beta, valueFunc)
+}
+
+// RememberXFetchCtx keeps the value and its metadata in a hash, so
+// other reads only get its value field
+func (s redisStorage) RememberXFetchCtx(ctx context.Context, key string, ttl time.Duration, beta float64, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return rememberXFetch(ctx, s, s.flight, key, ttl, beta, valueFunc)
 }
 
-func (s redisStorage) RememberForever(key string, valueFunc func() ([]byte, error)) (b []byte, err error) {
+func (s redisStorage) Delete(key string) error {
+	return s.DeleteCtx(context.Background(), key)
+}
+
+func (s redisStorage) DeleteCtx(ctx context.Context, key string) error {
+	return s.db.Del(ctx, s.prefixedKey(key)).Err()
+}
+
+func (s redisStorage) Increment(key string, delta int64, ttl time.Duration) (int64, error) {
+	return s.IncrementCtx(context.Background(), key, delta, ttl)
+}
+
+func (s redisStorage) IncrementCtx(ctx context.Context, key string, delta int64, ttl time.Duration) (n int64, err error) {
 	key = s.prefixedKey(key)
-	if b, err = s.db.Get(cb, key).Bytes(); err == nil {
-		return
-	}
 
-	if err == redis.Nil {
-		if b, err = valueFunc(); err == nil {
-			_, err = s.db.Set(cb, key, b, 0).Result()
-		}
+	n, err = s.db.Eval(ctx, incrScript, []string{key}, delta, ttl.Milliseconds()).Int64()
+	if err != nil && strings.Contains(err.Error(), "not an integer") {
+		err = ErrNotInteger
 	}
 
 	return
 }
 
-func (s redisStorage) Delete(key string) error {
-	return s.db.Del(cb, s.prefixedKey(key)).Err()
+func (s redisStorage) Decrement(key string, delta int64, ttl time.Duration) (int64, error) {
+	return s.DecrementCtx(context.Background(), key, delta, ttl)
+}
+
+func (s redisStorage) DecrementCtx(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return s.IncrementCtx(ctx, key, -delta, ttl)
+}
+
+func (s redisStorage) Add(key string, value []byte, ttl time.Duration) (bool, error) {
+	return s.AddCtx(context.Background(), key, value, ttl)
 }
 
-func (s redisStorage) Reset() (err error) {
+func (s redisStorage) AddCtx(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return s.db.SetNX(ctx, s.prefixedKey(key), value, ttl).Result()
+}
+
+func (s redisStorage) CompareAndSwap(key string, old, new []byte, ttl time.Duration) (bool, error) {
+	return s.CompareAndSwapCtx(context.Background(), key, old, new, ttl)
+}
+
+func (s redisStorage) CompareAndSwapCtx(ctx context.Context, key string, old, new []byte, ttl time.Duration) (bool, error) {
+	i, err := s.db.Eval(ctx, casScript, []string{s.prefixedKey(key)}, old, new, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return false, err
+	}
+
+	return i == 1, nil
+}
+
+func (s redisStorage) Reset() error {
+	return s.ResetCtx(context.Background())
+}
+
+func (s redisStorage) ResetCtx(ctx context.Context) (err error) {
 	var (
 		keys    []string
 		matched []string
@@ -160,7 +370,7 @@ func (s redisStorage) Reset() (err error) {
 	)
 
 	for {
-		if matched, cursor, err = s.db.Scan(cb, cursor, s.prefix+"*", 1000).Result(); err != nil {
+		if matched, cursor, err = s.db.Scan(ctx, cursor, s.prefix+"*", 1000).Result(); err != nil {
 			return
 		}
 		if len(matched) == 0 {
@@ -169,7 +379,11 @@ func (s redisStorage) Reset() (err error) {
 		keys = append(keys, matched...)
 	}
 
-	return s.db.Del(cb, keys...).Err()
+	return s.db.Del(ctx, keys...).Err()
+}
+
+func (s redisStorage) Tags(tags ...string) TaggedCacher {
+	return TaggedCacher{s: s, tags: tags}
 }
 
 func (s redisStorage) Close() error {
@@ -180,6 +394,195 @@ func (s redisStorage) gc() {
 	return
 }
 
+// tag adds the key to sets of the tags in one call, sets are
+// removed when the tags are flushed or the keys expire
+func (s redisStorage) tag(ctx context.Context, key string, tags []string, ttl time.Duration) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	sets := make([]string, len(tags))
+	for i, tag := range tags {
+		sets[i] = s.tagKey(tag)
+	}
+
+	return s.db.Eval(ctx, tagScript, sets, key, ttl.Milliseconds()).Err()
+}
+
+func (s redisStorage) flush(ctx context.Context, tags []string) (keys []string, err error) {
+	var (
+		members []string
+		seen    = make(map[string]bool)
+		del     = make([]string, 0, len(tags))
+	)
+
+	for _, tag := range tags {
+		if members, err = s.db.SMembers(ctx, s.tagKey(tag)).Result(); err != nil {
+			return nil, err
+		}
+
+		for _, key := range members {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+				del = append(del, s.prefixedKey(key))
+			}
+		}
+
+		del = append(del, s.tagKey(tag))
+	}
+
+	if err = s.db.Del(ctx, del...).Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (s redisStorage) tagKey(tag string) string {
+	return s.prefix + "tag:" + tag
+}
+
+// acquire keeps the lock under lockPrefix, apart from the entries
+func (s redisStorage) acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	return s.db.SetNX(ctx, s.lockPrefix+key, token, ttl).Result()
+}
+
+func (s redisStorage) release(ctx context.Context, key, token string) (bool, error) {
+	i, err := s.db.Eval(ctx, unlockScript, []string{s.lockPrefix + key}, token).Int64()
+	return i == 1, err
+}
+
+func (s redisStorage) extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	i, err := s.db.Eval(ctx, extendScript, []string{s.lockPrefix + key}, token, ttl.Milliseconds()).Int64()
+	return i == 1, err
+}
+
+// value gets the entry by prefixed key, nil is returned if it's
+// missing. Hashes stored by setMeta are read from their value field
+func (s redisStorage) value(ctx context.Context, key string) (b []byte, err error) {
+	b, err = s.db.Get(ctx, key).Bytes()
+	if isWrongType(err) {
+		b, err = s.db.HGet(ctx, key, "value").Bytes()
+	}
+	if err == redis.Nil {
+		err = nil
+	}
+
+	return
+}
+
+// getMeta reads the hash stored by setMeta. Entries which aren't
+// hashes have no metadata, so they are returned as stale values
+func (s redisStorage) getMeta(ctx context.Context, key string) (b []byte, meta entryMeta, err error) {
+	var fields []interface{}
+	if fields, err = s.db.HMGet(ctx, s.prefixedKey(key), "value", "soft_expiry", "delta").Result(); err != nil {
+		if isWrongType(err) {
+			b, err = s.value(ctx, s.prefixedKey(key))
+		}
+		return
+	}
+
+	value, ok := fields[0].(string)
+	if !ok {
+		return
+	}
+
+	softExpiry, _ := fields[1].(string)
+	delta, _ := fields[2].(string)
+
+	if meta.softExpiry, err = strconv.ParseInt(softExpiry, 10, 64); err != nil {
+		return nil, entryMeta{}, ErrInvalidPayload
+	}
+	if meta.delta, err = strconv.ParseInt(delta, 10, 64); err != nil {
+		return nil, entryMeta{}, ErrInvalidPayload
+	}
+
+	return []byte(value), meta, nil
+}
+
+// setMeta keeps the value and its metadata in separate fields of
+// a hash, so the metadata never leaks into the value
+func (s redisStorage) setMeta(ctx context.Context, key string, value []byte, meta entryMeta, ttl time.Duration) error {
+	return s.db.Eval(ctx, setMetaScript, []string{s.prefixedKey(key)}, value, meta.softExpiry, meta.delta, ttl.Milliseconds()).Err()
+}
+
+func (s redisStorage) compute(ctx context.Context, key string, ttl time.Duration, valueFunc func() ([]byte, error)) (b []byte, err error) {
+	if b, err = valueFunc(); err == nil {
+		err = s.db.Set(ctx, key, b, ttl).Err()
+	}
+
+	return
+}
+
+// computeLocked computes the value while holding the lock of the
+// key. Instances which can't get the lock wait for the value, and
+// compute it by themselves if it's still missing after lockWait
+func (s redisStorage) computeLocked(ctx context.Context, key string, ttl time.Duration, valueFunc func() ([]byte, error)) (b []byte, err error) {
+	var (
+		token    string
+		locked   bool
+		lockKey  = s.lockPrefix + "remember:" + key
+		deadline = time.Now().Add(s.lockWait)
+	)
+
+	if token, err = lockToken(); err != nil {
+		return
+	}
+
+	for {
+		if locked, err = s.db.SetNX(ctx, lockKey, token, s.lockTTL).Result(); err != nil {
+			return
+		}
+
+		if locked {
+			defer s.unlock(lockKey, token)
+
+			// The holder before may store the value just before
+			if b, err = s.value(ctx, key); err != nil || b != nil {
+				return
+			}
+
+			return s.compute(ctx, key, ttl, valueFunc)
+		}
+
+		if !time.Now().Before(deadline) {
+			return s.compute(ctx, key, ttl, valueFunc)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(s.lockRetry):
+		}
+
+		if b, err = s.value(ctx, key); err != nil || b != nil {
+			return
+		}
+	}
+}
+
+// unlock releases the lock even if ctx is done, errors are
+// ignored since the lock expires after lockTTL anyway
+func (s redisStorage) unlock(lockKey, token string) {
+	_ = s.db.Eval(context.Background(), unlockScript, []string{lockKey}, token).Err()
+}
+
+func lockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// isWrongType reports whether the command is applied to an entry of
+// other type, e.g. GET on hashes stored by setMeta
+func isWrongType(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "WRONGTYPE")
+}
+
 func (s redisStorage) prefixedKey(key string) string {
 	return s.prefix + key
 }
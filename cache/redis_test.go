@@ -1,7 +1,9 @@
 package cache
 
 import (
+	"context"
 	"errors"
+	"strconv"
 	"testing"
 	"time"
 
@@ -9,15 +11,33 @@ import (
 	"github.com/go-dawn/module/cache/mocks"
 	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/sync/singleflight"
 )
 
-var mockErr = errors.New("fake error")
+var (
+	mockErr   = errors.New("fake error")
+	wrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	cb        = context.Background()
+)
 
 func Test_Cache_Redis_New(t *testing.T) {
 	t.Parallel()
 
-	s := newRedis(config.New())
-	assert.Nil(t, s.db)
+	at := assert.New(t)
+
+	c := config.New()
+	c.Set("lock", true)
+	c.Set("lockTTL", "5s")
+
+	s := newRedis(c)
+	at.Nil(s.db)
+	at.NotNil(s.flight)
+	at.True(s.lock)
+	at.Equal("dawn_lock:", s.lockPrefix)
+	at.Equal(time.Second*5, s.lockTTL)
+	at.Equal(time.Second*5, s.lockWait)
+	at.Equal(time.Millisecond*50, s.lockRetry)
 }
 
 func Test_Cache_Redis_Has(t *testing.T) {
@@ -74,6 +94,20 @@ func Test_Cache_Redis_Get(t *testing.T) {
 		at.Nil(b)
 	})
 
+	t.Run("entry with metadata", func(t *testing.T) {
+		s, mockDB := getRedisStorage()
+
+		// Entries of RememberSWR and RememberXFetch are hashes
+		mockDB.On("Get", cb, "k1").
+			Once().Return(redis.NewStringResult("", wrongType)).
+			On("HGet", cb, "k1", "value").
+			Once().Return(redis.NewStringResult("v1", nil))
+
+		b, err := s.Get("k1")
+		at.Nil(err)
+		at.Equal("v1", string(b))
+	})
+
 	t.Run("error", func(t *testing.T) {
 		s, mockDB := getRedisStorage()
 
@@ -131,21 +165,22 @@ func Test_Cache_Redis_Many(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		s, mockDB := getRedisStorage()
 
-		mockDB.On("MGet", cb, "k1", "k2").
-			Once().Return(redis.NewSliceResult([]interface{}{nil, "v2"}, nil))
+		mockDB.On("Eval", cb, manyScript, []string{"k1", "k2", "k3"}).
+			Once().Return(redis.NewCmdResult([]interface{}{nil, "v2", nil}, nil))
 
-		b, err := s.Many([]string{"k1", "k2"})
+		b, err := s.Many([]string{"k1", "k2", "k3"})
 		at.Nil(err)
-		at.Len(b, 2)
+		at.Len(b, 3)
 		at.Nil(b[0])
 		at.Equal("v2", string(b[1]))
+		at.Nil(b[2])
 	})
 
 	t.Run("error", func(t *testing.T) {
 		s, mockDB := getRedisStorage()
 
-		mockDB.On("MGet", cb, "k1", "k2").
-			Once().Return(redis.NewSliceResult(nil, mockErr))
+		mockDB.On("Eval", cb, manyScript, []string{"k1", "k2"}).
+			Once().Return(redis.NewCmdResult(nil, mockErr))
 
 		_, err := s.Many([]string{"k1", "k2"})
 		at.Equal(mockErr, err)
@@ -186,10 +221,8 @@ func Test_Cache_Redis_Pull(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		s, mockDB := getRedisStorage()
 
-		mockDB.On("Get", cb, "k1").
-			Once().Return(redis.NewStringResult("v1", nil)).
-			On("Del", cb, "k1").
-			Once().Return(redis.NewIntResult(1, nil))
+		mockDB.On("Eval", cb, pullScript, []string{"k1"}).
+			Once().Return(redis.NewCmdResult("v1", nil))
 
 		b, err := s.Pull("k1")
 		at.Nil(err)
@@ -199,13 +232,24 @@ func Test_Cache_Redis_Pull(t *testing.T) {
 	t.Run("non-exist", func(t *testing.T) {
 		s, mockDB := getRedisStorage()
 
-		mockDB.On("Get", cb, "k1").
-			Once().Return(redis.NewStringResult("", redis.Nil))
+		mockDB.On("Eval", cb, pullScript, []string{"k1"}).
+			Once().Return(redis.NewCmdResult(nil, redis.Nil))
 
 		b, err := s.Pull("k1")
 		at.Nil(err)
 		at.Nil(b)
 	})
+
+	t.Run("error", func(t *testing.T) {
+		s, mockDB := getRedisStorage()
+
+		mockDB.On("Eval", cb, pullScript, []string{"k1"}).
+			Once().Return(redis.NewCmdResult(nil, mockErr))
+
+		b, err := s.Pull("k1")
+		at.Equal(mockErr, err)
+		at.Nil(b)
+	})
 }
 
 func Test_Cache_Redis_PullWithDefault(t *testing.T) {
@@ -216,10 +260,8 @@ func Test_Cache_Redis_PullWithDefault(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		s, mockDB := getRedisStorage()
 
-		mockDB.On("Get", cb, "k1").
-			Once().Return(redis.NewStringResult("v1", nil)).
-			On("Del", cb, "k1").
-			Once().Return(redis.NewIntResult(1, nil))
+		mockDB.On("Eval", cb, pullScript, []string{"k1"}).
+			Once().Return(redis.NewCmdResult("v1", nil))
 
 		b, err := s.PullWithDefault("k1", []byte("v11"))
 		at.Nil(err)
@@ -229,8 +271,8 @@ func Test_Cache_Redis_PullWithDefault(t *testing.T) {
 	t.Run("non-exist", func(t *testing.T) {
 		s, mockDB := getRedisStorage()
 
-		mockDB.On("Get", cb, "k1").
-			Once().Return(redis.NewStringResult("", redis.Nil))
+		mockDB.On("Eval", cb, pullScript, []string{"k1"}).
+			Once().Return(redis.NewCmdResult(nil, redis.Nil))
 
 		b, err := s.PullWithDefault("k1", []byte("v11"))
 		at.Nil(err)
@@ -274,7 +316,7 @@ func Test_Cache_Redis_Remember(t *testing.T) {
 		s, mockDB := getRedisStorage()
 
 		mockDB.On("Get", cb, "k1").
-			Once().Return(redis.NewStringResult("", redis.Nil)).
+			Twice().Return(redis.NewStringResult("", redis.Nil)).
 			On("Set", cb, "k1", []byte("v11"), time.Second).
 			Once().Return(redis.NewStatusResult("OK", nil))
 
@@ -308,7 +350,7 @@ func Test_Cache_Redis_RememberForever(t *testing.T) {
 		s, mockDB := getRedisStorage()
 
 		mockDB.On("Get", cb, "k1").
-			Once().Return(redis.NewStringResult("", redis.Nil)).
+			Twice().Return(redis.NewStringResult("", redis.Nil)).
 			On("Set", cb, "k1", []byte("v11"), time.Duration(0)).
 			Once().Return(redis.NewStatusResult("OK", nil))
 
@@ -320,6 +362,354 @@ func Test_Cache_Redis_RememberForever(t *testing.T) {
 	})
 }
 
+func Test_Cache_Redis_Remember_Lock(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	getLockedStorage := func() (redisStorage, *mocks.Cmdable) {
+		s, mockDB := getRedisStorage()
+		s.lock = true
+		s.lockPrefix = "l_"
+		s.lockTTL = time.Second
+		s.lockWait = time.Millisecond * 20
+		s.lockRetry = time.Millisecond * 5
+		return s, mockDB
+	}
+
+	valueFunc := func() ([]byte, error) {
+		return []byte("v11"), nil
+	}
+
+	t.Run("locked", func(t *testing.T) {
+		s, mockDB := getLockedStorage()
+
+		mockDB.On("Get", cb, "k1").
+			Times(3).Return(redis.NewStringResult("", redis.Nil)).
+			On("SetNX", cb, "l_remember:k1", mock.AnythingOfType("string"), time.Second).
+			Once().Return(redis.NewBoolResult(true, nil)).
+			On("Set", cb, "k1", []byte("v11"), time.Second).
+			Once().Return(redis.NewStatusResult("OK", nil)).
+			On("Eval", cb, unlockScript, []string{"l_remember:k1"}, mock.AnythingOfType("string")).
+			Once().Return(redis.NewCmdResult(int64(1), nil))
+
+		b, err := s.Remember("k1", time.Second, valueFunc)
+		at.Nil(err)
+		at.Equal("v11", string(b))
+		at.True(mockDB.AssertExpectations(t))
+	})
+
+	t.Run("stored by lock holder", func(t *testing.T) {
+		s, mockDB := getLockedStorage()
+
+		mockDB.On("Get", cb, "k1").
+			Twice().Return(redis.NewStringResult("", redis.Nil)).
+			On("SetNX", cb, "l_remember:k1", mock.AnythingOfType("string"), time.Second).
+			Once().Return(redis.NewBoolResult(false, nil)).
+			On("Get", cb, "k1").
+			Once().Return(redis.NewStringResult("v1", nil))
+
+		b, err := s.Remember("k1", time.Second, valueFunc)
+		at.Nil(err)
+		at.Equal("v1", string(b))
+	})
+
+	t.Run("wait timeout", func(t *testing.T) {
+		s, mockDB := getLockedStorage()
+
+		mockDB.On("Get", cb, "k1").
+			Return(redis.NewStringResult("", redis.Nil)).
+			On("SetNX", cb, "l_remember:k1", mock.AnythingOfType("string"), time.Second).
+			Return(redis.NewBoolResult(false, nil)).
+			On("Set", cb, "k1", []byte("v11"), time.Second).
+			Once().Return(redis.NewStatusResult("OK", nil))
+
+		b, err := s.Remember("k1", time.Second, valueFunc)
+		at.Nil(err)
+		at.Equal("v11", string(b))
+	})
+
+	t.Run("lock error", func(t *testing.T) {
+		s, mockDB := getLockedStorage()
+
+		mockDB.On("Get", cb, "k1").
+			Twice().Return(redis.NewStringResult("", redis.Nil)).
+			On("SetNX", cb, "l_remember:k1", mock.AnythingOfType("string"), time.Second).
+			Once().Return(redis.NewBoolResult(false, mockErr))
+
+		_, err := s.Remember("k1", time.Second, valueFunc)
+		at.Equal(mockErr, err)
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		s, mockDB := getLockedStorage()
+		s.lockWait = time.Minute
+
+		var (
+			ctx, cancel = context.WithCancel(cb)
+			unlocked    = make(chan struct{})
+		)
+
+		mockDB.On("Get", ctx, "k1").
+			Once().Return(redis.NewStringResult("", redis.Nil)).
+			On("Get", mock.Anything, "k1").
+			Times(3).Return(redis.NewStringResult("", redis.Nil)).
+			On("SetNX", mock.Anything, "l_remember:k1", mock.AnythingOfType("string"), time.Second).
+			Once().Return(redis.NewBoolResult(false, nil)).
+			Run(func(mock.Arguments) {
+				cancel()
+			}).
+			On("SetNX", mock.Anything, "l_remember:k1", mock.AnythingOfType("string"), time.Second).
+			Once().Return(redis.NewBoolResult(true, nil)).
+			On("Set", mock.Anything, "k1", []byte("v11"), time.Second).
+			Once().Return(redis.NewStatusResult("OK", nil)).
+			On("Eval", cb, unlockScript, []string{"l_remember:k1"}, mock.AnythingOfType("string")).
+			Once().Return(redis.NewCmdResult(int64(1), nil)).
+			Run(func(mock.Arguments) {
+				close(unlocked)
+			})
+
+		_, err := s.RememberCtx(ctx, "k1", time.Second, valueFunc)
+		at.Equal(context.Canceled, err)
+
+		// The flight goes on for other waiters
+		select {
+		case <-unlocked:
+		case <-time.After(time.Second):
+			at.Fail("flight is not finished")
+		}
+		at.True(mockDB.AssertExpectations(t))
+	})
+}
+
+func Test_Cache_Redis_RememberSWR(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	valueFunc := func() ([]byte, error) {
+		return []byte("v11"), nil
+	}
+
+	t.Run("fresh", func(t *testing.T) {
+		s, mockDB := getRedisStorage()
+
+		softExpiry := strconv.FormatInt(time.Now().Add(time.Minute).UnixNano(), 10)
+
+		mockDB.On("HMGet", cb, "k1", "value", "soft_expiry", "delta").
+			Once().Return(redis.NewSliceResult([]interface{}{"v1", softExpiry, "0"}, nil))
+
+		b, err := s.RememberSWR("k1", time.Minute, time.Minute, valueFunc)
+		at.Nil(err)
+		at.Equal("v1", string(b))
+	})
+
+	t.Run("non-exist", func(t *testing.T) {
+		s, mockDB := getRedisStorage()
+
+		mockDB.On("HMGet", cb, "k1", "value", "soft_expiry", "delta").
+			Twice().Return(redis.NewSliceResult([]interface{}{nil, nil, nil}, nil)).
+			On("Eval", cb, setMetaScript, []string{"k1"}, []byte("v11"), mock.MatchedBy(func(softExpiry int64) bool {
+				return !entryMeta{softExpiry: softExpiry}.stale()
+			}), mock.AnythingOfType("int64"), int64(120000)).
+			Once().Return(redis.NewCmdResult(int64(1), nil))
+
+		b, err := s.RememberSWR("k1", time.Minute, time.Minute, valueFunc)
+		at.Nil(err)
+		at.Equal("v11", string(b))
+		at.True(mockDB.AssertExpectations(t))
+	})
+
+	t.Run("plain entry", func(t *testing.T) {
+		s, mockDB := getRedisStorage()
+
+		// Entries stored by Set have no metadata, so they are stale
+		mockDB.On("HMGet", cb, "k1", "value", "soft_expiry", "delta").
+			Once().Return(redis.NewSliceResult(nil, wrongType)).
+			On("Get", cb, "k1").
+			Once().Return(redis.NewStringResult("v1", nil))
+
+		b, meta, err := s.getMeta(cb, "k1")
+		at.Nil(err)
+		at.Equal("v1", string(b))
+		at.True(meta.stale())
+	})
+
+	t.Run("invalid payload", func(t *testing.T) {
+		s, mockDB := getRedisStorage()
+
+		mockDB.On("HMGet", cb, "k2", "value", "soft_expiry", "delta").
+			Once().Return(redis.NewSliceResult([]interface{}{"v1", "soft", "0"}, nil))
+
+		_, err := s.RememberSWR("k2", time.Minute, time.Minute, valueFunc)
+		at.Equal(ErrInvalidPayload, err)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		s, mockDB := getRedisStorage()
+
+		mockDB.On("HMGet", cb, "k1", "value", "soft_expiry", "delta").
+			Once().Return(redis.NewSliceResult(nil, mockErr))
+
+		_, err := s.RememberSWR("k1", time.Minute, time.Minute, valueFunc)
+		at.Equal(mockErr, err)
+	})
+}
+
+func Test_Cache_Redis_RememberXFetch(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s, mockDB := getRedisStorage()
+
+	mockDB.On("HMGet", cb, "k1", "value", "soft_expiry", "delta").
+		Twice().Return(redis.NewSliceResult([]interface{}{nil, nil, nil}, nil)).
+		On("Eval", cb, setMetaScript, []string{"k1"}, []byte("v11"), mock.AnythingOfType("int64"), mock.AnythingOfType("int64"), int64(60000)).
+		Once().Return(redis.NewCmdResult(int64(1), nil))
+
+	b, err := s.RememberXFetch("k1", time.Minute, 1, func() ([]byte, error) {
+		return []byte("v11"), nil
+	})
+	at.Nil(err)
+	at.Equal("v11", string(b))
+}
+
+func Test_Cache_Redis_Increment(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	t.Run("ttl", func(t *testing.T) {
+		s, mockDB := getRedisStorage()
+
+		mockDB.On("Eval", cb, incrScript, []string{"k1"}, int64(5), int64(60000)).
+			Once().Return(redis.NewCmdResult(int64(5), nil))
+
+		n, err := s.Increment("k1", 5, time.Minute)
+		at.Nil(err)
+		at.Equal(int64(5), n)
+	})
+
+	t.Run("forever", func(t *testing.T) {
+		s, mockDB := getRedisStorage()
+
+		mockDB.On("Eval", cb, incrScript, []string{"k1"}, int64(-5), int64(0)).
+			Once().Return(redis.NewCmdResult(int64(-5), nil))
+
+		n, err := s.Decrement("k1", 5, 0)
+		at.Nil(err)
+		at.Equal(int64(-5), n)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		s, mockDB := getRedisStorage()
+
+		mockDB.On("Eval", cb, incrScript, []string{"k1"}, int64(1), int64(0)).
+			Once().Return(redis.NewCmdResult(nil, errors.New("ERR value is not an integer or out of range"))).
+			On("Eval", cb, incrScript, []string{"k2"}, int64(1), int64(0)).
+			Once().Return(redis.NewCmdResult(nil, mockErr))
+
+		_, err := s.Increment("k1", 1, 0)
+		at.Equal(ErrNotInteger, err)
+
+		_, err = s.Increment("k2", 1, 0)
+		at.Equal(mockErr, err)
+	})
+}
+
+func Test_Cache_Redis_Add(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s, mockDB := getRedisStorage()
+
+	mockDB.On("SetNX", cb, "k1", []byte("v1"), time.Minute).
+		Once().Return(redis.NewBoolResult(true, nil)).
+		On("SetNX", cb, "k1", []byte("v1"), time.Minute).
+		Once().Return(redis.NewBoolResult(false, nil)).
+		On("SetNX", cb, "k2", []byte("v2"), time.Minute).
+		Once().Return(redis.NewBoolResult(false, mockErr))
+
+	ok, err := s.Add("k1", []byte("v1"), time.Minute)
+	at.Nil(err)
+	at.True(ok)
+
+	ok, err = s.Add("k1", []byte("v1"), time.Minute)
+	at.Nil(err)
+	at.False(ok)
+
+	_, err = s.Add("k2", []byte("v2"), time.Minute)
+	at.Equal(mockErr, err)
+}
+
+func Test_Cache_Redis_CompareAndSwap(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s, mockDB := getRedisStorage()
+
+	mockDB.On("Eval", cb, casScript, []string{"k1"}, []byte("v1"), []byte("v11"), int64(60000)).
+		Once().Return(redis.NewCmdResult(int64(1), nil)).
+		On("Eval", cb, casScript, []string{"k1"}, []byte("v1"), []byte("v11"), int64(0)).
+		Once().Return(redis.NewCmdResult(int64(0), nil)).
+		On("Eval", cb, casScript, []string{"k2"}, []byte("v2"), []byte("v22"), int64(60000)).
+		Once().Return(redis.NewCmdResult(nil, mockErr))
+
+	ok, err := s.CompareAndSwap("k1", []byte("v1"), []byte("v11"), time.Minute)
+	at.Nil(err)
+	at.True(ok)
+
+	ok, err = s.CompareAndSwap("k1", []byte("v1"), []byte("v11"), 0)
+	at.Nil(err)
+	at.False(ok)
+
+	_, err = s.CompareAndSwap("k2", []byte("v2"), []byte("v22"), time.Minute)
+	at.Equal(mockErr, err)
+}
+
+func Test_Cache_Redis_Lock(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s, mockDB := getRedisStorage()
+	s.prefix = "p_"
+	s.lockPrefix = "l_"
+
+	mockDB.On("SetNX", cb, "l_lock:job", "token", time.Minute).
+		Once().Return(redis.NewBoolResult(true, nil)).
+		On("Eval", cb, extendScript, []string{"l_lock:job"}, "token", int64(60000)).
+		Once().Return(redis.NewCmdResult(int64(1), nil)).
+		On("Eval", cb, unlockScript, []string{"l_lock:job"}, "token").
+		Once().Return(redis.NewCmdResult(int64(1), nil)).
+		On("Eval", cb, unlockScript, []string{"l_lock:job"}, "token").
+		Once().Return(redis.NewCmdResult(int64(0), nil)).
+		On("Eval", cb, extendScript, []string{"l_lock:job"}, "token", int64(60000)).
+		Once().Return(redis.NewCmdResult(nil, mockErr))
+
+	ok, err := s.acquire(cb, "lock:job", "token", time.Minute)
+	at.Nil(err)
+	at.True(ok)
+
+	ok, err = s.extend(cb, "lock:job", "token", time.Minute)
+	at.Nil(err)
+	at.True(ok)
+
+	ok, err = s.release(cb, "lock:job", "token")
+	at.Nil(err)
+	at.True(ok)
+
+	ok, err = s.release(cb, "lock:job", "token")
+	at.Nil(err)
+	at.False(ok)
+
+	_, err = s.extend(cb, "lock:job", "token", time.Minute)
+	at.Equal(mockErr, err)
+}
+
 func Test_Cache_Redis_Delete(t *testing.T) {
 	t.Parallel()
 
@@ -362,6 +752,63 @@ func Test_Cache_Redis_Reset(t *testing.T) {
 	})
 }
 
+func Test_Cache_Redis_Tags(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	t.Run("tag", func(t *testing.T) {
+		s, mockDB := getRedisStorage()
+		s.prefix = "p_"
+
+		mockDB.On("Set", cb, "p_k1", []byte("v1"), time.Minute).
+			Twice().Return(redis.NewStatusResult("", nil)).
+			On("Eval", cb, tagScript, []string{"p_tag:t1", "p_tag:t2"}, "k1", int64(60000)).
+			Once().Return(redis.NewCmdResult(int64(1), nil)).
+			On("Set", cb, "p_k2", []byte("v2"), time.Duration(0)).
+			Once().Return(redis.NewStatusResult("", nil)).
+			On("Eval", cb, tagScript, []string{"p_tag:t1"}, "k2", int64(0)).
+			Once().Return(redis.NewCmdResult(nil, mockErr))
+
+		at.Nil(s.Tags("t1", "t2").Set("k1", []byte("v1"), time.Minute))
+		at.Equal(mockErr, s.Tags("t1").Forever("k2", []byte("v2")))
+		at.Nil(s.Tags().Set("k1", []byte("v1"), time.Minute))
+		at.True(mockDB.AssertExpectations(t))
+	})
+
+	t.Run("flush", func(t *testing.T) {
+		s, mockDB := getRedisStorage()
+		s.prefix = "p_"
+
+		mockDB.On("SMembers", cb, "p_tag:t1").
+			Once().Return(redis.NewStringSliceResult([]string{"k1", "k2"}, nil)).
+			On("SMembers", cb, "p_tag:t2").
+			Once().Return(redis.NewStringSliceResult([]string{"k2", "k3"}, nil)).
+			On("Del", cb, "p_k1", "p_k2", "p_tag:t1", "p_k3", "p_tag:t2").
+			Once().Return(redis.NewIntResult(5, nil))
+
+		keys, err := s.flush(cb, []string{"t1", "t2"})
+		at.Nil(err)
+		at.Equal([]string{"k1", "k2", "k3"}, keys)
+	})
+
+	t.Run("flush error", func(t *testing.T) {
+		s, mockDB := getRedisStorage()
+
+		mockDB.On("SMembers", cb, "tag:t1").
+			Once().Return(redis.NewStringSliceResult(nil, mockErr))
+
+		at.Equal(mockErr, s.Tags("t1").Flush())
+
+		mockDB.On("SMembers", cb, "tag:t2").
+			Once().Return(redis.NewStringSliceResult([]string{"k1"}, nil)).
+			On("Del", cb, "k1", "tag:t2").
+			Once().Return(redis.NewIntResult(0, mockErr))
+
+		at.Equal(mockErr, s.Tags("t2").Flush())
+	})
+}
+
 func Test_Cache_Redis_Close(t *testing.T) {
 	t.Parallel()
 
@@ -379,9 +826,42 @@ func Test_Cache_Redis_GC(t *testing.T) {
 	(redisStorage{}).gc()
 }
 
+func Test_Cache_Redis_Ctx(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "trace")
+
+	s, mockDB := getRedisStorage()
+
+	mockDB.On("Exists", ctx, "k1").
+		Once().Return(redis.NewIntResult(1, nil)).
+		On("Get", ctx, "k1").
+		Once().Return(redis.NewStringResult("v1", nil)).
+		On("Set", ctx, "k1", []byte("v1"), time.Minute).
+		Once().Return(redis.NewStatusResult("", nil)).
+		On("Del", ctx, "k1").
+		Once().Return(redis.NewIntResult(1, nil))
+
+	ok, err := s.HasCtx(ctx, "k1")
+	at.Nil(err)
+	at.True(ok)
+
+	b, err := s.GetCtx(ctx, "k1")
+	at.Nil(err)
+	at.Equal("v1", string(b))
+
+	at.Nil(s.SetCtx(ctx, "k1", []byte("v1"), time.Minute))
+	at.Nil(s.DeleteCtx(ctx, "k1"))
+
+	mockDB.AssertExpectations(t)
+}
+
 func getRedisStorage() (redisStorage, *mocks.Cmdable) {
 	mockDB := new(mocks.Cmdable)
-	return redisStorage{db: mockDB}, mockDB
+	return redisStorage{db: mockDB, flight: &singleflight.Group{}}, mockDB
 }
 
 func TestRedis(t *testing.T) {
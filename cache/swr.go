@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// entryMeta is kept next to values stored by
+// RememberSWR and RememberXFetch
+type entryMeta struct {
+	// softExpiry is the unix nano time when the value gets stale
+	softExpiry int64
+	// delta is the nanoseconds which the value took to compute
+	delta int64
+}
+
+// stale determines if the value should be refreshed
+func (m entryMeta) stale() bool {
+	return time.Now().UnixNano() >= m.softExpiry
+}
+
+// early determines if the value should be recomputed before it
+// expires. The probability grows as the expiry approaches, and
+// values which are slow to compute are recomputed earlier. Beta
+// larger than 1 favors earlier recomputation
+func (m entryMeta) early(beta float64) bool {
+	gap := float64(m.delta) * beta * -math.Log(1-rand.Float64())
+	return float64(time.Now().UnixNano())+gap >= float64(m.softExpiry)
+}
+
+// metaStorage keeps values with their metadata
+type metaStorage interface {
+	// getMeta gets the value and its metadata,
+	// nil is returned if the value is missing
+	getMeta(ctx context.Context, key string) ([]byte, entryMeta, error)
+
+	// setMeta stores the value and its metadata for a given number of ttl
+	setMeta(ctx context.Context, key string, value []byte, meta entryMeta, ttl time.Duration) error
+}
+
+// rememberSWR returns stale values at once and refreshes them in
+// background. Only missing values are computed synchronously
+func rememberSWR(ctx context.Context, s metaStorage, g *singleflight.Group, key string, freshTTL, staleTTL time.Duration, valueFunc func() ([]byte, error)) ([]byte, error) {
+	b, meta, err := s.getMeta(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if b == nil {
+		return remember(ctx, g, key, metaValue(s, key), func(ctx context.Context) ([]byte, error) {
+			return computeMeta(ctx, s, key, freshTTL, freshTTL+staleTTL, valueFunc)
+		})
+	}
+
+	if meta.stale() {
+		// The channel is buffered, so the result can be dropped.
+		// Failed refreshes keep the stale value until it expires
+		g.DoChan(key, func() (interface{}, error) {
+			return computeMeta(context.Background(), s, key, freshTTL, freshTTL+staleTTL, valueFunc)
+		})
+	}
+
+	return b, nil
+}
+
+// rememberXFetch recomputes values probabilistically before they
+// expire. Errors of early recomputation are ignored since the
+// value is still valid, so is a done ctx while waiting for it
+func rememberXFetch(ctx context.Context, s metaStorage, g *singleflight.Group, key string, ttl time.Duration, beta float64, valueFunc func() ([]byte, error)) ([]byte, error) {
+	b, meta, err := s.getMeta(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	compute := func(ctx context.Context) ([]byte, error) {
+		return computeMeta(ctx, s, key, ttl, ttl, valueFunc)
+	}
+
+	if b == nil {
+		return remember(ctx, g, key, metaValue(s, key), compute)
+	}
+
+	if beta <= 0 {
+		beta = 1
+	}
+
+	if !meta.early(beta) {
+		return b, nil
+	}
+
+	flightCtx := detach(ctx)
+
+	ch := g.DoChan(key, func() (interface{}, error) {
+		return compute(flightCtx)
+	})
+
+	select {
+	case <-ctx.Done():
+		return b, nil
+	case r := <-ch:
+		if r.Err != nil {
+			return b, nil
+		}
+		return r.Val.([]byte), nil
+	}
+}
+
+func metaValue(s metaStorage, key string) func(context.Context) ([]byte, error) {
+	return func(ctx context.Context) ([]byte, error) {
+		b, _, err := s.getMeta(ctx, key)
+		return b, err
+	}
+}
+
+// computeMeta computes the value and stores it with the time it
+// took, the value gets stale after softTTL and expires after ttl
+func computeMeta(ctx context.Context, s metaStorage, key string, softTTL, ttl time.Duration, valueFunc func() ([]byte, error)) ([]byte, error) {
+	start := time.Now()
+
+	b, err := valueFunc()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	meta := entryMeta{
+		softExpiry: now.Add(softTTL).UnixNano(),
+		delta:      int64(now.Sub(start)),
+	}
+
+	if err = s.setMeta(ctx, key, b, meta, ttl); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
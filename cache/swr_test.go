@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Cache_EntryMeta(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	now := time.Now()
+
+	at.True(entryMeta{softExpiry: now.Add(-time.Second).UnixNano()}.stale())
+	at.False(entryMeta{softExpiry: now.Add(time.Minute).UnixNano()}.stale())
+
+	at.True(entryMeta{softExpiry: now.Add(-time.Second).UnixNano()}.early(1))
+	at.False(entryMeta{softExpiry: now.Add(time.Minute).UnixNano()}.early(1))
+	at.True(entryMeta{softExpiry: now.Add(time.Minute).UnixNano(), delta: int64(time.Hour)}.early(1e6))
+}
+
+func Test_Cache_RememberSWR(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s := getMemStorage()
+
+	var calls int32
+	valueFunc := func() ([]byte, error) {
+		return []byte{byte('0' + atomic.AddInt32(&calls, 1))}, nil
+	}
+
+	t.Run("miss", func(t *testing.T) {
+		b, err := s.RememberSWR("swr", time.Minute, time.Minute, valueFunc)
+		at.Nil(err)
+		at.Equal("1", string(b))
+
+		_, meta, _ := s.getMeta(cb, "swr")
+		at.False(meta.stale())
+	})
+
+	t.Run("fresh", func(t *testing.T) {
+		b, err := s.RememberSWR("swr", time.Minute, time.Minute, valueFunc)
+		at.Nil(err)
+		at.Equal("1", string(b))
+		at.Equal(int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		at.Nil(s.setMeta(cb, "swr", []byte("1"), entryMeta{}, time.Minute))
+
+		b, err := s.RememberSWR("swr", time.Minute, time.Minute, valueFunc)
+		at.Nil(err)
+		at.Equal("1", string(b))
+
+		at.Eventually(func() bool {
+			return string(s.value("swr")) == "2"
+		}, time.Second, time.Millisecond*5)
+	})
+
+	t.Run("refresh error", func(t *testing.T) {
+		at.Nil(s.setMeta(cb, "swr", []byte("2"), entryMeta{}, time.Minute))
+
+		done := make(chan struct{})
+		b, err := s.RememberSWR("swr", time.Minute, time.Minute, func() ([]byte, error) {
+			defer close(done)
+			return nil, mockErr
+		})
+		at.Nil(err)
+		at.Equal("2", string(b))
+
+		<-done
+		at.Equal("2", string(s.value("swr")))
+	})
+
+	t.Run("miss error", func(t *testing.T) {
+		_, err := s.RememberSWR("swr error", time.Minute, time.Minute, func() ([]byte, error) {
+			return nil, mockErr
+		})
+		at.Equal(mockErr, err)
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(cb)
+		cancel()
+
+		_, err := s.RememberSWRCtx(ctx, "swr", time.Minute, time.Minute, valueFunc)
+		at.Equal(context.Canceled, err)
+	})
+}
+
+func Test_Cache_RememberXFetch(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s := getMemStorage()
+
+	valueFunc := func() ([]byte, error) {
+		return []byte("new"), nil
+	}
+
+	t.Run("miss", func(t *testing.T) {
+		b, err := s.RememberXFetch("xfetch", time.Minute, 1, valueFunc)
+		at.Nil(err)
+		at.Equal("new", string(b))
+	})
+
+	t.Run("not early", func(t *testing.T) {
+		meta := entryMeta{softExpiry: time.Now().Add(time.Minute).UnixNano()}
+		at.Nil(s.setMeta(cb, "xfetch", []byte("old"), meta, time.Minute))
+
+		b, err := s.RememberXFetch("xfetch", time.Minute, 0, valueFunc)
+		at.Nil(err)
+		at.Equal("old", string(b))
+	})
+
+	t.Run("early", func(t *testing.T) {
+		meta := entryMeta{softExpiry: time.Now().Add(time.Minute).UnixNano(), delta: int64(time.Hour)}
+		at.Nil(s.setMeta(cb, "xfetch", []byte("old"), meta, time.Minute))
+
+		b, err := s.RememberXFetch("xfetch", time.Minute, 1e6, valueFunc)
+		at.Nil(err)
+		at.Equal("new", string(b))
+		at.Equal("new", string(s.value("xfetch")))
+	})
+
+	t.Run("early error", func(t *testing.T) {
+		meta := entryMeta{softExpiry: time.Now().Add(time.Minute).UnixNano(), delta: int64(time.Hour)}
+		at.Nil(s.setMeta(cb, "xfetch", []byte("old"), meta, time.Minute))
+
+		b, err := s.RememberXFetch("xfetch", time.Minute, 1e6, func() ([]byte, error) {
+			return nil, mockErr
+		})
+		at.Nil(err)
+		at.Equal("old", string(b))
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(cb)
+		cancel()
+
+		_, err := s.RememberXFetchCtx(ctx, "xfetch", time.Minute, 1, valueFunc)
+		at.Equal(context.Canceled, err)
+	})
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// TaggedCacher links entries to tags, so entries of any tag can
+// be flushed together. Keys are not namespaced by tags, entries
+// can be read without tags and a key links to all tags it has
+// been stored with until it's removed.
+type TaggedCacher struct {
+	s    Cacher
+	tags []string
+}
+
+// Get retrieves an entry from the cache for the given key.
+func (t TaggedCacher) Get(key string) ([]byte, error) {
+	return t.GetCtx(context.Background(), key)
+}
+
+// GetCtx retrieves an entry from the cache for the given key.
+func (t TaggedCacher) GetCtx(ctx context.Context, key string) ([]byte, error) {
+	return t.s.GetCtx(ctx, key)
+}
+
+// Set stores an entry linked to the tags in the cache for a given number of ttl.
+func (t TaggedCacher) Set(key string, value []byte, ttl time.Duration) error {
+	return t.SetCtx(context.Background(), key, value, ttl)
+}
+
+// SetCtx stores an entry linked to the tags in the cache for a given number of ttl.
+func (t TaggedCacher) SetCtx(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := t.s.SetCtx(ctx, key, value, ttl); err != nil {
+		return err
+	}
+
+	return t.s.tag(ctx, key, t.tags, ttl)
+}
+
+// Forever stores an entry linked to the tags in the cache indefinitely.
+func (t TaggedCacher) Forever(key string, value []byte) error {
+	return t.ForeverCtx(context.Background(), key, value)
+}
+
+// ForeverCtx stores an entry linked to the tags in the cache indefinitely.
+func (t TaggedCacher) ForeverCtx(ctx context.Context, key string, value []byte) error {
+	if err := t.s.ForeverCtx(ctx, key, value); err != nil {
+		return err
+	}
+
+	return t.s.tag(ctx, key, t.tags, 0)
+}
+
+// Remember gets an entry from the cache, or stores an entry linked
+// to the tags from the closure in the cache for a given number of ttl.
+func (t TaggedCacher) Remember(key string, ttl time.Duration, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return t.RememberCtx(context.Background(), key, ttl, valueFunc)
+}
+
+// RememberCtx gets an entry from the cache, or stores an entry linked
+// to the tags from the closure in the cache for a given number of ttl.
+func (t TaggedCacher) RememberCtx(ctx context.Context, key string, ttl time.Duration, valueFunc func() ([]byte, error)) ([]byte, error) {
+	b, err := t.s.RememberCtx(ctx, key, ttl, valueFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	return b, t.s.tag(ctx, key, t.tags, ttl)
+}
+
+// Flush removes entries linked to any of the tags.
+func (t TaggedCacher) Flush() error {
+	return t.FlushCtx(context.Background())
+}
+
+// FlushCtx removes entries linked to any of the tags.
+func (t TaggedCacher) FlushCtx(ctx context.Context) error {
+	_, err := t.s.flush(ctx, t.tags)
+	return err
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-dawn/dawn/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Cache_Tags(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s := newMemory(config.New())
+
+	user := s.Tags("user:42")
+	orders := s.Tags("user:42", "orders")
+
+	at.Nil(user.Set("profile", []byte("p"), time.Minute))
+	at.Nil(orders.Forever("orders", []byte("o")))
+	at.Nil(s.Tags("orders").Set("summary", []byte("s"), time.Minute))
+	at.Nil(s.Set("untagged", []byte("u"), time.Minute))
+
+	b, err := user.Get("profile")
+	at.Nil(err)
+	at.Equal("p", string(b))
+
+	b, err = user.Remember("settings", time.Minute, func() ([]byte, error) {
+		return []byte("st"), nil
+	})
+	at.Nil(err)
+	at.Equal("st", string(b))
+
+	at.Nil(s.Tags("user:42").Flush())
+
+	for _, key := range []string{"profile", "orders", "settings"} {
+		ok, err := s.Has(key)
+		at.Nil(err)
+		at.False(ok, key)
+	}
+
+	for _, key := range []string{"summary", "untagged"} {
+		ok, err := s.Has(key)
+		at.Nil(err)
+		at.True(ok, key)
+	}
+
+	t.Run("error", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		at.Equal(context.Canceled, user.SetCtx(ctx, "k", []byte("v"), time.Minute))
+		at.Equal(context.Canceled, user.ForeverCtx(ctx, "k", []byte("v")))
+		_, err := user.RememberCtx(ctx, "k", time.Minute, func() ([]byte, error) {
+			return []byte("v"), nil
+		})
+		at.Equal(context.Canceled, err)
+		at.Equal(context.Canceled, user.FlushCtx(ctx))
+	})
+}
@@ -0,0 +1,522 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-dawn/dawn/config"
+	dawnRedis "github.com/go-dawn/dawn/db/redis"
+	"github.com/go-redis/redis/v8"
+)
+
+// PubSub is a subset of go-redis Client
+type PubSub interface {
+	Publish(ctx context.Context, channel string, message interface{}) *redis.IntCmd
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+}
+
+// invalidation is the message telling other instances
+// to drop their l1 entries
+type invalidation struct {
+	// Source is the id of the instance which changed the keys
+	Source string   `json:"source"`
+	Keys   []string `json:"keys,omitempty"`
+	// All is set when the storage is reset
+	All bool `json:"all,omitempty"`
+}
+
+// tieredStorage puts l1 in front of l2. Reads fill l1 for l1TTL,
+// writes go to l2 and drop the entries in l1 of all instances. l1
+// should be a bounded memory storage used by the tiered storage only
+type tieredStorage struct {
+	l1      Cacher
+	l2      Cacher
+	l1TTL   time.Duration
+	db      PubSub
+	channel string
+	id      string
+	retry   time.Duration
+	done    chan struct{}
+	// gen is bumped before l1 entries are dropped, so values
+	// loaded from l2 before can't be filled back into l1
+	mu  sync.Mutex
+	gen uint64
+}
+
+func newTiered(name string, c *config.Config, storage map[string]Cacher) *tieredStorage {
+	s := &tieredStorage{
+		l1:      tier(name, c.GetString("l1"), storage),
+		l2:      tier(name, c.GetString("l2"), storage),
+		l1TTL:   c.GetDuration("l1TTL", time.Second*5),
+		channel: c.GetString("channel", "dawn_cache_invalidation"),
+		retry:   time.Second,
+		done:    make(chan struct{}),
+	}
+
+	s.id, _ = lockToken()
+
+	// l1 entries of other instances are only invalidated
+	// by l1TTL if there is no connection
+	if conn := c.GetString("connection"); conn != "" {
+		db, ok := dawnRedis.Conn(conn).(PubSub)
+		if !ok {
+			panic(fmt.Sprintf("dawn:cache connection %s of %s can't subscribe", conn, name))
+		}
+		s.db = db
+	}
+
+	return s
+}
+
+func tier(name, tier string, storage map[string]Cacher) Cacher {
+	s, ok := storage[tier]
+	if !ok {
+		panic(fmt.Sprintf("dawn:cache unknown storage %s of %s", tier, name))
+	}
+	return s
+}
+
+func (s *tieredStorage) Has(key string) (bool, error) {
+	return s.HasCtx(context.Background(), key)
+}
+
+func (s *tieredStorage) HasCtx(ctx context.Context, key string) (bool, error) {
+	if b, err := s.l1.GetCtx(ctx, key); err != nil || b != nil {
+		return b != nil, err
+	}
+
+	return s.l2.HasCtx(ctx, key)
+}
+
+func (s *tieredStorage) Get(key string) ([]byte, error) {
+	return s.GetCtx(context.Background(), key)
+}
+
+func (s *tieredStorage) GetCtx(ctx context.Context, key string) ([]byte, error) {
+	return s.read(ctx, key, func() ([]byte, error) {
+		return s.l2.GetCtx(ctx, key)
+	})
+}
+
+func (s *tieredStorage) GetWithDefault(key string, defaultValue []byte) ([]byte, error) {
+	return s.GetWithDefaultCtx(context.Background(), key, defaultValue)
+}
+
+func (s *tieredStorage) GetWithDefaultCtx(ctx context.Context, key string, defaultValue []byte) (b []byte, err error) {
+	if b, err = s.GetCtx(ctx, key); err == nil && b == nil {
+		b = defaultValue
+	}
+
+	return
+}
+
+func (s *tieredStorage) Many(keys []string) ([][]byte, error) {
+	return s.ManyCtx(context.Background(), keys)
+}
+
+func (s *tieredStorage) ManyCtx(ctx context.Context, keys []string) (values [][]byte, err error) {
+	if values, err = s.l1.ManyCtx(ctx, keys); err != nil {
+		return
+	}
+
+	var (
+		missed  []string
+		indexes []int
+	)
+
+	for i, v := range values {
+		if v == nil {
+			missed = append(missed, keys[i])
+			indexes = append(indexes, i)
+		}
+	}
+
+	if len(missed) == 0 {
+		return
+	}
+
+	gen := s.generation()
+
+	// Drivers may modify keys
+	var filled [][]byte
+	if filled, err = s.l2.ManyCtx(ctx, append([]string(nil), missed...)); err != nil {
+		return
+	}
+
+	for i, v := range filled {
+		if v != nil {
+			values[indexes[i]] = v
+			if err = s.fill(ctx, gen, missed[i], v); err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+func (s *tieredStorage) Set(key string, value []byte, ttl time.Duration) error {
+	return s.SetCtx(context.Background(), key, value, ttl)
+}
+
+func (s *tieredStorage) SetCtx(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.write(ctx, []string{key}, func() error {
+		return s.l2.SetCtx(ctx, key, value, ttl)
+	})
+}
+
+func (s *tieredStorage) Pull(key string) ([]byte, error) {
+	return s.PullCtx(context.Background(), key)
+}
+
+func (s *tieredStorage) PullCtx(ctx context.Context, key string) (b []byte, err error) {
+	err = s.write(ctx, []string{key}, func() (err error) {
+		b, err = s.l2.PullCtx(ctx, key)
+		return
+	})
+
+	return
+}
+
+func (s *tieredStorage) PullWithDefault(key string, defaultValue []byte) ([]byte, error) {
+	return s.PullWithDefaultCtx(context.Background(), key, defaultValue)
+}
+
+func (s *tieredStorage) PullWithDefaultCtx(ctx context.Context, key string, defaultValue []byte) (b []byte, err error) {
+	err = s.write(ctx, []string{key}, func() (err error) {
+		b, err = s.l2.PullWithDefaultCtx(ctx, key, defaultValue)
+		return
+	})
+
+	return
+}
+
+func (s *tieredStorage) Forever(key string, value []byte) error {
+	return s.ForeverCtx(context.Background(), key, value)
+}
+
+func (s *tieredStorage) ForeverCtx(ctx context.Context, key string, value []byte) error {
+	return s.write(ctx, []string{key}, func() error {
+		return s.l2.ForeverCtx(ctx, key, value)
+	})
+}
+
+func (s *tieredStorage) Remember(key string, ttl time.Duration, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return s.RememberCtx(context.Background(), key, ttl, valueFunc)
+}
+
+func (s *tieredStorage) RememberCtx(ctx context.Context, key string, ttl time.Duration, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return s.read(ctx, key, func() ([]byte, error) {
+		return s.l2.RememberCtx(ctx, key, ttl, s.computed(ctx, key, valueFunc))
+	})
+}
+
+func (s *tieredStorage) RememberForever(key string, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return s.RememberForeverCtx(context.Background(), key, valueFunc)
+}
+
+func (s *tieredStorage) RememberForeverCtx(ctx context.Context, key string, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return s.read(ctx, key, func() ([]byte, error) {
+		return s.l2.RememberForeverCtx(ctx, key, s.computed(ctx, key, valueFunc))
+	})
+}
+
+func (s *tieredStorage) RememberSWR(key string, freshTTL, staleTTL time.Duration, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return s.RememberSWRCtx(context.Background(), key, freshTTL, staleTTL, valueFunc)
+}
+
+// RememberSWRCtx only checks staleness when l1 misses, so
+// values may be stale for l1TTL more
+func (s *tieredStorage) RememberSWRCtx(ctx context.Context, key string, freshTTL, staleTTL time.Duration, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return s.read(ctx, key, func() ([]byte, error) {
+		return s.l2.RememberSWRCtx(ctx, key, freshTTL, staleTTL, s.computed(ctx, key, valueFunc))
+	})
+}
+
+func (s *tieredStorage) RememberXFetch(key string, ttl time.Duration, beta float64, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return s.RememberXFetchCtx(context.Background(), key, ttl, beta, valueFunc)
+}
+
+// RememberXFetchCtx only recomputes early when l1 misses
+func (s *tieredStorage) RememberXFetchCtx(ctx context.Context, key string, ttl time.Duration, beta float64, valueFunc func() ([]byte, error)) ([]byte, error) {
+	return s.read(ctx, key, func() ([]byte, error) {
+		return s.l2.RememberXFetchCtx(ctx, key, ttl, beta, s.computed(ctx, key, valueFunc))
+	})
+}
+
+func (s *tieredStorage) Delete(key string) error {
+	return s.DeleteCtx(context.Background(), key)
+}
+
+func (s *tieredStorage) DeleteCtx(ctx context.Context, key string) error {
+	return s.write(ctx, []string{key}, func() error {
+		return s.l2.DeleteCtx(ctx, key)
+	})
+}
+
+func (s *tieredStorage) Increment(key string, delta int64, ttl time.Duration) (int64, error) {
+	return s.IncrementCtx(context.Background(), key, delta, ttl)
+}
+
+// IncrementCtx changes the counter in l2 only, since
+// counters in l1 can't be kept in sync
+func (s *tieredStorage) IncrementCtx(ctx context.Context, key string, delta int64, ttl time.Duration) (n int64, err error) {
+	err = s.write(ctx, []string{key}, func() (err error) {
+		n, err = s.l2.IncrementCtx(ctx, key, delta, ttl)
+		return
+	})
+
+	return
+}
+
+func (s *tieredStorage) Decrement(key string, delta int64, ttl time.Duration) (int64, error) {
+	return s.DecrementCtx(context.Background(), key, delta, ttl)
+}
+
+func (s *tieredStorage) DecrementCtx(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return s.IncrementCtx(ctx, key, -delta, ttl)
+}
+
+func (s *tieredStorage) Add(key string, value []byte, ttl time.Duration) (bool, error) {
+	return s.AddCtx(context.Background(), key, value, ttl)
+}
+
+func (s *tieredStorage) AddCtx(ctx context.Context, key string, value []byte, ttl time.Duration) (added bool, err error) {
+	err = s.write(ctx, []string{key}, func() (err error) {
+		added, err = s.l2.AddCtx(ctx, key, value, ttl)
+		return
+	})
+
+	return
+}
+
+func (s *tieredStorage) CompareAndSwap(key string, old, new []byte, ttl time.Duration) (bool, error) {
+	return s.CompareAndSwapCtx(context.Background(), key, old, new, ttl)
+}
+
+// CompareAndSwapCtx compares with the entry in l2,
+// since entries in l1 may be stale
+func (s *tieredStorage) CompareAndSwapCtx(ctx context.Context, key string, old, new []byte, ttl time.Duration) (swapped bool, err error) {
+	err = s.write(ctx, []string{key}, func() (err error) {
+		swapped, err = s.l2.CompareAndSwapCtx(ctx, key, old, new, ttl)
+		return
+	})
+
+	return
+}
+
+func (s *tieredStorage) Reset() error {
+	return s.ResetCtx(context.Background())
+}
+
+func (s *tieredStorage) ResetCtx(ctx context.Context) (err error) {
+	if err = s.l2.ResetCtx(ctx); err != nil {
+		return
+	}
+
+	s.bump()
+	if err = s.l1.ResetCtx(ctx); err != nil {
+		return
+	}
+
+	return s.publish(ctx, invalidation{All: true})
+}
+
+func (s *tieredStorage) Tags(tags ...string) TaggedCacher {
+	return TaggedCacher{s: s, tags: tags}
+}
+
+// Close stops the invalidation subscriber, l1 and l2
+// are closed by the module
+func (s *tieredStorage) Close() error {
+	close(s.done)
+	return nil
+}
+
+// gc subscribes invalidations of other instances until the
+// storage is closed, and resubscribes after retry if it fails
+func (s *tieredStorage) gc() {
+	if s.db == nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		<-s.done
+		cancel()
+	}()
+
+	for {
+		s.subscribe(ctx)
+
+		select {
+		case <-s.done:
+			return
+		case <-time.After(s.retry):
+		}
+	}
+}
+
+func (s *tieredStorage) subscribe(ctx context.Context) {
+	ps := s.db.Subscribe(ctx, s.channel)
+	defer func() {
+		_ = ps.Close()
+	}()
+
+	ch := ps.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			s.invalidate(ctx, msg.Payload)
+		}
+	}
+}
+
+// invalidate drops l1 entries changed by other instances,
+// invalid messages are ignored
+func (s *tieredStorage) invalidate(ctx context.Context, payload string) {
+	var i invalidation
+	if err := json.Unmarshal([]byte(payload), &i); err != nil || i.Source == s.id {
+		return
+	}
+
+	s.bump()
+
+	if i.All {
+		_ = s.l1.ResetCtx(ctx)
+		return
+	}
+
+	for _, key := range i.Keys {
+		_ = s.l1.DeleteCtx(ctx, key)
+	}
+}
+
+func (s *tieredStorage) tag(ctx context.Context, key string, tags []string, ttl time.Duration) error {
+	return s.l2.tag(ctx, key, tags, ttl)
+}
+
+func (s *tieredStorage) flush(ctx context.Context, tags []string) (keys []string, err error) {
+	if keys, err = s.l2.flush(ctx, tags); err != nil || len(keys) == 0 {
+		return
+	}
+
+	s.bump()
+	for _, key := range keys {
+		if err = s.l1.DeleteCtx(ctx, key); err != nil {
+			return
+		}
+	}
+
+	return keys, s.publish(ctx, invalidation{Keys: keys})
+}
+
+// acquire holds the lock in l2 only, which is shared by instances
+func (s *tieredStorage) acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	return s.l2.acquire(ctx, key, token, ttl)
+}
+
+func (s *tieredStorage) release(ctx context.Context, key, token string) (bool, error) {
+	return s.l2.release(ctx, key, token)
+}
+
+func (s *tieredStorage) extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	return s.l2.extend(ctx, key, token, ttl)
+}
+
+// read gets the entry from l1, or loads it from l2 and fills l1
+func (s *tieredStorage) read(ctx context.Context, key string, load func() ([]byte, error)) (b []byte, err error) {
+	if b, err = s.l1.GetCtx(ctx, key); err != nil || b != nil {
+		return
+	}
+
+	gen := s.generation()
+
+	if b, err = load(); err != nil || b == nil {
+		return
+	}
+
+	err = s.fill(ctx, gen, key, b)
+
+	return
+}
+
+// fill puts the value loaded from l2 into l1, unless l1 entries
+// are dropped since gen. The value is still returned to the
+// caller, it's just not kept
+func (s *tieredStorage) fill(ctx context.Context, gen uint64, key string, b []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.gen != gen {
+		return nil
+	}
+
+	return s.l1.SetCtx(ctx, key, b, s.l1TTL)
+}
+
+func (s *tieredStorage) generation() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.gen
+}
+
+// bump must be called before l1 entries are dropped
+func (s *tieredStorage) bump() {
+	s.mu.Lock()
+	s.gen++
+	s.mu.Unlock()
+}
+
+// write changes l2, then drops the keys in l1 of all instances
+func (s *tieredStorage) write(ctx context.Context, keys []string, change func() error) (err error) {
+	if err = change(); err != nil {
+		return
+	}
+
+	s.bump()
+	for _, key := range keys {
+		if err = s.l1.DeleteCtx(ctx, key); err != nil {
+			return
+		}
+	}
+
+	return s.publish(ctx, invalidation{Keys: keys})
+}
+
+// computed wraps valueFunc of Remember, so the new value
+// invalidates stale l1 entries of other instances
+func (s *tieredStorage) computed(ctx context.Context, key string, valueFunc func() ([]byte, error)) func() ([]byte, error) {
+	return func() (b []byte, err error) {
+		if b, err = valueFunc(); err == nil {
+			err = s.publish(ctx, invalidation{Keys: []string{key}})
+		}
+		return
+	}
+}
+
+func (s *tieredStorage) publish(ctx context.Context, i invalidation) error {
+	if s.db == nil {
+		return nil
+	}
+
+	i.Source = s.id
+
+	b, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Publish(ctx, s.channel, b).Err()
+}
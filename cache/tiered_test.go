@@ -0,0 +1,510 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-dawn/dawn/config"
+	"github.com/go-dawn/module/cache/mocks"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func Test_Cache_Tiered_New(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	storage := map[string]Cacher{
+		"local": newMemory(config.New()),
+		"redis": newMemory(config.New()),
+	}
+
+	c := config.New()
+	c.Set("l1", "local")
+	c.Set("l2", "redis")
+
+	s := newTiered("near", c, storage)
+	at.Equal(storage["local"], s.l1)
+	at.Equal(storage["redis"], s.l2)
+	at.Equal(time.Second*5, s.l1TTL)
+	at.Equal("dawn_cache_invalidation", s.channel)
+	at.NotEmpty(s.id)
+	at.Nil(s.db)
+
+	// No subscriber without connection
+	s.gc()
+	at.Nil(s.Close())
+
+	c.Set("l2", "invalid")
+	at.Panics(func() {
+		newTiered("near", c, storage)
+	})
+}
+
+func Test_Cache_Tiered_Read(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s, _ := getTieredStorage()
+	at.Nil(s.l2.Set("k1", []byte("v1"), time.Minute))
+
+	t.Run("get", func(t *testing.T) {
+		b, err := s.Get("k1")
+		at.Nil(err)
+		at.Equal("v1", string(b))
+
+		// filled l1
+		b, err = s.l1.Get("k1")
+		at.Nil(err)
+		at.Equal("v1", string(b))
+
+		// l1 hit
+		at.Nil(s.l2.Delete("k1"))
+		b, err = s.GetWithDefault("k1", []byte("default"))
+		at.Nil(err)
+		at.Equal("v1", string(b))
+
+		b, err = s.GetWithDefault("k2", []byte("default"))
+		at.Nil(err)
+		at.Equal("default", string(b))
+	})
+
+	t.Run("has", func(t *testing.T) {
+		at.Nil(s.l2.Set("k3", []byte("v3"), time.Minute))
+
+		for key, expected := range map[string]bool{"k1": true, "k2": false, "k3": true} {
+			ok, err := s.Has(key)
+			at.Nil(err)
+			at.Equal(expected, ok, key)
+		}
+	})
+
+	t.Run("many", func(t *testing.T) {
+		at.Nil(s.l2.Set("k4", []byte("v4"), time.Minute))
+
+		values, err := s.Many([]string{"k1", "k2", "k4"})
+		at.Nil(err)
+		at.Equal([][]byte{[]byte("v1"), nil, []byte("v4")}, values)
+
+		b, err := s.l1.Get("k4")
+		at.Nil(err)
+		at.Equal("v4", string(b))
+
+		values, err = s.Many([]string{"k1"})
+		at.Nil(err)
+		at.Equal([][]byte{[]byte("v1")}, values)
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(cb)
+		cancel()
+
+		_, err := s.GetCtx(ctx, "k1")
+		at.Equal(context.Canceled, err)
+
+		_, err = s.ManyCtx(ctx, []string{"k1"})
+		at.Equal(context.Canceled, err)
+	})
+}
+
+func Test_Cache_Tiered_Write(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s, mockDB := getTieredStorage()
+
+	published := func(keys ...string) interface{} {
+		return mock.MatchedBy(func(b []byte) bool {
+			var i invalidation
+			return json.Unmarshal(b, &i) == nil && i.Source == s.id &&
+				assert.ObjectsAreEqual(keys, i.Keys)
+		})
+	}
+
+	mockDB.On("Publish", cb, s.channel, published("k1")).
+		Return(redis.NewIntResult(1, nil))
+
+	fill := func() {
+		at.Nil(s.l1.Set("k1", []byte("stale"), time.Minute))
+	}
+
+	assertDropped := func() {
+		b, err := s.l1.Get("k1")
+		at.Nil(err)
+		at.Nil(b)
+	}
+
+	t.Run("set", func(t *testing.T) {
+		fill()
+		at.Nil(s.Set("k1", []byte("v1"), time.Minute))
+		assertDropped()
+
+		b, err := s.Get("k1")
+		at.Nil(err)
+		at.Equal("v1", string(b))
+	})
+
+	t.Run("forever", func(t *testing.T) {
+		fill()
+		at.Nil(s.Forever("k1", []byte("v1")))
+		assertDropped()
+	})
+
+	t.Run("pull", func(t *testing.T) {
+		fill()
+		b, err := s.Pull("k1")
+		at.Nil(err)
+		at.Equal("v1", string(b))
+		assertDropped()
+
+		b, err = s.PullWithDefault("k1", []byte("default"))
+		at.Nil(err)
+		at.Equal("default", string(b))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		fill()
+		at.Nil(s.Delete("k1"))
+		assertDropped()
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		mockDB.On("Publish", cb, s.channel, mock.MatchedBy(func(b []byte) bool {
+			var i invalidation
+			return json.Unmarshal(b, &i) == nil && i.All
+		})).Once().Return(redis.NewIntResult(1, nil))
+
+		fill()
+		at.Nil(s.l2.Set("k2", []byte("v2"), time.Minute))
+		at.Nil(s.Reset())
+		assertDropped()
+
+		ok, err := s.Has("k2")
+		at.Nil(err)
+		at.False(ok)
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(cb)
+		cancel()
+
+		at.Equal(context.Canceled, s.SetCtx(ctx, "k1", []byte("v1"), time.Minute))
+		at.Equal(context.Canceled, s.ResetCtx(ctx))
+	})
+
+	t.Run("publish error", func(t *testing.T) {
+		s, mockDB := getTieredStorage()
+
+		mockDB.On("Publish", cb, s.channel, mock.Anything).
+			Once().Return(redis.NewIntResult(0, mockErr))
+
+		at.Equal(mockErr, s.Delete("k1"))
+	})
+}
+
+func Test_Cache_Tiered_Remember(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s, mockDB := getTieredStorage()
+
+	mockDB.On("Publish", cb, s.channel, mock.Anything).
+		Return(redis.NewIntResult(1, nil))
+
+	valueFunc := func() ([]byte, error) {
+		return []byte("v"), nil
+	}
+
+	remembers := map[string]func(key string) ([]byte, error){
+		"remember": func(key string) ([]byte, error) {
+			return s.Remember(key, time.Minute, valueFunc)
+		},
+		"remember forever": func(key string) ([]byte, error) {
+			return s.RememberForever(key, valueFunc)
+		},
+		"remember swr": func(key string) ([]byte, error) {
+			return s.RememberSWR(key, time.Minute, time.Minute, valueFunc)
+		},
+		"remember xfetch": func(key string) ([]byte, error) {
+			return s.RememberXFetch(key, time.Minute, 1, valueFunc)
+		},
+	}
+
+	for name, remember := range remembers {
+		b, err := remember(name)
+		at.Nil(err, name)
+		at.Equal("v", string(b), name)
+
+		b, err = s.l1.Get(name)
+		at.Nil(err, name)
+		at.Equal("v", string(b), name)
+	}
+
+	// Every computed value is published
+	mockDB.AssertNumberOfCalls(t, "Publish", len(remembers))
+
+	t.Run("error", func(t *testing.T) {
+		_, err := s.Remember("error", time.Minute, func() ([]byte, error) {
+			return nil, mockErr
+		})
+		at.Equal(mockErr, err)
+
+		ok, err := s.l1.Has("error")
+		at.Nil(err)
+		at.False(ok)
+	})
+}
+
+func Test_Cache_Tiered_Invalidate(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s, _ := getTieredStorage()
+
+	payload := func(i invalidation) string {
+		b, err := json.Marshal(i)
+		at.Nil(err)
+		return string(b)
+	}
+
+	at.Nil(s.l1.Set("k1", []byte("v1"), time.Minute))
+	at.Nil(s.l1.Set("k2", []byte("v2"), time.Minute))
+
+	s.invalidate(cb, "invalid")
+	s.invalidate(cb, payload(invalidation{Source: s.id, Keys: []string{"k1"}}))
+
+	ok, err := s.l1.Has("k1")
+	at.Nil(err)
+	at.True(ok)
+
+	s.invalidate(cb, payload(invalidation{Source: "other", Keys: []string{"k1"}}))
+
+	ok, err = s.l1.Has("k1")
+	at.Nil(err)
+	at.False(ok)
+
+	s.invalidate(cb, payload(invalidation{Source: "other", All: true}))
+
+	ok, err = s.l1.Has("k2")
+	at.Nil(err)
+	at.False(ok)
+}
+
+func Test_Cache_Tiered_Subscribe(t *testing.T) {
+	t.Parallel()
+
+	s, _ := getTieredStorage()
+
+	// Subscribing keeps retrying since the server is unreachable
+	s.db = redis.NewClient(&redis.Options{Addr: "127.0.0.1:0", MaxRetries: -1})
+	s.retry = time.Millisecond
+
+	done := make(chan struct{})
+	go func() {
+		s.gc()
+		close(done)
+	}()
+
+	time.Sleep(time.Millisecond * 10)
+	assert.Nil(t, s.Close())
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("subscriber is not stopped")
+	}
+}
+
+func Test_Cache_Tiered_Read_Invalidated(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s, _ := getTieredStorage()
+	at.Nil(s.l2.Set("k1", []byte("v1"), time.Minute))
+	at.Nil(s.l2.Set("k2", []byte("v2"), time.Minute))
+
+	// Another instance changes the keys after they're loaded from l2
+	s.l2 = loadHook{Cacher: s.l2, loaded: func() {
+		s.invalidate(cb, `{"source":"other","keys":["k1","k2"]}`)
+	}}
+
+	b, err := s.Get("k1")
+	at.Nil(err)
+	at.Equal("v1", string(b))
+
+	values, err := s.Many([]string{"k2"})
+	at.Nil(err)
+	at.Equal([][]byte{[]byte("v2")}, values)
+
+	for _, key := range []string{"k1", "k2"} {
+		b, err = s.l1.Get(key)
+		at.Nil(err)
+		at.Nil(b, key)
+	}
+}
+
+// loadHook calls loaded after entries are loaded
+type loadHook struct {
+	Cacher
+	loaded func()
+}
+
+func (h loadHook) GetCtx(ctx context.Context, key string) ([]byte, error) {
+	defer h.loaded()
+	return h.Cacher.GetCtx(ctx, key)
+}
+
+func (h loadHook) ManyCtx(ctx context.Context, keys []string) ([][]byte, error) {
+	defer h.loaded()
+	return h.Cacher.ManyCtx(ctx, keys)
+}
+
+func getTieredStorage() (*tieredStorage, *mocks.PubSub) {
+	mockDB := new(mocks.PubSub)
+
+	return &tieredStorage{
+		l1:      newMemory(config.New()),
+		l2:      newMemory(config.New()),
+		l1TTL:   time.Minute,
+		db:      mockDB,
+		channel: "invalidation",
+		id:      "test",
+		retry:   time.Second,
+		done:    make(chan struct{}),
+	}, mockDB
+}
+
+func Test_Cache_Tiered_Tags(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s, mockDB := getTieredStorage()
+
+	mockDB.On("Publish", cb, s.channel, mock.Anything).
+		Return(redis.NewIntResult(1, nil))
+
+	at.Nil(s.Tags("t1").Set("k1", []byte("v1"), time.Minute))
+	at.Nil(s.Tags("t2").Set("k2", []byte("v2"), time.Minute))
+
+	// Fill l1
+	_, err := s.Many([]string{"k1", "k2"})
+	at.Nil(err)
+
+	at.Nil(s.Tags("t1").Flush())
+
+	for _, c := range []Cacher{s.l1, s.l2} {
+		ok, err := c.Has("k1")
+		at.Nil(err)
+		at.False(ok)
+
+		ok, err = c.Has("k2")
+		at.Nil(err)
+		at.True(ok)
+	}
+
+	b, err := json.Marshal(invalidation{Source: s.id, Keys: []string{"k1"}})
+	at.Nil(err)
+	mockDB.AssertCalled(t, "Publish", cb, s.channel, b)
+
+	// Nothing is published without flushed keys
+	calls := len(mockDB.Calls)
+	at.Nil(s.Tags("t1").Flush())
+	at.Len(mockDB.Calls, calls)
+}
+
+func Test_Cache_Tiered_Increment(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s, mockDB := getTieredStorage()
+
+	mockDB.On("Publish", cb, s.channel, mock.Anything).
+		Return(redis.NewIntResult(1, nil))
+
+	at.Nil(s.l1.Set("counter", []byte("10"), time.Minute))
+
+	n, err := s.Increment("counter", 3, time.Minute)
+	at.Nil(err)
+	at.Equal(int64(3), n)
+
+	n, err = s.Decrement("counter", 1, time.Minute)
+	at.Nil(err)
+	at.Equal(int64(2), n)
+
+	ok, err := s.l1.Has("counter")
+	at.Nil(err)
+	at.False(ok)
+
+	mockDB.AssertNumberOfCalls(t, "Publish", 2)
+}
+
+func Test_Cache_Tiered_CompareAndSwap(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s, mockDB := getTieredStorage()
+
+	mockDB.On("Publish", cb, s.channel, mock.Anything).
+		Return(redis.NewIntResult(1, nil))
+
+	ok, err := s.Add("k1", []byte("v1"), time.Minute)
+	at.Nil(err)
+	at.True(ok)
+
+	ok, err = s.Add("k1", []byte("v11"), time.Minute)
+	at.Nil(err)
+	at.False(ok)
+
+	// Stale l1 entries are ignored and removed
+	at.Nil(s.l1.Set("k1", []byte("stale"), time.Minute))
+
+	ok, err = s.CompareAndSwap("k1", []byte("stale"), []byte("v11"), time.Minute)
+	at.Nil(err)
+	at.False(ok)
+
+	ok, err = s.CompareAndSwap("k1", []byte("v1"), []byte("v11"), time.Minute)
+	at.Nil(err)
+	at.True(ok)
+
+	b, err := s.Get("k1")
+	at.Nil(err)
+	at.Equal("v11", string(b))
+}
+
+func Test_Cache_Tiered_Lock(t *testing.T) {
+	t.Parallel()
+
+	at := assert.New(t)
+
+	s, _ := getTieredStorage()
+
+	l := newLocker("job", time.Minute, s)
+
+	ok, err := l.TryAcquire()
+	at.Nil(err)
+	at.True(ok)
+
+	// Locks are held in l2
+	ok, err = s.l2.acquire(cb, "lock:job", "token", time.Minute)
+	at.Nil(err)
+	at.False(ok)
+
+	at.Nil(l.Extend())
+	at.Nil(l.Release())
+
+	ok, err = s.l2.acquire(cb, "lock:job", "token", time.Minute)
+	at.Nil(err)
+	at.True(ok)
+}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound occurs when typed helpers can't find the entry
+var ErrNotFound = errors.New("dawn:cache entry not found")
+
+// TypedCacher stores values encoded by the codec of the storage
+// instead of raw bytes. Helpers are named after json as the most
+// common codec, the codec is configured by codec in storage config
+type TypedCacher struct {
+	Cacher
+	Codec Codec
+}
+
+// Typed gets typed cache of storage by specific name or fallback.
+func Typed(name ...string) TypedCacher {
+	n := m.fallback
+
+	if len(name) > 0 && name[0] != "" {
+		n = name[0]
+	}
+
+	codec := m.codecs[n]
+	if codec == nil {
+		codec = jsonCodec{}
+	}
+
+	return TypedCacher{Cacher: m.storage[n], Codec: codec}
+}
+
+// GetJSON retrieves an entry from the cache and decodes it into v.
+// Returns ErrNotFound if value is not found.
+func (t TypedCacher) GetJSON(key string, v interface{}) error {
+	return t.GetJSONCtx(context.Background(), key, v)
+}
+
+// GetJSONCtx retrieves an entry from the cache and decodes it into v.
+// Returns ErrNotFound if value is not found.
+func (t TypedCacher) GetJSONCtx(ctx context.Context, key string, v interface{}) error {
+	b, err := t.GetCtx(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	return t.decode(b, v)
+}
+
+// SetJSON encodes v and stores it in the cache for a given number of ttl.
+func (t TypedCacher) SetJSON(key string, v interface{}, ttl time.Duration) error {
+	return t.SetJSONCtx(context.Background(), key, v, ttl)
+}
+
+// SetJSONCtx encodes v and stores it in the cache for a given number of ttl.
+func (t TypedCacher) SetJSONCtx(ctx context.Context, key string, v interface{}, ttl time.Duration) error {
+	b, err := t.Codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return t.SetCtx(ctx, key, b, ttl)
+}
+
+// RememberJSON decodes an entry from the cache into v, or stores the
+// value from the closure in the cache for a given number of ttl.
+func (t TypedCacher) RememberJSON(key string, ttl time.Duration, v interface{}, valueFunc func() (interface{}, error)) error {
+	return t.RememberJSONCtx(context.Background(), key, ttl, v, valueFunc)
+}
+
+// RememberJSONCtx decodes an entry from the cache into v, or stores the
+// value from the closure in the cache for a given number of ttl.
+func (t TypedCacher) RememberJSONCtx(ctx context.Context, key string, ttl time.Duration, v interface{}, valueFunc func() (interface{}, error)) error {
+	b, err := t.RememberCtx(ctx, key, ttl, func() ([]byte, error) {
+		value, err := valueFunc()
+		if err != nil {
+			return nil, err
+		}
+		return t.Codec.Marshal(value)
+	})
+	if err != nil {
+		return err
+	}
+
+	return t.decode(b, v)
+}
+
+// decode treats empty entries as missing ones since
+// encoded values are never empty
+func (t TypedCacher) decode(b []byte, v interface{}) error {
+	if len(b) == 0 {
+		return ErrNotFound
+	}
+
+	return t.Codec.Unmarshal(b, v)
+}
@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-dawn/dawn/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Cache_Typed(t *testing.T) {
+	at := assert.New(t)
+
+	m = &Module{
+		fallback: fallback,
+		storage: map[string]Cacher{
+			fallback: newMemory(config.New()),
+			"gob":    newMemory(config.New()),
+		},
+		codecs: map[string]Codec{
+			"gob": gobCodec{},
+		},
+	}
+
+	at.Equal(jsonCodec{}, Typed().Codec)
+	at.Equal(gobCodec{}, Typed("gob").Codec)
+
+	s := Typed()
+
+	t.Run("not found", func(t *testing.T) {
+		var v codecEntry
+		at.Equal(ErrNotFound, s.GetJSON("typed", &v))
+	})
+
+	t.Run("set and get", func(t *testing.T) {
+		at.Nil(s.SetJSON("typed", codecEntry{Name: "dawn"}, time.Minute))
+
+		b, err := s.Get("typed")
+		at.Nil(err)
+		at.Equal(`{"Name":"dawn","Tags":null}`, string(b))
+
+		var v codecEntry
+		at.Nil(s.GetJSON("typed", &v))
+		at.Equal("dawn", v.Name)
+	})
+
+	t.Run("set error", func(t *testing.T) {
+		at.NotNil(s.SetJSON("typed", make(chan int), time.Minute))
+	})
+
+	t.Run("remember", func(t *testing.T) {
+		calls := 0
+		valueFunc := func() (interface{}, error) {
+			calls++
+			return codecEntry{Name: "remember"}, nil
+		}
+
+		for i := 0; i < 2; i++ {
+			var v codecEntry
+			at.Nil(s.RememberJSON("remember", time.Minute, &v, valueFunc))
+			at.Equal("remember", v.Name)
+		}
+		at.Equal(1, calls)
+	})
+
+	t.Run("remember error", func(t *testing.T) {
+		var v codecEntry
+
+		err := s.RememberJSON("remember error", time.Minute, &v, func() (interface{}, error) {
+			return nil, errors.New("fake error")
+		})
+		at.NotNil(err)
+
+		err = s.RememberJSON("remember error", time.Minute, &v, func() (interface{}, error) {
+			return make(chan int), nil
+		})
+		at.NotNil(err)
+
+		has, err := s.Has("remember error")
+		at.Nil(err)
+		at.False(has)
+	})
+}
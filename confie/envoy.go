@@ -53,7 +53,25 @@ func (e *Envoy) Verify(key, code string) error {
 		return ErrNotMatched
 	}
 
+	// Pull the code if possible, so it can be used only once
+	// even when it's verified concurrently
+	if p, ok := e.m.Storage.(puller); ok {
+		if b, err = p.Pull(key); err != nil {
+			return err
+		}
+		if code != string(b) {
+			return ErrNotMatched
+		}
+		return nil
+	}
+
 	_ = e.m.Delete(key)
 
 	return nil
 }
+
+// puller is implemented by storages which get and delete
+// a key atomically, e.g. cache.Cacher
+type puller interface {
+	Pull(key string) ([]byte, error)
+}
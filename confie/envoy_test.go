@@ -84,6 +84,41 @@ func Test_Confie_Envoy_Verify(t *testing.T) {
 		err := e.Verify("key", "123456")
 		at.Nil(err)
 	})
+
+	t.Run("pulled by others", func(t *testing.T) {
+		e, _, storage := mockEnvoy()
+		e.m.Storage = pullStorage{storage}
+
+		storage.On("Get", "key").
+			Once().Return([]byte("123456"), nil).
+			On("Pull", "key").
+			Once().Return(nil, nil)
+
+		at.Equal(ErrNotMatched, e.Verify("key", "123456"))
+	})
+
+	t.Run("pulled", func(t *testing.T) {
+		e, _, storage := mockEnvoy()
+		e.m.Storage = pullStorage{storage}
+
+		storage.On("Get", "key").
+			Once().Return([]byte("123456"), nil).
+			On("Pull", "key").
+			Once().Return([]byte("123456"), nil)
+
+		at.Nil(e.Verify("key", "123456"))
+	})
+}
+
+// pullStorage is a storage which supports Pull
+type pullStorage struct {
+	*mocks.Storage
+}
+
+func (s pullStorage) Pull(key string) ([]byte, error) {
+	args := s.Called(key)
+	b, _ := args.Get(0).([]byte)
+	return b, args.Error(1)
 }
 
 func mockEnvoy() (*Envoy, *bytes.Buffer, *mocks.Storage) {